@@ -0,0 +1,257 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// openTestMySQL migrates the database of PAGER_TEST_MYSQL_DSN and makes it the pager connection. The returned func
+// drops the rbac tables, so the DSN must point at a disposable database, e.g.
+//
+//	PAGER_TEST_MYSQL_DSN='root:secret@tcp(127.0.0.1:3306)/pager_test' go test -bench RouteAccess
+func openTestMySQL(tb testing.TB) func() {
+	dsn := os.Getenv("PAGER_TEST_MYSQL_DSN")
+	if dsn == "" {
+		tb.Skip("PAGER_TEST_MYSQL_DSN isn't set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	previousDB, previousWrappers := sqlConnection, dbWrappers
+	setDatabaseConnection(db)
+	restore := func() {
+		db.Close()
+		setDatabaseConnection(previousDB, previousWrappers...)
+	}
+
+	var schema string
+	if err = db.QueryRow("SELECT DATABASE()").Scan(&schema); err != nil {
+		restore()
+		tb.Fatal(err)
+	}
+	m, err := NewMigration(MigrationOptions{dialect: MYSQLDialect, schema: schema, primaryKey: AutoIncrementKey})
+	if err == nil {
+		err = m.InitDBMigration()
+	}
+	if err != nil {
+		restore()
+		tb.Fatal(err)
+	}
+	return func() {
+		m.ClearMigration()
+		restore()
+	}
+}
+
+// insertRows inserts rows into the columns of table, a few hundred rows per statement
+func insertRows(tb testing.TB, table string, columns []string, rows [][]interface{}) {
+	const batchSize = 500
+	placeholders := "(?" + strings.Repeat(",?", len(columns)-1) + ")"
+	for start := 0; start < len(rows); start += batchSize {
+		end := start + batchSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		values := make([]string, 0, end-start)
+		args := make([]interface{}, 0, (end-start)*len(columns))
+		for _, row := range rows[start:end] {
+			values = append(values, placeholders)
+			args = append(args, row...)
+		}
+		insertQuery := fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", table, strings.Join(columns, ", "), strings.Join(values, ", "))
+		if _, err := sqlConnection.Exec(insertQuery, args...); err != nil {
+			tb.Fatal(err)
+		}
+	}
+}
+
+// accessFixture sizes the grants seeded by seedAccess
+type accessFixture struct {
+	users              int
+	roles              int
+	permissionsPerRole int
+	rolesPerUser       int
+	groups             int
+	rolesPerGroup      int
+}
+
+// seedAccess grants each user rolesPerUser roles and the roles of one group, role r holds the permissions
+// GET /r<r>/p<p>/:id. It returns the path of a permission granted to user 1 through its first role
+func seedAccess(tb testing.TB, fixture accessFixture) string {
+	var users, roles, permissions, rolePermissions, userRoles, groups, groupRoles, userGroups [][]interface{}
+	for r := 1; r <= fixture.roles; r++ {
+		roles = append(roles, []interface{}{r, fmt.Sprintf("role-%d", r)})
+		for p := 1; p <= fixture.permissionsPerRole; p++ {
+			id := (r-1)*fixture.permissionsPerRole + p
+			permissions = append(permissions, []interface{}{id, fmt.Sprintf("p-%d", id), "GET", fmt.Sprintf("/r%d/p%d/:id", r, p)})
+			rolePermissions = append(rolePermissions, []interface{}{r, id})
+		}
+	}
+	for g := 1; g <= fixture.groups; g++ {
+		groups = append(groups, []interface{}{g, fmt.Sprintf("group-%d", g)})
+		for i := 0; i < fixture.rolesPerGroup; i++ {
+			groupRoles = append(groupRoles, []interface{}{g, (g*7+i)%fixture.roles + 1})
+		}
+	}
+	for u := 1; u <= fixture.users; u++ {
+		users = append(users, []interface{}{u, fmt.Sprintf("user-%d", u), fmt.Sprintf("user-%d@example.com", u), "-"})
+		for i := 0; i < fixture.rolesPerUser; i++ {
+			userRoles = append(userRoles, []interface{}{(u+i*13)%fixture.roles + 1, u})
+		}
+		if fixture.groups > 0 {
+			userGroups = append(userGroups, []interface{}{u%fixture.groups + 1, u})
+		}
+	}
+
+	insertRows(tb, userTable, []string{"id", "username", "email", "password"}, users)
+	insertRows(tb, roleTable, []string{"id", "name"}, roles)
+	insertRows(tb, permissionTable, []string{"id", "name", "method", "route"}, permissions)
+	insertRows(tb, rolePermissionTable, []string{"role_id", "permission_id"}, rolePermissions)
+	insertRows(tb, userRoleTable, []string{"role_id", "user_id"}, userRoles)
+	if fixture.groups > 0 {
+		insertRows(tb, groupTable, []string{"id", "name"}, groups)
+		insertRows(tb, groupRoleTable, []string{"group_id", "role_id"}, groupRoles)
+		insertRows(tb, userGroupTable, []string{"group_id", "user_id"}, userGroups)
+	}
+	return fmt.Sprintf("/r%d/p1/42", 1%fixture.roles+1)
+}
+
+func TestCanAccessThroughGroupOnly(t *testing.T) {
+	defer openTestMySQL(t)()
+	insertRows(t, userTable, []string{"id", "username", "email", "password"}, [][]interface{}{{1, "john", "john@example.com", "-"}})
+	insertRows(t, roleTable, []string{"id", "name"}, [][]interface{}{{1, "reporter"}})
+	insertRows(t, permissionTable, []string{"id", "name", "method", "route"}, [][]interface{}{{1, "reports.read", "GET", "/reports/:id"}})
+	insertRows(t, rolePermissionTable, []string{"role_id", "permission_id"}, [][]interface{}{{1, 1}})
+	insertRows(t, groupTable, []string{"id", "name"}, [][]interface{}{{1, "finance"}})
+	insertRows(t, groupRoleTable, []string{"group_id", "role_id"}, [][]interface{}{{1, 1}})
+	insertRows(t, userGroupTable, []string{"group_id", "user_id"}, [][]interface{}{{1, 1}})
+
+	user := &User{ID: "1"}
+	if !user.CanAccessWithContext(context.Background(), "GET", "/reports/7") {
+		t.Error("CanAccess() = false for a route granted through a group role")
+	}
+	if user.CanAccessWithContext(context.Background(), "DELETE", "/reports/7") {
+		t.Error("CanAccess() = true for a method the group doesn't grant")
+	}
+	if !(&Principal{UserID: "1"}).CanAccess("GET", "/reports/7") {
+		t.Error("Principal.CanAccess() = false for a route granted through a group role")
+	}
+
+	if _, err := sqlConnection.Exec("DELETE FROM rbac_user_group WHERE user_id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if user.CanAccessWithContext(context.Background(), "GET", "/reports/7") {
+		t.Error("CanAccess() = true after leaving the group")
+	}
+}
+
+func TestRenamedRoleAliasGivesWayToNewRole(t *testing.T) {
+	defer openTestMySQL(t)()
+	insertRows(t, userTable, []string{"id", "username", "email", "password"}, [][]interface{}{{1, "john", "john@example.com", "-"}})
+	insertRows(t, roleTable, []string{"id", "name"}, [][]interface{}{{1, "reporter"}})
+	insertRows(t, userRoleTable, []string{"role_id", "user_id"}, [][]interface{}{{1, 1}})
+
+	ctx := context.Background()
+	if err := (&Role{ID: "1"}).RenameWithAlias(ctx, "analyst", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	user := &User{ID: "1"}
+	if !user.HasRoleWithContext(ctx, "reporter") {
+		t.Fatal("HasRole() = false for the old name during the grace period")
+	}
+
+	if err := (&Role{Name: "reporter"}).CreateRoleWithContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if user.HasRoleWithContext(ctx, "reporter") {
+		t.Error("HasRole() = true for a new role named after the old name")
+	}
+	if !user.HasRoleWithContext(ctx, "analyst") {
+		t.Error("HasRole() = false for the new name")
+	}
+	var aliases int64
+	err := sqlConnection.QueryRow("SELECT COUNT(1) FROM rbac_name_alias WHERE kind = ? AND alias = ?", aliasRole, "reporter").Scan(&aliases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliases != 0 {
+		t.Error("the alias of the old name outlives the role created with it")
+	}
+}
+
+// benchmarkRouteAccess seeds 2000 users holding 3 roles and a group of 2 roles, out of 200 roles of 20 permissions
+func benchmarkRouteAccess(b *testing.B, access func(ctx context.Context, path string) bool) {
+	defer openTestMySQL(b)()
+	path := seedAccess(b, accessFixture{users: 2000, roles: 200, permissionsPerRole: 20, rolesPerUser: 3, groups: 50, rolesPerGroup: 2})
+	ctx := context.Background()
+	if !access(ctx, path) {
+		b.Fatalf("user 1 can't access %s", path)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		access(ctx, path)
+	}
+}
+
+// BenchmarkRouteAccessUserRole is the check of the first releases: the exact route through the user roles only
+func BenchmarkRouteAccessUserRole(b *testing.B) {
+	benchmarkRouteAccess(b, func(ctx context.Context, path string) bool {
+		getQuery := `SELECT COUNT(1)
+		FROM rbac_user_role ur
+		JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
+		JOIN rbac_permission p ON p.id = rp.permission_id
+		WHERE ur.user_id = ? AND p.method = ? AND p.route = ?`
+		route := path[:strings.LastIndexByte(path, '/')] + "/:id"
+		var count int64
+		if err := dbConnection.QueryRowContext(ctx, getQuery, "1", "GET", route).Scan(&count); err != nil {
+			b.Fatal(err)
+		}
+		return count > 0
+	})
+}
+
+// BenchmarkRouteAccessPermissionFirst tests the grant conditions against every permission of the method
+func BenchmarkRouteAccessPermissionFirst(b *testing.B) {
+	benchmarkRouteAccess(b, func(ctx context.Context, path string) bool {
+		getQuery := `SELECT p.route
+		FROM ` + permissionRoutes + `
+		WHERE p.method = ? AND (` + routePatternCondition + `)
+		AND (` + grantedPermissionCondition + `)`
+		result, err := dbConnection.QueryContext(ctx, getQuery, grantedPermissionArgs("1", "GET", path)...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer result.Close()
+		for result.Next() {
+			var route string
+			if err = result.Scan(&route); err != nil {
+				b.Fatal(err)
+			}
+			if MatchRoute(route, path) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// BenchmarkRouteAccessGrantedPermissions is the check of CanAccess, starting from the grants of the user
+func BenchmarkRouteAccessGrantedPermissions(b *testing.B) {
+	benchmarkRouteAccess(b, func(ctx context.Context, path string) bool {
+		granted, err := (&User{ID: "1", db: dbConnection}).grantedRoute(ctx, "GET", path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return granted
+	})
+}
@@ -0,0 +1,305 @@
+package pager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyHeader is the header ProtectRouteUsingAPIKey reads the key from
+	APIKeyHeader = "X-API-Key"
+
+	apiKeyPrefix = "pgr_"
+	// apiKeyUsageInterval bounds the writes of last_used_at to one per key and interval
+	apiKeyUsageInterval = time.Minute
+)
+
+var (
+	ErrInvalidAPIKey      = fmt.Errorf("%w: invalid api key", ErrInvalidAuthorization)
+	ErrInvalidAPIKeyID    = errors.New("invalid api key id")
+	ErrInvalidAPIKeyName  = errors.New("api key requires a name of at most 100 characters")
+	ErrInvalidAPIKeyScope = errors.New("api key scopes must be non-empty permission names without spaces")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+)
+
+// APIKey is a long-lived credential of a user or a service account, revoked by Auth.RevokeAPIKey.
+// Only the hash of the key is stored, the key itself is returned once by Auth.CreateAPIKey
+type APIKey struct {
+	ID     string `db:"id" json:"id"`
+	UserID string `db:"user_id" json:"user_id"`
+	Name   string `db:"name" json:"name"`
+	// Prefix is the public part of the key, enough to recognize it in the listings
+	Prefix     string    `db:"prefix" json:"prefix"`
+	Scopes     []string  `db:"scopes" json:"scopes,omitempty"`
+	LastUsedAt time.Time `db:"last_used_at" json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `db:"revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// Revoked reports whether the key was revoked
+func (k *APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+func hashAPIKey(key string) string {
+	digest := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(digest[:])
+}
+
+// generateAPIKey returns a key made of pgr_, 12 hex characters identifying it and a 256 bits secret,
+// e.g. pgr_1a2b3c4d5e6f.Zm9v...
+func generateAPIKey() (key, prefix string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return "", "", err
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	prefix = apiKeyPrefix + hex.EncodeToString(id)
+	return prefix + "." + base64.RawURLEncoding.EncodeToString(secret), prefix, nil
+}
+
+// parseAPIKey returns the prefix of key, or ErrInvalidAPIKey when key isn't shaped like a generated key
+func parseAPIKey(key string) (string, error) {
+	separator := strings.IndexByte(key, '.')
+	if separator < 0 || !strings.HasPrefix(key, apiKeyPrefix) || len(key) > maxCredentialLength || !visibleASCII(key) {
+		return "", ErrInvalidAPIKey
+	}
+	return key[:separator], nil
+}
+
+func (a *Auth) CreateAPIKey(user *User, name string, scopes []string) (string, *APIKey, error) {
+	return a.CreateAPIKeyWithContext(context.Background(), user, name, scopes)
+}
+
+// CreateAPIKeyWithContext creates a key of the active user and returns it with its record, the key can't be retrieved later.
+// The key authenticates as the user. scopes are permission names narrowing the key: its requests only hold the permissions
+// of the user named by scopes, in ProtectWithRBAC, Can and Principal.CanAccess. A key without scopes holds every permission of the user
+func (a *Auth) CreateAPIKeyWithContext(ctx context.Context, user *User, name string, scopes []string) (string, *APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if user == nil || user.ID == "" {
+		return "", nil, ErrInvalidUserID
+	}
+	if !user.Active {
+		return "", nil, ErrUserNotActive
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return "", nil, ErrInvalidAPIKeyName
+	}
+	for _, scope := range scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\r\n") {
+			return "", nil, ErrInvalidAPIKeyScope
+		}
+	}
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+	apiKey := &APIKey{
+		ID:        newPrimaryKey(),
+		UserID:    user.ID,
+		Name:      name,
+		Prefix:    prefix,
+		Scopes:    scopes,
+		CreatedAt: clock.Now(),
+	}
+
+	insertQuery := `INSERT INTO rbac_api_key (
+		id,
+		user_id,
+		name,
+		prefix,
+		key_hash,
+		scopes,
+		created_at) VALUES (?,?,?,?,?,?,?)`
+	result, err := dbConnection.ExecContext(
+		ctx,
+		insertQuery,
+		primaryKeyValue(apiKey.ID),
+		apiKey.UserID,
+		apiKey.Name,
+		apiKey.Prefix,
+		hashAPIKey(key),
+		strings.Join(scopes, " "),
+		apiKey.CreatedAt,
+	)
+	if err != nil {
+		return "", nil, err
+	}
+	if apiKey.ID, err = insertedID(apiKey.ID, result); err != nil {
+		return "", nil, err
+	}
+	return key, apiKey, nil
+}
+
+func (a *Auth) RevokeAPIKey(id string) error {
+	return a.RevokeAPIKeyWithContext(context.Background(), id)
+}
+
+// RevokeAPIKeyWithContext revokes the key, its next request is rejected. Revoking it again is a no-op
+func (a *Auth) RevokeAPIKeyWithContext(ctx context.Context, id string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if id == "" {
+		return ErrInvalidAPIKeyID
+	}
+	result, err := dbConnection.ExecContext(ctx, `UPDATE rbac_api_key SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, clock.Now(), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var exists int
+	err = queryRow(ctx, dbConnection, `SELECT 1 FROM rbac_api_key WHERE id = ?`, id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrAPIKeyNotFound
+	}
+	return err
+}
+
+func (a *Auth) ListAPIKeys(user *User) ([]APIKey, error) {
+	return a.ListAPIKeysWithContext(context.Background(), user)
+}
+
+// ListAPIKeysWithContext returns the keys of the user, the revoked ones included, newest first
+func (a *Auth) ListAPIKeysWithContext(ctx context.Context, user *User) ([]APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if user == nil || user.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+	getQuery := `SELECT id, user_id, name, prefix, scopes, last_used_at, revoked_at, created_at
+	FROM rbac_api_key
+	WHERE user_id = ?
+	ORDER BY created_at DESC, id DESC`
+	result, err := dbConnection.QueryContext(ctx, getQuery, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	keys := make([]APIKey, 0)
+	for result.Next() {
+		var key APIKey
+		var scopes string
+		err = result.Scan(
+			&key.ID,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			textColumn{&scopes},
+			timestamp{&key.LastUsedAt},
+			timestamp{&key.RevokedAt},
+			timestamp{&key.CreatedAt},
+		)
+		if err != nil {
+			return nil, err
+		}
+		key.Scopes = strings.Fields(scopes)
+		keys = append(keys, key)
+	}
+	return keys, result.Err()
+}
+
+// verifyAPIKey returns the non-revoked key of an active user matching key, or ErrInvalidAPIKey
+func verifyAPIKey(ctx context.Context, key string) (*APIKey, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	prefix, err := parseAPIKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey := &APIKey{Prefix: prefix}
+	var keyHash, scopes string
+	getQuery := `SELECT k.id, k.user_id, k.name, k.key_hash, k.scopes, k.last_used_at, k.created_at
+	FROM rbac_api_key k
+	JOIN rbac_user u ON u.id = k.user_id
+	WHERE k.prefix = ? AND k.revoked_at IS NULL AND u.active = 1`
+	err = queryRow(ctx, dbConnection, getQuery, prefix).Scan(
+		&apiKey.ID,
+		&apiKey.UserID,
+		&apiKey.Name,
+		&keyHash,
+		textColumn{&scopes},
+		timestamp{&apiKey.LastUsedAt},
+		timestamp{&apiKey.CreatedAt},
+	)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(keyHash), []byte(hashAPIKey(key))) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey.Scopes = strings.Fields(scopes)
+
+	now := clock.Now()
+	if now.Sub(apiKey.LastUsedAt) >= apiKeyUsageInterval {
+		updateQuery := `UPDATE rbac_api_key SET last_used_at = ? WHERE id = ?`
+		if _, err = dbConnection.ExecContext(ctx, updateQuery, now, apiKey.ID); err != nil {
+			return nil, err
+		}
+		apiKey.LastUsedAt = now
+	}
+	return apiKey, nil
+}
+
+// AuthenticateAPIKey resolves the principal of the key in the X-API-Key header and returns r carrying it,
+// the counterpart of AuthenticateRequest for the frameworks adapting the middleware
+func (a *Auth) AuthenticateAPIKey(r *http.Request) (*http.Request, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := verifyAPIKey(r.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	ctx, err := a.principalContext(a.requestContext(r), apiKey.UserID)
+	if err != nil {
+		return nil, ErrUnauthenticated
+	}
+	principal := PrincipalFromContext(ctx)
+	principal.APIKeyID = apiKey.ID
+	principal.Scopes = apiKey.Scopes
+	return r.WithContext(ctx), nil
+}
+
+// ProtectRouteUsingAPIKey authenticates the requests by the key of the X-API-Key header,
+// chain it with ProtectWithRBAC to check the route against the roles of the key owner, narrowed by the key scopes
+func (a *Auth) ProtectRouteUsingAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, err := a.AuthenticateAPIKey(r)
+		if err != nil {
+			a.writeError(w, r, a.ErrorToStatus(err), err)
+			return
+		}
+
+		next.ServeHTTP(w, authenticated)
+	})
+}
@@ -0,0 +1,96 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// ownerPermissions answers the permission loads of a user holding users.read and users.delete
+func ownerPermissions(query string, args []driver.NamedValue) fakeResponse {
+	if strings.Contains(query, "JOIN "+permissionRoutes+" ON p.id = granted.permission_id") {
+		return fakeResponse{columns: []string{"route"}, rows: [][]driver.Value{{"/users"}, {"/users/:id"}}}
+	}
+	if !strings.Contains(query, "WHERE ur.user_id IN") {
+		return fakeResponse{}
+	}
+	return fakeResponse{
+		columns: []string{"user_id", "name", "method", "route", "expires_at"},
+		rows: [][]driver.Value{
+			{"1", "users.read", "GET", "/users", nil},
+			{"1", "users.delete", "DELETE", "/users/:id", nil},
+		},
+	}
+}
+
+func TestAPIKeyScopesNarrowAuthorization(t *testing.T) {
+	_, restore := openFakeDB(t, ownerPermissions)
+	defer restore()
+	auth := &Auth{}
+
+	tests := []struct {
+		name   string
+		scopes []string
+		method string
+		path   string
+		want   error
+	}{
+		{"unscoped key holds the owner permissions", nil, "DELETE", "/users/5", nil},
+		{"route in scope", []string{"users.read"}, "GET", "/users", nil},
+		{"route out of scope", []string{"users.read"}, "DELETE", "/users/5", ErrPermissionDenied},
+		{"scope the owner doesn't hold", []string{"users.read", "billing.read"}, "DELETE", "/users/5", ErrPermissionDenied},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal := &Principal{UserID: "1", APIKeyID: "key", Scopes: test.scopes}
+			r := httptest.NewRequest(test.method, test.path, nil)
+			r = r.WithContext(context.WithValue(r.Context(), PrincipalKey, principal))
+			if err := auth.AuthorizeRequest(r); err != test.want {
+				t.Errorf("AuthorizeRequest() = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyScopesNarrowCan(t *testing.T) {
+	_, restore := openFakeDB(t, ownerPermissions)
+	defer restore()
+
+	principal := &Principal{UserID: "1", Scopes: []string{"users.read"}}
+	ctx := context.WithValue(context.Background(), PrincipalKey, principal)
+	if !Can(ctx, "users.read") {
+		t.Error("Can(users.read) = false, want true")
+	}
+	if Can(ctx, "users.delete") {
+		t.Error("Can(users.delete) = true for a key scoped to users.read")
+	}
+}
+
+func TestCreateAPIKeyRejectsInvalidScopes(t *testing.T) {
+	_, restore := openFakeDB(t, nil)
+	defer restore()
+
+	user := &User{ID: "1", Active: true}
+	for _, scopes := range [][]string{{""}, {"users.read users.delete"}} {
+		if _, _, err := (&Auth{}).CreateAPIKey(user, "ci", scopes); err != ErrInvalidAPIKeyScope {
+			t.Errorf("CreateAPIKey(%q) = %v, want ErrInvalidAPIKeyScope", scopes, err)
+		}
+	}
+}
+
+func TestParseAPIKey(t *testing.T) {
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parseAPIKey(key); err != nil || got != prefix {
+		t.Errorf("parseAPIKey(%q) = %q, %v, want %q", key, got, err, prefix)
+	}
+	for _, invalid := range []string{"", "pgr_abc", "key.secret", "pgr_abc.sec ret"} {
+		if _, err := parseAPIKey(invalid); err != ErrInvalidAPIKey {
+			t.Errorf("parseAPIKey(%q) = %v, want ErrInvalidAPIKey", invalid, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Constants for audit actions
+const (
+	AuditTokenRevoked = "token.revoked"
+	AuditLoginFailed  = "login.failed"
+)
+
+type AuditEntry struct {
+	ID       string            `db:"id" json:"id"`
+	ActorID  string            `db:"actor_id" json:"actor_id,omitempty"`
+	Action   string            `db:"action" json:"action"`
+	Target   string            `db:"target" json:"target"`
+	Reason   string            `db:"reason" json:"reason,omitempty"`
+	Metadata map[string]string `db:"metadata" json:"metadata,omitempty"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// actorFromContext returns the actor set by WithActor, or the id of the authenticated user stored by the middleware
+func actorFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	if actorID, ok := ctx.Value(actorKey{}).(string); ok {
+		return actorID
+	}
+	if principal := PrincipalFromContext(ctx); principal != nil {
+		return principal.UserID
+	}
+	user, ok := ctx.Value(UserPrinciple).(*User)
+	if !ok || user == nil {
+		return ""
+	}
+	return user.ID
+}
+
+// WriteAudit records entry into rbac_audit_log, ptx is optional
+func WriteAudit(ctx context.Context, entry *AuditEntry, ptx *PagerTx) error {
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+	return writeAudit(ctx, db, entry)
+}
+
+func writeAudit(ctx context.Context, db DbContract, entry *AuditEntry) error {
+	entry.Metadata = withContextMetadata(ctx, entry.Metadata)
+
+	var metadata interface{}
+	if len(entry.Metadata) > 0 {
+		raw, err := json.Marshal(entry.Metadata)
+		if err != nil {
+			return err
+		}
+		metadata = string(raw)
+	}
+
+	entry.CreatedAt = clock.Now()
+	insertQuery := `INSERT INTO rbac_audit_log (
+		actor_id,
+		action,
+		target,
+		reason,
+		metadata,
+		created_at) VALUES (?,?,?,?,?,?)`
+	result, err := db.ExecContext(
+		ctx,
+		insertQuery,
+		primaryKeyValue(entry.ActorID),
+		entry.Action,
+		entry.Target,
+		entry.Reason,
+		metadata,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	entry.ID, _ = insertedID("", result)
+	return nil
+}
+
+// withContextMetadata adds the client address and the correlation id of ctx to metadata, explicit values win
+func withContextMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	values := map[string]string{
+		"client_ip":  ClientIPFromContext(ctx),
+		"request_id": RequestIDFromContext(ctx),
+	}
+	for key, value := range values {
+		if value == "" || metadata[key] != "" {
+			continue
+		}
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[key] = value
+	}
+	return metadata
+}
@@ -0,0 +1,94 @@
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+const auditArchiveBatchSize = 1000
+
+// ArchiveAuditLogs streams the audit entries created before the given time into w as JSON lines, oldest first,
+// and deletes them in batches once written. It returns the number of archived entries, on error the entries
+// already written and deleted stay archived and the rest is left untouched
+func (p *Pager) ArchiveAuditLogs(ctx context.Context, before time.Time, w io.Writer) (int64, error) {
+	encoder := json.NewEncoder(w)
+	var archived int64
+	var lastID int64
+	for {
+		entries, batchLastID, err := archiveAuditBatch(ctx, before, lastID, encoder)
+		if err != nil {
+			return archived, err
+		}
+		if entries == 0 {
+			return archived, nil
+		}
+		archived += entries
+		lastID = batchLastID
+
+		select {
+		case <-ctx.Done():
+			return archived, ctx.Err()
+		default:
+		}
+	}
+}
+
+func archiveAuditBatch(ctx context.Context, before time.Time, afterID int64, encoder *json.Encoder) (int64, int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	getQuery := `SELECT id, actor_id, action, target, reason, metadata, created_at
+	FROM rbac_audit_log
+	WHERE created_at < ? AND id > ?
+	ORDER BY id
+	LIMIT ?`
+	result, err := dbConnection.QueryContext(ctx, getQuery, before.UTC(), afterID, auditArchiveBatchSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer result.Close()
+
+	var count, lastID int64
+	for result.Next() {
+		var entry AuditEntry
+		var metadata string
+		err = result.Scan(
+			&lastID,
+			textColumn{&entry.ActorID},
+			&entry.Action,
+			&entry.Target,
+			textColumn{&entry.Reason},
+			textColumn{&metadata},
+			timestamp{&entry.CreatedAt},
+		)
+		if err != nil {
+			return 0, 0, err
+		}
+		if metadata != "" {
+			if err = json.Unmarshal([]byte(metadata), &entry.Metadata); err != nil {
+				return 0, 0, err
+			}
+		}
+		entry.ID = strconv.FormatInt(lastID, 10)
+		if err = encoder.Encode(&entry); err != nil {
+			return 0, 0, err
+		}
+		count++
+	}
+	if err = result.Err(); err != nil {
+		return 0, 0, err
+	}
+	if count == 0 {
+		return 0, 0, nil
+	}
+
+	deleteQuery := `DELETE FROM rbac_audit_log WHERE created_at < ? AND id > ? AND id <= ?`
+	_, err = dbConnection.ExecContext(ctx, deleteQuery, before.UTC(), afterID, lastID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, lastID, nil
+}
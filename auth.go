@@ -2,12 +2,16 @@ package pager
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
-	"github.com/go-redis/redis"
+	"log"
+	"net"
 	"net/http"
-	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	uuid "github.com/satori/go.uuid"
 )
 
 var (
@@ -19,40 +23,163 @@ var (
 	ErrValidateCookie       = errors.New("error validate cookie")
 	ErrUserNotFound         = errors.New("user not found")
 	ErrUserNotActive        = errors.New("user is not active")
+	ErrUserMerged           = errors.New("user was merged into another account")
+	ErrTokenExpired         = errors.New("token is expired or does not exist")
+	ErrTokenRevoked         = errors.New("token has been revoked")
+	ErrUnauthenticated      = errors.New("authentication required")
+	// ErrInvalidCredentials is returned by Authenticate for an unknown identifier as well as a wrong password,
+	// so sign-in responses can't be used to enumerate the users
+	ErrInvalidCredentials = errors.New("invalid username or password")
 )
 
 type LoginParams struct {
 	Identifier string
 	Password   string
+	// Claims are stored in the session, e.g. tenant or scopes
+	Claims map[string]string
+	// ClientIP enables per-IP login throttling when LoginThrottle is configured, see Auth.LoginParamsFromRequest
+	ClientIP string
+	// Secure marks the session cookie of SignInWithCookie Secure, LoginParamsFromRequest sets it on the HTTPS requests
+	Secure bool
 }
 
-type LoginMethod int
-
 const (
 	LoginEmail         LoginMethod = 0
 	LoginUsername      LoginMethod = 1
 	LoginEmailUsername LoginMethod = 2
 
-	CookieBasedAuth int = 0
-	TokenBasedAuth  int = 1
+	CookieBasedAuth AuthStrategy = 0
+	TokenBasedAuth  AuthStrategy = 1
 
-	authorization string = "Authorization"
-	UserPrinciple string = "UserPrinciple"
+	authorization    string        = "Authorization"
+	knownIPRetention time.Duration = 90 * 24 * time.Hour
+	UserPrinciple    string        = "UserPrinciple"
 )
 
 type Auth struct {
 	SessionName string
 
-	cacheClient      *redis.Client
-	loginMethod      LoginMethod
-	origin           string
-	expiredInSeconds int64
+	sessionStore   SessionStore
+	sessionCipher  *SessionCipher
+	loginThrottle  *loginThrottle
+	notifications  *notificationDispatcher
+	cacheKeyPrefix string
+	loginMethod    LoginMethod
+	principalMode  PrincipalMode
+	// genericLoginError collapses ErrUserNotActive into ErrInvalidCredentials as well
+	genericLoginError bool
+	// legacyAuthorization accepts the Authorization headers without the Bearer scheme
+	legacyAuthorization bool
+	origin              string
+	expiredInSeconds    int64
+	// refreshExpiredInSeconds is the lifetime of the refresh tokens, 30 days when zero
+	refreshExpiredInSeconds int64
+	// cookieDomain shares the session cookie with the subdomains, empty for a host-only cookie
+	cookieDomain   string
+	cookieSameSite http.SameSite
+	secureCookie   bool
+	trustedProxies []*net.IPNet
+	requestID      RequestIDExtractor
+	errorRenderer  *errorRenderer
+	loginPages     *loginPages
+	// breachedPasswords checks the new passwords against known breaches when set
+	breachedPasswords *BreachedPasswordOptions
 
 	tokenStrategy    TokenGenerator
 	passwordStrategy PasswordGenerator
+	// jwt enables SignInWithJWT and ProtectRouteUsingJWT when set
+	jwt *JWTOptions
+	// stateless issues JWTs from SignIn and SignInWithCookie instead of storing sessions
+	stateless   bool
+	revocations RevocationList
+
+	dummyHashOnce sync.Once
+	dummyHash     string
 }
 
 func (a *Auth) Authenticate(params LoginParams) (*User, error) {
+	loggedUser, err := a.throttledAuthenticate(params)
+	if err != nil {
+		return nil, a.loginError(params, err)
+	}
+	a.notifyNewLoginIP(context.Background(), loggedUser, params.ClientIP)
+	return loggedUser, nil
+}
+
+func (a *Auth) throttledAuthenticate(params LoginParams) (*User, error) {
+	if a.loginThrottle == nil {
+		return a.authenticate(params)
+	}
+
+	err := a.loginThrottle.check(params.ClientIP)
+	if err != nil {
+		return nil, err
+	}
+	loggedUser, err := a.authenticate(params)
+	if err != nil {
+		errThrottle := a.loginThrottle.fail(params.ClientIP)
+		if errThrottle == ErrLoginThrottled || errors.Is(errThrottle, ErrTooManyAttempts) {
+			return nil, errThrottle
+		}
+		return nil, err
+	}
+	a.loginThrottle.succeed(params.ClientIP)
+	return loggedUser, nil
+}
+
+// Notify delivers a notification through the configured Notifier,
+// e.g. for events owned by the application such as EventMFADisabled
+func (a *Auth) Notify(ctx context.Context, event NotificationEvent, user *User, data map[string]string) {
+	a.notifications.dispatch(ctx, &Notification{
+		Event: event,
+		User:  user,
+		Data:  data,
+	})
+}
+
+func (a *Auth) ChangePassword(ctx context.Context, user *User, newPassword string) error {
+	if err := a.checkBreachedPassword(ctx, user, newPassword); err != nil {
+		return err
+	}
+	hashed, err := hashPassword(a.passwordStrategy, newPassword)
+	if err != nil {
+		return err
+	}
+	user.Password = hashed
+	err = user.SaveWithContext(ctx)
+	if err != nil {
+		return err
+	}
+	a.notifications.dispatch(ctx, &Notification{
+		Event: EventPasswordChanged,
+		User:  user,
+	})
+	return nil
+}
+
+// notifyNewLoginIP fires EventNewLoginIP when a user that signed in before uses an unknown address
+func (a *Auth) notifyNewLoginIP(ctx context.Context, user *User, ip string) {
+	if a.notifications == nil || ip == "" {
+		return
+	}
+	knownIPKey := a.cacheKey("known_ip:" + user.ID + ":" + ip)
+	if _, err := a.sessionStore.Get(knownIPKey); err != ErrSessionNotFound {
+		return
+	}
+	_ = a.sessionStore.Set(knownIPKey, "1", knownIPRetention)
+
+	loginCount, err := a.sessionStore.Increment(a.cacheKey("known_ip_count:"+user.ID), knownIPRetention)
+	if err != nil || loginCount <= 1 {
+		return
+	}
+	a.notifications.dispatch(ctx, &Notification{
+		Event:    EventNewLoginIP,
+		User:     user,
+		ClientIP: ip,
+	})
+}
+
+func (a *Auth) authenticate(params LoginParams) (*User, error) {
 	var loggedUser *User
 	var err error
 
@@ -69,6 +196,11 @@ func (a *Auth) Authenticate(params LoginParams) (*User, error) {
 		loggedUser, err = FindUserByUsernameOrEmail(params.Identifier, nil)
 	}
 	if loggedUser == nil {
+		// spend the same time as a wrong password, so the response time doesn't reveal unknown users
+		a.passwordStrategy.ValidatePassword(a.dummyPasswordHash(), params.Password)
+		if err != nil {
+			return nil, err
+		}
 		return nil, ErrInvalidUserLogin
 	}
 	if err != nil {
@@ -79,36 +211,74 @@ func (a *Auth) Authenticate(params LoginParams) (*User, error) {
 		return nil, ErrInvalidPasswordLogin
 	}
 
+	if loggedUser.IsMerged() {
+		return nil, ErrUserMerged
+	}
 	if !loggedUser.Active {
 		return nil, ErrUserNotActive
 	}
+	if loggedUser.IsService() {
+		// service accounts authenticate with issued tokens only
+		return nil, ErrInvalidUserLogin
+	}
+	a.rehashPassword(context.Background(), loggedUser, params.Password)
 	return loggedUser, nil
 }
 
+// loginError hides the precise reason of a failed sign-in: unknown users and wrong passwords always
+// become ErrInvalidCredentials, inactive users too with GenericLoginError, which audits the precise reason
+func (a *Auth) loginError(params LoginParams, err error) error {
+	switch err {
+	case ErrInvalidUserLogin, ErrInvalidPasswordLogin, ErrUserNotActive, ErrUserMerged:
+	default:
+		return err
+	}
+	if !a.genericLoginError {
+		if err == ErrUserNotActive || err == ErrUserMerged {
+			return err
+		}
+		return ErrInvalidCredentials
+	}
+
+	errAudit := WriteAudit(context.Background(), &AuditEntry{
+		Action:   AuditLoginFailed,
+		Target:   params.Identifier,
+		Reason:   err.Error(),
+		Metadata: map[string]string{"client_ip": params.ClientIP},
+	}, nil)
+	if errAudit != nil {
+		log.Printf("failed to audit the failed sign-in, err = %s", errAudit)
+	}
+	return ErrInvalidCredentials
+}
+
+// dummyPasswordHash is compared against on the unknown user path, it's hashed once with the configured strategy
+func (a *Auth) dummyPasswordHash() string {
+	a.dummyHashOnce.Do(func() {
+		a.dummyHash = a.passwordStrategy.HashPassword(uuid.NewV4().String())
+	})
+	return a.dummyHash
+}
+
 func (a *Auth) SignInWithCookie(w http.ResponseWriter, params LoginParams) (*User, error) {
 	loggedUser, err := a.Authenticate(params)
 	if err != nil {
 		return nil, err
 	}
 
-	hashCookie := a.tokenStrategy.GenerateToken()
-	http.SetCookie(w, &http.Cookie{
-		Name:    a.SessionName,
-		Value:   hashCookie,
-		Path:    "/",
-		Expires: time.Now().Add(time.Duration(a.expiredInSeconds)),
-	})
-
-	err = a.cacheClient.Do(
-		"SETEX",
-		hashCookie,
-		strconv.FormatInt(a.expiredInSeconds, 10),
-		loggedUser.ID,
-	).Err()
+	var hashCookie string
+	if a.stateless {
+		hashCookie, err = a.IssueJWT(context.Background(), loggedUser, params.Claims)
+	} else {
+		hashCookie = a.tokenStrategy.GenerateToken()
+		err = a.storeSession(hashCookie, loggedUser, params.Claims)
+	}
 	if err != nil {
 		return nil, ErrCreatingCookie
 	}
 
+	http.SetCookie(w, a.sessionCookie(hashCookie, 0, params.Secure))
+
 	return loggedUser, nil
 }
 
@@ -118,21 +288,16 @@ func (a *Auth) ClearSession(w http.ResponseWriter, r *http.Request) error {
 		return ErrInvalidCookie
 	}
 	cookie := cookieData.Value
-	err = a.cacheClient.Do(
-		"DEL",
-		cookie,
-	).Err()
-	if err != nil {
+	if a.stateless {
+		// an invalid or expired token has nothing left to revoke
+		a.revokeStateless(r.Context(), cookie)
+	} else if err = a.sessionStore.Delete(a.cacheKey(cookie)); err != nil {
 		return err
 	}
+	observeSessionEnded("logout")
 
 	// clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:   a.SessionName,
-		Value:  "",
-		Path:   "/",
-		MaxAge: -1,
-	})
+	http.SetCookie(w, a.sessionCookie("", -1, a.secureRequest(r)))
 	return nil
 }
 
@@ -142,13 +307,13 @@ func (a *Auth) SignIn(params LoginParams) (*User, string, error) {
 		return nil, "", err
 	}
 
-	token := a.tokenStrategy.GenerateToken()
-	err = a.cacheClient.Do(
-		"SETEX",
-		token,
-		strconv.FormatInt(a.expiredInSeconds, 10),
-		loggedUser.ID,
-	).Err()
+	var token string
+	if a.stateless {
+		token, err = a.IssueJWT(context.Background(), loggedUser, params.Claims)
+	} else {
+		token = a.tokenStrategy.GenerateToken()
+		err = a.storeSession(token, loggedUser, params.Claims)
+	}
 	if err != nil {
 		return nil, "", ErrCreatingCookie
 	}
@@ -158,69 +323,119 @@ func (a *Auth) SignIn(params LoginParams) (*User, string, error) {
 
 func (a *Auth) Logout(request *http.Request) error {
 	var err error
-	var user *User
-	user = GetUserLogin(request)
-	if user == nil {
+	if GetPrincipal(request) == nil {
 		return ErrInvalidUserLogin
 	}
 
-	token := request.Header.Get(authorization)
-	err = a.cacheClient.Do(
-		"DEL",
-		token,
-	).Err()
+	token, err := a.requestBearerToken(request)
+	if err != nil {
+		return err
+	}
+	if a.stateless {
+		_, err = a.revokeStateless(request.Context(), token)
+		return err
+	}
+	err = a.sessionStore.Delete(a.cacheKey(token))
 	if err != nil {
 		return err
 	}
+	observeSessionEnded("logout")
 	return nil
 }
 
 func (a *Auth) Register(user *User) error {
-	user.Password = a.passwordStrategy.HashPassword(user.Password)
+	username, err := NormalizeUsername(user.Username)
+	if err != nil {
+		return err
+	}
+	user.Username = username
+	// validate before hashing, the hash of an empty password isn't empty
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if err := a.checkBreachedPassword(context.Background(), user, user.Password); err != nil {
+		return err
+	}
+	user.Password, err = hashPassword(a.passwordStrategy, user.Password)
+	if err != nil {
+		return err
+	}
 	return user.CreateUser()
 }
 
+// AuthenticateRequest resolves the principal of r without writing the response and returns r carrying it,
+// for the frameworks adapting the middleware. The session cookie of the rejected cookie-based requests is cleared,
+// the errors map to their status through ErrorToStatus
+func (a *Auth) AuthenticateRequest(w http.ResponseWriter, r *http.Request, strategy AuthStrategy) (*http.Request, error) {
+	if strategy == CookieBasedAuth && !a.allowedOrigin(r) {
+		return nil, ErrOriginNotAllowed
+	}
+	userID, err := a.sessionUserID(r, strategy)
+	if err != nil {
+		if strategy == CookieBasedAuth {
+			a.ClearSession(w, r)
+		}
+		return nil, err
+	}
+	ctx, err := a.principalContext(a.requestContext(r), userID)
+	if err != nil {
+		if strategy == CookieBasedAuth {
+			a.ClearSession(w, r)
+		}
+		return nil, ErrUnauthenticated
+	}
+	return r.WithContext(ctx), nil
+}
+
+// AuthorizeRequest checks the route of r against the permissions of its principal,
+// it returns ErrUnauthenticated without a principal and ErrPermissionDenied when the route isn't granted
+func (a *Auth) AuthorizeRequest(r *http.Request) error {
+	principal := GetPrincipal(r)
+	if principal == nil {
+		return ErrUnauthenticated
+	}
+
+	var granted bool
+	if principal.prefetched || len(principal.Scopes) > 0 {
+		granted = principal.CanAccess(r.Method, r.URL.Path)
+		explainAccess(r.Context(), principal.accessUser(), r.Method, r.URL.Path, granted)
+	} else {
+		granted = principal.accessUser().CanAccessWithContext(r.Context(), r.Method, r.URL.Path)
+	}
+	if !granted {
+		return ErrPermissionDenied
+	}
+	return nil
+}
+
 func (a *Auth) ProtectRoute(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := a.getUserPrinciple(r, CookieBasedAuth)
+		authenticated, err := a.AuthenticateRequest(w, r, CookieBasedAuth)
 		if err != nil {
-			// clear session
-			a.ClearSession(w, r)
-
-			w.WriteHeader(http.StatusUnauthorized)
+			a.writeError(w, r, a.ErrorToStatus(err), err)
 			return
 		}
-		ctx := context.WithValue(r.Context(), UserPrinciple, user)
-		r = r.WithContext(ctx)
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, authenticated)
 	})
 }
 
 func (a *Auth) ProtectRouteUsingToken(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user, err := a.getUserPrinciple(r, TokenBasedAuth)
+		authenticated, err := a.AuthenticateRequest(w, r, TokenBasedAuth)
 		if err != nil {
-			w.WriteHeader(http.StatusUnauthorized)
+			a.writeError(w, r, a.ErrorToStatus(err), err)
 			return
 		}
-		ctx := context.WithValue(r.Context(), UserPrinciple, user)
-		r = r.WithContext(ctx)
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, authenticated)
 	})
 }
 
 func (a *Auth) ProtectWithRBAC(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		user := GetUserLogin(r)
-		if user == nil {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-
-		if !user.CanAccess(r.Method, r.URL.Path) {
-			w.WriteHeader(http.StatusForbidden)
+		if err := a.AuthorizeRequest(r); err != nil {
+			a.writeError(w, r, a.ErrorToStatus(err), err)
 			return
 		}
 
@@ -228,15 +443,148 @@ func (a *Auth) ProtectWithRBAC(next http.Handler) http.Handler {
 	})
 }
 
-func (a *Auth) VerifyToken(token string) (int64, error) {
-	result, err := a.cacheClient.Do(
-		"GET",
-		token,
-	).Int64()
+func (a *Auth) VerifyToken(token string) (string, error) {
+	session, err := a.GetSession(token)
+	if err != nil {
+		return "", err
+	}
+	return session.UserID, nil
+}
+
+func (a *Auth) GetSession(token string) (*SessionData, error) {
+	if a.stateless {
+		return a.statelessSession(token)
+	}
+	raw, err := a.sessionStore.Get(a.cacheKey(token))
+	if err == ErrSessionNotFound {
+		_, errRevoked := a.sessionStore.Get(a.revokedKey(token))
+		if errRevoked == nil {
+			return nil, ErrTokenRevoked
+		}
+		return nil, ErrTokenExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a.decodeSession(raw)
+}
+
+func (a *Auth) RevokeToken(token, reason string) error {
+	return a.RevokeTokenWithContext(context.Background(), token, reason)
+}
+
+// RevokeTokenWithContext invalidates token and records the revocation into the audit log,
+// the actor is the authenticated user stored in ctx by the middleware
+func (a *Auth) RevokeTokenWithContext(ctx context.Context, token, reason string) error {
+	session, err := a.GetSession(token)
+	if err != nil {
+		return err
+	}
+
+	if a.stateless {
+		_, err = a.revokeStateless(ctx, token)
+		if err != nil {
+			return err
+		}
+		return WriteAudit(ctx, &AuditEntry{
+			ActorID: actorFromContext(ctx),
+			Action:  AuditTokenRevoked,
+			Target:  session.UserID,
+			Reason:  reason,
+		}, nil)
+	}
+
+	err = a.sessionStore.Set(
+		a.revokedKey(token),
+		reason,
+		time.Duration(a.expiredInSeconds)*time.Second,
+	)
+	if err != nil {
+		return err
+	}
+	err = a.sessionStore.Delete(a.cacheKey(token))
+	if err != nil {
+		return err
+	}
+	observeSessionEnded("revoked")
+
+	return WriteAudit(ctx, &AuditEntry{
+		ActorID: actorFromContext(ctx),
+		Action:  AuditTokenRevoked,
+		Target:  session.UserID,
+		Reason:  reason,
+	}, nil)
+}
+
+func (a *Auth) revokedKey(token string) string {
+	return a.cacheKey("revoked:" + token)
+}
+
+func (a *Auth) storeSession(token string, user *User, claims map[string]string) error {
+	return a.storeSessionFor(token, user, claims, time.Duration(a.expiredInSeconds)*time.Second)
+}
+
+func (a *Auth) storeSessionFor(token string, user *User, claims map[string]string, expiration time.Duration) error {
+	return a.storeSessionData(a.cacheKey(token), &SessionData{
+		UserID:   user.ID,
+		Claims:   claims,
+		IssuedAt: clock.Now(),
+	}, expiration)
+}
+
+// storeSessionData stores the session under key, indexed by its user when the store supports it
+func (a *Auth) storeSessionData(key string, session *SessionData, expiration time.Duration) error {
+	raw, err := a.encodeSession(session)
+	if err != nil {
+		return err
+	}
+	if indexer, ok := a.sessionStore.(SessionIndexer); ok {
+		err = indexer.SetIndexed(key, raw, expiration, a.sessionIndexKey(session.UserID))
+	} else {
+		err = a.sessionStore.Set(key, raw, expiration)
+	}
+	if err != nil {
+		return err
+	}
+	a.observeSessionCreated(key)
+	return nil
+}
+
+func (a *Auth) sessionIndexKey(userID string) string {
+	return a.cacheKey("sessions:" + userID)
+}
+
+func (a *Auth) encodeSession(session *SessionData) (string, error) {
+	payload, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	if a.sessionCipher == nil {
+		return string(payload), nil
+	}
+	return a.sessionCipher.Encrypt(payload)
+}
+
+func (a *Auth) decodeSession(raw string) (*SessionData, error) {
+	payload := []byte(raw)
+	if a.sessionCipher != nil && !strings.HasPrefix(raw, "{") && strings.Contains(raw, ".") {
+		var err error
+		payload, err = a.sessionCipher.Decrypt(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(string(payload), "{") {
+		// sessions written before SessionData only hold the user id
+		return &SessionData{UserID: raw}, nil
+	}
+
+	session := new(SessionData)
+	err := json.Unmarshal(payload, session)
 	if err != nil {
-		return -1, err
+		return nil, err
 	}
-	return result, nil
+	return session, nil
 }
 
 func (a *Auth) GetUserByToken(token string) (*User, error) {
@@ -254,40 +602,65 @@ func (a *Auth) GetUserByToken(token string) (*User, error) {
 	return user, nil
 }
 
-func (a *Auth) getUserPrinciple(r *http.Request, strategy int) (*User, error) {
+func (a *Auth) sessionUserID(r *http.Request, strategy AuthStrategy) (string, error) {
 	var token string
 	switch strategy {
 	case CookieBasedAuth:
 		cookieData, err := r.Cookie(a.SessionName)
 		if err != nil {
-			return nil, ErrInvalidCookie
+			return "", ErrInvalidCookie
+		}
+		if token, err = cookieToken(cookieData.Value); err != nil {
+			return "", err
 		}
-		token = cookieData.Value
 	case TokenBasedAuth:
-		rawToken := r.Header.Get(authorization)
-		headers := strings.Split(rawToken, " ")
-		if len(headers) != 2 {
-			return nil, ErrInvalidAuthorization
+		var err error
+		if token, err = a.requestBearerToken(r); err != nil {
+			return "", err
 		}
-		token = headers[1]
+	default:
+		return "", ErrInvalidAuthorization
 	}
 
 	userID, err := a.VerifyToken(token)
 	if err != nil {
-		return nil, ErrValidateCookie
+		return "", ErrValidateCookie
 	}
+	return userID, nil
+}
 
-	user, err := FindUser(map[string]interface{}{
-		"id": userID,
-	}, nil)
-	if err != nil {
-		return nil, ErrUserNotFound
-	}
+func (a *Auth) cacheKey(key string) string {
+	return a.cacheKeyPrefix + key
+}
 
-	return user, nil
+type keyMigrator interface {
+	MigrateKeys(match, prefix string) (int, error)
 }
 
+// MigrateCacheKeys renames the existing keys matching the glob pattern into the prefixed keyspace,
+// e.g. "$2a$*" matches the tokens issued by DefaultTokenGenerator before CacheKeyPrefix was introduced.
+// It returns the number of renamed keys
+func (a *Auth) MigrateCacheKeys(match string) (int, error) {
+	migrator, ok := a.sessionStore.(keyMigrator)
+	if !ok {
+		return 0, ErrUnsupportedSessionStore
+	}
+	return migrator.MigrateKeys(match, a.cacheKeyPrefix)
+}
+
+// GetUserLogin returns the authenticated user, loading it when the middleware stored a slim or lazy principal
 func GetUserLogin(r *http.Request) *User {
 	ctx := r.Context()
-	return ctx.Value(UserPrinciple).(*User)
+	if user, ok := ctx.Value(UserPrinciple).(*User); ok {
+		return user
+	}
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return nil
+	}
+	user, err := principal.User()
+	if err != nil {
+		return nil
+	}
+	return user
 }
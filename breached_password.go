@@ -0,0 +1,122 @@
+package pager
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrBreachedPassword = errors.New("password appears in a known data breach")
+
+// PwnedPasswordChecker returns how many times password appears in known breaches, zero when it doesn't
+type PwnedPasswordChecker interface {
+	BreachCount(ctx context.Context, password string) (int, error)
+}
+
+// BreachedPasswordOptions checks the passwords of Register, RegisterWithOptions and ChangePassword.
+// Breached passwords are rejected with ErrBreachedPassword unless WarnOnly is set, OnBreached is called either way.
+// Checker failures never block the caller, they are logged
+type BreachedPasswordOptions struct {
+	Checker PwnedPasswordChecker
+	// MinCount ignores the passwords seen less often, 1 when zero
+	MinCount   int
+	WarnOnly   bool
+	OnBreached func(ctx context.Context, user *User, count int)
+}
+
+const hibpRangeEndpoint = "https://api.pwnedpasswords.com/range/"
+
+// HIBPChecker queries the Have I Been Pwned range API, only the first 5 characters of the SHA-1
+// of the password leave the process (k-anonymity)
+type HIBPChecker struct {
+	Client   *http.Client
+	Endpoint string
+	// UserAgent is required by the API, "pager" when empty
+	UserAgent string
+}
+
+func NewHIBPChecker() *HIBPChecker {
+	return &HIBPChecker{
+		Client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (h *HIBPChecker) BreachCount(ctx context.Context, password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	endpoint := h.Endpoint
+	if endpoint == "" {
+		endpoint = hibpRangeEndpoint
+	}
+	request, err := http.NewRequest(http.MethodGet, endpoint+prefix, nil)
+	if err != nil {
+		return 0, err
+	}
+	request = request.WithContext(ctx)
+	userAgent := h.UserAgent
+	if userAgent == "" {
+		userAgent = "pager"
+	}
+	request.Header.Set("User-Agent", userAgent)
+	// padding hides the size of the response, the padded entries have a zero count
+	request.Header.Set("Add-Padding", "true")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("pwned passwords range API responded %s", response.Status)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		fields := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(fields) != 2 || fields[0] != suffix {
+			continue
+		}
+		return strconv.Atoi(fields[1])
+	}
+	return 0, scanner.Err()
+}
+
+// checkBreachedPassword is called with the plain password, before it's hashed
+func (a *Auth) checkBreachedPassword(ctx context.Context, user *User, password string) error {
+	opts := a.breachedPasswords
+	if opts == nil || opts.Checker == nil {
+		return nil
+	}
+	count, err := opts.Checker.BreachCount(ctx, password)
+	if err != nil {
+		logf(ctx, "failed to check the password against known breaches, err = %s", err)
+		return nil
+	}
+	minCount := opts.MinCount
+	if minCount <= 0 {
+		minCount = 1
+	}
+	if count < minCount {
+		return nil
+	}
+	if opts.OnBreached != nil {
+		opts.OnBreached(ctx, user, count)
+	}
+	if opts.WarnOnly {
+		return nil
+	}
+	return ErrBreachedPassword
+}
@@ -0,0 +1,84 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Constants for break-glass audit actions
+const (
+	AuditBreakGlassGranted = "breakglass.granted"
+)
+
+var (
+	ErrBreakGlassReason   = errors.New("break-glass access requires a reason")
+	ErrBreakGlassTTL      = errors.New("break-glass access requires a positive ttl")
+	ErrBreakGlassHeldRole = errors.New("user already holds the role without expiry")
+)
+
+func (a *Auth) BreakGlass(user *User, role *Role, reason string, ttl time.Duration) error {
+	return a.BreakGlassWithContext(context.Background(), user, role, reason, ttl)
+}
+
+// BreakGlassWithContext grants role to user for ttl in an emergency, records it into the audit log
+// and fires EventBreakGlass, which ignores the notification preferences.
+// The grant is stored with its expiry, so it lapses even if the process restarts before
+func (a *Auth) BreakGlassWithContext(ctx context.Context, user *User, role *Role, reason string, ttl time.Duration) error {
+	if reason == "" {
+		return ErrBreakGlassReason
+	}
+	if ttl <= 0 {
+		return ErrBreakGlassTTL
+	}
+	if user.ID == "" {
+		return ErrInvalidUserID
+	}
+	if role.ID == "" {
+		return ErrInvalidRoleID
+	}
+	if role.db == nil {
+		role.db = dbConnection
+	}
+
+	// AssignUntil would put an expiry on the permanent grant
+	var expiresAt time.Time
+	getQuery := `SELECT expires_at FROM rbac_user_role WHERE user_id = ? AND role_id = ?`
+	err := queryRow(ctx, role.db, getQuery, user.ID, role.ID).Scan(timestamp{&expiresAt})
+	if err == nil && expiresAt.IsZero() {
+		return ErrBreakGlassHeldRole
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	expiresAt = clock.Now().Add(ttl)
+	err = role.AssignUntilWithContext(ctx, user, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	data := map[string]string{
+		"role":       role.Name,
+		"reason":     reason,
+		"expires_at": expiresAt.Format(timestampLayout),
+	}
+	err = writeAudit(ctx, role.db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditBreakGlassGranted,
+		Target:   user.ID,
+		Reason:   reason,
+		Metadata: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	a.notifications.dispatch(ctx, &Notification{
+		Event: EventBreakGlass,
+		User:  user,
+		Data:  data,
+	})
+	return nil
+}
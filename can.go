@@ -0,0 +1,106 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+)
+
+var ErrPermissionDenied = errors.New("permission denied")
+
+// PermissionDeniedError is the panic value of MustCan
+type PermissionDeniedError struct {
+	Permission string
+}
+
+func (e *PermissionDeniedError) Error() string {
+	return "permission denied: " + e.Permission
+}
+
+func (e *PermissionDeniedError) Is(target error) bool {
+	return target == ErrPermissionDenied
+}
+
+// Can reports whether the principal stored in ctx by the middleware holds permissionName,
+// the permissions are resolved once per request. It's false without a principal
+func Can(ctx context.Context, permissionName string) bool {
+	principal := PrincipalFromContext(ctx)
+	if principal == nil {
+		return false
+	}
+	return principal.Can(permissionName)
+}
+
+// MustCan panics with a *PermissionDeniedError unless Can, mount Auth.RecoverPermissionDenied
+// to turn the panic into a 403 response
+func MustCan(ctx context.Context, permissionName string) {
+	if !Can(ctx, permissionName) {
+		panic(&PermissionDeniedError{Permission: permissionName})
+	}
+}
+
+// RecoverPermissionDenied responds 403 to the requests whose handler panicked in MustCan, other panics are re-raised
+func (a *Auth) RecoverPermissionDenied(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if _, ok := recovered.(*PermissionDeniedError); !ok {
+				panic(recovered)
+			}
+			a.writeError(w, r, http.StatusForbidden, ErrPermissionDenied)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// CheckAccess reports whether the user could access the route, for the admin tooling answering "could user X do Y?".
+// Only the id is needed, the user isn't loaded. It returns ErrUserNotFound for an unknown id,
+// and the errors of the queries instead of denying the access
+func (p *Pager) CheckAccess(ctx context.Context, userID, method, path string) (bool, error) {
+	user, err := introspectedUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	granted, err := user.canAccess(ctx, method, path)
+	if err != nil {
+		return false, err
+	}
+	explainAccess(ctx, user, method, path, granted)
+	return granted, nil
+}
+
+// UserHasPermission is the permission counterpart of CheckAccess
+func (p *Pager) UserHasPermission(ctx context.Context, userID, permissionName string) (bool, error) {
+	user, err := introspectedUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+	return user.hasPermission(ctx, permissionName)
+}
+
+// introspectedUser returns a user bound to the connection holding the id only, once its existence is checked
+func introspectedUser(ctx context.Context, userID string) (*User, error) {
+	if userID == "" {
+		return nil, ErrInvalidUserID
+	}
+	queryCtx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var exists int
+	err := queryRow(queryCtx, dbConnection, `SELECT 1 FROM rbac_user WHERE id = ?`, userID).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &User{ID: userID, db: dbConnection}, nil
+}
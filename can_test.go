@@ -0,0 +1,99 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+var errConnectionLost = errors.New("connection lost")
+
+// introspection answers the existence of user 1 and fails the queries containing failing
+func introspection(failing string) fakeHandler {
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		switch {
+		case strings.Contains(query, "SELECT 1 FROM rbac_user WHERE id = ?"):
+			return fakeRowsOf([]string{"1"}, int64(1))
+		case failing != "" && strings.Contains(query, failing):
+			return fakeResponse{err: errConnectionLost}
+		case strings.Contains(query, "COUNT(1)"):
+			return fakeRowsOf([]string{"count"}, int64(1))
+		case strings.Contains(query, "JOIN "+permissionRoutes+" ON p.id = granted.permission_id"):
+			return fakeRowsOf([]string{"route"}, "/reports/:id")
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestCheckAccessReturnsQueryErrors(t *testing.T) {
+	pager := &Pager{}
+	tests := []struct {
+		name    string
+		failing string
+		granted bool
+		err     error
+	}{
+		{name: "granted", granted: true},
+		{name: "grants", failing: "granted.permission_id", err: errConnectionLost},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restore := openFakeDB(t, introspection(test.failing))
+			defer restore()
+
+			granted, err := pager.CheckAccess(context.Background(), "1", "GET", "/reports/7")
+			if granted != test.granted || err != test.err {
+				t.Errorf("CheckAccess() = %v, %v, want %v, %v", granted, err, test.granted, test.err)
+			}
+			if (&User{ID: "1"}).CanAccessWithContext(context.Background(), "GET", "/reports/7") != test.granted {
+				t.Errorf("CanAccess() = %v, want %v", !test.granted, test.granted)
+			}
+		})
+	}
+}
+
+func TestUserHasPermissionReturnsQueryErrors(t *testing.T) {
+	pager := &Pager{}
+	tests := []struct {
+		name    string
+		failing string
+		granted bool
+		err     error
+	}{
+		{name: "granted", granted: true},
+		{name: "grants", failing: "COUNT(1)", err: errConnectionLost},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, restore := openFakeDB(t, introspection(test.failing))
+			defer restore()
+
+			granted, err := pager.UserHasPermission(context.Background(), "1", "reports.read")
+			if granted != test.granted || err != test.err {
+				t.Errorf("UserHasPermission() = %v, %v, want %v, %v", granted, err, test.granted, test.err)
+			}
+		})
+	}
+}
+
+func TestUserHasPermissionReturnsAliasErrors(t *testing.T) {
+	_, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		switch {
+		case strings.Contains(query, "SELECT 1 FROM rbac_user WHERE id = ?"):
+			return fakeRowsOf([]string{"1"}, int64(1))
+		case strings.Contains(query, "COUNT(1)"):
+			return fakeRowsOf([]string{"count"}, int64(0))
+		case strings.Contains(query, "rbac_name_alias"):
+			return fakeResponse{err: errConnectionLost}
+		}
+		return fakeResponse{}
+	})
+	defer restore()
+
+	granted, err := (&Pager{}).UserHasPermission(context.Background(), "1", "reports.view")
+	if granted || err != errConnectionLost {
+		t.Errorf("UserHasPermission() = %v, %v, want false, %v", granted, err, errConnectionLost)
+	}
+}
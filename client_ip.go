@@ -0,0 +1,134 @@
+package pager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// parseTrustedProxies accepts CIDRs as well as single addresses
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func (a *Auth) trustedProxy(ip net.IP) bool {
+	for _, network := range a.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client, the forwarding headers (Forwarded, X-Forwarded-For, X-Real-IP)
+// are only honored when the request comes from a trusted proxy. The chain is walked from the nearest hop
+// and the first address that isn't a trusted proxy is the client
+func (a *Auth) ClientIP(r *http.Request) string {
+	remote := parseHostIP(r.RemoteAddr)
+	if remote == nil {
+		return r.RemoteAddr
+	}
+	if !a.trustedProxy(remote) {
+		return remote.String()
+	}
+
+	// indexed by the canonical keys, Header.Values needs Go 1.14
+	var chain []string
+	if forwarded := r.Header[textproto.CanonicalMIMEHeaderKey("Forwarded")]; len(forwarded) > 0 {
+		chain = forwardedFor(forwarded)
+	} else if forwardedFor := r.Header[textproto.CanonicalMIMEHeaderKey("X-Forwarded-For")]; len(forwardedFor) > 0 {
+		for _, value := range forwardedFor {
+			chain = append(chain, strings.Split(value, ",")...)
+		}
+	} else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+		chain = []string{realIP}
+	}
+
+	client := remote
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := parseHostIP(strings.TrimSpace(chain[i]))
+		if ip == nil {
+			break
+		}
+		client = ip
+		if !a.trustedProxy(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// forwardedFor extracts the for= parameters of the RFC 7239 Forwarded header values
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					chain = append(chain, strings.Trim(kv[1], `"`))
+				}
+			}
+		}
+	}
+	return chain
+}
+
+// parseHostIP parses an address with an optional port, IPv6 may be bracketed
+func parseHostIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(strings.Trim(address, "[]"))
+}
+
+// LoginParamsFromRequest fills ClientIP of the login params from r
+func (a *Auth) LoginParamsFromRequest(r *http.Request, identifier, password string) LoginParams {
+	return LoginParams{
+		Identifier: identifier,
+		Password:   password,
+		ClientIP:   a.ClientIP(r),
+		Secure:     a.secureRequest(r),
+	}
+}
+
+// secureRequest reports whether r came over HTTPS, directly or through a trusted proxy setting X-Forwarded-Proto
+func (a *Auth) secureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	remote := parseHostIP(r.RemoteAddr)
+	return remote != nil && a.trustedProxy(remote) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// ClientIPFromContext returns the client address stored by the middleware, audit entries record it as client_ip
+func ClientIPFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
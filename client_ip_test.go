@@ -0,0 +1,102 @@
+package pager
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := &Auth{trustedProxies: proxies}
+
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string][]string
+		want    string
+	}{
+		{name: "direct", remote: "203.0.113.7:4000", want: "203.0.113.7"},
+		{
+			name:    "untrusted peer",
+			remote:  "203.0.113.7:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.9"}},
+			want:    "203.0.113.7",
+		},
+		{
+			name:    "x-forwarded-for",
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.9, 10.0.0.3"}},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "repeated x-forwarded-for",
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.66, 198.51.100.9", "192.0.2.1"}},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "forwarded",
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"Forwarded": {`for=198.51.100.9;proto=https, for="[2001:db8::1]:80"`}},
+			want:    "2001:db8::1",
+		},
+		{
+			name:   "forwarded takes precedence",
+			remote: "10.0.0.2:4000",
+			headers: map[string][]string{
+				"Forwarded":       {"for=198.51.100.9"},
+				"X-Forwarded-For": {"198.51.100.10"},
+			},
+			want: "198.51.100.9",
+		},
+		{
+			name:    "x-real-ip",
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Real-Ip": {"198.51.100.9"}},
+			want:    "198.51.100.9",
+		},
+		{
+			name:    "garbage stops the walk",
+			remote:  "10.0.0.2:4000",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.9, unknown"}},
+			want:    "10.0.0.2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remote
+			for name, values := range test.headers {
+				for _, value := range values {
+					r.Header.Add(name, value)
+				}
+			}
+			if got := auth.ClientIP(r); got != test.want {
+				t.Errorf("ClientIP() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseHostIP(t *testing.T) {
+	for address, want := range map[string]string{
+		"198.51.100.9":      "198.51.100.9",
+		"198.51.100.9:80":   "198.51.100.9",
+		"[2001:db8::1]:443": "2001:db8::1",
+		"[2001:db8::1]":     "2001:db8::1",
+		"2001:db8::1":       "2001:db8::1",
+		"example.com:80":    "",
+		"":                  "",
+	} {
+		var got string
+		if ip := parseHostIP(address); ip != nil {
+			got = ip.String()
+		}
+		if got != want {
+			t.Errorf("parseHostIP(%q) = %q, want %q", address, got, want)
+		}
+	}
+}
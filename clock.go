@@ -0,0 +1,50 @@
+package pager
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const timestampLayout = "2006-01-02 15:04:05"
+
+type Clock interface {
+	Now() time.Time
+}
+
+type DefaultClock struct{}
+
+func (c *DefaultClock) Now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+var clock Clock = &DefaultClock{}
+var mutexClockLock = &sync.Mutex{}
+
+func setClock(c Clock) {
+	mutexClockLock.Lock()
+	clock = c
+	mutexClockLock.Unlock()
+}
+
+// timestamp scans DATETIME/TIMESTAMP columns whether or not the connection uses parseTime=true
+type timestamp struct {
+	t *time.Time
+}
+
+func (ts timestamp) Scan(value interface{}) error {
+	var err error
+	switch v := value.(type) {
+	case nil:
+		*ts.t = time.Time{}
+	case time.Time:
+		*ts.t = v
+	case []byte:
+		*ts.t, err = time.Parse(timestampLayout, string(v))
+	case string:
+		*ts.t, err = time.Parse(timestampLayout, v)
+	default:
+		err = fmt.Errorf("unsupported timestamp type %T", value)
+	}
+	return err
+}
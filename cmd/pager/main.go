@@ -0,0 +1,352 @@
+// Command pager manages the pager schema and its users from the terminal.
+//
+//	pager [flags] migrate up|down|status
+//	pager [flags] user create -email EMAIL -username USERNAME [-roles a,b] [-type human|service]
+//	pager [flags] role assign|revoke USER ROLE
+//	pager [flags] permission list [-page N] [-size N]
+//	pager [flags] permission import [-prefix /api] [-skip-missing] OPENAPI.json
+//	pager [flags] policy plan|apply POLICY.json
+//	pager [flags] hash-password [PASSWORD]
+//
+// The passwords are read from the first line of stdin when omitted. The flags default to the
+// PAGER_DSN, PAGER_PRIMARY_KEY, PAGER_REDIS_ADDR, PAGER_REDIS_PASSWORD, PAGER_PASSWORD_PEPPER and
+// PAGER_PASSWORD_COST environment variables
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dhanarJkusuma/pager/v2"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// cliActor is recorded as the actor of the audited mutations
+const cliActor = "cli"
+
+var primaryKeys = map[string]pager.PrimaryKeyType{
+	"auto_increment": pager.AutoIncrementKey,
+	"uuid":           pager.UUIDKey,
+	"ulid":           pager.ULIDKey,
+	"snowflake":      pager.SnowflakeKey,
+}
+
+var errUsage = errors.New("usage: pager [flags] migrate|user|role|permission|policy|hash-password ...")
+
+type config struct {
+	dsn           string
+	primaryKey    string
+	redisAddr     string
+	redisPassword string
+	pepper        string
+	cost          int
+	snowflakeNode int64
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.dsn, "dsn", os.Getenv("PAGER_DSN"), "MySQL data source name, e.g. user:pass@tcp(localhost:3306)/app?parseTime=true")
+	flag.StringVar(&cfg.primaryKey, "primary-key", envOr("PAGER_PRIMARY_KEY", "auto_increment"), "auto_increment, uuid, ulid or snowflake")
+	flag.StringVar(&cfg.redisAddr, "redis-addr", os.Getenv("PAGER_REDIS_ADDR"), "redis address of the session store")
+	flag.StringVar(&cfg.redisPassword, "redis-password", os.Getenv("PAGER_REDIS_PASSWORD"), "redis password")
+	flag.StringVar(&cfg.pepper, "pepper", os.Getenv("PAGER_PASSWORD_PEPPER"), "password pepper, see Options.PasswordPepper")
+	cost, _ := strconv.Atoi(os.Getenv("PAGER_PASSWORD_COST"))
+	flag.IntVar(&cfg.cost, "cost", cost, "bcrypt cost, see Options.PasswordCost")
+	snowflakeNode, err := strconv.ParseInt(os.Getenv("PAGER_SNOWFLAKE_NODE"), 10, 64)
+	if err != nil {
+		snowflakeNode = -1
+	}
+	flag.Int64Var(&cfg.snowflakeNode, "snowflake-node", snowflakeNode, "node id of the snowflake primary keys, 0 to 1023")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), errUsage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(cfg, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if err == errUsage {
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(cfg config, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	if args[0] == "hash-password" {
+		return hashPassword(cfg, args[1:])
+	}
+
+	rbac, db, err := cfg.buildPager()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := pager.WithActor(context.Background(), cliActor)
+	switch args[0] {
+	case "migrate":
+		return migrate(rbac, args[1:])
+	case "user":
+		return userCommand(ctx, rbac, args[1:])
+	case "role":
+		return roleCommand(ctx, args[1:])
+	case "permission":
+		return permissionCommand(ctx, rbac, args[1:])
+	case "policy":
+		return policyCommand(ctx, rbac, args[1:])
+	}
+	return errUsage
+}
+
+func (c config) buildPager() (*pager.Pager, *sql.DB, error) {
+	if c.dsn == "" {
+		return nil, nil, errors.New("missing -dsn or PAGER_DSN")
+	}
+	primaryKey, ok := primaryKeys[c.primaryKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown primary key %q", c.primaryKey)
+	}
+	if primaryKey == pager.SnowflakeKey && c.snowflakeNode < 0 {
+		return nil, nil, errors.New("missing -snowflake-node or PAGER_SNOWFLAKE_NODE")
+	}
+	db, err := sql.Open(pager.MYSQLDialect, c.dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	opts := &pager.Options{
+		DbConnection:   db,
+		Dialect:        pager.MYSQLDialect,
+		PrimaryKey:     primaryKey,
+		PasswordPepper: []byte(c.pepper),
+		PasswordCost:   c.cost,
+	}
+	if primaryKey == pager.SnowflakeKey {
+		opts.SnowflakeNodeID = &c.snowflakeNode
+	}
+	if c.redisAddr != "" {
+		opts.Redis = &pager.RedisOptions{Addr: c.redisAddr, Password: c.redisPassword}
+	}
+	return pager.NewPager(opts).BuildPager(), db, nil
+}
+
+func migrate(rbac *pager.Pager, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	switch args[0] {
+	case "up":
+		if err := rbac.Migration.InitDBMigration(); err != nil {
+			return err
+		}
+		fmt.Println("migrated")
+		return nil
+	case "down":
+		rbac.Migration.ClearMigration()
+		return nil
+	case "status":
+		if err := rbac.Migration.CheckMigration(); err != nil {
+			return err
+		}
+		fmt.Println("up to date")
+		return nil
+	}
+	return errUsage
+}
+
+func userCommand(ctx context.Context, rbac *pager.Pager, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return errUsage
+	}
+	flags := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := flags.String("email", "", "email of the user")
+	username := flags.String("username", "", "username of the user")
+	password := flags.String("password", "", "password, read from stdin when empty")
+	roles := flags.String("roles", "", "comma-separated names of the roles to assign")
+	userType := flags.String("type", string(pager.UserTypeHuman), "human or service")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *password == "" {
+		line, err := readLine()
+		if err != nil {
+			return err
+		}
+		*password = line
+	}
+
+	user := &pager.User{
+		Email:    *email,
+		Username: *username,
+		Password: *password,
+		Type:     pager.UserType(*userType),
+	}
+	opts := pager.RegisterOptions{CheckUnique: true, DefaultRoles: splitList(*roles)}
+	if err := rbac.Auth.RegisterWithOptions(ctx, user, opts); err != nil {
+		return err
+	}
+	fmt.Println(user.ID)
+	return nil
+}
+
+func roleCommand(ctx context.Context, args []string) error {
+	if len(args) != 3 || (args[0] != "assign" && args[0] != "revoke") {
+		return errUsage
+	}
+	user, err := pager.FindUserByUsernameOrEmailWithContext(ctx, args[1], nil)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return pager.ErrUserNotFound
+	}
+	role, err := pager.GetRoleContext(ctx, args[2], nil)
+	if err != nil {
+		return err
+	}
+	if role == nil {
+		return pager.ErrRoleNotFound
+	}
+
+	if args[0] == "assign" {
+		return role.AssignWithContext(ctx, user)
+	}
+	return role.RevokeWithContext(ctx, user)
+}
+
+func permissionCommand(ctx context.Context, rbac *pager.Pager, args []string) error {
+	if len(args) > 0 && args[0] == "import" {
+		return importOpenAPI(ctx, rbac, args[1:])
+	}
+	if len(args) == 0 || args[0] != "list" {
+		return errUsage
+	}
+	flags := flag.NewFlagSet("permission list", flag.ContinueOnError)
+	page := flags.Int64("page", 1, "page number")
+	size := flags.Int64("size", 100, "page size")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	permissions, err := pager.FindPermissions(ctx, *page, *size, nil)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tMETHOD\tROUTE\tDESCRIPTION")
+	for _, permission := range permissions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", permission.Name, permission.Method, permission.Route, permission.Description)
+	}
+	return w.Flush()
+}
+
+func importOpenAPI(ctx context.Context, rbac *pager.Pager, args []string) error {
+	flags := flag.NewFlagSet("permission import", flag.ContinueOnError)
+	prefix := flags.String("prefix", "", "prepended to the paths of the document")
+	skipMissing := flags.Bool("skip-missing", false, "skip the operations without operationId")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+
+	seed, err := rbac.Migration.ImportOpenAPI(ctx, flags.Arg(0), pager.OpenAPIImportOptions{
+		Prefix:                 *prefix,
+		SkipMissingOperationID: *skipMissing,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d permissions\n", len(seed.Permissions))
+	return nil
+}
+
+// policyCommand prints the plan of the policy file, apply then executes it
+func policyCommand(ctx context.Context, rbac *pager.Pager, args []string) error {
+	if len(args) != 2 || (args[0] != "plan" && args[0] != "apply") {
+		return errUsage
+	}
+	plan, err := rbac.Migration.PlanPolicyFile(ctx, args[1])
+	if err != nil {
+		return err
+	}
+	if plan.Empty() {
+		fmt.Println("no changes")
+		return nil
+	}
+	fmt.Print(plan)
+	if args[0] == "plan" {
+		return nil
+	}
+	if err = rbac.Migration.ApplyPolicy(ctx, plan); err != nil {
+		return err
+	}
+	fmt.Printf("applied %d changes\n", len(plan.Changes))
+	return nil
+}
+
+func hashPassword(cfg config, args []string) error {
+	var password string
+	switch len(args) {
+	case 0:
+		line, err := readLine()
+		if err != nil {
+			return err
+		}
+		password = line
+	case 1:
+		password = args[0]
+	default:
+		return errUsage
+	}
+	strategy := &pager.DefaultBcryptPassword{Cost: cfg.cost, Pepper: []byte(cfg.pepper)}
+	hashed, err := strategy.Hash(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hashed)
+	return nil
+}
+
+func readLine() (string, error) {
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", errors.New("empty password on stdin")
+	}
+	return line, nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
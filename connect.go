@@ -0,0 +1,127 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const defaultMySQLPort = "3306"
+
+var (
+	ErrUnsupportedDriver = errors.New("unsupported database driver")
+	ErrMissingHost       = errors.New("missing database host")
+)
+
+// Config describes the database of Connect, the DSN is built from it
+type Config struct {
+	// Driver is the registered database/sql driver, MYSQLDialect when empty. pager imports
+	// github.com/go-sql-driver/mysql, the packages of the other drivers are imported by the application
+	Driver string
+	// Host is a host, a host:port (3306 when omitted) or the path of a unix socket
+	Host   string
+	User   string
+	Pass   string
+	DBName string
+	// Params are added to the DSN, parseTime=true is set unless overridden
+	Params map[string]string
+	// Options configures the pager, DbConnection and Dialect are set by Connect
+	Options *Options
+}
+
+func (c Config) driver() string {
+	if c.Driver == "" {
+		return MYSQLDialect
+	}
+	return c.Driver
+}
+
+// DSN returns the data source name of the configured database
+func (c Config) DSN() (string, error) {
+	if c.driver() != MYSQLDialect {
+		return "", ErrUnsupportedDriver
+	}
+	if c.Host == "" {
+		return "", ErrMissingHost
+	}
+
+	var dsn strings.Builder
+	if c.User != "" {
+		dsn.WriteString(c.User)
+		if c.Pass != "" {
+			dsn.WriteString(":" + c.Pass)
+		}
+		dsn.WriteString("@")
+	}
+	if strings.HasPrefix(c.Host, "/") {
+		dsn.WriteString("unix(" + c.Host + ")")
+	} else if _, _, err := net.SplitHostPort(c.Host); err == nil {
+		dsn.WriteString("tcp(" + c.Host + ")")
+	} else {
+		dsn.WriteString("tcp(" + net.JoinHostPort(c.Host, defaultMySQLPort) + ")")
+	}
+	dsn.WriteString("/" + c.DBName)
+
+	params := map[string]string{"parseTime": "true"}
+	for key, value := range c.Params {
+		params[key] = value
+	}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		if i == 0 {
+			dsn.WriteString("?")
+		} else {
+			dsn.WriteString("&")
+		}
+		dsn.WriteString(key + "=" + url.QueryEscape(params[key]))
+	}
+	return dsn.String(), nil
+}
+
+// Connect opens and pings the configured database and returns the built pager, Pager.Close closes the connection.
+// The migration scripts are split into statements, multiStatements isn't needed in Params
+func Connect(cfg Config) (*Pager, error) {
+	return ConnectWithContext(context.Background(), cfg)
+}
+
+func ConnectWithContext(ctx context.Context, cfg Config) (*Pager, error) {
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(cfg.driver(), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	opts := &Options{}
+	if cfg.Options != nil {
+		copied := *cfg.Options
+		opts = &copied
+	}
+	opts.DbConnection = db
+	opts.Dialect = cfg.driver()
+	p := NewPager(opts).BuildPager()
+	p.db = db
+	return p, nil
+}
+
+// Close closes the database connection opened by Connect, it does nothing for the pagers given a DbConnection
+func (p *Pager) Close() error {
+	if p.db == nil {
+		return nil
+	}
+	return p.db.Close()
+}
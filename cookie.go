@@ -0,0 +1,110 @@
+package pager
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidOrigin    = errors.New("invalid origin, expected an absolute http(s) URL")
+	ErrOriginNotAllowed = errors.New("origin is not allowed")
+)
+
+// cookieDomainFromOrigin returns the host of origin, e.g. https://example.com:8443 gives example.com
+func cookieDomainFromOrigin(origin string) (string, error) {
+	parsed, err := url.Parse(origin)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return "", ErrInvalidOrigin
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if net.ParseIP(host) != nil {
+		// cookies can't be shared across the "subdomains" of an IP address
+		return "", ErrInvalidOrigin
+	}
+	return host, nil
+}
+
+// sessionCookie is the session cookie holding value, secure tells whether the request came over HTTPS
+func (a *Auth) sessionCookie(value string, maxAge int, secure bool) *http.Cookie {
+	sameSite := a.cookieSameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	cookie := &http.Cookie{
+		Name:     a.SessionName,
+		Value:    value,
+		Path:     "/",
+		Domain:   a.cookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		SameSite: sameSite,
+		// the browsers drop the SameSite=None cookies that aren't Secure
+		Secure: secure || a.secureCookie || sameSite == http.SameSiteNoneMode,
+	}
+	if maxAge >= 0 {
+		cookie.Expires = clock.Now().Add(time.Duration(a.expiredInSeconds) * time.Second)
+	}
+	return cookie
+}
+
+// AllowedHost reports whether host (with an optional port) is the cookie domain or one of its subdomains,
+// every host is allowed when the session cookie isn't shared, the origin checks then compare the host of the request
+func (a *Auth) AllowedHost(host string) bool {
+	if a.cookieDomain == "" {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == a.cookieDomain || strings.HasSuffix(host, "."+a.cookieDomain)
+}
+
+// allowedOrigin validates the Origin header of cross-origin requests against the cookie domain,
+// requests without an Origin header are same-origin
+func (a *Auth) allowedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return a.allowedOriginHost(r, origin)
+}
+
+// allowedFormOrigin is allowedOrigin for the forms changing the session, it falls back to the Referer header
+// as the browsers omitting Origin still send it
+func (a *Auth) allowedFormOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	return a.allowedOriginHost(r, origin)
+}
+
+// allowedOriginHost checks the host of origin against the cookie domain. Without a cookie domain the host must be
+// the host of r or of SessionOptions.Origin
+func (a *Auth) allowedOriginHost(r *http.Request, origin string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	if a.cookieDomain != "" {
+		return a.AllowedHost(parsed.Host)
+	}
+	if sameHost(parsed.Host, r.Host) {
+		return true
+	}
+	configured, err := url.Parse(a.origin)
+	return err == nil && configured.Host != "" && sameHost(parsed.Host, configured.Host)
+}
+
+// sameHost compares two hosts with their optional ports, case-insensitively
+func sameHost(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
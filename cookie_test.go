@@ -0,0 +1,89 @@
+package pager
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionCookieAttributes(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		auth         *Auth
+		remote       string
+		tls          bool
+		proto        string
+		wantSecure   bool
+		wantSameSite http.SameSite
+	}{
+		{name: "http", remote: "203.0.113.7:4000", wantSameSite: http.SameSiteLaxMode},
+		{name: "https", remote: "203.0.113.7:4000", tls: true, wantSecure: true, wantSameSite: http.SameSiteLaxMode},
+		{
+			name:         "trusted proxy forwarding https",
+			auth:         &Auth{trustedProxies: proxies},
+			remote:       "10.0.0.2:4000",
+			proto:        "https",
+			wantSecure:   true,
+			wantSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:         "untrusted proxy forwarding https",
+			auth:         &Auth{trustedProxies: proxies},
+			remote:       "203.0.113.7:4000",
+			proto:        "https",
+			wantSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:         "secure cookie option",
+			auth:         &Auth{secureCookie: true},
+			remote:       "203.0.113.7:4000",
+			wantSecure:   true,
+			wantSameSite: http.SameSiteLaxMode,
+		},
+		{
+			name:         "same site none",
+			auth:         &Auth{cookieSameSite: http.SameSiteNoneMode},
+			remote:       "203.0.113.7:4000",
+			wantSecure:   true,
+			wantSameSite: http.SameSiteNoneMode,
+		},
+		{
+			name:         "same site strict",
+			auth:         &Auth{cookieSameSite: http.SameSiteStrictMode},
+			remote:       "203.0.113.7:4000",
+			wantSameSite: http.SameSiteStrictMode,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.auth == nil {
+				test.auth = &Auth{}
+			}
+			r := httptest.NewRequest(http.MethodPost, "/login", nil)
+			r.RemoteAddr = test.remote
+			if test.tls {
+				r.TLS = &tls.ConnectionState{}
+			}
+			if test.proto != "" {
+				r.Header.Set("X-Forwarded-Proto", test.proto)
+			}
+			params := test.auth.LoginParamsFromRequest(r, "john@example.com", "secret")
+			cookie := test.auth.sessionCookie("token", 0, params.Secure)
+
+			if !cookie.HttpOnly {
+				t.Error("the session cookie isn't HttpOnly")
+			}
+			if cookie.Secure != test.wantSecure {
+				t.Errorf("Secure = %v, want %v", cookie.Secure, test.wantSecure)
+			}
+			if cookie.SameSite != test.wantSameSite {
+				t.Errorf("SameSite = %v, want %v", cookie.SameSite, test.wantSameSite)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package pager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// maxCredentialLength bounds the tokens read from the Authorization header and the session cookie,
+// the stateless tokens carrying the roles are the longest ones
+const maxCredentialLength = 8192
+
+// The credential errors wrap ErrInvalidAuthorization and ErrInvalidCookie, so they map to the same status and message
+var (
+	ErrEmptyAuthorization  = fmt.Errorf("%w: empty header", ErrInvalidAuthorization)
+	ErrAuthorizationScheme = fmt.Errorf("%w: unsupported scheme", ErrInvalidAuthorization)
+	ErrEmptyCredential     = fmt.Errorf("%w: empty token", ErrInvalidAuthorization)
+	ErrCredentialTooLong   = fmt.Errorf("%w: token is too long", ErrInvalidAuthorization)
+	ErrCredentialCharacter = fmt.Errorf("%w: token holds a forbidden character", ErrInvalidAuthorization)
+	ErrEmptyCookie         = fmt.Errorf("%w: empty value", ErrInvalidCookie)
+	ErrCookieTooLong       = fmt.Errorf("%w: value is too long", ErrInvalidCookie)
+	ErrCookieCharacter     = fmt.Errorf("%w: value holds a forbidden character", ErrInvalidCookie)
+	ErrEmptyQueryParams    = errors.New("query params are empty")
+	ErrInvalidQueryColumn  = errors.New("invalid query column")
+)
+
+// bearerToken extracts the token of an Authorization header value, the scheme must be Bearer (case-insensitive)
+// and the token must be a non-empty run of visible ASCII characters
+func bearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrEmptyAuthorization
+	}
+	if len(header) > maxCredentialLength+len("Bearer ") {
+		return "", ErrCredentialTooLong
+	}
+	separator := strings.IndexByte(header, ' ')
+	if separator < 0 && strings.EqualFold(header, "Bearer") {
+		return "", ErrEmptyCredential
+	}
+	if separator <= 0 || !strings.EqualFold(header[:separator], "Bearer") {
+		return "", ErrAuthorizationScheme
+	}
+	token := strings.TrimLeft(header[separator+1:], " ")
+	if token == "" {
+		return "", ErrEmptyCredential
+	}
+	if !visibleASCII(token) {
+		return "", ErrCredentialCharacter
+	}
+	return token, nil
+}
+
+// legacyAuthorizationToken extracts the token of the Authorization headers accepted before the Bearer scheme was required:
+// the token alone, or the token behind any scheme. The token is held to the rules of bearerToken
+func legacyAuthorizationToken(header string) (string, error) {
+	token, err := bearerToken(header)
+	if err != ErrAuthorizationScheme {
+		return token, err
+	}
+	token = header
+	if separator := strings.IndexByte(header, ' '); separator >= 0 {
+		token = strings.TrimLeft(header[separator+1:], " ")
+	}
+	if token == "" {
+		return "", ErrEmptyCredential
+	}
+	if len(token) > maxCredentialLength {
+		return "", ErrCredentialTooLong
+	}
+	if !visibleASCII(token) {
+		return "", ErrCredentialCharacter
+	}
+	return token, nil
+}
+
+// requestBearerToken reads the token of the Authorization header of r, see SessionOptions.LegacyAuthorization
+func (a *Auth) requestBearerToken(r *http.Request) (string, error) {
+	if a.legacyAuthorization {
+		return legacyAuthorizationToken(r.Header.Get(authorization))
+	}
+	return bearerToken(r.Header.Get(authorization))
+}
+
+// cookieToken validates the value of the session cookie
+func cookieToken(value string) (string, error) {
+	if value == "" {
+		return "", ErrEmptyCookie
+	}
+	if len(value) > maxCredentialLength {
+		return "", ErrCookieTooLong
+	}
+	if !visibleASCII(value) {
+		return "", ErrCookieCharacter
+	}
+	return value, nil
+}
+
+// visibleASCII rejects the control characters, the spaces and the non-ASCII bytes, none of the
+// token generators of pager produce them
+func visibleASCII(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] <= ' ' || value[i] >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// userQueryColumns are the columns FindUser filters on
+var userQueryColumns = map[string]bool{
+	"id":         true,
+	"email":      true,
+	"username":   true,
+	"active":     true,
+	"type":       true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// queryConditions builds the AND-ed equality conditions of params, the columns are checked against
+// allowed and sorted so the query text is stable
+func queryConditions(params map[string]interface{}, allowed map[string]bool) (string, []interface{}, error) {
+	if len(params) == 0 {
+		return "", nil, ErrEmptyQueryParams
+	}
+	columns := make([]string, 0, len(params))
+	for column := range params {
+		if !allowed[column] {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidQueryColumn, column)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	conditions := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		conditions[i] = column + " = ?"
+		values[i] = params[column]
+	}
+	return strings.Join(conditions, " AND "), values, nil
+}
@@ -0,0 +1,175 @@
+//go:build go1.18
+// +build go1.18
+
+package pager
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		err    error
+	}{
+		{"Bearer abc.def", "abc.def", nil},
+		{"bearer   abc", "abc", nil},
+		{"BEARER abc", "abc", nil},
+		{"", "", ErrEmptyAuthorization},
+		{"Bearer", "", ErrEmptyCredential},
+		{"Bearer ", "", ErrEmptyCredential},
+		{"abc", "", ErrAuthorizationScheme},
+		{"Token abc", "", ErrAuthorizationScheme},
+		{" Bearer abc", "", ErrAuthorizationScheme},
+		{"Bearer a b", "", ErrCredentialCharacter},
+		{"Bearer abc\x00", "", ErrCredentialCharacter},
+		{"Bearer " + strings.Repeat("a", maxCredentialLength+1), "", ErrCredentialTooLong},
+	}
+	for _, test := range tests {
+		token, err := bearerToken(test.header)
+		if token != test.token || err != test.err {
+			t.Errorf("bearerToken(%.20q) = %q, %v, want %q, %v", test.header, token, err, test.token, test.err)
+		}
+	}
+}
+
+func TestLegacyAuthorization(t *testing.T) {
+	tests := []struct {
+		header string
+		legacy bool
+		token  string
+		err    error
+	}{
+		{"Bearer abc", false, "abc", nil},
+		{"abc", false, "", ErrAuthorizationScheme},
+		{"Bearer abc", true, "abc", nil},
+		{"abc", true, "abc", nil},
+		{"Token abc", true, "abc", nil},
+		{"Token a b", true, "", ErrCredentialCharacter},
+		{"Token ", true, "", ErrEmptyCredential},
+		{"", true, "", ErrEmptyAuthorization},
+		{"abc\x7f", true, "", ErrCredentialCharacter},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set(authorization, test.header)
+		a := &Auth{legacyAuthorization: test.legacy}
+		token, err := a.requestBearerToken(r)
+		if token != test.token || err != test.err {
+			t.Errorf("requestBearerToken(%q) with legacy %v = %q, %v, want %q, %v", test.header, test.legacy, token, err, test.token, test.err)
+		}
+	}
+}
+
+func FuzzBearerToken(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"Bearer",
+		"Bearer ",
+		"Bearer abc",
+		"bearer    abc.def-ghi_jkl",
+		"Basic dXNlcjpwYXNz",
+		"abc",
+		" Bearer abc",
+		"Bearer a b",
+		"Bearer \t",
+		"Bearer \x00abc",
+		"Bearer é",
+		"Bearer " + strings.Repeat("a", maxCredentialLength),
+		"Bearer " + strings.Repeat("a", maxCredentialLength+1),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, header string) {
+		check := func(name, token string, err error) {
+			if err != nil {
+				if token != "" || !errors.Is(err, ErrInvalidAuthorization) {
+					t.Fatalf("%s(%q) = %q, %v, want an ErrInvalidAuthorization", name, header, token, err)
+				}
+				return
+			}
+			if token == "" || len(token) > maxCredentialLength || !visibleASCII(token) || !strings.HasSuffix(header, token) {
+				t.Fatalf("%s(%q) = %q, not a visible ASCII suffix of the header", name, header, token)
+			}
+		}
+
+		token, err := bearerToken(header)
+		check("bearerToken", token, err)
+		if err == nil && !strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+			t.Fatalf("bearerToken(%q) = %q without the Bearer scheme", header, token)
+		}
+
+		legacyToken, legacyErr := legacyAuthorizationToken(header)
+		check("legacyAuthorizationToken", legacyToken, legacyErr)
+		if err == nil && (legacyToken != token || legacyErr != nil) {
+			t.Fatalf("legacyAuthorizationToken(%q) = %q, %v, want the bearer token %q", header, legacyToken, legacyErr, token)
+		}
+	})
+}
+
+func FuzzCookieToken(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"abc",
+		"a b",
+		"abc;def",
+		"abc\r\n",
+		"\xff\xfe",
+		strings.Repeat("a", maxCredentialLength),
+		strings.Repeat("a", maxCredentialLength+1),
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		token, err := cookieToken(value)
+		if err != nil {
+			if token != "" || !errors.Is(err, ErrInvalidCookie) {
+				t.Fatalf("cookieToken(%q) = %q, %v, want an ErrInvalidCookie", value, token, err)
+			}
+			return
+		}
+		if token != value || len(token) > maxCredentialLength || !visibleASCII(token) {
+			t.Fatalf("cookieToken(%q) = %q, want the value unchanged", value, token)
+		}
+	})
+}
+
+func FuzzQueryConditions(f *testing.F) {
+	f.Add("email", "john@example.com", "", "")
+	f.Add("id", "1", "username", "john")
+	f.Add("active", "1", "type", "service")
+	f.Add("email = '' OR 1=1 --", "x", "", "")
+	f.Add("username", "' OR '1'='1", "id`", "1")
+	f.Add("", "", "", "")
+	f.Fuzz(func(t *testing.T, firstColumn, firstValue, secondColumn, secondValue string) {
+		params := map[string]interface{}{firstColumn: firstValue}
+		if secondColumn != "" {
+			params[secondColumn] = secondValue
+		}
+
+		conditions, values, err := queryConditions(params, userQueryColumns)
+		if err != nil {
+			if !errors.Is(err, ErrInvalidQueryColumn) || (userQueryColumns[firstColumn] && (secondColumn == "" || userQueryColumns[secondColumn])) {
+				t.Fatalf("queryConditions(%q) = %v", params, err)
+			}
+			return
+		}
+		parts := strings.Split(conditions, " AND ")
+		if len(parts) != len(params) || len(values) != len(params) {
+			t.Fatalf("queryConditions(%q) = %q with %d values, want %d conditions", params, conditions, len(values), len(params))
+		}
+		for i, part := range parts {
+			column := strings.TrimSuffix(part, " = ?")
+			if !userQueryColumns[column] || values[i] != params[column] {
+				t.Fatalf("queryConditions(%q) = %q, %q: condition %q isn't an allowed column bound to its value", params, conditions, values, part)
+			}
+			if i > 0 && parts[i-1] >= part {
+				t.Fatalf("queryConditions(%q) = %q, the columns aren't sorted", params, conditions)
+			}
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package pager
+
+import "strings"
+
+// Description is the non-secret configuration of the deployment, for the generic admin frontends
+type Description struct {
+	Dialect string `json:"dialect"`
+	Schema  string `json:"schema,omitempty"`
+	// Tables maps the entities into their table names, e.g. "user" into "rbac_user"
+	Tables      map[string]string  `json:"tables"`
+	PrimaryKey  string             `json:"primary_key"`
+	LoginMethod string             `json:"login_method"`
+	Session     SessionDescription `json:"session"`
+	// Features lists the optional features and whether they are enabled
+	Features map[string]bool `json:"features"`
+}
+
+type SessionDescription struct {
+	Name                    string `json:"name"`
+	Origin                  string `json:"origin,omitempty"`
+	CookieDomain            string `json:"cookie_domain,omitempty"`
+	ExpiredInSeconds        int64  `json:"expired_in_seconds"`
+	RefreshExpiredInSeconds int64  `json:"refresh_expired_in_seconds"`
+	Principal               string `json:"principal"`
+	GenericLoginError       bool   `json:"generic_login_error"`
+}
+
+var primaryKeyNames = map[PrimaryKeyType]string{
+	AutoIncrementKey: "auto_increment",
+	UUIDKey:          "uuid",
+	ULIDKey:          "ulid",
+	SnowflakeKey:     "snowflake",
+}
+
+var principalModeNames = map[PrincipalMode]string{
+	PrincipalFull: "full",
+	PrincipalSlim: "slim",
+	PrincipalLazy: "lazy",
+}
+
+// Describe returns the table names, the dialect, the login method, the session settings and the enabled features
+func (p *Pager) Describe() Description {
+	description := p.description
+	description.Tables = make(map[string]string, len(p.description.Tables))
+	for entity, table := range p.description.Tables {
+		description.Tables[entity] = table
+	}
+	description.Features = make(map[string]bool, len(p.description.Features))
+	for feature, enabled := range p.description.Features {
+		description.Features[feature] = enabled
+	}
+	return description
+}
+
+func (p *pagerBuilder) describe(auth *Auth) Description {
+	opts := p.pagerOptions
+	tables := make(map[string]string, len(existTable))
+	for table := range existTable {
+		tables[strings.TrimPrefix(table, "rbac_")] = table
+	}
+	_, allEnforced := p.permissionFlags.(nopPermissionFlags)
+
+	return Description{
+		Dialect:     opts.Dialect,
+		Schema:      opts.SchemaName,
+		Tables:      tables,
+		PrimaryKey:  primaryKeyNames[opts.PrimaryKey],
+		LoginMethod: auth.loginMethod.String(),
+		Session: SessionDescription{
+			Name:                    auth.SessionName,
+			Origin:                  auth.origin,
+			CookieDomain:            auth.cookieDomain,
+			ExpiredInSeconds:        auth.expiredInSeconds,
+			RefreshExpiredInSeconds: int64(auth.refreshExpiration().Seconds()),
+			Principal:               principalModeNames[auth.principalMode],
+			GenericLoginError:       auth.genericLoginError,
+		},
+		Features: map[string]bool{
+			"session_encryption": auth.sessionCipher != nil,
+			"login_throttle":     auth.loginThrottle != nil,
+			"notifications":      auth.notifications != nil,
+			"breached_passwords": auth.breachedPasswords != nil,
+			"password_pepper":    len(opts.PasswordPepper) > 0,
+			"login_pages":        auth.loginPages != nil,
+			"trusted_proxies":    len(auth.trustedProxies) > 0,
+			"request_id":         auth.requestID != nil,
+			"permission_cache":   opts.PermissionCacheTTL > 0,
+			"permission_rollout": p.permissionFlags != nil && !allEnforced,
+			"username_policy":    opts.UsernamePolicy != nil,
+			"dual_control":       opts.DualControl != nil,
+			"query_log":          opts.QueryLog != nil,
+			"retry":              opts.Retry != nil,
+		},
+	}
+}
@@ -0,0 +1,507 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const defaultPendingOperationTTL = 24 * time.Hour
+
+// Constants for the operations guarded by the dual control
+const (
+	OperationDeleteRole           = "role.delete"
+	OperationRevokeRole           = "role.revoke"
+	OperationRevokeGroupRole      = "group.role.revoke"
+	OperationClearRolePermissions = "role.permissions.clear"
+)
+
+// Constants for pending operation status
+const (
+	PendingOperationPending  = "pending"
+	PendingOperationExecuted = "executed"
+	PendingOperationRejected = "rejected"
+)
+
+// Constants for pending operation audit actions
+const (
+	AuditOperationRequested = "operation.requested"
+	AuditOperationConfirmed = "operation.confirmed"
+	AuditOperationRejected  = "operation.rejected"
+)
+
+var (
+	ErrDualControlRequired       = errors.New("operation requires the confirmation of a second user")
+	ErrInvalidPendingOperationID = errors.New("invalid pending operation id")
+	ErrPendingOperationDecided   = errors.New("pending operation has already been decided or has expired")
+	ErrSameApprover              = errors.New("operation must be confirmed by another user than its requester")
+	ErrActorRequired             = errors.New("operation requires an authenticated actor")
+)
+
+// DualControlOptions requires a second user to confirm the destructive operations, see Role.RequestDelete,
+// Role.RequestRevoke, Role.RequestRemoveAllPermissions and Group.RequestRevokeRole.
+//
+// The other operations removing grants aren't guarded, restrict them to trusted callers:
+//   - User.Delete removes the roles of the user, the protected ones included, deactivate the user instead
+//   - Pager.MergeUsers moves the roles of the duplicate to the primary user, no role is revoked
+//   - ReviewCampaign.Close revokes the roles decided ReviewRevoked, the protected ones included,
+//     the decisions are the second review
+//   - Group.RemoveUser and Group.DeleteGroup take the roles of the group, the protected ones included, from its members
+//   - Permission.RevokeFromAllRoles, Permission.DeletePermission and Role.RemoveChild remove permissions from the roles
+type DualControlOptions struct {
+	// DeleteRole guards Role.DeleteRole and Role.RemoveAllPermissions, which empties the role
+	DeleteRole bool
+	// ProtectedRoles lists the names of the roles whose Role.Revoke and Group.RevokeRole are guarded, e.g. "superadmin"
+	ProtectedRoles []string
+	// TTL is the lifetime of a pending operation, 24 hours when zero
+	TTL time.Duration
+}
+
+// newDualControl copies opts with the default TTL
+func newDualControl(opts *DualControlOptions) *DualControlOptions {
+	if opts == nil {
+		return nil
+	}
+	copied := *opts
+	if copied.TTL <= 0 {
+		copied.TTL = defaultPendingOperationTTL
+	}
+	return &copied
+}
+
+func setDualControl(opts *DualControlOptions) {
+	updateSettings(func(s *settings) {
+		s.dualControl = newDualControl(opts)
+	})
+}
+
+func currentDualControl() *DualControlOptions {
+	return loadSettings().dualControl
+}
+
+type dualControlKey struct{}
+
+// requireDualControl returns ErrDualControlRequired when the operation on role is guarded,
+// the confirmed pending operations execute with a ctx marked by PendingOperation.Confirm
+func requireDualControl(ctx context.Context, db DbContract, operation string, role *Role) error {
+	opts := currentDualControl()
+	if opts == nil {
+		return nil
+	}
+	if approved, _ := ctx.Value(dualControlKey{}).(bool); approved {
+		return nil
+	}
+	switch operation {
+	case OperationDeleteRole, OperationClearRolePermissions:
+		if opts.DeleteRole {
+			return ErrDualControlRequired
+		}
+	case OperationRevokeRole, OperationRevokeGroupRole:
+		if len(opts.ProtectedRoles) == 0 {
+			return nil
+		}
+		name := role.Name
+		if name == "" {
+			err := queryRow(ctx, db, `SELECT name FROM rbac_role WHERE id = ?`, role.ID).Scan(&name)
+			if err != nil && err != sql.ErrNoRows {
+				return err
+			}
+		}
+		for _, protected := range opts.ProtectedRoles {
+			if protected == name {
+				return ErrDualControlRequired
+			}
+		}
+	}
+	return nil
+}
+
+type PendingOperation struct {
+	ID          string    `db:"id" json:"id"`
+	Operation   string    `db:"operation" json:"operation"`
+	RoleID      string    `db:"role_id" json:"role_id"`
+	RoleName    string    `db:"role_name" json:"role_name"`
+	UserID      string    `db:"user_id" json:"user_id,omitempty"`
+	GroupID     string    `db:"group_id" json:"group_id,omitempty"`
+	Status      string    `db:"status" json:"status"`
+	RequestedBy string    `db:"requested_by" json:"requested_by"`
+	ApprovedBy  string    `db:"approved_by" json:"approved_by,omitempty"`
+	ExpiresAt   time.Time `db:"expires_at" json:"expires_at"`
+	DecidedAt   time.Time `db:"decided_at" json:"decided_at,omitempty"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+
+	db DbContract
+}
+
+func (ptx *PagerTx) PendingOperation(operation *PendingOperation) *PendingOperation {
+	operation.db = ptx.db
+	return operation
+}
+
+// RequestDelete queues the deletion of the role until another user confirms it, the requester is the authenticated user in ctx
+func (r *Role) RequestDelete(ctx context.Context) (*PendingOperation, error) {
+	if r.ID == "" {
+		return nil, ErrInvalidRoleID
+	}
+	return r.requestOperation(ctx, OperationDeleteRole, "", "")
+}
+
+// RequestRevoke queues the revocation of the role from u until another user confirms it
+func (r *Role) RequestRevoke(ctx context.Context, u *User) (*PendingOperation, error) {
+	if r.ID == "" {
+		return nil, ErrInvalidRoleID
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+	return r.requestOperation(ctx, OperationRevokeRole, u.ID, "")
+}
+
+// RequestRemoveAllPermissions queues Role.RemoveAllPermissions until another user confirms it
+func (r *Role) RequestRemoveAllPermissions(ctx context.Context) (*PendingOperation, error) {
+	if r.ID == "" {
+		return nil, ErrInvalidRoleID
+	}
+	return r.requestOperation(ctx, OperationClearRolePermissions, "", "")
+}
+
+// RequestRevokeRole queues the revocation of the role from the group until another user confirms it
+func (g *Group) RequestRevokeRole(ctx context.Context, r *Role) (*PendingOperation, error) {
+	if g.ID == "" {
+		return nil, ErrInvalidGroupID
+	}
+	if r.ID == "" {
+		return nil, ErrInvalidRoleID
+	}
+	if r.db == nil {
+		r.db = g.db
+	}
+	return r.requestOperation(ctx, OperationRevokeGroupRole, "", g.ID)
+}
+
+func (r *Role) requestOperation(ctx context.Context, operation, userID, groupID string) (*PendingOperation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	requestedBy := actorFromContext(ctx)
+	if requestedBy == "" {
+		return nil, ErrActorRequired
+	}
+	if r.Name == "" {
+		err := queryRow(ctx, r.db, `SELECT name FROM rbac_role WHERE id = ?`, r.ID).Scan(&r.Name)
+		if err == sql.ErrNoRows {
+			return nil, ErrRoleNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ttl := defaultPendingOperationTTL
+	if opts := currentDualControl(); opts != nil {
+		ttl = opts.TTL
+	}
+	now := clock.Now()
+	pending := &PendingOperation{
+		ID:          newPrimaryKey(),
+		Operation:   operation,
+		RoleID:      r.ID,
+		RoleName:    r.Name,
+		UserID:      userID,
+		GroupID:     groupID,
+		Status:      PendingOperationPending,
+		RequestedBy: requestedBy,
+		ExpiresAt:   now.Add(ttl),
+		CreatedAt:   now,
+		db:          r.db,
+	}
+	insertQuery := `INSERT INTO rbac_pending_operation (
+		id,
+		operation,
+		role_id,
+		role_name,
+		user_id,
+		group_id,
+		status,
+		requested_by,
+		expires_at,
+		created_at) VALUES (?,?,?,?,?,?,?,?,?,?)`
+	result, err := r.db.ExecContext(
+		ctx,
+		insertQuery,
+		primaryKeyValue(pending.ID),
+		pending.Operation,
+		pending.RoleID,
+		pending.RoleName,
+		primaryKeyValue(pending.UserID),
+		primaryKeyValue(pending.GroupID),
+		pending.Status,
+		pending.RequestedBy,
+		pending.ExpiresAt,
+		pending.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	pending.ID, err = insertedID(pending.ID, result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAudit(ctx, r.db, &AuditEntry{
+		ActorID:  requestedBy,
+		Action:   AuditOperationRequested,
+		Target:   pending.ID,
+		Metadata: pending.auditMetadata(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+const pendingOperationColumns = `SELECT
+		id,
+		operation,
+		role_id,
+		role_name,
+		user_id,
+		group_id,
+		status,
+		requested_by,
+		approved_by,
+		expires_at,
+		decided_at,
+		created_at
+	FROM rbac_pending_operation`
+
+func (o *PendingOperation) scanFields() []interface{} {
+	return []interface{}{
+		&o.ID,
+		&o.Operation,
+		&o.RoleID,
+		&o.RoleName,
+		textColumn{&o.UserID},
+		textColumn{&o.GroupID},
+		&o.Status,
+		&o.RequestedBy,
+		textColumn{&o.ApprovedBy},
+		timestamp{&o.ExpiresAt},
+		timestamp{&o.DecidedAt},
+		timestamp{&o.CreatedAt},
+	}
+}
+
+func FindPendingOperation(ctx context.Context, id string, ptx *PagerTx) (*PendingOperation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	pending := &PendingOperation{db: db}
+	err := queryRow(ctx, db, pendingOperationColumns+` WHERE id = ?`, id).Scan(pending.scanFields()...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pending, nil
+}
+
+// FindPendingOperations returns the operations waiting for a confirmation, oldest first
+func FindPendingOperations(ctx context.Context, ptx *PagerTx) ([]PendingOperation, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	result, err := db.QueryContext(
+		ctx,
+		pendingOperationColumns+` WHERE status = ? AND expires_at > ? ORDER BY created_at`,
+		PendingOperationPending,
+		clock.Now(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	operations := make([]PendingOperation, 0)
+	for result.Next() {
+		pending := PendingOperation{db: db}
+		err = result.Scan(pending.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, pending)
+	}
+	return operations, result.Err()
+}
+
+// Confirm executes the operation, the approver is the authenticated user in ctx and must differ from the requester.
+// The operation is executed as stored, in the transaction marking it executed: unless it is bound to a PagerTx
+// with PagerTx.PendingOperation, Confirm runs in its own transaction
+func (o *PendingOperation) Confirm(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if o.db == nil {
+		o.db = dbConnection
+	}
+	if o.ID == "" {
+		return ErrInvalidPendingOperationID
+	}
+	approvedBy := actorFromContext(ctx)
+	if approvedBy == "" {
+		return ErrActorRequired
+	}
+	if approvedBy == o.RequestedBy {
+		return ErrSameApprover
+	}
+	if o.db != dbConnection {
+		return o.confirm(ctx, o.db, approvedBy)
+	}
+
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = o.confirm(ctx, wrapTx(tx), approvedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (o *PendingOperation) confirm(ctx context.Context, db DbContract, approvedBy string) error {
+	err := o.decide(ctx, db, PendingOperationExecuted, approvedBy)
+	if err != nil {
+		return err
+	}
+	// execute what was requested, not the fields of o the caller may have changed
+	stored := &PendingOperation{db: o.db}
+	err = queryRow(ctx, db, pendingOperationColumns+` WHERE id = ?`, o.ID).Scan(stored.scanFields()...)
+	if err != nil {
+		return err
+	}
+	*o = *stored
+
+	role := &Role{ID: o.RoleID, Name: o.RoleName, db: db}
+	approvedCtx := context.WithValue(ctx, dualControlKey{}, true)
+	switch o.Operation {
+	case OperationDeleteRole:
+		err = role.DeleteRoleWithContext(approvedCtx)
+	case OperationRevokeRole:
+		err = role.RevokeWithContext(approvedCtx, &User{ID: o.UserID})
+	case OperationRevokeGroupRole:
+		err = (&Group{ID: o.GroupID, db: db}).RevokeRoleWithContext(approvedCtx, role)
+	case OperationClearRolePermissions:
+		_, err = role.RemoveAllPermissions(approvedCtx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return writeAudit(ctx, db, &AuditEntry{
+		ActorID:  approvedBy,
+		Action:   AuditOperationConfirmed,
+		Target:   o.ID,
+		Metadata: o.auditMetadata(),
+	})
+}
+
+// Reject discards the operation, the requester may reject its own operation to cancel it
+func (o *PendingOperation) Reject(ctx context.Context, reason string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if o.db == nil {
+		o.db = dbConnection
+	}
+	if o.ID == "" {
+		return ErrInvalidPendingOperationID
+	}
+	rejectedBy := actorFromContext(ctx)
+	if rejectedBy == "" {
+		return ErrActorRequired
+	}
+
+	err := o.decide(ctx, o.db, PendingOperationRejected, rejectedBy)
+	if err != nil {
+		return err
+	}
+	return writeAudit(ctx, o.db, &AuditEntry{
+		ActorID:  rejectedBy,
+		Action:   AuditOperationRejected,
+		Target:   o.ID,
+		Reason:   reason,
+		Metadata: o.auditMetadata(),
+	})
+}
+
+// decide moves the operation out of pending, failing when it expired or a concurrent decision came first.
+// The requester can't execute its own operation whatever o says
+func (o *PendingOperation) decide(ctx context.Context, db DbContract, status, decidedBy string) error {
+	decidedAt := clock.Now()
+	updateQuery := `UPDATE rbac_pending_operation
+	SET status = ?, approved_by = ?, decided_at = ?
+	WHERE id = ? AND status = ? AND expires_at > ?`
+	args := []interface{}{status, decidedBy, decidedAt, o.ID, PendingOperationPending, decidedAt}
+	if status == PendingOperationExecuted {
+		updateQuery += ` AND requested_by <> ?`
+		args = append(args, decidedBy)
+	}
+	result, err := db.ExecContext(ctx, updateQuery, args...)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPendingOperationDecided
+	}
+
+	o.Status = status
+	o.ApprovedBy = decidedBy
+	o.DecidedAt = decidedAt
+	return nil
+}
+
+func (o *PendingOperation) auditMetadata() map[string]string {
+	metadata := map[string]string{
+		"operation":    o.Operation,
+		"role":         o.RoleName,
+		"requested_by": o.RequestedBy,
+	}
+	if o.UserID != "" {
+		metadata["user_id"] = o.UserID
+	}
+	if o.GroupID != "" {
+		metadata["group_id"] = o.GroupID
+	}
+	return metadata
+}
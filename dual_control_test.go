@@ -0,0 +1,150 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// pendingOperations stores the pending operation with id 1 and answers its decisions as applied
+func pendingOperations() fakeHandler {
+	var stored []driver.Value
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		query = strings.TrimSpace(query)
+		switch {
+		case strings.HasPrefix(query, "INSERT INTO rbac_pending_operation"):
+			stored = []driver.Value{int64(1)}
+			for _, arg := range args[1:8] {
+				stored = append(stored, arg.Value)
+			}
+			stored = append(stored, nil, args[8].Value, nil, args[9].Value)
+			return fakeResponse{affected: 1, lastInsertID: 1}
+		case strings.HasPrefix(query, "UPDATE rbac_pending_operation"):
+			return fakeResponse{affected: 1}
+		case strings.HasPrefix(query, "SELECT") && strings.Contains(query, "FROM rbac_pending_operation"):
+			return fakeResponse{columns: make([]string, len(stored)), rows: [][]driver.Value{stored}}
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestDualControlGuardsGroupRoleRevocation(t *testing.T) {
+	fake, restore := openFakeDB(t, pendingOperations())
+	defer restore()
+	setDualControl(&DualControlOptions{ProtectedRoles: []string{"superadmin"}})
+	defer setDualControl(nil)
+
+	group := &Group{ID: "7"}
+	superadmin := &Role{ID: "1", Name: "superadmin"}
+	if err := group.RevokeRoleWithContext(context.Background(), superadmin); err != ErrDualControlRequired {
+		t.Fatalf("RevokeRole(superadmin) = %v, want ErrDualControlRequired", err)
+	}
+	if len(fake.executed("DELETE FROM rbac_group_role")) > 0 {
+		t.Fatal("RevokeRole(superadmin) removed the role without a confirmation")
+	}
+	if err := group.RevokeRoleWithContext(context.Background(), &Role{ID: "2", Name: "editor"}); err != nil {
+		t.Fatalf("RevokeRole(editor) = %v", err)
+	}
+
+	pending, err := group.RequestRevokeRole(WithActor(context.Background(), "10"), superadmin)
+	if err != nil {
+		t.Fatalf("RequestRevokeRole() = %v", err)
+	}
+	if pending.Operation != OperationRevokeGroupRole || pending.GroupID != "7" || pending.RoleID != "1" {
+		t.Fatalf("RequestRevokeRole() = %+v", pending)
+	}
+	if inserted := fake.executed("INSERT INTO rbac_pending_operation"); len(inserted) != 1 || inserted[0].args[5].Value != "7" {
+		t.Errorf("RequestRevokeRole() didn't store the group of the operation: %+v", inserted)
+	}
+	if err = pending.Confirm(WithActor(context.Background(), "10")); err != ErrSameApprover {
+		t.Fatalf("Confirm() by the requester = %v, want ErrSameApprover", err)
+	}
+	if err = pending.Confirm(WithActor(context.Background(), "11")); err != nil {
+		t.Fatalf("Confirm() = %v", err)
+	}
+	revoked := fake.executed("DELETE FROM rbac_group_role")
+	if len(revoked) != 2 || revoked[1].args[0].Value != "7" || revoked[1].args[1].Value != "1" {
+		t.Errorf("Confirm() didn't revoke the role from the group: %+v", revoked)
+	}
+}
+
+func TestDualControlGuardsRemoveAllPermissions(t *testing.T) {
+	fake, restore := openFakeDB(t, pendingOperations())
+	defer restore()
+	setDualControl(&DualControlOptions{DeleteRole: true})
+	defer setDualControl(nil)
+
+	role := &Role{ID: "1", Name: "editor"}
+	if _, err := role.RemoveAllPermissions(context.Background()); err != ErrDualControlRequired {
+		t.Fatalf("RemoveAllPermissions() = %v, want ErrDualControlRequired", err)
+	}
+	if len(fake.executed("DELETE FROM rbac_role_permission")) > 0 {
+		t.Fatal("RemoveAllPermissions() removed the permissions without a confirmation")
+	}
+
+	pending, err := role.RequestRemoveAllPermissions(WithActor(context.Background(), "10"))
+	if err != nil {
+		t.Fatalf("RequestRemoveAllPermissions() = %v", err)
+	}
+	if err = pending.Confirm(WithActor(context.Background(), "11")); err != nil {
+		t.Fatalf("Confirm() = %v", err)
+	}
+	if len(fake.executed("DELETE FROM rbac_role_permission WHERE role_id = ?")) != 1 {
+		t.Error("Confirm() didn't remove the permissions of the role")
+	}
+}
+
+func TestConfirmExecutesTheStoredOperationInOneTransaction(t *testing.T) {
+	fake, restore := openFakeDB(t, pendingOperations())
+	defer restore()
+	setDualControl(&DualControlOptions{ProtectedRoles: []string{"superadmin"}})
+	defer setDualControl(nil)
+
+	pending, err := (&Role{ID: "1", Name: "superadmin"}).RequestRevoke(WithActor(context.Background(), "10"), &User{ID: "5"})
+	if err != nil {
+		t.Fatalf("RequestRevoke() = %v", err)
+	}
+	// a caller can't turn the confirmed revocation into another one
+	pending.UserID = "6"
+	if err = pending.Confirm(WithActor(context.Background(), "11")); err != nil {
+		t.Fatalf("Confirm() = %v", err)
+	}
+
+	decided := fake.executed("UPDATE rbac_pending_operation")
+	if len(decided) != 1 || !strings.Contains(decided[0].query, "requested_by <> ?") || decided[0].args[6].Value != "11" {
+		t.Fatalf("Confirm() doesn't exclude the requester: %+v", decided)
+	}
+	revoked := fake.executed("DELETE FROM rbac_user_role")
+	if len(revoked) != 1 || revoked[0].args[1].Value != "5" {
+		t.Fatalf("Confirm() didn't revoke the stored assignment: %+v", revoked)
+	}
+	for _, statement := range append(append(decided, fake.executed("FROM rbac_pending_operation WHERE id = ?")...), revoked...) {
+		if !statement.inTx {
+			t.Errorf("%q ran outside the transaction of the decision", statement.query)
+		}
+	}
+}
+
+func TestFailedConfirmLeavesTheOperationPending(t *testing.T) {
+	handler := pendingOperations()
+	fake, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		if strings.HasPrefix(strings.TrimSpace(query), "DELETE FROM rbac_user_role") {
+			return fakeResponse{err: errors.New("lock wait timeout")}
+		}
+		return handler(query, args)
+	})
+	defer restore()
+
+	pending, err := (&Role{ID: "1", Name: "superadmin"}).RequestRevoke(WithActor(context.Background(), "10"), &User{ID: "5"})
+	if err != nil {
+		t.Fatalf("RequestRevoke() = %v", err)
+	}
+	if err = pending.Confirm(WithActor(context.Background(), "11")); err == nil {
+		t.Fatal("Confirm() = nil, want the error of the revocation")
+	}
+	if !fake.rolledBack() {
+		t.Error("the operation was marked executed although it failed")
+	}
+}
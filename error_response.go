@@ -0,0 +1,161 @@
+package pager
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	htmltemplate "html/template"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ErrorTemplates overrides the error bodies per format, the templates are executed against the Problem.
+// Empty templates fall back to the problem+json/problem+xml encoding and the default HTML page
+type ErrorTemplates struct {
+	JSON string
+	XML  string
+	HTML string
+}
+
+const defaultErrorPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Status}} {{.Title}}</title></head>
+<body><h1>{{.Status}} {{.Title}}</h1><p>{{.Detail}}</p>{{if .RequestID}}<p><small>Request ID: {{.RequestID}}</small></p>{{end}}</body></html>
+`
+
+const (
+	mimeJSON = "application/json"
+	mimeXML  = "application/xml"
+	mimeHTML = "text/html"
+
+	problemXML = "application/problem+xml"
+)
+
+type errorRenderer struct {
+	json *template.Template
+	xml  *template.Template
+	html *htmltemplate.Template
+}
+
+func newErrorRenderer(templates ErrorTemplates) (*errorRenderer, error) {
+	renderer := new(errorRenderer)
+	var err error
+	if templates.JSON != "" {
+		if renderer.json, err = template.New("json").Parse(templates.JSON); err != nil {
+			return nil, err
+		}
+	}
+	if templates.XML != "" {
+		if renderer.xml, err = template.New("xml").Parse(templates.XML); err != nil {
+			return nil, err
+		}
+	}
+	page := templates.HTML
+	if page == "" {
+		page = defaultErrorPage
+	}
+	if renderer.html, err = htmltemplate.New("html").Parse(page); err != nil {
+		return nil, err
+	}
+	return renderer, nil
+}
+
+var defaultErrorRenderer, _ = newErrorRenderer(ErrorTemplates{})
+
+// render returns the body and its content type
+func (e *errorRenderer) render(mediaType string, problem *Problem) ([]byte, string, error) {
+	var buf bytes.Buffer
+	var err error
+	switch mediaType {
+	case mimeXML:
+		if e.xml != nil {
+			err = e.xml.Execute(&buf, problem)
+			break
+		}
+		buf.WriteString(xml.Header)
+		err = xml.NewEncoder(&buf).Encode(problem)
+		return buf.Bytes(), problemXML, err
+	case mimeHTML:
+		err = e.html.Execute(&buf, problem)
+		return buf.Bytes(), mimeHTML + "; charset=utf-8", err
+	default:
+		if e.json != nil {
+			err = e.json.Execute(&buf, problem)
+			break
+		}
+		err = json.NewEncoder(&buf).Encode(problem)
+		return buf.Bytes(), problemJSON, err
+	}
+	return buf.Bytes(), mediaType + "; charset=utf-8", err
+}
+
+// negotiateErrorType picks JSON, XML or HTML from the Accept header, JSON when the client accepts anything
+func negotiateErrorType(accept string) string {
+	type weightedType struct {
+		mediaType string
+		weight    float64
+	}
+
+	candidates := make([]weightedType, 0)
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		weight := 1.0
+		if q, ok := params["q"]; ok {
+			if weight, err = strconv.ParseFloat(q, 64); err != nil || weight <= 0 {
+				continue
+			}
+		}
+		switch {
+		case mediaType == mimeJSON || strings.HasSuffix(mediaType, "+json"):
+			mediaType = mimeJSON
+		case mediaType == mimeXML || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml") && mediaType != "application/xhtml+xml":
+			mediaType = mimeXML
+		case mediaType == mimeHTML || mediaType == "application/xhtml+xml" || mediaType == "text/*":
+			mediaType = mimeHTML
+		case mediaType == "*/*" || mediaType == "application/*":
+			mediaType = mimeJSON
+		default:
+			continue
+		}
+		candidates = append(candidates, weightedType{mediaType: mediaType, weight: weight})
+	}
+	if len(candidates) == 0 {
+		return mimeJSON
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].weight > candidates[j].weight
+	})
+	return candidates[0].mediaType
+}
+
+// writeError writes status with a problem body negotiated from the Accept header. err picks the detail,
+// the generic message of the status is used for nil errors
+func (a *Auth) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	problem := a.problem(r, status, err)
+	if problem.RequestID == "" && a.requestID != nil {
+		problem.RequestID = a.requestID(r)
+	}
+
+	renderer := a.errorRenderer
+	if renderer == nil {
+		renderer = defaultErrorRenderer
+	}
+	body, contentType, errRender := renderer.render(negotiateErrorType(r.Header.Get("Accept")), problem)
+	if errRender != nil {
+		logf(r.Context(), "failed to render the %d response, err = %s", status, errRender)
+		w.WriteHeader(status)
+		return
+	}
+
+	setRetryAfter(w, err)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(body)
+}
@@ -0,0 +1,162 @@
+// Command e2e runs the demo scenarios against a running examples/webapp:
+// registration, login, 403 before the role grant, the grant through the admin API, then 200.
+//
+//	go run ./examples/e2e -base http://localhost:8080
+//
+// It exits with 1 on the first failed step
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+)
+
+type client struct {
+	base string
+	http *http.Client
+}
+
+type step struct {
+	name string
+	run  func() error
+}
+
+func main() {
+	base := flag.String("base", "http://localhost:8080", "base URL of examples/webapp")
+	adminEmail := flag.String("admin-email", envOr("DEMO_ADMIN_EMAIL", "admin@example.com"), "email of the demo admin")
+	adminPassword := flag.String("admin-password", envOr("DEMO_ADMIN_PASSWORD", "change-me-now"), "password of the demo admin")
+	flag.Parse()
+
+	c := &client{base: *base, http: &http.Client{Timeout: 10 * time.Second}}
+	suffix := time.Now().Format("20060102150405")
+	email := "e2e-" + suffix + "@example.com"
+	password := "e2e-password-" + suffix
+
+	var userID, userToken, adminToken string
+	steps := []step{
+		{"register a user", func() error {
+			var user struct {
+				ID string `json:"id"`
+			}
+			err := c.expect(http.MethodPost, "/api/register", "", map[string]string{
+				"email":    email,
+				"username": "e2e" + suffix,
+				"password": password,
+			}, http.StatusCreated, &user)
+			userID = user.ID
+			return err
+		}},
+		{"register the same email again", func() error {
+			return c.expect(http.MethodPost, "/api/register", "", map[string]string{
+				"email":    email,
+				"username": "e2e" + suffix + "b",
+				"password": password,
+			}, http.StatusConflict, nil)
+		}},
+		{"reject a wrong password", func() error {
+			return c.expect(http.MethodPost, "/api/login", "", map[string]string{"email": email, "password": "wrong"}, http.StatusUnauthorized, nil)
+		}},
+		{"log in", func() (err error) {
+			userToken, err = c.login(email, password)
+			return err
+		}},
+		{"read the profile", func() error {
+			return c.expect(http.MethodGet, "/api/me", userToken, nil, http.StatusOK, nil)
+		}},
+		{"reports are forbidden without a role", func() error {
+			return c.expect(http.MethodGet, "/api/reports", userToken, nil, http.StatusForbidden, nil)
+		}},
+		{"the admin API is forbidden to the user", func() error {
+			return c.expect(http.MethodGet, "/api/admin/rbac/users", userToken, nil, http.StatusForbidden, nil)
+		}},
+		{"log in as admin", func() (err error) {
+			adminToken, err = c.login(*adminEmail, *adminPassword)
+			return err
+		}},
+		{"grant the viewer role", func() error {
+			return c.expect(http.MethodPut, "/api/admin/rbac/users/"+userID+"/roles/viewer", adminToken, nil, http.StatusNoContent, nil)
+		}},
+		{"reports are allowed to viewers", func() error {
+			return c.expect(http.MethodGet, "/api/reports", userToken, nil, http.StatusOK, nil)
+		}},
+		{"the dashboard stays forbidden to viewers", func() error {
+			return c.expect(http.MethodGet, "/api/admin/dashboard", userToken, nil, http.StatusForbidden, nil)
+		}},
+		{"revoke the viewer role", func() error {
+			return c.expect(http.MethodDelete, "/api/admin/rbac/users/"+userID+"/roles/viewer", adminToken, nil, http.StatusNoContent, nil)
+		}},
+		{"reports are forbidden again", func() error {
+			return c.expect(http.MethodGet, "/api/reports", userToken, nil, http.StatusForbidden, nil)
+		}},
+		{"delete the user", func() error {
+			return c.expect(http.MethodDelete, "/api/admin/rbac/users/"+userID, adminToken, nil, http.StatusNoContent, nil)
+		}},
+	}
+
+	for i, s := range steps {
+		if err := s.run(); err != nil {
+			fmt.Printf("FAIL %2d %s: %s\n", i+1, s.name, err)
+			os.Exit(1)
+		}
+		fmt.Printf("ok   %2d %s\n", i+1, s.name)
+	}
+}
+
+func (c *client) login(email, password string) (string, error) {
+	var session struct {
+		Token string `json:"token"`
+	}
+	err := c.expect(http.MethodPost, "/api/login", "", map[string]string{"email": email, "password": password}, http.StatusOK, &session)
+	if err == nil && session.Token == "" {
+		err = fmt.Errorf("no token in the response")
+	}
+	return session.Token, err
+}
+
+// expect sends the request and fails unless the response has status, the JSON body is decoded into out when set
+func (c *client) expect(method, path, token string, body interface{}, status int, out interface{}) error {
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(raw)
+	}
+	request, err := http.NewRequest(method, c.base+path, payload)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	raw, _ := ioutil.ReadAll(io.LimitReader(response.Body, 1<<20))
+	if response.StatusCode != status {
+		return fmt.Errorf("%s %s: expected %d, got %d: %s", method, path, status, response.StatusCode, raw)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,179 @@
+// Command webapp is a demo of pager: a login page, an admin area and RBAC-managed JSON endpoints.
+//
+//	PAGER_DSN='user:pass@tcp(localhost:3306)/demo?parseTime=true' go run ./examples/webapp
+//
+// The schema is migrated and the demo roles are seeded on start, an admin is created from
+// DEMO_ADMIN_EMAIL and DEMO_ADMIN_PASSWORD (admin@example.com / change-me-now by default).
+// examples/e2e drives the scenarios against it
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/dhanarJkusuma/pager/v2"
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// seed declares the demo permissions: viewers read the reports, admins also open the dashboard and manage RBAC
+var seed = &pager.Seed{
+	Permissions: []pager.SeedPermission{
+		{Name: "reports.view", Method: http.MethodGet, Route: "/api/reports", DisplayName: "View reports"},
+		{Name: "admin.dashboard", Method: http.MethodGet, Route: "/admin", DisplayName: "Open the admin dashboard"},
+		{Name: "admin.dashboard.api", Method: http.MethodGet, Route: "/api/admin/dashboard", DisplayName: "Read the dashboard data"},
+		// checked by the admin API itself for every method
+		{Name: "pager.admin", Method: http.MethodGet, Route: "/api/admin/rbac/*", DisplayName: "Manage users and roles"},
+	},
+	Roles: []pager.SeedRole{
+		{Name: "viewer", DisplayName: "Viewer", Permissions: []string{"reports.view"}},
+		{Name: "admin", DisplayName: "Administrator", Permissions: []string{"reports.view", "admin.dashboard", "admin.dashboard.api", "pager.admin"}},
+	},
+}
+
+func main() {
+	addr := envOr("DEMO_ADDR", ":8080")
+	db, err := sql.Open(pager.MYSQLDialect, os.Getenv("PAGER_DSN"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	rbac := pager.NewPager(&pager.Options{
+		DbConnection: db,
+		Dialect:      pager.MYSQLDialect,
+		Session: pager.SessionOptions{
+			SessionName:      "demo_session",
+			ExpiredInSeconds: 3600,
+		},
+		LoginPages: &pager.LoginPageOptions{Title: "Pager demo", RedirectTo: "/admin"},
+	}).BuildPager()
+
+	if err = rbac.Migration.CheckMigration(); err != nil {
+		if err = rbac.Migration.InitDBMigration(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	ctx := pager.WithActor(context.Background(), "demo")
+	if err = rbac.Migration.ApplySeed(ctx, seed); err != nil {
+		log.Fatal(err)
+	}
+	if err = bootstrapAdmin(ctx, rbac.Auth); err != nil {
+		log.Fatal(err)
+	}
+
+	auth := rbac.Auth
+	cookieRBAC := func(h http.Handler) http.Handler { return auth.ProtectRoute(auth.ProtectWithRBAC(h)) }
+	tokenRBAC := func(h http.Handler) http.Handler { return auth.ProtectRouteUsingToken(auth.ProtectWithRBAC(h)) }
+
+	mux := http.NewServeMux()
+	mux.Handle("/login", auth.LoginPageHandler())
+	mux.Handle("/logout", auth.LogoutPageHandler())
+	mux.Handle("/admin", cookieRBAC(http.HandlerFunc(dashboardPage)))
+
+	mux.HandleFunc("/api/register", register(auth))
+	mux.HandleFunc("/api/login", login(auth))
+	mux.Handle("/api/me", auth.ProtectRouteUsingToken(auth.ProfileHandler()))
+	mux.Handle("/api/reports", tokenRBAC(http.HandlerFunc(reports)))
+	mux.Handle("/api/admin/dashboard", tokenRBAC(http.HandlerFunc(dashboardData)))
+	mux.Handle("/api/admin/rbac/", auth.ProtectRouteUsingToken(auth.AdminHandler(pager.AdminOptions{Prefix: "/api/admin/rbac"})))
+
+	log.Printf("demo listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, mux))
+}
+
+func bootstrapAdmin(ctx context.Context, auth *pager.Auth) error {
+	email := envOr("DEMO_ADMIN_EMAIL", "admin@example.com")
+	existing, err := pager.GetUserWithContext(ctx, email, nil)
+	if err != nil || existing != nil {
+		return err
+	}
+	admin := &pager.User{Email: email, Username: "demo-admin", Password: envOr("DEMO_ADMIN_PASSWORD", "change-me-now")}
+	return auth.RegisterWithOptions(ctx, admin, pager.RegisterOptions{CheckUnique: true, DefaultRoles: []string{"admin"}})
+}
+
+type credentials struct {
+	Email    string `json:"email"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+func register(auth *pager.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body credentials
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user := &pager.User{Email: body.Email, Username: body.Username, Password: body.Password}
+		if err := auth.RegisterWithOptions(r.Context(), user, pager.RegisterOptions{CheckUnique: true}); err != nil {
+			auth.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, user)
+	}
+}
+
+func login(auth *pager.Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var body credentials
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		user, token, err := auth.SignIn(auth.LoginParamsFromRequest(r, body.Email, body.Password))
+		if err != nil {
+			auth.WriteProblem(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"user": user, "token": token})
+	}
+}
+
+func reports(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, []map[string]interface{}{
+		{"name": "signups", "value": 42},
+		{"name": "active_users", "value": 17},
+	})
+}
+
+func dashboardData(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func dashboardPage(w http.ResponseWriter, r *http.Request) {
+	user := pager.GetUserLogin(r)
+	if user == nil {
+		http.Redirect(w, r, "/login?next=/admin", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><body><h1>Admin area</h1><p>Signed in as %s.</p>
+<form method="post" action="/logout"><button type="submit">Sign out</button></form></body></html>`, html.EscapeString(user.Email))
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,147 @@
+package pager
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// Constants for the bindings of an AccessMatch
+const (
+	AccessViaRole   = "role"
+	AccessViaGroup  = "group"
+	AccessViaDirect = "direct"
+)
+
+// AccessMatch is a grant of the user whose permission route matches the checked path
+type AccessMatch struct {
+	Permission string `json:"permission" xml:"permission"`
+	// Route is the route of the permission, or of one of its alias routes, matching the path
+	Route string `json:"route" xml:"route"`
+	// Via is the binding of the grant: AccessViaRole, AccessViaGroup or AccessViaDirect
+	Via   string `json:"via" xml:"via"`
+	Role  string `json:"role,omitempty" xml:"role,omitempty"`
+	Group string `json:"group,omitempty" xml:"group,omitempty"`
+}
+
+// AccessTrace explains a route check of CanAccess or the RBAC middleware
+type AccessTrace struct {
+	UserID  string        `json:"user_id" xml:"user_id"`
+	Method  string        `json:"method" xml:"method"`
+	Path    string        `json:"path" xml:"path"`
+	Granted bool          `json:"granted" xml:"granted"`
+	Matches []AccessMatch `json:"matches" xml:"match"`
+	// Unenforced is set when a permission flag lets the route through, see Options.PermissionFlags
+	Unenforced bool `json:"unenforced,omitempty" xml:"unenforced,omitempty"`
+	// Err is the failure of the explanation queries, the check itself isn't affected
+	Err string `json:"error,omitempty" xml:"error,omitempty"`
+}
+
+// AccessExplanation collects the traces of the checks made with the context of WithAccessExplain
+type AccessExplanation struct {
+	mutex  sync.Mutex
+	traces []AccessTrace
+}
+
+// Traces returns the traces in the order of the checks
+func (e *AccessExplanation) Traces() []AccessTrace {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return append([]AccessTrace(nil), e.traces...)
+}
+
+func (e *AccessExplanation) add(trace AccessTrace) {
+	e.mutex.Lock()
+	e.traces = append(e.traces, trace)
+	e.mutex.Unlock()
+}
+
+type accessExplainKey struct{}
+
+// WithAccessExplain returns ctx tracing the route checks made with it, each check runs an extra query.
+// The explanation is nil and ctx is returned untouched unless Options.ExplainAccess is set
+func WithAccessExplain(ctx context.Context) (context.Context, *AccessExplanation) {
+	if !loadSettings().accessExplain {
+		return ctx, nil
+	}
+	explanation := &AccessExplanation{}
+	return context.WithValue(ctx, accessExplainKey{}, explanation), explanation
+}
+
+// AccessExplanationFromContext returns the explanation of WithAccessExplain, nil when ctx doesn't trace the checks
+func AccessExplanationFromContext(ctx context.Context) *AccessExplanation {
+	if ctx == nil {
+		return nil
+	}
+	explanation, _ := ctx.Value(accessExplainKey{}).(*AccessExplanation)
+	return explanation
+}
+
+// explainAccess records why u was granted or denied the route when ctx traces the checks
+func explainAccess(ctx context.Context, u *User, method, path string, granted bool) {
+	explanation := AccessExplanationFromContext(ctx)
+	if explanation == nil {
+		return
+	}
+	db := u.db
+	if db == nil {
+		db = dbConnection
+	}
+
+	trace := AccessTrace{
+		UserID:  u.ID,
+		Method:  strings.ToUpper(method),
+		Path:    path,
+		Granted: granted,
+		Matches: make([]AccessMatch, 0),
+	}
+	matches, err := accessMatches(ctx, db, u.ID, method, path)
+	if err != nil {
+		trace.Err = err.Error()
+	}
+	trace.Matches = append(trace.Matches, matches...)
+	trace.Unenforced = !routeEnforced(ctx, &User{ID: u.ID, db: db}, method, path)
+	explanation.add(trace)
+}
+
+// accessMatches lists every binding granting userID a permission whose route matches path
+func accessMatches(ctx context.Context, db DbContract, userID, method, path string) ([]AccessMatch, error) {
+	getQuery := `SELECT p.name, p.route, granted.via, granted.role_name, granted.group_name
+	FROM (
+		SELECT rp.permission_id, 'role' AS via, r.name AS role_name, NULL AS group_name FROM rbac_user_role ur
+		JOIN rbac_role r ON r.id = ur.role_id
+		JOIN rbac_role_permission rp ON rp.role_id = ur.role_id
+		WHERE ur.user_id = ? AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+		UNION ALL
+		SELECT rp.permission_id, 'group', r.name, g.name FROM rbac_user_group ug
+		JOIN rbac_group g ON g.id = ug.group_id
+		JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+		JOIN rbac_role r ON r.id = gr.role_id
+		JOIN rbac_role_permission rp ON rp.role_id = gr.role_id
+		WHERE ug.user_id = ?
+		UNION ALL
+		SELECT up.permission_id, 'direct', NULL, NULL FROM rbac_user_permission up
+		WHERE up.user_id = ?
+	) granted
+	JOIN ` + permissionRoutes + ` ON p.id = granted.permission_id
+	WHERE p.method = ? AND (` + routePatternCondition + `)
+	ORDER BY p.name, p.route, granted.via, granted.role_name, granted.group_name`
+	result, err := db.QueryContext(ctx, getQuery, append(grantedPermissionArgs(userID), method, path)...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	matches := make([]AccessMatch, 0)
+	for result.Next() {
+		var match AccessMatch
+		err = result.Scan(&match.Permission, &match.Route, &match.Via, textColumn{&match.Role}, textColumn{&match.Group})
+		if err != nil {
+			return nil, err
+		}
+		if MatchRoute(match.Route, path) {
+			matches = append(matches, match)
+		}
+	}
+	return matches, result.Err()
+}
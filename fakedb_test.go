@@ -0,0 +1,194 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeResponse is the answer of a fakeHandler: the rows of a query, or the affected rows and insert id of a statement
+type fakeResponse struct {
+	columns      []string
+	rows         [][]driver.Value
+	affected     int64
+	lastInsertID int64
+	err          error
+}
+
+// fakeHandler answers the statements sent to the fake driver
+type fakeHandler func(query string, args []driver.NamedValue) fakeResponse
+
+// fakeStatement is a statement received by the fake driver
+type fakeStatement struct {
+	query string
+	args  []driver.NamedValue
+	inTx  bool
+}
+
+// fakeDB records the statements of the connection opened by openFakeDB
+type fakeDB struct {
+	mutex      sync.Mutex
+	handler    fakeHandler
+	statements []fakeStatement
+	rollbacks  int
+}
+
+// executed returns the statements whose query contains fragment
+func (f *fakeDB) executed(fragment string) []fakeStatement {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var matching []fakeStatement
+	for _, statement := range f.statements {
+		if strings.Contains(statement.query, fragment) {
+			matching = append(matching, statement)
+		}
+	}
+	return matching
+}
+
+// rolledBack reports whether a transaction was rolled back
+func (f *fakeDB) rolledBack() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.rollbacks > 0
+}
+
+func (f *fakeDB) answer(query string, args []driver.NamedValue, inTx bool) fakeResponse {
+	f.mutex.Lock()
+	f.statements = append(f.statements, fakeStatement{query: query, args: args, inTx: inTx})
+	f.mutex.Unlock()
+	if f.handler == nil {
+		return fakeResponse{}
+	}
+	return f.handler(query, args)
+}
+
+var fakeDBs sync.Map
+
+func init() {
+	sql.Register("pagerfake", fakeDriver{})
+}
+
+// openFakeDB opens a connection answered by handler and makes it the pager connection,
+// the returned func restores the previous connection
+func openFakeDB(t testing.TB, handler fakeHandler) (*fakeDB, func()) {
+	fake := &fakeDB{handler: handler}
+	name := fmt.Sprintf("%s-%p", t.Name(), fake)
+	fakeDBs.Store(name, fake)
+	db, err := sql.Open("pagerfake", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	previousDB, previousWrappers := sqlConnection, dbWrappers
+	setDatabaseConnection(db)
+	return fake, func() {
+		db.Close()
+		fakeDBs.Delete(name)
+		setDatabaseConnection(previousDB, previousWrappers...)
+	}
+}
+
+// fakeRowsOf answers a single row of columns
+func fakeRowsOf(columns []string, values ...driver.Value) fakeResponse {
+	return fakeResponse{columns: columns, rows: [][]driver.Value{values}}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fake, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, errors.New("unknown fake database " + name)
+	}
+	return &fakeConn{db: fake.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db   *fakeDB
+	inTx bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements aren't supported by the fake driver")
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return c.Begin()
+}
+
+func (c *fakeConn) Commit() error {
+	c.inTx = false
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.inTx = false
+	c.db.mutex.Lock()
+	c.db.rollbacks++
+	c.db.mutex.Unlock()
+	return nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	response := c.db.answer(query, args, c.inTx)
+	if response.err != nil {
+		return nil, response.err
+	}
+	return &fakeRows{columns: response.columns, rows: response.rows}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	response := c.db.answer(query, args, c.inTx)
+	if response.err != nil {
+		return nil, response.err
+	}
+	return fakeResult(response), nil
+}
+
+type fakeResult fakeResponse
+
+func (r fakeResult) LastInsertId() (int64, error) {
+	return r.lastInsertID, nil
+}
+
+func (r fakeResult) RowsAffected() (int64, error) {
+	return r.affected, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
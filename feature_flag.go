@@ -0,0 +1,76 @@
+package pager
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// PermissionFlags lets permission checks consult a feature-flag provider, so a new permission can be
+// enforced for a share of the users first. A user denied a permission that isn't enforced for them is allowed
+type PermissionFlags interface {
+	Enforced(ctx context.Context, permissionName string, user *User) bool
+}
+
+// nopPermissionFlags enforces every permission
+type nopPermissionFlags struct{}
+
+func (nopPermissionFlags) Enforced(ctx context.Context, permissionName string, user *User) bool {
+	return true
+}
+
+// PercentageRollout enforces the listed permissions for the given percentage (0-100) of users,
+// picked by a stable hash of the user id. Unlisted permissions are always enforced
+type PercentageRollout map[string]int
+
+func (r PercentageRollout) Enforced(ctx context.Context, permissionName string, user *User) bool {
+	percentage, ok := r[permissionName]
+	if !ok || percentage >= 100 {
+		return true
+	}
+	if user == nil || percentage <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(permissionName + ":" + user.ID))
+	return int(h.Sum32()%100) < percentage
+}
+
+func permissionEnforced(ctx context.Context, u *User, permissionName string) bool {
+	permissionFlags := loadSettings().permissionFlags
+	if _, ok := permissionFlags.(nopPermissionFlags); ok {
+		return true
+	}
+	return permissionFlags.Enforced(ctx, permissionName, u)
+}
+
+// routeEnforced reports whether every permission of the route is enforced for u,
+// a route without permission is enforced
+func routeEnforced(ctx context.Context, u *User, method, path string) bool {
+	enforced, err := routeEnforcement(ctx, u, method, path)
+	return enforced || err != nil
+}
+
+// routeEnforcement is routeEnforced returning the errors of the query
+func routeEnforcement(ctx context.Context, u *User, method, path string) (bool, error) {
+	permissionFlags := loadSettings().permissionFlags
+	if _, ok := permissionFlags.(nopPermissionFlags); ok {
+		return true, nil
+	}
+
+	getQuery := `SELECT p.name, p.route FROM ` + permissionRoutes + ` WHERE p.method = ? AND (` + routePatternCondition + `)`
+	result, err := u.db.QueryContext(ctx, getQuery, method, path)
+	if err != nil {
+		return true, err
+	}
+	defer result.Close()
+	for result.Next() {
+		var name, route string
+		if err = result.Scan(&name, &route); err != nil {
+			return true, err
+		}
+		if MatchRoute(route, path) && !permissionFlags.Enforced(ctx, name, u) {
+			return false, nil
+		}
+	}
+	return true, result.Err()
+}
@@ -0,0 +1,241 @@
+package pager
+
+import (
+	"context"
+	"time"
+)
+
+const AuditRoleGrantExpired = "role_grant.expired"
+
+// RoleGrant is a time-bound assignment of a role to a user
+type RoleGrant struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	RoleID    string    `json:"role_id"`
+	RoleName  string    `json:"role_name"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (r *Role) AssignUntil(u *User, expiresAt time.Time) error {
+	return r.AssignUntilWithContext(context.Background(), u, expiresAt)
+}
+
+// AssignUntilWithContext grants the role to u until expiresAt, the grant is ignored by permission checks afterwards
+func (r *Role) AssignUntilWithContext(ctx context.Context, u *User, expiresAt time.Time) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return ErrInvalidRoleID
+	}
+
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	now := clock.Now()
+	insertQuery := `INSERT INTO rbac_user_role (
+		role_id, 
+		user_id,
+		expires_at,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE expires_at = ?, updated_at = ?`
+	_, err := r.db.ExecContext(
+		ctx,
+		insertQuery,
+		r.ID,
+		u.ID,
+		expiresAt.UTC(),
+		now,
+		now,
+		expiresAt.UTC(),
+		now,
+	)
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(u.ID)
+	return auditRoleAssignment(ctx, r.db, AuditRoleAssigned, r, u, &expiresAt)
+}
+
+// FindExpiringRoleGrants lists the time-bound grants still active that expire within the given days
+func FindExpiringRoleGrants(ctx context.Context, days int, ptx *PagerTx) ([]RoleGrant, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	now := clock.Now()
+	getQuery := `SELECT
+		u.id,
+		u.username,
+		u.email,
+		r.id,
+		r.name,
+		ur.expires_at
+	FROM rbac_user_role ur
+	JOIN rbac_user u ON ur.user_id = u.id
+	JOIN rbac_role r ON ur.role_id = r.id
+	WHERE ur.expires_at > ? AND ur.expires_at <= ?
+	ORDER BY ur.expires_at ASC`
+
+	result, err := db.QueryContext(ctx, getQuery, now, now.AddDate(0, 0, days))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	grants := make([]RoleGrant, 0)
+	for result.Next() {
+		var grant RoleGrant
+		err = result.Scan(
+			&grant.UserID,
+			&grant.Username,
+			&grant.Email,
+			&grant.RoleID,
+			&grant.RoleName,
+			timestamp{&grant.ExpiresAt},
+		)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, grant)
+	}
+	return grants, result.Err()
+}
+
+type ExpiringGrantsHook func(ctx context.Context, grants []RoleGrant)
+
+// ScheduleExpiringGrantsHook invokes hook every interval with the grants expiring within the given days,
+// until ctx is done. The hook is not invoked when nothing is about to expire
+func (p *Pager) ScheduleExpiringGrantsHook(ctx context.Context, interval time.Duration, days int, hook ExpiringGrantsHook) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			grants, err := FindExpiringRoleGrants(ctx, days, nil)
+			if err != nil {
+				logf(ctx, "failed to find expiring role grants, err = %s", err)
+			} else if len(grants) > 0 {
+				hook(ctx, grants)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// ExpireTemporaryGrants deletes the time-bound role grants that already expired and returns how many were revoked.
+// Permission checks ignore expired grants anyway, the sweep keeps the table and the audit trail tidy.
+// It's safe to call from an external scheduler, concurrent sweeps revoke each grant once
+func ExpireTemporaryGrants(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := expireTemporaryGrants(ctx, wrapTx(tx))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	if err = tx.Commit(); err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+func expireTemporaryGrants(ctx context.Context, db DbContract) (int64, error) {
+	now := clock.Now()
+	getQuery := `SELECT ur.user_id, ur.role_id, r.name, ur.expires_at
+	FROM rbac_user_role ur
+	JOIN rbac_role r ON ur.role_id = r.id
+	WHERE ur.expires_at <= ?
+	FOR UPDATE`
+	result, err := db.QueryContext(ctx, getQuery, now)
+	if err != nil {
+		return 0, err
+	}
+	grants := make([]RoleGrant, 0)
+	for result.Next() {
+		var grant RoleGrant
+		err = result.Scan(&grant.UserID, &grant.RoleID, &grant.RoleName, timestamp{&grant.ExpiresAt})
+		if err != nil {
+			result.Close()
+			return 0, err
+		}
+		grants = append(grants, grant)
+	}
+	result.Close()
+	if err = result.Err(); err != nil {
+		return 0, err
+	}
+
+	deleteQuery := `DELETE FROM rbac_user_role WHERE user_id = ? AND role_id = ? AND expires_at <= ?`
+	var revoked int64
+	userIDs := make([]string, 0, len(grants))
+	for _, grant := range grants {
+		deleted, err := db.ExecContext(ctx, deleteQuery, grant.UserID, grant.RoleID, now)
+		if err != nil {
+			return 0, err
+		}
+		if affected, _ := deleted.RowsAffected(); affected == 0 {
+			continue
+		}
+		revoked++
+		userIDs = append(userIDs, grant.UserID)
+
+		err = writeAudit(ctx, db, &AuditEntry{
+			ActorID: actorFromContext(ctx),
+			Action:  AuditRoleGrantExpired,
+			Target:  grant.UserID,
+			Metadata: map[string]string{
+				"role":       grant.RoleName,
+				"expires_at": grant.ExpiresAt.UTC().Format(time.RFC3339),
+			},
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	invalidateUserPermissions(userIDs...)
+	return revoked, nil
+}
+
+// ScheduleGrantJanitor runs ExpireTemporaryGrants every interval until ctx is done
+func (p *Pager) ScheduleGrantJanitor(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := ExpireTemporaryGrants(ctx); err != nil {
+				logf(ctx, "failed to expire temporary role grants, err = %s", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,260 @@
+package pager
+
+import (
+	"context"
+	"errors"
+)
+
+// Constants for the audit actions of the group mutations
+const (
+	AuditGroupMemberAdded   = "group.member_added"
+	AuditGroupMemberRemoved = "group.member_removed"
+	AuditGroupRoleAssigned  = "group.role_assigned"
+	AuditGroupRoleRevoked   = "group.role_revoked"
+)
+
+var ErrInvalidGroupID = errors.New("invalid group id")
+
+// grantedRoleCondition matches the role r held by a user directly through a non-expired grant or through a group
+const grantedRoleCondition = `EXISTS (
+		SELECT 1 FROM rbac_user_role ur
+		WHERE ur.role_id = r.id AND ur.user_id = ?
+		AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+	) OR EXISTS (
+		SELECT 1 FROM rbac_user_group ug
+		JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+		WHERE gr.role_id = r.id AND ug.user_id = ?
+	)`
+
+func grantedRoleArgs(userID string) []interface{} {
+	return []interface{}{userID, clock.Now(), userID}
+}
+
+func (g *Group) AddUser(u *User) error {
+	return g.AddUserWithContext(context.Background(), u)
+}
+
+// AddUserWithContext makes u a member of the group, u inherits the roles of the group. Adding it again is a no-op
+func (g *Group) AddUserWithContext(ctx context.Context, u *User) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	if g.ID == "" {
+		return ErrInvalidGroupID
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	now := clock.Now()
+	insertQuery := `INSERT IGNORE INTO rbac_user_group (
+		group_id,
+		user_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
+	result, err := g.db.ExecContext(ctx, insertQuery, g.ID, u.ID, now, now)
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(u.ID)
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+	return g.audit(ctx, AuditGroupMemberAdded, u.ID, nil)
+}
+
+func (g *Group) RemoveUser(u *User) error {
+	return g.RemoveUserWithContext(context.Background(), u)
+}
+
+func (g *Group) RemoveUserWithContext(ctx context.Context, u *User) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	if g.ID == "" {
+		return ErrInvalidGroupID
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	deleteQuery := `DELETE FROM rbac_user_group WHERE group_id = ? AND user_id = ?`
+	result, err := g.db.ExecContext(ctx, deleteQuery, g.ID, u.ID)
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(u.ID)
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+	return g.audit(ctx, AuditGroupMemberRemoved, u.ID, nil)
+}
+
+func (g *Group) AssignRole(r *Role) error {
+	return g.AssignRoleWithContext(context.Background(), r)
+}
+
+// AssignRoleWithContext grants the role to every member of the group, assigning it again is a no-op
+func (g *Group) AssignRoleWithContext(ctx context.Context, r *Role) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	if g.ID == "" {
+		return ErrInvalidGroupID
+	}
+	if r.ID == "" {
+		return ErrInvalidRoleID
+	}
+
+	now := clock.Now()
+	insertQuery := `INSERT IGNORE INTO rbac_group_role (
+		group_id,
+		role_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
+	result, err := g.db.ExecContext(ctx, insertQuery, g.ID, r.ID, now, now)
+	if err != nil {
+		return err
+	}
+	purgePermissionCache()
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+	return g.audit(ctx, AuditGroupRoleAssigned, g.ID, map[string]string{"role_id": r.ID, "role": r.Name})
+}
+
+func (g *Group) RevokeRole(r *Role) error {
+	return g.RevokeRoleWithContext(context.Background(), r)
+}
+
+func (g *Group) RevokeRoleWithContext(ctx context.Context, r *Role) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	if g.ID == "" {
+		return ErrInvalidGroupID
+	}
+	if r.ID == "" {
+		return ErrInvalidRoleID
+	}
+	if err := requireDualControl(ctx, g.db, OperationRevokeGroupRole, r); err != nil {
+		return err
+	}
+
+	deleteQuery := `DELETE FROM rbac_group_role WHERE group_id = ? AND role_id = ?`
+	result, err := g.db.ExecContext(ctx, deleteQuery, g.ID, r.ID)
+	if err != nil {
+		return err
+	}
+	purgePermissionCache()
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+	return g.audit(ctx, AuditGroupRoleRevoked, g.ID, map[string]string{"role_id": r.ID, "role": r.Name})
+}
+
+func (g *Group) GetRoles() ([]Role, error) {
+	return g.GetRolesWithContext(context.Background())
+}
+
+// GetRolesWithContext returns the roles granted to the members of the group
+func (g *Group) GetRolesWithContext(ctx context.Context) ([]Role, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	if g.ID == "" {
+		return nil, ErrInvalidGroupID
+	}
+
+	getQuery := `SELECT ` + roleColumns("r") + `
+	FROM rbac_group_role gr
+	JOIN rbac_role r ON gr.role_id = r.id
+	WHERE gr.group_id = ?
+	ORDER BY r.name`
+	result, err := g.db.QueryContext(ctx, getQuery, g.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	roles := make([]Role, 0)
+	for result.Next() {
+		var role Role
+		if err = result.Scan(role.scanFields()...); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, result.Err()
+}
+
+func (u *User) GetGroups() ([]Group, error) {
+	return u.GetGroupsWithContext(context.Background())
+}
+
+func (u *User) GetGroupsWithContext(ctx context.Context) ([]Group, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	getQuery := `SELECT g.id, g.name, g.created_at, g.updated_at
+	FROM rbac_user_group ug
+	JOIN rbac_group g ON ug.group_id = g.id
+	WHERE ug.user_id = ?
+	ORDER BY g.name`
+	result, err := u.db.QueryContext(ctx, getQuery, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	groups := make([]Group, 0)
+	for result.Next() {
+		group := Group{db: u.db}
+		err = result.Scan(&group.ID, &group.Name, timestamp{&group.CreatedAt}, timestamp{&group.UpdatedAt})
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, result.Err()
+}
+
+func (g *Group) audit(ctx context.Context, action, target string, metadata map[string]string) error {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["group_id"] = g.ID
+	if g.Name != "" {
+		metadata["group"] = g.Name
+	}
+	return writeAudit(ctx, g.db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   action,
+		Target:   target,
+		Metadata: metadata,
+	})
+}
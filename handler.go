@@ -1,11 +1,404 @@
 package pager
 
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultAdminPermission = "pager.admin"
+	defaultAdminPageSize   = 50
+	maxAdminPageSize       = 500
+)
+
+type AdminOptions struct {
+	// Permission is required from the authenticated user on every request, "pager.admin" when empty
+	Permission string
+	// Prefix is stripped from the request path, e.g. "/admin/rbac" when mounted at "/admin/rbac/"
+	Prefix string
+}
+
+// Admin serves the JSON API managing the users, the roles, the permissions and their assignments:
+//
+//	GET, POST          /users
+//	GET, PATCH, DELETE /users/{id}
+//	GET                /users/{id}/roles
+//	PUT, DELETE        /users/{id}/roles/{role}
+//	GET, POST          /roles
+//	GET, PATCH, DELETE /roles/{name}
+//	GET                /roles/{name}/permissions
+//	PUT, DELETE        /roles/{name}/permissions/{permission}
+//	GET, POST          /permissions
+//	GET, PATCH, DELETE /permissions/{name}
+//
+// The lists are paged with the page and size query parameters, PUT and DELETE of an assignment are idempotent
 type Admin struct {
+	auth       *Auth
+	permission string
+	prefix     string
+}
+
+type adminUserRequest struct {
+	Email    *string  `json:"email"`
+	Username *string  `json:"username"`
+	Password string   `json:"password"`
+	Type     UserType `json:"type"`
+	Roles    []string `json:"roles"`
+}
+
+// adminEntityRequest creates or updates a role or a permission, the nil fields are left untouched on update
+type adminEntityRequest struct {
+	Name        *string                `json:"name"`
+	Method      string                 `json:"method"`
+	Route       string                 `json:"route"`
+	Description string                 `json:"description"`
+	DisplayName *string                `json:"display_name"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+// AdminHandler returns the admin API, see Admin. Mount it behind ProtectRoute or ProtectRouteUsingToken,
+// the mutations are audited with the authenticated user as the actor
+func (a *Auth) AdminHandler(opts AdminOptions) http.Handler {
+	if opts.Permission == "" {
+		opts.Permission = defaultAdminPermission
+	}
+	return &Admin{
+		auth:       a,
+		permission: opts.Permission,
+		prefix:     strings.TrimSuffix(opts.Prefix, "/"),
+	}
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if PrincipalFromContext(r.Context()) == nil {
+		a.auth.writeError(w, r, http.StatusUnauthorized, nil)
+		return
+	}
+	if !Can(r.Context(), a.permission) {
+		a.auth.writeError(w, r, http.StatusForbidden, ErrPermissionDenied)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, a.prefix), "/")
+	segments := strings.Split(path, "/")
+	switch segments[0] {
+	case "users":
+		a.proceedUsers(w, r, segments[1:])
+	case "roles":
+		a.proceedRoles(w, r, segments[1:])
+	case "permissions":
+		a.proceedPermissions(w, r, segments[1:])
+	default:
+		a.auth.writeError(w, r, http.StatusNotFound, nil)
+	}
+}
+
+func (a *Admin) proceedUsers(w http.ResponseWriter, r *http.Request, segments []string) {
+	ctx := r.Context()
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			page, size := adminPage(r)
+			filter := UserFilter{Type: UserType(r.URL.Query().Get("type"))}
+			users, err := FindUsers(ctx, filter, page, size, nil)
+			a.respond(w, r, http.StatusOK, users, err)
+		case http.MethodPost:
+			var request adminUserRequest
+			if !a.decode(w, r, &request) {
+				return
+			}
+			user := &User{Password: request.Password, Type: request.Type}
+			if request.Email != nil {
+				user.Email = *request.Email
+			}
+			if request.Username != nil {
+				user.Username = *request.Username
+			}
+			opts := RegisterOptions{CheckUnique: true, DefaultRoles: request.Roles}
+			a.respond(w, r, http.StatusCreated, user, a.auth.RegisterWithOptions(ctx, user, opts))
+		default:
+			a.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	user, err := FindUserWithContext(ctx, map[string]interface{}{"id": segments[0]}, nil)
+	if err == nil && user == nil {
+		err = ErrUserNotFound
+	}
+	if err != nil {
+		a.auth.WriteProblem(w, r, err)
+		return
+	}
+
+	switch {
+	case len(segments) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			a.respond(w, r, http.StatusOK, user, nil)
+		case http.MethodPatch:
+			var request adminUserRequest
+			if !a.decode(w, r, &request) {
+				return
+			}
+			changes := ProfileChanges{Email: request.Email, Username: request.Username}
+			a.respond(w, r, http.StatusOK, user, user.UpdateProfileWithContext(ctx, changes))
+		case http.MethodDelete:
+			a.respond(w, r, http.StatusNoContent, nil, user.DeleteWithContext(ctx))
+		default:
+			a.methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+	case len(segments) == 2 && segments[1] == "roles":
+		if r.Method != http.MethodGet {
+			a.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		roles, err := user.GetRolesWithContext(ctx)
+		a.respond(w, r, http.StatusOK, roles, err)
+	case len(segments) == 3 && segments[1] == "roles":
+		role, err := GetRoleContext(ctx, segments[2], nil)
+		if err == nil && role == nil {
+			err = ErrRoleNotFound
+		}
+		if err != nil {
+			a.auth.WriteProblem(w, r, err)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if err = role.AssignWithContext(ctx, user); isDuplicateEntryError(err) {
+				err = nil
+			}
+			a.respond(w, r, http.StatusNoContent, nil, err)
+		case http.MethodDelete:
+			a.respond(w, r, http.StatusNoContent, nil, role.RevokeWithContext(ctx, user))
+		default:
+			a.methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		}
+	default:
+		a.auth.writeError(w, r, http.StatusNotFound, nil)
+	}
+}
+
+func (a *Admin) proceedRoles(w http.ResponseWriter, r *http.Request, segments []string) {
+	ctx := r.Context()
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			page, size := adminPage(r)
+			roles, err := FindRoles(ctx, page, size, nil)
+			a.respond(w, r, http.StatusOK, roles, err)
+		case http.MethodPost:
+			var request adminEntityRequest
+			if !a.decode(w, r, &request) {
+				return
+			}
+			role := &Role{Description: request.Description, Metadata: request.Metadata}
+			if request.Name != nil {
+				role.Name = *request.Name
+			}
+			if request.DisplayName != nil {
+				role.DisplayName = *request.DisplayName
+			}
+			err := role.CreateRoleWithContext(ctx)
+			if isDuplicateEntryError(err) {
+				err = ErrNameTaken
+			}
+			a.respond(w, r, http.StatusCreated, role, err)
+		default:
+			a.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	role, err := GetRoleContext(ctx, segments[0], nil)
+	if err == nil && role == nil {
+		err = ErrRoleNotFound
+	}
+	if err != nil {
+		a.auth.WriteProblem(w, r, err)
+		return
+	}
+
+	switch {
+	case len(segments) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			a.respond(w, r, http.StatusOK, role, nil)
+		case http.MethodPatch:
+			var request adminEntityRequest
+			if !a.decode(w, r, &request) {
+				return
+			}
+			if request.DisplayName != nil || request.Metadata != nil {
+				displayName, metadata := role.DisplayName, role.Metadata
+				if request.DisplayName != nil {
+					displayName = *request.DisplayName
+				}
+				if request.Metadata != nil {
+					metadata = request.Metadata
+				}
+				err = role.UpdateDisplayWithContext(ctx, displayName, metadata)
+			}
+			if err == nil && request.Name != nil {
+				err = role.Rename(ctx, *request.Name)
+			}
+			a.respond(w, r, http.StatusOK, role, err)
+		case http.MethodDelete:
+			a.respond(w, r, http.StatusNoContent, nil, role.DeleteRoleWithContext(ctx))
+		default:
+			a.methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+		}
+	case len(segments) == 2 && segments[1] == "permissions":
+		if r.Method != http.MethodGet {
+			a.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		permissions, err := role.GetPermissionWithContext(ctx)
+		a.respond(w, r, http.StatusOK, permissions, err)
+	case len(segments) == 3 && segments[1] == "permissions":
+		permission, err := GetPermissionWithContext(ctx, segments[2], nil)
+		if err == nil && permission == nil {
+			err = ErrPermissionNotFound
+		}
+		if err != nil {
+			a.auth.WriteProblem(w, r, err)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			if err = role.AddChildWithContext(ctx, permission); isDuplicateEntryError(err) {
+				err = nil
+			}
+			a.respond(w, r, http.StatusNoContent, nil, err)
+		case http.MethodDelete:
+			a.respond(w, r, http.StatusNoContent, nil, role.RemoveChildWithContext(ctx, permission))
+		default:
+			a.methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		}
+	default:
+		a.auth.writeError(w, r, http.StatusNotFound, nil)
+	}
 }
 
-func (a *Admin) proceedRequest() {
+func (a *Admin) proceedPermissions(w http.ResponseWriter, r *http.Request, segments []string) {
+	ctx := r.Context()
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			page, size := adminPage(r)
+			permissions, err := FindPermissions(ctx, page, size, nil)
+			a.respond(w, r, http.StatusOK, permissions, err)
+		case http.MethodPost:
+			var request adminEntityRequest
+			if !a.decode(w, r, &request) {
+				return
+			}
+			permission := &Permission{
+				Method:      strings.ToUpper(request.Method),
+				Route:       request.Route,
+				Description: request.Description,
+				Metadata:    request.Metadata,
+			}
+			if request.Name != nil {
+				permission.Name = *request.Name
+			}
+			if request.DisplayName != nil {
+				permission.DisplayName = *request.DisplayName
+			}
+			err := permission.CreatePermissionWithContext(ctx)
+			if isDuplicateEntryError(err) {
+				err = ErrNameTaken
+			}
+			a.respond(w, r, http.StatusCreated, permission, err)
+		default:
+			a.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+	if len(segments) > 1 {
+		a.auth.writeError(w, r, http.StatusNotFound, nil)
+		return
+	}
+
+	permission, err := GetPermissionWithContext(ctx, segments[0], nil)
+	if err == nil && permission == nil {
+		err = ErrPermissionNotFound
+	}
+	if err != nil {
+		a.auth.WriteProblem(w, r, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		a.respond(w, r, http.StatusOK, permission, nil)
+	case http.MethodPatch:
+		var request adminEntityRequest
+		if !a.decode(w, r, &request) {
+			return
+		}
+		if request.DisplayName != nil || request.Metadata != nil {
+			displayName, metadata := permission.DisplayName, permission.Metadata
+			if request.DisplayName != nil {
+				displayName = *request.DisplayName
+			}
+			if request.Metadata != nil {
+				metadata = request.Metadata
+			}
+			err = permission.UpdateDisplayWithContext(ctx, displayName, metadata)
+		}
+		if err == nil && request.Name != nil {
+			err = permission.Rename(ctx, *request.Name)
+		}
+		a.respond(w, r, http.StatusOK, permission, err)
+	case http.MethodDelete:
+		a.respond(w, r, http.StatusNoContent, nil, permission.DeletePermissionWithContext(ctx))
+	default:
+		a.methodNotAllowed(w, r, http.MethodGet, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (a *Admin) decode(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		a.auth.writeError(w, r, http.StatusBadRequest, nil)
+		return false
+	}
+	return true
+}
+
+// respond writes the problem of err, or body as JSON with status
+func (a *Admin) respond(w http.ResponseWriter, r *http.Request, status int, body interface{}, err error) {
+	if err != nil {
+		a.auth.WriteProblem(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if status == http.StatusNoContent {
+		w.WriteHeader(status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err = json.NewEncoder(w).Encode(body); err != nil {
+		logError(r.Context(), err)
+	}
+}
 
+func (a *Admin) methodNotAllowed(w http.ResponseWriter, r *http.Request, methods ...string) {
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	a.auth.writeError(w, r, http.StatusMethodNotAllowed, nil)
 }
 
-func (a *Admin) getRoleDashboard() {
+func adminPage(r *http.Request) (int64, int64) {
+	page, _ := strconv.ParseInt(r.URL.Query().Get("page"), 10, 64)
+	size, _ := strconv.ParseInt(r.URL.Query().Get("size"), 10, 64)
+	if size <= 0 {
+		size = defaultAdminPageSize
+	} else if size > maxAdminPageSize {
+		size = maxAdminPageSize
+	}
+	return page, size
 }
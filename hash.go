@@ -1,13 +1,23 @@
 package pager
 
 import (
+	"fmt"
+
 	uuid "github.com/satori/go.uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func hash(str string) string {
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(str), 10)
-	return string(hashedPassword)
+	hashedPassword, _ := bcryptHash(str, bcrypt.DefaultCost)
+	return hashedPassword
+}
+
+func bcryptHash(str string, cost int) (string, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(str), cost)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrPasswordHash, err)
+	}
+	return string(hashedPassword), nil
 }
 
 func compareHash(storedPassword, password string) bool {
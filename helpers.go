@@ -1,9 +1,68 @@
 package pager
 
-import "log"
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"strings"
+)
 
 func handleFatalError(err error) {
 	if err != nil {
 		log.Fatal(err)
 	}
 }
+
+// prefixColumns renders the column list of a SELECT, qualified with the table alias when given
+func prefixColumns(alias string, columns ...string) string {
+	if alias == "" {
+		return strings.Join(columns, ", ")
+	}
+	qualified := make([]string, len(columns))
+	for i := range columns {
+		qualified[i] = alias + "." + columns[i]
+	}
+	return strings.Join(qualified, ", ")
+}
+
+// textColumn scans nullable text columns, NULL is scanned into an empty string
+type textColumn struct {
+	s *string
+}
+
+func (c textColumn) Scan(value interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+	*c.s = ns.String
+	return nil
+}
+
+// jsonColumn scans nullable JSON encoded text columns into a map
+type jsonColumn struct {
+	m *map[string]interface{}
+}
+
+func (c jsonColumn) Scan(value interface{}) error {
+	var ns sql.NullString
+	if err := ns.Scan(value); err != nil {
+		return err
+	}
+	*c.m = nil
+	if !ns.Valid || ns.String == "" {
+		return nil
+	}
+	return json.Unmarshal([]byte(ns.String), c.m)
+}
+
+func jsonValue(m map[string]interface{}) (interface{}, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return string(raw), nil
+}
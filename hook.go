@@ -0,0 +1,80 @@
+package pager
+
+import (
+	"context"
+)
+
+type HookEvent int
+
+// Constants for entity lifecycle events
+const (
+	BeforeCreate HookEvent = iota
+	AfterCreate
+	BeforeSave
+	AfterSave
+	BeforeDelete
+	AfterDelete
+)
+
+type UserHook func(ctx context.Context, user *User) error
+type RoleHook func(ctx context.Context, role *Role) error
+type PermissionHook func(ctx context.Context, permission *Permission) error
+
+// EntityHooks holds lifecycle callbacks invoked around Create/Save/Delete of the entities.
+// A Before hook returning an error aborts the operation, an After hook error is returned to the caller
+// after the change has been written
+type EntityHooks struct {
+	user       map[HookEvent][]UserHook
+	role       map[HookEvent][]RoleHook
+	permission map[HookEvent][]PermissionHook
+}
+
+func NewEntityHooks() *EntityHooks {
+	return &EntityHooks{
+		user:       make(map[HookEvent][]UserHook),
+		role:       make(map[HookEvent][]RoleHook),
+		permission: make(map[HookEvent][]PermissionHook),
+	}
+}
+
+func (h *EntityHooks) OnUser(event HookEvent, hook UserHook) *EntityHooks {
+	h.user[event] = append(h.user[event], hook)
+	return h
+}
+
+func (h *EntityHooks) OnRole(event HookEvent, hook RoleHook) *EntityHooks {
+	h.role[event] = append(h.role[event], hook)
+	return h
+}
+
+func (h *EntityHooks) OnPermission(event HookEvent, hook PermissionHook) *EntityHooks {
+	h.permission[event] = append(h.permission[event], hook)
+	return h
+}
+
+func runUserHooks(ctx context.Context, event HookEvent, user *User) error {
+	for _, hook := range loadSettings().entityHooks.user[event] {
+		if err := hook(ctx, user); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runRoleHooks(ctx context.Context, event HookEvent, role *Role) error {
+	for _, hook := range loadSettings().entityHooks.role[event] {
+		if err := hook(ctx, role); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runPermissionHooks(ctx context.Context, event HookEvent, permission *Permission) error {
+	for _, hook := range loadSettings().entityHooks.permission[event] {
+		if err := hook(ctx, permission); err != nil {
+			return err
+		}
+	}
+	return nil
+}
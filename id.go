@@ -0,0 +1,66 @@
+package pager
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+)
+
+var ErrSnowflakeNodeRequired = errors.New("Options.SnowflakeNodeID is required with SnowflakeKey")
+
+type PrimaryKeyType int
+
+// Constants for PrimaryKey strategy
+const (
+	AutoIncrementKey PrimaryKeyType = 0
+	UUIDKey          PrimaryKeyType = 1
+	ULIDKey          PrimaryKeyType = 2
+	SnowflakeKey     PrimaryKeyType = 3
+)
+
+// defaultIDGenerator returns the generator used for Options.PrimaryKey when none is set explicitly,
+// AutoIncrementKey has no generator since the key is assigned by the database
+func defaultIDGenerator(options *Options) (IDGenerator, error) {
+	switch options.PrimaryKey {
+	case UUIDKey:
+		return &UUIDGenerator{}, nil
+	case ULIDKey:
+		return &ULIDGenerator{}, nil
+	case SnowflakeKey:
+		// a default node id would let two processes generate the same ids
+		if options.SnowflakeNodeID == nil {
+			return nil, ErrSnowflakeNodeRequired
+		}
+		return NewSnowflakeGenerator(*options.SnowflakeNodeID)
+	}
+	return nil, nil
+}
+
+// newPrimaryKey returns an app-side generated key, or an empty string
+// when the key is assigned by the database (AUTO_INCREMENT)
+func newPrimaryKey() string {
+	idGenerator := loadSettings().idGenerator
+	if idGenerator == nil {
+		return ""
+	}
+	return idGenerator.GenerateID()
+}
+
+// primaryKeyValue converts an empty key into NULL, so MySQL fills the AUTO_INCREMENT value
+func primaryKeyValue(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+func insertedID(id string, result sql.Result) (string, error) {
+	if id != "" {
+		return id, nil
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(lastID, 10), nil
+}
@@ -0,0 +1,155 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidIdentity       = errors.New("identity requires a provider and a subject")
+	ErrIdentityAlreadyLinked = errors.New("identity is already linked to another user")
+)
+
+// Identity links an external account (OAuth/SAML subject) to a user
+type Identity struct {
+	UserID    string    `db:"user_id" json:"user_id"`
+	Provider  string    `db:"provider" json:"provider"`
+	Subject   string    `db:"subject" json:"subject"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func (u *User) LinkIdentity(provider, subject string) error {
+	return u.LinkIdentityWithContext(context.Background(), provider, subject)
+}
+
+// LinkIdentityWithContext links the external subject of provider to the user, linking it again is a no-op
+func (u *User) LinkIdentityWithContext(ctx context.Context, provider, subject string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+	if provider == "" || subject == "" {
+		return ErrInvalidIdentity
+	}
+
+	insertQuery := `INSERT IGNORE INTO rbac_user_identity (
+		user_id,
+		provider,
+		subject,
+		created_at
+	) VALUES (?,?,?,?)`
+	result, err := u.db.ExecContext(ctx, insertQuery, u.ID, provider, subject, clock.Now())
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil || affected > 0 {
+		return err
+	}
+
+	var ownerID string
+	getQuery := `SELECT user_id FROM rbac_user_identity WHERE provider = ? AND subject = ?`
+	err = queryRow(ctx, u.db, getQuery, provider, subject).Scan(&ownerID)
+	if err != nil {
+		return err
+	}
+	if ownerID != u.ID {
+		return ErrIdentityAlreadyLinked
+	}
+	return nil
+}
+
+func (u *User) UnlinkIdentity(provider, subject string) error {
+	return u.UnlinkIdentityWithContext(context.Background(), provider, subject)
+}
+
+func (u *User) UnlinkIdentityWithContext(ctx context.Context, provider, subject string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	deleteQuery := `DELETE FROM rbac_user_identity WHERE user_id = ? AND provider = ? AND subject = ?`
+	_, err := u.db.ExecContext(ctx, deleteQuery, u.ID, provider, subject)
+	return err
+}
+
+func (u *User) GetIdentities() ([]Identity, error) {
+	return u.GetIdentitiesWithContext(context.Background())
+}
+
+func (u *User) GetIdentitiesWithContext(ctx context.Context) ([]Identity, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	getQuery := `SELECT user_id, provider, subject, created_at FROM rbac_user_identity WHERE user_id = ? ORDER BY provider`
+	result, err := u.db.QueryContext(ctx, getQuery, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	identities := make([]Identity, 0)
+	for result.Next() {
+		var identity Identity
+		err = result.Scan(&identity.UserID, &identity.Provider, &identity.Subject, timestamp{&identity.CreatedAt})
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, result.Err()
+}
+
+func FindUserByIdentity(provider, subject string, ptx *PagerTx) (*User, error) {
+	return FindUserByIdentityWithContext(context.Background(), provider, subject, ptx)
+}
+
+// FindUserByIdentityWithContext returns the user linked to the external subject, or nil when none is
+func FindUserByIdentityWithContext(ctx context.Context, provider, subject string, ptx *PagerTx) (*User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	var user = new(User)
+	getQuery := `SELECT ` + userColumns("u") + `
+	FROM rbac_user_identity ui
+	JOIN rbac_user u ON u.id = ui.user_id
+	WHERE ui.provider = ? AND ui.subject = ?`
+	err := queryRow(ctx, db, getQuery, provider, subject).Scan(user.scanFields()...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,124 @@
+package pager
+
+import (
+	"crypto/rand"
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrInvalidSnowflakeNode = errors.New("snowflake node id must be between 0 and 1023")
+
+type IDGenerator interface {
+	GenerateID() string
+}
+
+type UUIDGenerator struct{}
+
+func (g *UUIDGenerator) GenerateID() string {
+	return uuid.NewV4().String()
+}
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator generates 26 characters lexicographically sortable identifier,
+// 48 bits of millisecond timestamp followed by 80 bits of randomness
+type ULIDGenerator struct{}
+
+func (g *ULIDGenerator) GenerateID() string {
+	var id [16]byte
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	id[0] = byte(ms >> 40)
+	id[1] = byte(ms >> 32)
+	id[2] = byte(ms >> 24)
+	id[3] = byte(ms >> 16)
+	id[4] = byte(ms >> 8)
+	id[5] = byte(ms)
+	_, _ = rand.Read(id[6:])
+
+	// encode 128 bits into 26 base32 characters, the first character only carries 3 bits
+	var out [26]byte
+	var acc uint32
+	var bits uint
+	pos := len(out) - 1
+	for i := len(id) - 1; i >= 0; i-- {
+		acc |= uint32(id[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			out[pos] = crockfordAlphabet[acc&0x1f]
+			acc >>= 5
+			bits -= 5
+			pos--
+		}
+	}
+	out[pos] = crockfordAlphabet[acc&0x1f]
+	return string(out[:])
+}
+
+// Constants for Snowflake layout
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxNode      = -1 ^ (-1 << snowflakeNodeBits)
+	snowflakeMaxSequence  = -1 ^ (-1 << snowflakeSequenceBits)
+)
+
+// DefaultSnowflakeEpoch is the custom epoch (2010-11-04 01:42:54.657 UTC) used by SnowflakeGenerator
+var DefaultSnowflakeEpoch = time.Unix(1288834974, 657000000)
+
+// SnowflakeGenerator generates 64 bits identifier composed of 41 bits millisecond timestamp,
+// 10 bits node id and 12 bits sequence. Every node writing to the same tables must use a distinct node id.
+// GenerateID waits for the clock to catch up when it moves backwards, rather than reusing the past timestamps
+type SnowflakeGenerator struct {
+	NodeID int64
+	Epoch  time.Time
+
+	mutex     sync.Mutex
+	lastStamp int64
+	sequence  int64
+}
+
+func NewSnowflakeGenerator(nodeID int64) (*SnowflakeGenerator, error) {
+	if nodeID < 0 || nodeID > snowflakeMaxNode {
+		return nil, ErrInvalidSnowflakeNode
+	}
+	return &SnowflakeGenerator{
+		NodeID: nodeID,
+		Epoch:  DefaultSnowflakeEpoch,
+	}, nil
+}
+
+func (g *SnowflakeGenerator) GenerateID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	stamp := g.currentStamp()
+	for stamp < g.lastStamp {
+		// the clock moved backwards, the ids of the past timestamps may already be taken
+		time.Sleep(time.Duration(g.lastStamp-stamp) * time.Millisecond)
+		stamp = g.currentStamp()
+	}
+	if stamp == g.lastStamp {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSequence
+		if g.sequence == 0 {
+			// sequence exhausted, wait for the next millisecond
+			for stamp <= g.lastStamp {
+				stamp = g.currentStamp()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastStamp = stamp
+
+	id := stamp<<(snowflakeNodeBits+snowflakeSequenceBits) |
+		(g.NodeID&snowflakeMaxNode)<<snowflakeSequenceBits |
+		g.sequence
+	return strconv.FormatInt(id, 10)
+}
+
+func (g *SnowflakeGenerator) currentStamp() int64 {
+	return time.Since(g.Epoch).Nanoseconds() / int64(time.Millisecond)
+}
@@ -0,0 +1,96 @@
+package pager
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestULIDGeneratorSortsByTime(t *testing.T) {
+	generator := &ULIDGenerator{}
+	ids := make([]string, 5)
+	for i := range ids {
+		ids[i] = generator.GenerateID()
+		time.Sleep(2 * time.Millisecond)
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("ids = %v, want them sorted by generation time", ids)
+	}
+	for _, id := range ids {
+		if len(id) != 26 {
+			t.Errorf("len(%q) = %d, want 26", id, len(id))
+		}
+	}
+}
+
+func TestSnowflakeGeneratorIDsAreUnique(t *testing.T) {
+	generator, err := NewSnowflakeGenerator(7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const workers, perWorker = 8, 2000
+	ids := make(chan string, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				ids <- generator.GenerateID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[string]bool, workers*perWorker)
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("id %s was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestSnowflakeGeneratorWaitsForClockRegression(t *testing.T) {
+	generator, err := NewSnowflakeGenerator(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := generator.GenerateID()
+	// the clock moves 20ms backwards
+	generator.lastStamp += 20
+
+	start := time.Now()
+	next := generator.GenerateID()
+	if waited := time.Since(start); waited < 10*time.Millisecond {
+		t.Errorf("GenerateID() returned after %v, want it to wait for the clock", waited)
+	}
+	previousID, _ := strconv.ParseInt(previous, 10, 64)
+	nextID, _ := strconv.ParseInt(next, 10, 64)
+	if nextID <= previousID {
+		t.Errorf("GenerateID() = %d after %d, want increasing ids", nextID, previousID)
+	}
+}
+
+func TestSnowflakeNodeID(t *testing.T) {
+	node := int64(1024)
+	tests := []struct {
+		name    string
+		options Options
+		wantErr error
+	}{
+		{"missing node", Options{PrimaryKey: SnowflakeKey}, ErrSnowflakeNodeRequired},
+		{"node out of range", Options{PrimaryKey: SnowflakeKey, SnowflakeNodeID: &node}, ErrInvalidSnowflakeNode},
+		{"other keys", Options{PrimaryKey: ULIDKey}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := defaultIDGenerator(&test.options); err != test.wantErr {
+				t.Errorf("defaultIDGenerator() = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+package pager
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrJWTDisabled             = errors.New("jwt is not configured, set SessionOptions.JWT")
+	ErrJWTSecret               = errors.New("jwt secret must be at least 32 bytes")
+	ErrStalePermissions        = errors.New("permissions changed since the token was issued")
+	ErrInvalidVerificationMode = errors.New("invalid jwt verification mode")
+)
+
+// VerificationMode picks how much ProtectRouteUsingJWT trusts the roles embedded in the token,
+// trading the freshness of the permissions for latency
+type VerificationMode int
+
+// Constants for the verification modes
+const (
+	// VerifyOnline resolves the principal from the database on every request, like ProtectRouteUsingToken
+	VerifyOnline VerificationMode = 0
+	// VerifyHybrid checks that the subject of the token is still an active user, the permissions come from the
+	// permission cache and the token is rejected with ErrStalePermissions once they no longer match its permissions hash
+	VerifyHybrid VerificationMode = 1
+	// VerifyOffline trusts the subject and the roles of the token until it expires, without reading the user.
+	// The deactivated, deleted and merged users, the revoked and expired roles and the direct grants aren't seen,
+	// only the tokens of RevocationList are rejected. The tokens are issued for OfflineMaxAge at most to bound
+	// that delay. The permissions of the roles are read once per instance and kept until the permission cache is purged
+	VerifyOffline VerificationMode = 2
+)
+
+const (
+	defaultJWTExpiration = time.Hour
+	minJWTSecretLength   = 32
+
+	// defaultOfflineJWTMaxAge bounds the lifetime of the VerifyOffline tokens
+	defaultOfflineJWTMaxAge = 15 * time.Minute
+)
+
+type JWTOptions struct {
+	// Secret signs the tokens with HMAC-SHA256
+	Secret []byte
+	Issuer string
+	// EmbedRoles adds the role names and the permissions hash of the user to the claims,
+	// VerifyHybrid and VerifyOffline require it
+	EmbedRoles   bool
+	Verification VerificationMode
+	// OfflineMaxAge caps the lifetime of the tokens verified with VerifyOffline, 15 minutes when zero
+	OfflineMaxAge time.Duration
+}
+
+func (o *JWTOptions) offlineMaxAge() time.Duration {
+	if o.OfflineMaxAge > 0 {
+		return o.OfflineMaxAge
+	}
+	return defaultOfflineJWTMaxAge
+}
+
+func (o *JWTOptions) validate() error {
+	if len(o.Secret) < minJWTSecretLength {
+		return ErrJWTSecret
+	}
+	switch o.Verification {
+	case VerifyOnline:
+	case VerifyHybrid, VerifyOffline:
+		if !o.EmbedRoles {
+			return ErrInvalidVerificationMode
+		}
+	default:
+		return ErrInvalidVerificationMode
+	}
+	return nil
+}
+
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss,omitempty"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+	// Roles and PermissionsHash are only set with JWTOptions.EmbedRoles
+	Roles           []string          `json:"roles,omitempty"`
+	PermissionsHash string            `json:"ph,omitempty"`
+	Claims          map[string]string `json:"claims,omitempty"`
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignInWithJWT authenticates like SignIn and returns a signed JWT instead of a stored session,
+// it's verified by ProtectRouteUsingJWT and can only be revoked before it expires through SessionOptions.RevocationList
+func (a *Auth) SignInWithJWT(params LoginParams) (*User, string, error) {
+	if a.jwt == nil {
+		return nil, "", ErrJWTDisabled
+	}
+	loggedUser, err := a.Authenticate(params)
+	if err != nil {
+		return nil, "", err
+	}
+	token, err := a.IssueJWT(context.Background(), loggedUser, params.Claims)
+	if err != nil {
+		return nil, "", err
+	}
+	return loggedUser, token, nil
+}
+
+// IssueJWT signs a token for user, e.g. to reissue it once ProtectRouteUsingJWT rejects it with ErrStalePermissions
+func (a *Auth) IssueJWT(ctx context.Context, user *User, claims map[string]string) (string, error) {
+	if a.jwt == nil {
+		return "", ErrJWTDisabled
+	}
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	now := time.Now()
+	payload := jwtClaims{
+		Subject:   user.ID,
+		Issuer:    a.jwt.Issuer,
+		ID:        uuid.NewV4().String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(a.jwtExpiration()).Unix(),
+		Claims:    claims,
+	}
+	if a.jwt.EmbedRoles {
+		roles, err := activeRoleNames(ctx, user.ID)
+		if err != nil {
+			return "", err
+		}
+		loaded, err := loadEffectivePermissions(ctx, dbConnection, []string{user.ID}, 0)
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(roles)
+		payload.Roles = roles
+		payload.PermissionsHash = loaded[user.ID].hash()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(body)
+	return unsigned + "." + a.signJWT(unsigned), nil
+}
+
+func (a *Auth) signJWT(unsigned string) string {
+	mac := hmac.New(sha256.New, a.jwt.Secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (a *Auth) parseJWT(token string) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidAuthorization
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(a.signJWT(parts[0]+"."+parts[1]))) {
+		return nil, ErrInvalidAuthorization
+	}
+	body, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidAuthorization
+	}
+	claims := &jwtClaims{}
+	if err = json.Unmarshal(body, claims); err != nil || claims.Subject == "" {
+		return nil, ErrInvalidAuthorization
+	}
+	if a.jwt.Issuer != "" && claims.Issuer != a.jwt.Issuer {
+		return nil, ErrInvalidAuthorization
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrTokenExpired
+	}
+	if a.revocations != nil {
+		ctx, cancel := withQueryTimeout(context.Background())
+		defer cancel()
+		revoked, err := a.revocations.Revoked(ctx, claims.ID, claims.Subject, time.Unix(claims.IssuedAt, 0))
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// ProtectRouteUsingJWT authenticates the bearer tokens issued by SignInWithJWT,
+// the principal is resolved according to JWTOptions.Verification
+func (a *Auth) ProtectRouteUsingJWT(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.jwt == nil {
+			a.writeError(w, r, http.StatusInternalServerError, ErrJWTDisabled)
+			return
+		}
+		token, err := a.requestBearerToken(r)
+		if err != nil {
+			a.writeError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		claims, err := a.parseJWT(token)
+		if err != nil {
+			a.writeError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+
+		ctx, err := a.jwtPrincipalContext(a.requestContext(r), claims)
+		if err == ErrStalePermissions {
+			a.writeError(w, r, http.StatusUnauthorized, err)
+			return
+		}
+		if err != nil {
+			a.writeError(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *Auth) jwtPrincipalContext(ctx context.Context, claims *jwtClaims) (context.Context, error) {
+	var permissions *effectivePermissions
+	switch a.jwt.Verification {
+	case VerifyHybrid:
+		if err := checkActiveUser(ctx, claims.Subject); err != nil {
+			return nil, err
+		}
+		user := &User{ID: claims.Subject, db: dbConnection}
+		cached, ok := cachedPermissions(ctx, user)
+		if !ok {
+			loaded, err := loadEffectivePermissions(ctx, dbConnection, []string{user.ID}, 0)
+			if err != nil {
+				return nil, err
+			}
+			cached = loaded[user.ID]
+		}
+		if cached.hash() != claims.PermissionsHash {
+			return nil, ErrStalePermissions
+		}
+		permissions = cached
+	case VerifyOffline:
+		// the tokens issued before OfflineMaxAge was lowered
+		if time.Duration(claims.ExpiresAt-claims.IssuedAt)*time.Second > a.jwt.offlineMaxAge() {
+			return nil, ErrTokenExpired
+		}
+		indexed, err := loadSettings().roleIndex.permissions(ctx, claims.Roles)
+		if err != nil {
+			return nil, err
+		}
+		permissions = indexed
+	default:
+		return a.principalContext(ctx, claims.Subject)
+	}
+
+	principal := &Principal{UserID: claims.Subject, Roles: claims.Roles, prefetched: true}
+	principal.permissionsOnce.Do(func() {
+		principal.permissions = permissions
+	})
+	return context.WithValue(ctx, PrincipalKey, principal), nil
+}
+
+// hash digests the granted names and routes, it changes whenever a grant of the user changes
+func (e *effectivePermissions) hash() string {
+	entries := make([]string, 0, len(e.names)+len(e.routes))
+	for name := range e.names {
+		entries = append(entries, "n "+name)
+	}
+	for route := range e.routes {
+		entries = append(entries, "r "+route)
+	}
+	for method, patterns := range e.patterns {
+		for _, pattern := range patterns {
+			entries = append(entries, "r "+routeKey(method, pattern))
+		}
+	}
+	sort.Strings(entries)
+	digest := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return base64.RawURLEncoding.EncodeToString(digest[:16])
+}
+
+// rolePermissionIndex keeps the permissions of the roles named in the offline tokens, it's emptied with the permission cache
+type rolePermissionIndex struct {
+	mutex sync.RWMutex
+	roles map[string]*effectivePermissions
+}
+
+func newRolePermissionIndex() *rolePermissionIndex {
+	return &rolePermissionIndex{roles: make(map[string]*effectivePermissions)}
+}
+
+func (i *rolePermissionIndex) purge() {
+	i.mutex.Lock()
+	i.roles = make(map[string]*effectivePermissions)
+	i.mutex.Unlock()
+}
+
+// permissions merges the permissions of roles, loading the roles missing from the index
+func (i *rolePermissionIndex) permissions(ctx context.Context, roles []string) (*effectivePermissions, error) {
+	merged := newEffectivePermissions(time.Time{})
+	var missing []string
+	i.mutex.RLock()
+	for _, role := range roles {
+		indexed, ok := i.roles[role]
+		if !ok {
+			missing = append(missing, role)
+			continue
+		}
+		merged.merge(indexed)
+	}
+	i.mutex.RUnlock()
+	if len(missing) == 0 {
+		return merged, nil
+	}
+
+	loaded := make(map[string]*effectivePermissions, len(missing))
+	args := make([]interface{}, 0, len(missing))
+	for _, role := range missing {
+		loaded[role] = newEffectivePermissions(time.Time{})
+		args = append(args, role)
+	}
+	getQuery := `SELECT r.name, p.name, p.method, p.route
+	FROM rbac_role r
+	JOIN rbac_role_permission rp ON rp.role_id = r.id
+	JOIN ` + permissionRoutes + ` ON p.id = rp.permission_id
+	WHERE r.name IN (?` + strings.Repeat(",?", len(missing)-1) + `)`
+	result, err := dbConnection.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var role, name, method, route string
+		if err = result.Scan(&role, &name, &method, &route); err != nil {
+			return nil, err
+		}
+		loaded[role].addRoute(name, method, route)
+	}
+	if err = result.Err(); err != nil {
+		return nil, err
+	}
+
+	i.mutex.Lock()
+	for role, permissions := range loaded {
+		i.roles[role] = permissions
+		merged.merge(permissions)
+	}
+	i.mutex.Unlock()
+	return merged, nil
+}
+
+func (e *effectivePermissions) merge(other *effectivePermissions) {
+	for name := range other.names {
+		e.names[name] = true
+	}
+	for route := range other.routes {
+		e.routes[route] = true
+	}
+	for method, patterns := range other.patterns {
+		e.patterns[method] = append(e.patterns[method], patterns...)
+	}
+	for name, routes := range other.namedRoutes {
+		e.namedRoutes[name] = append(e.namedRoutes[name], routes...)
+	}
+}
@@ -0,0 +1,143 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testJWTSecret = []byte("0123456789abcdef0123456789abcdef")
+
+func TestHybridVerificationRejectsInactiveUsers(t *testing.T) {
+	_, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		if strings.HasPrefix(query, "SELECT active, merged_into IS NOT NULL FROM rbac_user") {
+			return fakeRowsOf([]string{"active", "merged"}, false, false)
+		}
+		return fakeResponse{}
+	})
+	defer restore()
+
+	auth := &Auth{jwt: &JWTOptions{Secret: testJWTSecret, EmbedRoles: true, Verification: VerifyHybrid}}
+	claims := &jwtClaims{Subject: "1", IssuedAt: time.Now().Unix(), ExpiresAt: time.Now().Add(time.Hour).Unix()}
+	if _, err := auth.jwtPrincipalContext(context.Background(), claims); err != ErrUserNotActive {
+		t.Fatalf("jwtPrincipalContext() = %v, want %v", err, ErrUserNotActive)
+	}
+}
+
+func TestOfflineVerificationBoundsTheTokenLifetime(t *testing.T) {
+	auth := &Auth{
+		expiredInSeconds: int64((24 * time.Hour).Seconds()),
+		jwt:              &JWTOptions{Secret: testJWTSecret, EmbedRoles: true, Verification: VerifyOffline},
+	}
+	if got := auth.jwtExpiration(); got != defaultOfflineJWTMaxAge {
+		t.Errorf("jwtExpiration() = %v, want %v", got, defaultOfflineJWTMaxAge)
+	}
+
+	now := time.Now()
+	claims := &jwtClaims{Subject: "1", IssuedAt: now.Unix(), ExpiresAt: now.Add(24 * time.Hour).Unix()}
+	if _, err := auth.jwtPrincipalContext(context.Background(), claims); err != ErrTokenExpired {
+		t.Errorf("jwtPrincipalContext() = %v, want %v for a token outliving OfflineMaxAge", err, ErrTokenExpired)
+	}
+}
+
+// signedJWT signs header and claims with the secret of auth, whatever they hold
+func signedJWT(t *testing.T, auth *Auth, header string, claims jwtClaims) string {
+	body, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(header)) + "." + base64.RawURLEncoding.EncodeToString(body)
+	return unsigned + "." + auth.signJWT(unsigned)
+}
+
+func TestParseJWT(t *testing.T) {
+	revocations := NewMemoryRevocationList()
+	auth := &Auth{jwt: &JWTOptions{Secret: testJWTSecret, Issuer: "pager"}, revocations: revocations}
+	issued, err := auth.IssueJWT(context.Background(), &User{ID: "1"}, map[string]string{"tenant": "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(issued, ".")
+	otherSecret := &Auth{jwt: &JWTOptions{Secret: []byte("fedcba9876543210fedcba9876543210"), Issuer: "pager"}}
+	forged, err := otherSecret.IssueJWT(context.Background(), &User{ID: "1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	valid := jwtClaims{Subject: "1", Issuer: "pager", ID: "a", IssuedAt: now.Unix(), ExpiresAt: now.Add(time.Hour).Unix()}
+	expired := valid
+	expired.ExpiresAt = now.Add(-time.Second).Unix()
+	otherIssuer := valid
+	otherIssuer.Issuer = "other"
+	noSubject := valid
+	noSubject.Subject = ""
+	revoked := valid
+	revoked.ID = "revoked"
+	if err = revocations.Revoke(context.Background(), revoked.ID, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	admin, _ := json.Marshal(jwtClaims{Subject: "2", Issuer: "pager", ExpiresAt: now.Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "issued", token: issued},
+		{name: "signed", token: signedJWT(t, auth, `{"alg":"HS256","typ":"JWT"}`, valid)},
+		{name: "alg none", token: signedJWT(t, auth, `{"alg":"none","typ":"JWT"}`, valid), wantErr: ErrInvalidAuthorization},
+		{name: "alg none unsigned", token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." + parts[1] + ".", wantErr: ErrInvalidAuthorization},
+		{name: "alg HS512", token: signedJWT(t, auth, `{"alg":"HS512","typ":"JWT"}`, valid), wantErr: ErrInvalidAuthorization},
+		{name: "other secret", token: forged, wantErr: ErrInvalidAuthorization},
+		{name: "modified claims", token: parts[0] + "." + base64.RawURLEncoding.EncodeToString(admin) + "." + parts[2], wantErr: ErrInvalidAuthorization},
+		{name: "missing signature", token: parts[0] + "." + parts[1], wantErr: ErrInvalidAuthorization},
+		{name: "expired", token: signedJWT(t, auth, `{"alg":"HS256","typ":"JWT"}`, expired), wantErr: ErrTokenExpired},
+		{name: "other issuer", token: signedJWT(t, auth, `{"alg":"HS256","typ":"JWT"}`, otherIssuer), wantErr: ErrInvalidAuthorization},
+		{name: "missing subject", token: signedJWT(t, auth, `{"alg":"HS256","typ":"JWT"}`, noSubject), wantErr: ErrInvalidAuthorization},
+		{name: "revoked", token: signedJWT(t, auth, `{"alg":"HS256","typ":"JWT"}`, revoked), wantErr: ErrTokenRevoked},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := auth.parseJWT(test.token)
+			if err != test.wantErr {
+				t.Fatalf("parseJWT() = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && claims.Subject != "1" {
+				t.Errorf("Subject = %q, want %q", claims.Subject, "1")
+			}
+		})
+	}
+
+	claims, err := auth.parseJWT(issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Claims["tenant"] != "acme" || claims.ExpiresAt-claims.IssuedAt != int64(defaultJWTExpiration.Seconds()) {
+		t.Errorf("claims = %+v, want the tenant claim and the default expiration", claims)
+	}
+}
+
+func TestJWTOptionsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options JWTOptions
+		wantErr error
+	}{
+		{name: "online", options: JWTOptions{Secret: testJWTSecret}},
+		{name: "short secret", options: JWTOptions{Secret: []byte("secret")}, wantErr: ErrJWTSecret},
+		{name: "hybrid without roles", options: JWTOptions{Secret: testJWTSecret, Verification: VerifyHybrid}, wantErr: ErrInvalidVerificationMode},
+		{name: "offline", options: JWTOptions{Secret: testJWTSecret, EmbedRoles: true, Verification: VerifyOffline}},
+		{name: "unknown mode", options: JWTOptions{Secret: testJWTSecret, Verification: 7}, wantErr: ErrInvalidVerificationMode},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.options.validate(); err != test.wantErr {
+				t.Errorf("validate() = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
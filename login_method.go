@@ -0,0 +1,118 @@
+package pager
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidLoginMethod  = errors.New(`invalid login method, expected "email", "username" or "email|username"`)
+	ErrInvalidAuthStrategy = errors.New(`invalid auth strategy, expected "cookie" or "token"`)
+)
+
+// LoginMethod picks the identifier accepted by Authenticate. The numeric values are kept for
+// compatibility, configuration should use the names understood by ParseLoginMethod
+type LoginMethod int
+
+const (
+	loginMethodEmail    = "email"
+	loginMethodUsername = "username"
+)
+
+func (m LoginMethod) String() string {
+	switch m {
+	case LoginEmail:
+		return loginMethodEmail
+	case LoginUsername:
+		return loginMethodUsername
+	case LoginEmailUsername:
+		return loginMethodEmail + "|" + loginMethodUsername
+	}
+	return fmt.Sprintf("LoginMethod(%d)", int(m))
+}
+
+// ParseLoginMethod parses "email", "username" or both joined by '|' in any order, case-insensitive
+func ParseLoginMethod(value string) (LoginMethod, error) {
+	var email, username bool
+	for _, part := range strings.Split(value, "|") {
+		switch strings.ToLower(strings.TrimSpace(part)) {
+		case loginMethodEmail:
+			email = true
+		case loginMethodUsername:
+			username = true
+		default:
+			return 0, ErrInvalidLoginMethod
+		}
+	}
+	switch {
+	case email && username:
+		return LoginEmailUsername, nil
+	case username:
+		return LoginUsername, nil
+	}
+	return LoginEmail, nil
+}
+
+func (m LoginMethod) MarshalText() ([]byte, error) {
+	switch m {
+	case LoginEmail, LoginUsername, LoginEmailUsername:
+		return []byte(m.String()), nil
+	}
+	return nil, ErrInvalidLoginMethod
+}
+
+// UnmarshalText lets JSON, YAML or env decoders read the LoginMethod by name
+func (m *LoginMethod) UnmarshalText(text []byte) error {
+	method, err := ParseLoginMethod(string(text))
+	if err != nil {
+		return err
+	}
+	*m = method
+	return nil
+}
+
+// AuthStrategy tells where the middleware reads the session token from
+type AuthStrategy int
+
+const (
+	authStrategyCookie = "cookie"
+	authStrategyToken  = "token"
+)
+
+func (s AuthStrategy) String() string {
+	switch s {
+	case CookieBasedAuth:
+		return authStrategyCookie
+	case TokenBasedAuth:
+		return authStrategyToken
+	}
+	return fmt.Sprintf("AuthStrategy(%d)", int(s))
+}
+
+func ParseAuthStrategy(value string) (AuthStrategy, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case authStrategyCookie:
+		return CookieBasedAuth, nil
+	case authStrategyToken:
+		return TokenBasedAuth, nil
+	}
+	return 0, ErrInvalidAuthStrategy
+}
+
+func (s AuthStrategy) MarshalText() ([]byte, error) {
+	switch s {
+	case CookieBasedAuth, TokenBasedAuth:
+		return []byte(s.String()), nil
+	}
+	return nil, ErrInvalidAuthStrategy
+}
+
+func (s *AuthStrategy) UnmarshalText(text []byte) error {
+	strategy, err := ParseAuthStrategy(string(text))
+	if err != nil {
+		return err
+	}
+	*s = strategy
+	return nil
+}
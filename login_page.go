@@ -0,0 +1,267 @@
+package pager
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+)
+
+const (
+	// CSRFCookieName and CSRFFormField carry the double-submit token of the login and logout forms
+	CSRFCookieName = "pager_csrf"
+	CSRFFormField  = "csrf_token"
+
+	csrfTokenSize = 32
+)
+
+var ErrInvalidCSRFToken = errors.New("invalid or missing csrf token")
+
+// LoginPageOptions enables the server-rendered login and logout pages, meant for internal tools.
+// The templates are html/template sources executed against LoginPageData, the defaults are used when empty.
+// The forms of custom templates must post the token: <input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+type LoginPageOptions struct {
+	Title          string
+	LoginPath      string
+	LogoutPath     string
+	RedirectTo     string
+	LoginTemplate  string
+	LogoutTemplate string
+}
+
+type LoginPageData struct {
+	Title      string
+	Action     string
+	Identifier string
+	Next       string
+	Error      string
+	// CSRFToken is the value of the csrf_token field, the POST is rejected unless it matches the pager_csrf cookie
+	CSRFToken string
+}
+
+const defaultLoginPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="next" value="{{.Next}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<p><label>Email or username <input name="identifier" value="{{.Identifier}}" autocomplete="username" required autofocus></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body></html>
+`
+
+const defaultLogoutPage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<p><button type="submit">Sign out</button></p>
+</form>
+</body></html>
+`
+
+type loginPages struct {
+	opts   LoginPageOptions
+	login  *htmltemplate.Template
+	logout *htmltemplate.Template
+}
+
+func newLoginPages(opts LoginPageOptions) (*loginPages, error) {
+	if opts.Title == "" {
+		opts.Title = "Sign in"
+	}
+	if opts.LoginPath == "" {
+		opts.LoginPath = "/login"
+	}
+	if opts.LogoutPath == "" {
+		opts.LogoutPath = "/logout"
+	}
+	if opts.RedirectTo == "" {
+		opts.RedirectTo = "/"
+	}
+	if opts.LoginTemplate == "" {
+		opts.LoginTemplate = defaultLoginPage
+	}
+	if opts.LogoutTemplate == "" {
+		opts.LogoutTemplate = defaultLogoutPage
+	}
+
+	login, err := htmltemplate.New("login").Parse(opts.LoginTemplate)
+	if err != nil {
+		return nil, err
+	}
+	logout, err := htmltemplate.New("logout").Parse(opts.LogoutTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return &loginPages{opts: opts, login: login, logout: logout}, nil
+}
+
+// safeRedirect only follows local paths, so the login page can't be used as an open redirect
+func safeRedirect(next, fallback string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return fallback
+	}
+	return next
+}
+
+// csrfToken returns the token of the csrf cookie of r, or sets a new one. The token is kept across the renders,
+// so the forms of several tabs stay valid
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(CSRFCookieName); err == nil && len(cookie.Value) == base64.RawURLEncoding.EncodedLen(csrfTokenSize) && visibleASCII(cookie.Value) {
+		return cookie.Value, nil
+	}
+	token := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	value := base64.RawURLEncoding.EncodeToString(token)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return value, nil
+}
+
+// validCSRFToken compares the token posted by the form with the csrf cookie, r.ParseForm must have been called
+func validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostForm.Get(CSRFFormField))) == 1
+}
+
+// renderForm renders tmpl with a csrf token
+func (l *loginPages) renderForm(w http.ResponseWriter, r *http.Request, tmpl *htmltemplate.Template, status int, data LoginPageData) {
+	token, err := csrfToken(w, r)
+	if err != nil {
+		logError(r.Context(), err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	data.CSRFToken = token
+	l.render(w, r, tmpl, status, data)
+}
+
+func (l *loginPages) render(w http.ResponseWriter, r *http.Request, tmpl *htmltemplate.Template, status int, data LoginPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	if err := tmpl.Execute(w, data); err != nil {
+		logf(r.Context(), "failed to render the %s page, err = %s", tmpl.Name(), err)
+	}
+}
+
+// LoginPageHandler serves the login form on GET and signs in with SignInWithCookie on POST,
+// it responds 404 unless Options.LoginPages is set
+func (a *Auth) LoginPageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages := a.loginPages
+		if pages == nil {
+			a.writeError(w, r, http.StatusNotFound, nil)
+			return
+		}
+
+		data := LoginPageData{
+			Title:  pages.opts.Title,
+			Action: pages.opts.LoginPath,
+			Next:   safeRedirect(r.URL.Query().Get("next"), pages.opts.RedirectTo),
+		}
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			pages.renderForm(w, r, pages.login, http.StatusOK, data)
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			a.writeError(w, r, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		if !a.allowedFormOrigin(r) {
+			a.writeError(w, r, http.StatusForbidden, ErrOriginNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			a.writeError(w, r, http.StatusBadRequest, nil)
+			return
+		}
+		if !validCSRFToken(r) {
+			a.writeError(w, r, http.StatusForbidden, ErrInvalidCSRFToken)
+			return
+		}
+		data.Identifier = r.PostForm.Get("identifier")
+		data.Next = safeRedirect(r.PostForm.Get("next"), pages.opts.RedirectTo)
+
+		params := a.LoginParamsFromRequest(r, data.Identifier, r.PostForm.Get("password"))
+		_, err := a.SignInWithCookie(w, params)
+		if err != nil {
+			status := http.StatusUnauthorized
+			if err == ErrLoginThrottled || errors.Is(err, ErrTooManyAttempts) {
+				status = http.StatusTooManyRequests
+			}
+			setRetryAfter(w, err)
+			data.Error = LocalizeError(err, r.Header.Get("Accept-Language"))
+			pages.renderForm(w, r, pages.login, status, data)
+			return
+		}
+		http.Redirect(w, r, data.Next, http.StatusSeeOther)
+	})
+}
+
+// LogoutPageHandler asks for a confirmation on GET and clears the session cookie on POST,
+// it responds 404 unless Options.LoginPages is set
+func (a *Auth) LogoutPageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages := a.loginPages
+		if pages == nil {
+			a.writeError(w, r, http.StatusNotFound, nil)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			pages.renderForm(w, r, pages.logout, http.StatusOK, LoginPageData{
+				Title:  pages.opts.Title,
+				Action: pages.opts.LogoutPath,
+			})
+			return
+		case http.MethodPost:
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			a.writeError(w, r, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		if !a.allowedFormOrigin(r) {
+			a.writeError(w, r, http.StatusForbidden, ErrOriginNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			a.writeError(w, r, http.StatusBadRequest, nil)
+			return
+		}
+		if !validCSRFToken(r) {
+			a.writeError(w, r, http.StatusForbidden, ErrInvalidCSRFToken)
+			return
+		}
+		if err := a.ClearSession(w, r); err != nil && err != ErrInvalidCookie {
+			logError(r.Context(), err)
+		}
+		http.Redirect(w, r, pages.opts.LoginPath, http.StatusSeeOther)
+	})
+}
@@ -0,0 +1,118 @@
+package pager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func loginPagesAuth(t *testing.T, cookieDomain string) *Auth {
+	pages, err := newLoginPages(LoginPageOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Auth{SessionName: "session", cookieDomain: cookieDomain, loginPages: pages}
+}
+
+// csrfCookie renders the logout form and returns its csrf cookie
+func csrfCookie(t *testing.T, auth *Auth) *http.Cookie {
+	w := httptest.NewRecorder()
+	auth.LogoutPageHandler().ServeHTTP(w, httptest.NewRequest("GET", "http://app.example.com/logout", nil))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == CSRFCookieName {
+			if !strings.Contains(w.Body.String(), `value="`+cookie.Value+`"`) {
+				t.Fatalf("the logout form doesn't carry the csrf token %q", cookie.Value)
+			}
+			return cookie
+		}
+	}
+	t.Fatal("the logout form didn't set the csrf cookie")
+	return nil
+}
+
+func postLogout(auth *Auth, headers map[string]string, cookie *http.Cookie, token string) int {
+	form := url.Values{CSRFFormField: {token}}
+	r := httptest.NewRequest("POST", "http://app.example.com/logout", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for name, value := range headers {
+		r.Header.Set(name, value)
+	}
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	auth.LogoutPageHandler().ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestLogoutPageRejectsCrossSiteForms(t *testing.T) {
+	for _, cookieDomain := range []string{"", "example.com"} {
+		auth := loginPagesAuth(t, cookieDomain)
+		cookie := csrfCookie(t, auth)
+
+		tests := []struct {
+			name    string
+			headers map[string]string
+			cookie  *http.Cookie
+			token   string
+			want    int
+		}{
+			{"same origin", map[string]string{"Origin": "http://app.example.com"}, cookie, cookie.Value, http.StatusSeeOther},
+			{"same origin referer", map[string]string{"Referer": "http://app.example.com/logout"}, cookie, cookie.Value, http.StatusSeeOther},
+			{"foreign origin", map[string]string{"Origin": "http://evil.test"}, cookie, cookie.Value, http.StatusForbidden},
+			{"foreign referer", map[string]string{"Referer": "http://evil.test/form"}, cookie, cookie.Value, http.StatusForbidden},
+			{"opaque origin", map[string]string{"Origin": "null"}, cookie, cookie.Value, http.StatusForbidden},
+			{"missing token", nil, cookie, "", http.StatusForbidden},
+			{"missing cookie", nil, nil, cookie.Value, http.StatusForbidden},
+			{"mismatching token", nil, cookie, strings.Repeat("A", len(cookie.Value)), http.StatusForbidden},
+		}
+		for _, test := range tests {
+			if got := postLogout(auth, test.headers, test.cookie, test.token); got != test.want {
+				t.Errorf("cookie domain %q, %s: POST /logout = %d, want %d", cookieDomain, test.name, got, test.want)
+			}
+		}
+	}
+}
+
+func TestAllowedOriginWithoutCookieDomain(t *testing.T) {
+	auth := &Auth{origin: "https://app.example.com"}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"", true},
+		{"http://api.example.com", true},
+		{"http://API.example.com", true},
+		{"https://app.example.com", true},
+		{"http://api.example.com:8080", false},
+		{"http://evil.test", false},
+		{"null", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "http://api.example.com/users", nil)
+		if test.origin != "" {
+			r.Header.Set("Origin", test.origin)
+		}
+		if got := auth.allowedOrigin(r); got != test.want {
+			t.Errorf("allowedOrigin(%q) = %v, want %v", test.origin, got, test.want)
+		}
+	}
+}
+
+func TestLoginPageKeepsCSRFToken(t *testing.T) {
+	auth := loginPagesAuth(t, "")
+	cookie := csrfCookie(t, auth)
+
+	r := httptest.NewRequest("GET", "http://app.example.com/login", nil)
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	auth.LoginPageHandler().ServeHTTP(w, r)
+	if len(w.Result().Cookies()) != 0 {
+		t.Error("the login form replaced a valid csrf cookie")
+	}
+	if !strings.Contains(w.Body.String(), `name="csrf_token" value="`+cookie.Value+`"`) {
+		t.Error("the login form doesn't carry the token of the csrf cookie")
+	}
+}
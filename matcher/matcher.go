@@ -0,0 +1,169 @@
+// Package matcher implements the route patterns of the pager permissions, so applications can apply
+// the same matching semantics in their own checks.
+//
+// A pattern is a path whose segments are either literal, "*" or a ":name" parameter:
+//
+//	/users/:id          matches /users/42
+//	/users/:id(\d+)     matches /users/42 but not /users/me
+//	/users/*/posts      matches /users/42/posts
+//	/users/*            matches /users/42 and /users/42/posts
+//
+// A "*" ending the pattern matches every remaining segment (prefix matching), elsewhere it matches one segment.
+// A parameter may constrain its segment with a regular expression between parentheses, which can't contain "/".
+package matcher
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidPattern = errors.New("invalid route pattern")
+
+type segmentKind int
+
+const (
+	literalSegment segmentKind = iota
+	wildcardSegment
+	paramSegment
+)
+
+type segment struct {
+	kind  segmentKind
+	value string
+	// constraint restricts a parameter segment, nil accepts any segment
+	constraint *regexp.Regexp
+}
+
+// Matcher is a compiled route pattern, safe for concurrent use
+type Matcher struct {
+	pattern  string
+	segments []segment
+	// prefix is set when the pattern ends with "*"
+	prefix bool
+}
+
+// IsPattern reports whether route holds a wildcard or a parameter segment, other routes only match themselves
+func IsPattern(route string) bool {
+	return strings.Contains(route, "*") || strings.Contains(route, "/:")
+}
+
+// Compile parses pattern, it fails with ErrInvalidPattern on a malformed parameter constraint
+func Compile(pattern string) (*Matcher, error) {
+	m := &Matcher{pattern: pattern}
+	if !IsPattern(pattern) {
+		return m, nil
+	}
+
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	m.segments = make([]segment, 0, len(parts))
+	for i, part := range parts {
+		switch {
+		case part == "*":
+			if i == len(parts)-1 {
+				m.prefix = true
+			}
+			m.segments = append(m.segments, segment{kind: wildcardSegment})
+		case strings.HasPrefix(part, ":"):
+			name, constraint, err := parseParam(part[1:])
+			if err != nil {
+				return nil, err
+			}
+			m.segments = append(m.segments, segment{kind: paramSegment, value: name, constraint: constraint})
+		default:
+			m.segments = append(m.segments, segment{kind: literalSegment, value: part})
+		}
+	}
+	return m, nil
+}
+
+func parseParam(param string) (string, *regexp.Regexp, error) {
+	open := strings.Index(param, "(")
+	if open < 0 {
+		return param, nil, nil
+	}
+	if !strings.HasSuffix(param, ")") {
+		return "", nil, ErrInvalidPattern
+	}
+	constraint, err := regexp.Compile("^(?:" + param[open+1:len(param)-1] + ")$")
+	if err != nil {
+		return "", nil, ErrInvalidPattern
+	}
+	return param[:open], constraint, nil
+}
+
+// MustCompile is like Compile but panics on an invalid pattern
+func MustCompile(pattern string) *Matcher {
+	m, err := Compile(pattern)
+	if err != nil {
+		panic(err.Error() + ": " + pattern)
+	}
+	return m
+}
+
+func (m *Matcher) String() string {
+	return m.pattern
+}
+
+// Match reports whether path matches the pattern
+func (m *Matcher) Match(path string) bool {
+	_, ok := m.match(path)
+	return ok
+}
+
+// Params returns the values of the parameter segments of path, nil when path doesn't match
+func (m *Matcher) Params(path string) map[string]string {
+	params, ok := m.match(path)
+	if !ok {
+		return nil
+	}
+	if params == nil {
+		params = make(map[string]string)
+	}
+	return params
+}
+
+func (m *Matcher) match(path string) (map[string]string, bool) {
+	if m.segments == nil {
+		return nil, path == m.pattern
+	}
+
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var params map[string]string
+	for i, seg := range m.segments {
+		if i >= len(parts) || parts[i] == "" {
+			return nil, false
+		}
+		switch seg.kind {
+		case wildcardSegment:
+			if m.prefix && i == len(m.segments)-1 {
+				return params, true
+			}
+		case paramSegment:
+			if seg.constraint != nil && !seg.constraint.MatchString(parts[i]) {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[seg.value] = parts[i]
+		default:
+			if seg.value != parts[i] {
+				return nil, false
+			}
+		}
+	}
+	return params, len(m.segments) == len(parts)
+}
+
+// Match compiles pattern and matches path against it, an invalid pattern matches nothing
+func Match(pattern, path string) bool {
+	if pattern == path {
+		return true
+	}
+	m, err := Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return m.Match(path)
+}
@@ -0,0 +1,117 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/users", "/users", true},
+		{"/users", "/users/", false},
+		{"/users", "/users/42", false},
+		{"/users/:id", "/users/42", true},
+		{"/users/:id", "/users/42/", true},
+		{"/users/:id", "/users", false},
+		{"/users/:id", "/users//", false},
+		{"/users/:id", "/users/42/posts", false},
+		{`/users/:id(\d+)`, "/users/42", true},
+		{`/users/:id(\d+)`, "/users/me", false},
+		{`/users/:id(\d+)`, "/users/42a", false},
+		{`/users/:id(me|\d+)/posts`, "/users/me/posts", true},
+		{"/users/*/posts", "/users/42/posts", true},
+		{"/users/*/posts", "/users/42/43/posts", false},
+		{"/users/*", "/users/42", true},
+		{"/users/*", "/users/42/posts/7", true},
+		{"/users/*", "/users", false},
+		{"/users/*", "/users/", false},
+		{"/*", "/anything/at/all", true},
+		{"/users/:id(", "/users/42", false},
+		{`/users/:id([)`, "/users/42", false},
+	}
+	for _, test := range tests {
+		if got := Match(test.pattern, test.path); got != test.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", test.pattern, test.path, got, test.want)
+		}
+	}
+}
+
+func TestCompileRejectsInvalidConstraints(t *testing.T) {
+	for _, pattern := range []string{"/users/:id(", `/users/:id(\d+`, "/users/:id([)"} {
+		if _, err := Compile(pattern); err != ErrInvalidPattern {
+			t.Errorf("Compile(%q) = %v, want ErrInvalidPattern", pattern, err)
+		}
+	}
+}
+
+func TestParams(t *testing.T) {
+	m := MustCompile(`/orgs/:org/users/:id(\d+)`)
+	if got, want := m.Params("/orgs/acme/users/42"), map[string]string{"org": "acme", "id": "42"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Params() = %v, want %v", got, want)
+	}
+	if got := m.Params("/orgs/acme/users/me"); got != nil {
+		t.Errorf("Params() of a path not matching = %v, want nil", got)
+	}
+	if got := MustCompile("/users/*").Params("/users/42"); got == nil || len(got) != 0 {
+		t.Errorf("Params() of a pattern without parameters = %v, want an empty map", got)
+	}
+}
+
+func BenchmarkMatchLiteral(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile("/users/profile")
+	for i := 0; i < b.N; i++ {
+		m.Match("/users/profile")
+	}
+}
+
+func BenchmarkMatchParam(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile("/orgs/:org/users/:id")
+	for i := 0; i < b.N; i++ {
+		m.Match("/orgs/acme/users/42")
+	}
+}
+
+func BenchmarkMatchRegex(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile(`/orgs/:org([a-z]+)/users/:id(\d+)`)
+	for i := 0; i < b.N; i++ {
+		m.Match("/orgs/acme/users/42")
+	}
+}
+
+func BenchmarkMatchRegexMiss(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile(`/orgs/:org([a-z]+)/users/:id(\d+)`)
+	for i := 0; i < b.N; i++ {
+		m.Match("/orgs/acme/users/me")
+	}
+}
+
+func BenchmarkMatchPrefix(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile("/files/*")
+	for i := 0; i < b.N; i++ {
+		m.Match("/files/2024/reports/q1/summary.pdf")
+	}
+}
+
+func BenchmarkMatchPrefixMiss(b *testing.B) {
+	b.ReportAllocs()
+	m := MustCompile("/files/*")
+	for i := 0; i < b.N; i++ {
+		m.Match("/images/2024/reports/q1/summary.pdf")
+	}
+}
+
+func BenchmarkCompileRegex(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Compile(`/orgs/:org([a-z]+)/users/:id(\d+)`)
+	}
+}
@@ -0,0 +1,318 @@
+package pager
+
+import (
+	"context"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const memorySessionShards = 32
+
+type memoryEntry struct {
+	value     string
+	members   map[string]struct{}
+	expiresAt time.Time
+}
+
+func (e *memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+type memoryShard struct {
+	mutex   sync.RWMutex
+	entries map[string]*memoryEntry
+}
+
+// MemorySessionStore keeps the sessions in the process memory, for development and CI environments without redis.
+// Sessions don't survive restarts and aren't shared between instances. Expired keys are evicted on access
+// and by a janitor running every cleanup interval, stop it with Close
+type MemorySessionStore struct {
+	shards [memorySessionShards]*memoryShard
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewMemorySessionStore starts the janitor with cleanupInterval, one minute when zero
+func NewMemorySessionStore(cleanupInterval time.Duration) *MemorySessionStore {
+	if cleanupInterval <= 0 {
+		cleanupInterval = time.Minute
+	}
+	store := &MemorySessionStore{done: make(chan struct{})}
+	for i := range store.shards {
+		store.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+	go store.janitor(cleanupInterval)
+	return store
+}
+
+func (s *MemorySessionStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.evictExpired()
+		}
+	}
+}
+
+func (s *MemorySessionStore) evictExpired() {
+	now := time.Now()
+	for _, shard := range s.shards {
+		shard.mutex.Lock()
+		for key, entry := range shard.entries {
+			if entry.expired(now) {
+				delete(shard.entries, key)
+			}
+		}
+		shard.mutex.Unlock()
+	}
+}
+
+// Close stops the janitor
+func (s *MemorySessionStore) Close() {
+	s.once.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *MemorySessionStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%memorySessionShards]
+}
+
+func expiresAt(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
+
+// get returns the live entry of key, the caller holds the shard lock
+func (sh *memoryShard) get(key string) *memoryEntry {
+	entry, ok := sh.entries[key]
+	if !ok {
+		return nil
+	}
+	if entry.expired(time.Now()) {
+		delete(sh.entries, key)
+		return nil
+	}
+	return entry
+}
+
+func (s *MemorySessionStore) Set(key, value string, expiration time.Duration) error {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	shard.entries[key] = &memoryEntry{value: value, expiresAt: expiresAt(expiration)}
+	shard.mutex.Unlock()
+	return nil
+}
+
+func (s *MemorySessionStore) Get(key string) (string, error) {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	entry := shard.get(key)
+	if entry == nil || entry.members != nil {
+		return "", ErrSessionNotFound
+	}
+	return entry.value, nil
+}
+
+func (s *MemorySessionStore) Delete(keys ...string) error {
+	for _, key := range keys {
+		shard := s.shard(key)
+		shard.mutex.Lock()
+		delete(shard.entries, key)
+		shard.mutex.Unlock()
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) Increment(key string, expiration time.Duration) (int64, error) {
+	shard := s.shard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	entry := shard.get(key)
+	if entry == nil {
+		entry = &memoryEntry{value: "0", expiresAt: expiresAt(expiration)}
+		shard.entries[key] = entry
+	}
+	counter, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	counter++
+	entry.value = strconv.FormatInt(counter, 10)
+	return counter, nil
+}
+
+func (s *MemorySessionStore) SetIndexed(key, value string, expiration time.Duration, indexKey string) error {
+	if err := s.Set(key, value, expiration); err != nil {
+		return err
+	}
+
+	shard := s.shard(indexKey)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	newest := expiresAt(expiration)
+	index := shard.get(indexKey)
+	if index == nil {
+		index = &memoryEntry{members: make(map[string]struct{}), expiresAt: newest}
+		shard.entries[indexKey] = index
+	}
+	index.members[key] = struct{}{}
+	// the index lives as long as its newest member
+	if !index.expiresAt.IsZero() && (newest.IsZero() || newest.After(index.expiresAt)) {
+		index.expiresAt = newest
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) IndexMembers(indexKey string) ([]string, error) {
+	shard := s.shard(indexKey)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	index := shard.get(indexKey)
+	if index == nil {
+		return nil, nil
+	}
+	members := make([]string, 0, len(index.members))
+	for member := range index.members {
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+func (s *MemorySessionStore) DeleteBatched(keys []string, batchSize int, progress func(deleted, total int)) error {
+	if batchSize <= 0 {
+		batchSize = len(keys)
+	}
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if err := s.Delete(keys[start:end]...); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(end, len(keys))
+		}
+	}
+	return nil
+}
+
+// ttl returns the remaining lifetime of key, zero for missing or expired keys
+func (s *MemorySessionStore) ttl(key string, now time.Time) time.Duration {
+	shard := s.shard(key)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	entry, ok := shard.entries[key]
+	if !ok || entry.expired(now) {
+		return 0
+	}
+	if entry.expiresAt.IsZero() {
+		return -1
+	}
+	return entry.expiresAt.Sub(now)
+}
+
+// keys returns the live keys matching the glob pattern
+func (s *MemorySessionStore) keys(match string) []string {
+	now := time.Now()
+	keys := make([]string, 0)
+	for _, shard := range s.shards {
+		shard.mutex.RLock()
+		for key, entry := range shard.entries {
+			if entry.expired(now) {
+				continue
+			}
+			if matched, _ := path.Match(match, key); matched {
+				keys = append(keys, key)
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	return keys
+}
+
+func (s *MemorySessionStore) SessionStats(ctx context.Context, indexMatch string, lifetime time.Duration) (*SessionStats, error) {
+	stats := &SessionStats{SessionsPerUser: make(map[int]int64)}
+	var totalAge time.Duration
+	now := time.Now()
+	for _, indexKey := range s.keys(indexMatch) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		members, err := s.IndexMembers(indexKey)
+		if err != nil {
+			return nil, err
+		}
+		active := 0
+		for _, member := range members {
+			ttl := s.ttl(member, now)
+			if ttl == 0 {
+				continue
+			}
+			active++
+			if ttl > 0 {
+				totalAge += lifetime - ttl
+			}
+		}
+		if active > 0 {
+			stats.Users++
+			stats.ActiveSessions += int64(active)
+			stats.SessionsPerUser[active]++
+		}
+	}
+	if stats.ActiveSessions > 0 {
+		stats.AverageAge = totalAge / time.Duration(stats.ActiveSessions)
+	}
+	return stats, nil
+}
+
+// MigrateKeys renames the keys matching the glob pattern into prefix, the keys already prefixed are skipped
+func (s *MemorySessionStore) MigrateKeys(match, prefix string) (int, error) {
+	migrated := 0
+	for _, key := range s.keys(match) {
+		if strings.HasPrefix(key, prefix) {
+			continue
+		}
+		shard := s.shard(key)
+		shard.mutex.Lock()
+		entry := shard.get(key)
+		shard.mutex.Unlock()
+		if entry == nil {
+			continue
+		}
+
+		// like RENAMENX, an existing prefixed key wins
+		target := s.shard(prefix + key)
+		target.mutex.Lock()
+		moved := target.get(prefix+key) == nil
+		if moved {
+			target.entries[prefix+key] = entry
+		}
+		target.mutex.Unlock()
+		if !moved {
+			continue
+		}
+
+		shard.mutex.Lock()
+		if shard.entries[key] == entry {
+			delete(shard.entries, key)
+		}
+		shard.mutex.Unlock()
+		migrated++
+	}
+	return migrated, nil
+}
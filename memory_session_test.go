@@ -0,0 +1,93 @@
+package pager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	const ttl = 30 * time.Millisecond
+	tests := []struct {
+		name       string
+		expiration time.Duration
+		wait       time.Duration
+		wantErr    error
+	}{
+		{name: "live", expiration: time.Minute, wait: ttl + 20*time.Millisecond},
+		{name: "expired", expiration: ttl, wait: ttl + 20*time.Millisecond, wantErr: ErrSessionNotFound},
+		{name: "without expiration", wait: ttl + 20*time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemorySessionStore(time.Hour)
+			defer store.Close()
+
+			if err := store.Set("session", "value", test.expiration); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(test.wait)
+			value, err := store.Get("session")
+			if err != test.wantErr {
+				t.Fatalf("Get() = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && value != "value" {
+				t.Errorf("Get() = %q, want %q", value, "value")
+			}
+		})
+	}
+}
+
+func TestMemorySessionStoreJanitorEvictsExpiredKeys(t *testing.T) {
+	store := NewMemorySessionStore(10 * time.Millisecond)
+	defer store.Close()
+
+	if err := store.Set("session", "value", 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(store.shard("session").entries) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the janitor didn't evict the expired session")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestMemorySessionStoreIncrementKeepsTheFirstExpiration(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+	defer store.Close()
+
+	for i := int64(1); i <= 3; i++ {
+		counter, err := store.Increment("attempts", 40*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if counter != i {
+			t.Fatalf("Increment() = %d, want %d", counter, i)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if counter, _ := store.Increment("attempts", 40*time.Millisecond); counter != 1 {
+		t.Errorf("Increment() = %d after the window, want a new counter", counter)
+	}
+}
+
+func TestMemorySessionStoreIndexOutlivesItsMembers(t *testing.T) {
+	store := NewMemorySessionStore(time.Hour)
+	defer store.Close()
+
+	if err := store.SetIndexed("short", "1", 20*time.Millisecond, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetIndexed("long", "1", time.Minute, "user:1"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(40 * time.Millisecond)
+	if ttl := store.ttl("user:1", time.Now()); ttl < 50*time.Second {
+		t.Errorf("index ttl = %v, want the ttl of its newest member", ttl)
+	}
+	if _, err := store.Get("short"); err != ErrSessionNotFound {
+		t.Errorf("Get(short) = %v, want %v", err, ErrSessionNotFound)
+	}
+}
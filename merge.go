@@ -0,0 +1,108 @@
+package pager
+
+import (
+	"context"
+	"errors"
+)
+
+const AuditUserMerged = "user.merged"
+
+var ErrMergeSameUser = errors.New("can't merge a user into itself")
+
+// mergeUserQueries move the references of the duplicate (second argument) to the primary (first argument),
+// the assignments the primary already has are kept as they are
+var mergeUserQueries = []string{
+	`INSERT IGNORE INTO rbac_user_role (role_id, user_id, expires_at, created_at, updated_at)
+	SELECT role_id, ?, expires_at, created_at, updated_at FROM rbac_user_role WHERE user_id = ?`,
+	`INSERT IGNORE INTO rbac_user_group (group_id, user_id, created_at, updated_at)
+	SELECT group_id, ?, created_at, updated_at FROM rbac_user_group WHERE user_id = ?`,
+	`INSERT IGNORE INTO rbac_user_permission (user_id, permission_id, reason, created_at, updated_at)
+	SELECT ?, permission_id, reason, created_at, updated_at FROM rbac_user_permission WHERE user_id = ?`,
+	`UPDATE IGNORE rbac_review_item SET user_id = ? WHERE user_id = ?`,
+	`UPDATE rbac_user_identity SET user_id = ? WHERE user_id = ?`,
+	`UPDATE rbac_permission_request SET user_id = ? WHERE user_id = ?`,
+}
+
+// mergeCleanupQueries remove what was copied from the duplicate
+var mergeCleanupQueries = []string{
+	`DELETE FROM rbac_user_role WHERE user_id = ?`,
+	`DELETE FROM rbac_user_group WHERE user_id = ?`,
+	`DELETE FROM rbac_user_permission WHERE user_id = ?`,
+}
+
+// MergeUsers moves the role assignments, group memberships, direct permissions, identities and requests of
+// duplicateID to primaryID in one transaction, then marks the duplicate as merged into the primary, a merged user
+// can't sign in or be activated again. The audit trail is left as it was recorded, a single AuditUserMerged entry
+// links both accounts.
+// The duplicate's sessions are revoked when the session store implements SessionIndexer, its owner signs in
+// again with the primary account
+func (p *Pager) MergeUsers(ctx context.Context, primaryID, duplicateID string) error {
+	if primaryID == "" || duplicateID == "" {
+		return ErrInvalidUserID
+	}
+	if primaryID == duplicateID {
+		return ErrMergeSameUser
+	}
+
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = mergeUsers(ctx, wrapTx(tx), primaryID, duplicateID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(primaryID, duplicateID)
+
+	if p.Auth == nil {
+		return nil
+	}
+	if _, ok := p.Auth.sessionStore.(SessionIndexer); ok {
+		_, err = p.Auth.RevokeAllSessions(ctx, duplicateID, RevokeOptions{Reason: "merged into " + primaryID})
+	}
+	return err
+}
+
+func mergeUsers(ctx context.Context, db DbContract, primaryID, duplicateID string) error {
+	var count int64
+	err := queryRow(ctx, db, `SELECT COUNT(1) FROM rbac_user WHERE id IN (?, ?) AND merged_into IS NULL FOR UPDATE`, primaryID, duplicateID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count != 2 {
+		return ErrUserNotFound
+	}
+
+	for _, query := range mergeUserQueries {
+		_, err = db.ExecContext(ctx, query, primaryID, duplicateID)
+		if err != nil {
+			return err
+		}
+	}
+	for _, query := range mergeCleanupQueries {
+		_, err = db.ExecContext(ctx, query, duplicateID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.ExecContext(ctx, `UPDATE rbac_user SET active = 0, merged_into = ?, updated_at = ? WHERE id = ?`,
+		primaryKeyValue(primaryID),
+		clock.Now(),
+		duplicateID,
+	)
+	if err != nil {
+		return err
+	}
+	return writeAudit(ctx, db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditUserMerged,
+		Target:   duplicateID,
+		Metadata: map[string]string{"primary_id": primaryID},
+	})
+}
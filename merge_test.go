@@ -0,0 +1,70 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mergedAccounts answers the merge of user 2 into user 1
+func mergedAccounts(query string, args []driver.NamedValue) fakeResponse {
+	if strings.HasPrefix(query, "SELECT COUNT(1) FROM rbac_user") {
+		return fakeRowsOf([]string{"count"}, int64(2))
+	}
+	return fakeResponse{affected: 1}
+}
+
+func TestMergeUsersKeepsTheAuditTrail(t *testing.T) {
+	fake, restore := openFakeDB(t, mergedAccounts)
+	defer restore()
+
+	if err := (&Pager{}).MergeUsers(context.Background(), "1", "2"); err != nil {
+		t.Fatalf("MergeUsers() = %v", err)
+	}
+	if rewritten := fake.executed("UPDATE rbac_audit_log"); len(rewritten) != 0 {
+		t.Errorf("the merge rewrote the audit trail: %v", rewritten[0].query)
+	}
+	marked := fake.executed("UPDATE rbac_user SET active = 0, merged_into = ?")
+	if len(marked) != 1 || marked[0].args[0].Value != "1" || marked[0].args[2].Value != "2" {
+		t.Fatalf("the duplicate isn't marked as merged: %v", marked)
+	}
+	logged := fake.executed("INSERT INTO rbac_audit_log")
+	if len(logged) != 1 || logged[0].args[1].Value != AuditUserMerged || !logged[0].inTx {
+		t.Errorf("audit entries = %v, want a single %s entry", logged, AuditUserMerged)
+	}
+}
+
+func TestMergeUsersRejectsMergedUsers(t *testing.T) {
+	fake, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		return fakeRowsOf([]string{"count"}, int64(1))
+	})
+	defer restore()
+
+	if err := (&Pager{}).MergeUsers(context.Background(), "1", "2"); err != ErrUserNotFound {
+		t.Fatalf("MergeUsers() = %v, want %v", err, ErrUserNotFound)
+	}
+	if counted := fake.executed("AND merged_into IS NULL"); len(counted) != 1 {
+		t.Error("an already merged user can be merged again")
+	}
+}
+
+func TestMergedUserCantSignIn(t *testing.T) {
+	_, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		if !strings.HasPrefix(query, "SELECT "+userColumns("")+" FROM rbac_user") {
+			return fakeResponse{}
+		}
+		now := time.Now()
+		return fakeRowsOf(
+			[]string{"id", "email", "username", "password", "active", "type", "merged_into", "created_at", "updated_at"},
+			"2", "john@example.com", "john", "custom$secret", true, string(UserTypeHuman), "1", now, now,
+		)
+	})
+	defer restore()
+
+	auth := &Auth{loginMethod: LoginEmail, passwordStrategy: customPassword{}}
+	if _, err := auth.authenticate(LoginParams{Identifier: "john@example.com", Password: "secret"}); err != ErrUserMerged {
+		t.Fatalf("authenticate() = %v, want %v", err, ErrUserMerged)
+	}
+}
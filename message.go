@@ -0,0 +1,243 @@
+package pager
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultLanguage = "en"
+
+// Constants for error message keys
+const (
+	MsgInvalidPassword      = "auth.invalid_password"
+	MsgInvalidUser          = "auth.invalid_user"
+	MsgCreatingCookie       = "auth.creating_cookie"
+	MsgInvalidCookie        = "auth.invalid_cookie"
+	MsgInvalidAuthorization = "auth.invalid_authorization"
+	MsgValidateCookie       = "auth.validate_cookie"
+	MsgUserNotFound         = "auth.user_not_found"
+	MsgUserNotActive        = "auth.user_not_active"
+	MsgUserMerged           = "auth.user_merged"
+	MsgTokenExpired         = "auth.token_expired"
+	MsgTokenRevoked         = "auth.token_revoked"
+	MsgStalePermissions     = "auth.stale_permissions"
+	MsgLoginThrottled       = "auth.login_throttled"
+	MsgTooManyAttempts      = "auth.too_many_attempts"
+	MsgInvalidCredentials   = "auth.invalid_credentials"
+	MsgUserExists           = "auth.user_exists"
+	MsgUnauthorized         = "auth.unauthorized"
+	MsgBreachedPassword     = "auth.breached_password"
+	MsgReservedUsername     = "auth.reserved_username"
+	MsgConfusableUsername   = "auth.confusable_username"
+	MsgForbidden            = "auth.forbidden"
+	MsgBadRequest           = "request.invalid"
+	MsgNotFound             = "request.not_found"
+	MsgMethodNotAllowed     = "request.method_not_allowed"
+	MsgInvalidUserID        = "entity.invalid_user_id"
+	MsgInvalidPermissionID  = "entity.invalid_permission_id"
+	MsgInvalidRoleID        = "entity.invalid_role_id"
+	MsgValidation           = "entity.validation"
+	MsgSystemEntity         = "entity.system"
+	MsgDualControlRequired  = "entity.dual_control_required"
+	MsgSameApprover         = "entity.same_approver"
+	MsgInternal             = "internal"
+)
+
+var errorMessageKeys = map[error]string{
+	ErrInvalidPasswordLogin: MsgInvalidPassword,
+	ErrInvalidUserLogin:     MsgInvalidUser,
+	ErrCreatingCookie:       MsgCreatingCookie,
+	ErrInvalidCookie:        MsgInvalidCookie,
+	ErrInvalidAuthorization: MsgInvalidAuthorization,
+	ErrValidateCookie:       MsgValidateCookie,
+	ErrUserNotFound:         MsgUserNotFound,
+	ErrUserNotActive:        MsgUserNotActive,
+	ErrUserMerged:           MsgUserMerged,
+	ErrTokenExpired:         MsgTokenExpired,
+	ErrTokenRevoked:         MsgTokenRevoked,
+	ErrStalePermissions:     MsgStalePermissions,
+	ErrUnauthenticated:      MsgUnauthorized,
+	ErrLoginThrottled:       MsgLoginThrottled,
+	ErrTooManyAttempts:      MsgTooManyAttempts,
+	ErrInvalidCredentials:   MsgInvalidCredentials,
+	ErrUserExists:           MsgUserExists,
+	ErrOriginNotAllowed:     MsgForbidden,
+	ErrInvalidCSRFToken:     MsgForbidden,
+	ErrPermissionDenied:     MsgForbidden,
+	ErrBreachedPassword:     MsgBreachedPassword,
+	ErrReservedUsername:     MsgReservedUsername,
+	ErrConfusableUsername:   MsgConfusableUsername,
+	ErrInvalidUserID:        MsgInvalidUserID,
+	ErrInvalidPermissionID:  MsgInvalidPermissionID,
+	ErrInvalidRoleID:        MsgInvalidRoleID,
+	ErrSystemEntity:         MsgSystemEntity,
+	ErrDualControlRequired:  MsgDualControlRequired,
+	ErrSameApprover:         MsgSameApprover,
+}
+
+var messageCatalog = map[string]map[string]string{
+	"en": {
+		MsgInvalidPassword:      "Invalid password.",
+		MsgInvalidUser:          "Invalid user.",
+		MsgCreatingCookie:       "Unable to create the session, please try again.",
+		MsgInvalidCookie:        "Your session is invalid, please sign in again.",
+		MsgInvalidAuthorization: "Invalid authorization header.",
+		MsgValidateCookie:       "Your session has expired, please sign in again.",
+		MsgUserNotFound:         "User not found.",
+		MsgUserNotActive:        "User is not active.",
+		MsgUserMerged:           "This account was merged into another one, sign in with that account.",
+		MsgTokenExpired:         "Your session has expired, please sign in again.",
+		MsgStalePermissions:     "Your permissions have changed, please sign in again.",
+		MsgTokenRevoked:         "Your session has been revoked, please sign in again.",
+		MsgLoginThrottled:       "Too many failed login attempts, please try again later.",
+		MsgTooManyAttempts:      "Too many failed login attempts, please wait a moment before trying again.",
+		MsgInvalidCredentials:   "Invalid username or password.",
+		MsgUserExists:           "The email or username is already registered.",
+		MsgUnauthorized:         "Please sign in to continue.",
+		MsgBreachedPassword:     "This password appeared in a data breach, please choose another one.",
+		MsgReservedUsername:     "This username is reserved, please choose another one.",
+		MsgConfusableUsername:   "The username mixes characters of different alphabets, please choose another one.",
+		MsgForbidden:            "You don't have access to this resource.",
+		MsgBadRequest:           "The request is invalid.",
+		MsgNotFound:             "The resource was not found.",
+		MsgMethodNotAllowed:     "The method is not allowed for this resource.",
+		MsgInvalidUserID:        "Invalid user id.",
+		MsgInvalidPermissionID:  "Invalid permission id.",
+		MsgInvalidRoleID:        "Invalid role id.",
+		MsgValidation:           "Some fields are invalid.",
+		MsgSystemEntity:         "System roles and permissions can't be deleted or renamed.",
+		MsgDualControlRequired:  "This operation must be confirmed by a second administrator.",
+		MsgSameApprover:         "The operation must be confirmed by another administrator.",
+		MsgInternal:             "Something went wrong, please try again later.",
+	},
+	"id": {
+		MsgInvalidPassword:      "Kata sandi salah.",
+		MsgInvalidUser:          "Pengguna tidak valid.",
+		MsgCreatingCookie:       "Gagal membuat sesi, silakan coba lagi.",
+		MsgInvalidCookie:        "Sesi tidak valid, silakan masuk kembali.",
+		MsgInvalidAuthorization: "Header otorisasi tidak valid.",
+		MsgValidateCookie:       "Sesi telah berakhir, silakan masuk kembali.",
+		MsgUserNotFound:         "Pengguna tidak ditemukan.",
+		MsgUserNotActive:        "Pengguna tidak aktif.",
+		MsgUserMerged:           "Akun ini telah digabungkan ke akun lain, silakan masuk dengan akun tersebut.",
+		MsgTokenExpired:         "Sesi telah berakhir, silakan masuk kembali.",
+		MsgStalePermissions:     "Hak akses Anda telah berubah, silakan masuk kembali.",
+		MsgTokenRevoked:         "Sesi telah dicabut, silakan masuk kembali.",
+		MsgLoginThrottled:       "Terlalu banyak percobaan masuk yang gagal, silakan coba lagi nanti.",
+		MsgTooManyAttempts:      "Terlalu banyak percobaan masuk yang gagal, silakan tunggu sebentar sebelum mencoba lagi.",
+		MsgInvalidCredentials:   "Nama pengguna atau kata sandi salah.",
+		MsgUserExists:           "Email atau nama pengguna sudah terdaftar.",
+		MsgUnauthorized:         "Silakan masuk untuk melanjutkan.",
+		MsgBreachedPassword:     "Kata sandi ini pernah bocor dalam insiden kebocoran data, silakan pilih kata sandi lain.",
+		MsgReservedUsername:     "Nama pengguna ini sudah dicadangkan, silakan pilih nama lain.",
+		MsgConfusableUsername:   "Nama pengguna mencampur huruf dari alfabet yang berbeda, silakan pilih nama lain.",
+		MsgForbidden:            "Anda tidak memiliki akses ke sumber daya ini.",
+		MsgBadRequest:           "Permintaan tidak valid.",
+		MsgNotFound:             "Sumber daya tidak ditemukan.",
+		MsgMethodNotAllowed:     "Metode tidak diizinkan untuk sumber daya ini.",
+		MsgInvalidUserID:        "ID pengguna tidak valid.",
+		MsgInvalidPermissionID:  "ID izin tidak valid.",
+		MsgInvalidRoleID:        "ID peran tidak valid.",
+		MsgValidation:           "Beberapa isian tidak valid.",
+		MsgSystemEntity:         "Peran dan izin sistem tidak dapat dihapus atau diganti namanya.",
+		MsgDualControlRequired:  "Operasi ini harus dikonfirmasi oleh administrator kedua.",
+		MsgSameApprover:         "Operasi harus dikonfirmasi oleh administrator lain.",
+		MsgInternal:             "Terjadi kesalahan, silakan coba beberapa saat lagi.",
+	},
+}
+var mutexMessageLock = &sync.RWMutex{}
+
+// RegisterMessages adds or overrides the translations of lang, keyed by the Msg* constants
+func RegisterMessages(lang string, messages map[string]string) {
+	mutexMessageLock.Lock()
+	defer mutexMessageLock.Unlock()
+
+	lang = strings.ToLower(lang)
+	catalog, ok := messageCatalog[lang]
+	if !ok {
+		catalog = make(map[string]string)
+		messageCatalog[lang] = catalog
+	}
+	for k, v := range messages {
+		catalog[k] = v
+	}
+}
+
+// ErrorMessageKey returns the message key of err, unknown errors are mapped into MsgInternal
+func ErrorMessageKey(err error) string {
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return MsgValidation
+	}
+	for target, key := range errorMessageKeys {
+		if errors.Is(err, target) {
+			return key
+		}
+	}
+	return MsgInternal
+}
+
+// LocalizeError translates err into the best language matching the Accept-Language header value
+func LocalizeError(err error, acceptLanguage string) string {
+	return Translate(ErrorMessageKey(err), acceptLanguage)
+}
+
+func Translate(key, acceptLanguage string) string {
+	mutexMessageLock.RLock()
+	defer mutexMessageLock.RUnlock()
+
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if message, ok := messageCatalog[lang][key]; ok {
+			return message
+		}
+		// fallback into the base language, e.g. "en-US" into "en"
+		if i := strings.Index(lang, "-"); i > 0 {
+			if message, ok := messageCatalog[lang[:i]][key]; ok {
+				return message
+			}
+		}
+	}
+	if message, ok := messageCatalog[defaultLanguage][key]; ok {
+		return message
+	}
+	return key
+}
+
+func parseAcceptLanguage(header string) []string {
+	type weightedLanguage struct {
+		lang   string
+		weight float64
+	}
+
+	parts := strings.Split(header, ",")
+	languages := make([]weightedLanguage, 0, len(parts))
+	for _, part := range parts {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.ToLower(strings.TrimSpace(fields[0]))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		weight := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					weight = q
+				}
+			}
+		}
+		languages = append(languages, weightedLanguage{lang: lang, weight: weight})
+	}
+	sort.SliceStable(languages, func(i, j int) bool {
+		return languages[i].weight > languages[j].weight
+	})
+
+	result := make([]string, 0, len(languages))
+	for _, l := range languages {
+		result = append(result, l.lang)
+	}
+	return result
+}
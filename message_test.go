@@ -0,0 +1,96 @@
+package pager
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMessageCatalogsAreComplete(t *testing.T) {
+	keys := map[string]bool{MsgValidation: true, MsgInternal: true, MsgBadRequest: true, MsgNotFound: true, MsgMethodNotAllowed: true}
+	for _, key := range errorMessageKeys {
+		keys[key] = true
+	}
+	for key := range messageCatalog[defaultLanguage] {
+		keys[key] = true
+	}
+	for lang, catalog := range messageCatalog {
+		for key := range keys {
+			if catalog[key] == "" {
+				t.Errorf("the %s catalog has no message for %s", lang, key)
+			}
+		}
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		name           string
+		key            string
+		acceptLanguage string
+		want           string
+	}{
+		{"default language", MsgUserNotFound, "", "User not found."},
+		{"exact language", MsgUserNotFound, "id", "Pengguna tidak ditemukan."},
+		{"base language", MsgUserNotFound, "id-ID", "Pengguna tidak ditemukan."},
+		{"upper-cased", MsgUserNotFound, "ID-id", "Pengguna tidak ditemukan."},
+		{"weighted", MsgUserNotFound, "en;q=0.5, id;q=0.9", "Pengguna tidak ditemukan."},
+		{"first supported", MsgUserNotFound, "fr-FR, fr;q=0.9, id;q=0.8", "Pengguna tidak ditemukan."},
+		{"unsupported", MsgUserNotFound, "fr, de;q=0.5", "User not found."},
+		{"wildcard", MsgUserNotFound, "*", "User not found."},
+		{"unknown key", "custom.key", "id", "custom.key"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Translate(test.key, test.acceptLanguage); got != test.want {
+				t.Errorf("Translate(%q, %q) = %q, want %q", test.key, test.acceptLanguage, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRegisterMessages(t *testing.T) {
+	forbidden := Translate(MsgForbidden, defaultLanguage)
+	defer func() {
+		mutexMessageLock.Lock()
+		delete(messageCatalog, "fr")
+		messageCatalog[defaultLanguage][MsgForbidden] = forbidden
+		mutexMessageLock.Unlock()
+	}()
+	RegisterMessages("FR", map[string]string{MsgUserNotFound: "Utilisateur introuvable."})
+	RegisterMessages(defaultLanguage, map[string]string{MsgForbidden: "Access denied."})
+
+	tests := []struct {
+		key            string
+		acceptLanguage string
+		want           string
+	}{
+		{MsgUserNotFound, "fr-CA", "Utilisateur introuvable."},
+		{MsgUserNotActive, "fr", "User is not active."},
+		{MsgForbidden, "en-US", "Access denied."},
+	}
+	for _, test := range tests {
+		if got := Translate(test.key, test.acceptLanguage); got != test.want {
+			t.Errorf("Translate(%q, %q) = %q, want %q", test.key, test.acceptLanguage, got, test.want)
+		}
+	}
+}
+
+func TestErrorMessageKey(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"mapped", ErrUserNotActive, MsgUserNotActive},
+		{"wrapped", fmt.Errorf("sign in: %w", ErrTokenRevoked), MsgTokenRevoked},
+		{"validation", fmt.Errorf("create: %w", ValidationErrors{{Field: "email"}}), MsgValidation},
+		{"unknown", fmt.Errorf("connection reset"), MsgInternal},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ErrorMessageKey(test.err); got != test.want {
+				t.Errorf("ErrorMessageKey(%v) = %q, want %q", test.err, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTelemetryDisabled is returned by the metric samplers when pager is built with the pager_notelemetry tag
+var ErrTelemetryDisabled = errors.New("telemetry is disabled by the pager_notelemetry build tag")
+
+// Constants for metric names
+const (
+	MetricPoolOpenConnections  = "pager_db_pool_open_connections"
+	MetricPoolInUse            = "pager_db_pool_in_use"
+	MetricPoolIdle             = "pager_db_pool_idle"
+	MetricPoolWaitCount        = "pager_db_pool_wait_count"
+	MetricPoolWaitDuration     = "pager_db_pool_wait_duration_seconds"
+	MetricPoolMaxIdleClosed    = "pager_db_pool_max_idle_closed"
+	MetricPoolMaxLifetimeClose = "pager_db_pool_max_lifetime_closed"
+)
+
+// Metrics receives the measurements of pager, plug a Prometheus/StatsD adapter through Options.Metrics.
+// Building with the pager_notelemetry tag compiles the measurements and the samplers out, Options.Metrics is then ignored
+type Metrics interface {
+	IncCounter(name string, labels map[string]string)
+	SetGauge(name string, value float64, labels map[string]string)
+	ObserveDuration(name string, duration time.Duration, labels map[string]string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) IncCounter(name string, labels map[string]string)                       {}
+func (nopMetrics) SetGauge(name string, value float64, labels map[string]string)          {}
+func (nopMetrics) ObserveDuration(name string, d time.Duration, labels map[string]string) {}
+
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+}
+
+// apply only sets the non-zero settings, leaving the rest to the caller's configuration
+func (o *PoolOptions) apply(db *sql.DB) {
+	if o.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(o.MaxOpenConns)
+	}
+	if o.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(o.MaxIdleConns)
+	}
+	if o.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(o.ConnMaxLifetime)
+	}
+}
+
+// PoolStats returns the statistics of the database connection pool
+func (p *Pager) PoolStats() sql.DBStats {
+	return sqlConnection.Stats()
+}
+
+// ReportPoolStats publishes the connection pool statistics as gauges
+func (p *Pager) ReportPoolStats() {
+	stats := p.PoolStats()
+	metrics.SetGauge(MetricPoolOpenConnections, float64(stats.OpenConnections), nil)
+	metrics.SetGauge(MetricPoolInUse, float64(stats.InUse), nil)
+	metrics.SetGauge(MetricPoolIdle, float64(stats.Idle), nil)
+	metrics.SetGauge(MetricPoolWaitCount, float64(stats.WaitCount), nil)
+	metrics.SetGauge(MetricPoolWaitDuration, stats.WaitDuration.Seconds(), nil)
+	metrics.SetGauge(MetricPoolMaxIdleClosed, float64(stats.MaxIdleClosed), nil)
+	metrics.SetGauge(MetricPoolMaxLifetimeClose, float64(stats.MaxLifetimeClosed), nil)
+}
+
+// SchedulePoolStats reports the connection pool statistics every interval until ctx is done
+func (p *Pager) SchedulePoolStats(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			p.ReportPoolStats()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
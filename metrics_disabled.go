@@ -0,0 +1,13 @@
+//go:build pager_notelemetry
+// +build pager_notelemetry
+
+package pager
+
+import "log"
+
+// metrics has a concrete type so the calls are inlined away
+var metrics nopMetrics
+
+func setMetrics(m Metrics) {
+	log.Println("[pager] built with pager_notelemetry, Options.Metrics is ignored")
+}
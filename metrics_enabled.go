@@ -0,0 +1,15 @@
+//go:build !pager_notelemetry
+// +build !pager_notelemetry
+
+package pager
+
+import "sync"
+
+var metrics Metrics = nopMetrics{}
+var mutexMetricsLock = &sync.Mutex{}
+
+func setMetrics(m Metrics) {
+	mutexMetricsLock.Lock()
+	metrics = m
+	mutexMetricsLock.Unlock()
+}
@@ -0,0 +1,106 @@
+// Package middleware provides the pager middleware as func(http.Handler) http.Handler values, as chi and
+// the standard library expect them, with pluggable unauthorized and forbidden responses:
+//
+//	m := middleware.New(auth, middleware.Options{
+//		OnUnauthorized: func(w http.ResponseWriter, r *http.Request, err error) {
+//			w.Header().Set("Content-Type", "application/json")
+//			w.WriteHeader(http.StatusUnauthorized)
+//			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+//		},
+//	})
+//	r := chi.NewRouter()
+//	r.With(m.Token, m.RBAC).Get("/reports", reports)
+//
+// The responses default to the problem details written by Auth.WriteProblem
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/dhanarJkusuma/pager/v2"
+)
+
+// ErrorHandler writes the response of a rejected request, err tells why it was rejected
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+type Options struct {
+	// OnUnauthorized responds to the requests failing the authentication
+	OnUnauthorized ErrorHandler
+	// OnForbidden responds to the requests denied by the origin check or the RBAC
+	OnForbidden ErrorHandler
+}
+
+type Middleware struct {
+	auth           *pager.Auth
+	onUnauthorized ErrorHandler
+	onForbidden    ErrorHandler
+}
+
+func New(auth *pager.Auth, opts Options) *Middleware {
+	m := &Middleware{
+		auth:           auth,
+		onUnauthorized: opts.OnUnauthorized,
+		onForbidden:    opts.OnForbidden,
+	}
+	if m.onUnauthorized == nil {
+		m.onUnauthorized = auth.WriteProblem
+	}
+	if m.onForbidden == nil {
+		m.onForbidden = auth.WriteProblem
+	}
+	return m
+}
+
+// Cookie authenticates the requests with the session cookie, as Auth.ProtectRoute
+func (m *Middleware) Cookie(next http.Handler) http.Handler {
+	return m.authenticate(next, pager.CookieBasedAuth)
+}
+
+// Token authenticates the requests with the Authorization header, as Auth.ProtectRouteUsingToken
+func (m *Middleware) Token(next http.Handler) http.Handler {
+	return m.authenticate(next, pager.TokenBasedAuth)
+}
+
+// APIKey authenticates the requests with the X-API-Key header, as Auth.ProtectRouteUsingAPIKey
+func (m *Middleware) APIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, err := m.auth.AuthenticateAPIKey(r)
+		if err != nil {
+			m.reject(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, authenticated)
+	})
+}
+
+// RBAC checks the route against the permissions of the principal, as Auth.ProtectWithRBAC.
+// It must run after Cookie, Token or APIKey
+func (m *Middleware) RBAC(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.auth.AuthorizeRequest(r); err != nil {
+			m.reject(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *Middleware) authenticate(next http.Handler, strategy pager.AuthStrategy) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authenticated, err := m.auth.AuthenticateRequest(w, r, strategy)
+		if err != nil {
+			m.reject(w, r, err)
+			return
+		}
+		next.ServeHTTP(w, authenticated)
+	})
+}
+
+// reject dispatches err to the callback of its status
+func (m *Middleware) reject(w http.ResponseWriter, r *http.Request, err error) {
+	if m.auth.ErrorToStatus(err) == http.StatusForbidden {
+		m.onForbidden(w, r, err)
+		return
+	}
+	m.onUnauthorized(w, r, err)
+}
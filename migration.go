@@ -1,28 +1,30 @@
 package pager
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
 	"path"
 	"reflect"
+	"regexp"
 	"runtime"
 	"strings"
 )
 
 const (
-	MYSQLDialect       = "mysql"
-	delimiterMigration = ";"
+	MYSQLDialect = "mysql"
 )
 
 var (
 	ErrMigrationAlreadyExist = errors.New("error while running migration, migration already exist")
 	ErrMigrationHistory      = errors.New("error while record migration history")
+	ErrInvalidIdentifier     = errors.New("invalid table or column name")
 )
 
+var identifierPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,63}$`)
+
 type RunMigration interface {
 	Run(ptx *PagerTx) error
 }
@@ -33,25 +35,100 @@ type indexSchema struct {
 }
 
 var existTable = map[string]bool{
-	userTable:           false,
-	permissionTable:     false,
-	roleTable:           false,
-	rolePermissionTable: false,
-	groupTable:          false,
-	userRoleTable:       false,
-	userGroupTable:      false,
-	migrationTable:      false,
+	userTable:              false,
+	permissionTable:        false,
+	roleTable:              false,
+	rolePermissionTable:    false,
+	groupTable:             false,
+	userRoleTable:          false,
+	userGroupTable:         false,
+	migrationTable:         false,
+	auditLogTable:          false,
+	notificationPrefTable:  false,
+	permissionTagTable:     false,
+	reviewCampaignTable:    false,
+	reviewItemTable:        false,
+	permissionRequestTable: false,
+	userPermissionTable:    false,
+	userIdentityTable:      false,
+	pendingOperationTable:  false,
+	groupRoleTable:         false,
+	nameAliasTable:         false,
+	permissionRouteTable:   false,
+	tokenRevocationTable:   false,
+	apiKeyTable:            false,
 }
 var indexes = map[string]string{
-	"rbac_user_email_idx":                      "CREATE UNIQUE INDEX `rbac_user_email_idx` ON rbac_user(email)",
-	"rbac_user_username_idx":                   "CREATE UNIQUE INDEX `rbac_user_username_idx` ON rbac_user(username)",
-	"rbac_permission_route_method_idx":         "CREATE UNIQUE INDEX `rbac_permission_route_method_idx` ON rbac_permission(route, method)",
-	"rbac_permission_name_idx":                 "CREATE UNIQUE INDEX `rbac_permission_name_idx` ON rbac_permission(name)",
-	"rbac_role_name_idx":                       "CREATE UNIQUE INDEX `rbac_role_name_idx` ON rbac_role(name)",
-	"rbac_group_name_idx":                      "CREATE UNIQUE INDEX `rbac_group_name_idx` ON rbac_group(name)",
-	"rbac_user_role_role_user_idx":             "CREATE UNIQUE INDEX `rbac_user_role_role_user_idx` on rbac_user_role (role_id, user_id)",
-	"rbac_role_permission_role_permission_idx": "CREATE UNIQUE INDEX `rbac_role_permission_role_permission_idx` on rbac_role_permission (role_id, permission_id)",
-	"rbac_migration_key_idx":                   "CREATE UNIQUE INDEX `rbac_migration_key_idx` on rbac_migration (migration_key)",
+	"rbac_user_email_idx":                           "CREATE UNIQUE INDEX `rbac_user_email_idx` ON rbac_user(email)",
+	"rbac_user_username_idx":                        "CREATE UNIQUE INDEX `rbac_user_username_idx` ON rbac_user(username)",
+	"rbac_user_type_idx":                            "CREATE INDEX `rbac_user_type_idx` ON rbac_user(type)",
+	"rbac_permission_route_method_idx":              "CREATE UNIQUE INDEX `rbac_permission_route_method_idx` ON rbac_permission(route, method)",
+	"rbac_permission_name_idx":                      "CREATE UNIQUE INDEX `rbac_permission_name_idx` ON rbac_permission(name)",
+	"rbac_role_name_idx":                            "CREATE UNIQUE INDEX `rbac_role_name_idx` ON rbac_role(name)",
+	"rbac_group_name_idx":                           "CREATE UNIQUE INDEX `rbac_group_name_idx` ON rbac_group(name)",
+	"rbac_user_role_role_user_idx":                  "CREATE UNIQUE INDEX `rbac_user_role_role_user_idx` on rbac_user_role (role_id, user_id)",
+	"rbac_role_permission_role_permission_idx":      "CREATE UNIQUE INDEX `rbac_role_permission_role_permission_idx` on rbac_role_permission (role_id, permission_id)",
+	"rbac_role_permission_permission_role_idx":      "CREATE INDEX `rbac_role_permission_permission_role_idx` on rbac_role_permission (permission_id, role_id)",
+	"rbac_user_role_user_role_idx":                  "CREATE INDEX `rbac_user_role_user_role_idx` on rbac_user_role (user_id, role_id, expires_at)",
+	"rbac_migration_key_idx":                        "CREATE UNIQUE INDEX `rbac_migration_key_idx` on rbac_migration (migration_key)",
+	"rbac_user_role_expires_at_idx":                 "CREATE INDEX `rbac_user_role_expires_at_idx` on rbac_user_role (expires_at)",
+	"rbac_audit_log_action_idx":                     "CREATE INDEX `rbac_audit_log_action_idx` on rbac_audit_log (action, created_at)",
+	"rbac_audit_log_created_at_idx":                 "CREATE INDEX `rbac_audit_log_created_at_idx` on rbac_audit_log (created_at)",
+	"rbac_user_notification_pref_user_category_idx": "CREATE UNIQUE INDEX `rbac_user_notification_pref_user_category_idx` on rbac_user_notification_pref (user_id, category)",
+	"rbac_permission_tag_permission_tag_idx":        "CREATE UNIQUE INDEX `rbac_permission_tag_permission_tag_idx` on rbac_permission_tag (permission_id, tag)",
+	"rbac_permission_tag_tag_idx":                   "CREATE INDEX `rbac_permission_tag_tag_idx` on rbac_permission_tag (tag)",
+	"rbac_review_campaign_status_idx":               "CREATE INDEX `rbac_review_campaign_status_idx` on rbac_review_campaign (status)",
+	"rbac_review_item_campaign_user_role_idx":       "CREATE UNIQUE INDEX `rbac_review_item_campaign_user_role_idx` on rbac_review_item (campaign_id, user_id, role_id)",
+	"rbac_review_item_campaign_decision_idx":        "CREATE INDEX `rbac_review_item_campaign_decision_idx` on rbac_review_item (campaign_id, decision)",
+	"rbac_permission_request_status_idx":            "CREATE INDEX `rbac_permission_request_status_idx` on rbac_permission_request (status, created_at)",
+	"rbac_permission_request_user_permission_idx":   "CREATE INDEX `rbac_permission_request_user_permission_idx` on rbac_permission_request (user_id, permission_id, status)",
+	"rbac_user_permission_user_permission_idx":      "CREATE UNIQUE INDEX `rbac_user_permission_user_permission_idx` on rbac_user_permission (user_id, permission_id)",
+	"rbac_user_identity_provider_subject_idx":       "CREATE UNIQUE INDEX `rbac_user_identity_provider_subject_idx` on rbac_user_identity (provider, subject)",
+	"rbac_user_identity_user_idx":                   "CREATE INDEX `rbac_user_identity_user_idx` on rbac_user_identity (user_id)",
+	"rbac_pending_operation_status_idx":             "CREATE INDEX `rbac_pending_operation_status_idx` ON rbac_pending_operation(status, expires_at)",
+	"rbac_group_role_group_role_idx":                "CREATE UNIQUE INDEX `rbac_group_role_group_role_idx` on rbac_group_role (group_id, role_id)",
+	"rbac_user_group_group_user_idx":                "CREATE UNIQUE INDEX `rbac_user_group_group_user_idx` on rbac_user_group (group_id, user_id)",
+	"rbac_user_group_user_group_idx":                "CREATE INDEX `rbac_user_group_user_group_idx` on rbac_user_group (user_id, group_id)",
+	"rbac_group_role_role_group_idx":                "CREATE INDEX `rbac_group_role_role_group_idx` on rbac_group_role (role_id, group_id)",
+	"rbac_name_alias_kind_alias_idx":                "CREATE UNIQUE INDEX `rbac_name_alias_kind_alias_idx` on rbac_name_alias (kind, alias)",
+	"rbac_permission_route_permission_route_idx":    "CREATE UNIQUE INDEX `rbac_permission_route_permission_route_idx` on rbac_permission_route (permission_id, route)",
+	"rbac_token_revocation_token_id_idx":            "CREATE INDEX `rbac_token_revocation_token_id_idx` on rbac_token_revocation (token_id)",
+	"rbac_token_revocation_user_id_idx":             "CREATE INDEX `rbac_token_revocation_user_id_idx` on rbac_token_revocation (user_id)",
+	"rbac_api_key_prefix_idx":                       "CREATE UNIQUE INDEX `rbac_api_key_prefix_idx` on rbac_api_key (prefix)",
+	"rbac_api_key_user_idx":                         "CREATE INDEX `rbac_api_key_user_idx` on rbac_api_key (user_id)",
+}
+
+// columnUpgrade is a column added or widened after the first release of its table, a VARCHAR column shorter
+// than the length of its definition is modified to it
+type columnUpgrade struct {
+	table      string
+	column     string
+	definition string
+}
+
+// columnUpgrades adds the columns missing from the tables created by an older release, CREATE TABLE IF NOT EXISTS
+// leaves those tables untouched. They run in order, so a definition can place its column AFTER a previous one
+var columnUpgrades = []columnUpgrade{
+	// the tagged argon2id hashes don't fit the 100 characters of the first release
+	{userTable, "password", "VARCHAR(255) NOT NULL"},
+	{userTable, "type", "VARCHAR(20) NOT NULL DEFAULT 'human' AFTER active"},
+	{userTable, "merged_into", "{{FOREIGN_KEY}} NULL AFTER type"},
+	{userTable, "created_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP"},
+	{userTable, "updated_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"},
+	{permissionTable, "display_name", "VARCHAR(100) AFTER description"},
+	{permissionTable, "metadata", "TEXT AFTER display_name"},
+	{permissionTable, "is_system", "TINYINT NOT NULL DEFAULT 0 AFTER metadata"},
+	{roleTable, "display_name", "VARCHAR(100) AFTER description"},
+	{roleTable, "metadata", "TEXT AFTER display_name"},
+	{roleTable, "is_system", "TINYINT NOT NULL DEFAULT 0 AFTER metadata"},
+	{rolePermissionTable, "created_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER permission_id"},
+	{rolePermissionTable, "updated_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at"},
+	{userRoleTable, "expires_at", "TIMESTAMP NULL DEFAULT NULL AFTER user_id"},
+	{userRoleTable, "created_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER expires_at"},
+	{userRoleTable, "updated_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at"},
+	{userGroupTable, "created_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER user_id"},
+	{userGroupTable, "updated_at", "TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP AFTER created_at"},
+	{pendingOperationTable, "group_id", "{{FOREIGN_KEY}} NULL AFTER user_id"},
 }
 
 type defaultMigrationConfig struct {
@@ -59,16 +136,25 @@ type defaultMigrationConfig struct {
 	revertMigrationPath string
 }
 
+type keyColumnConfig struct {
+	primaryKey string
+	foreignKey string
+}
+
 type Migration struct {
 	dialect    string
 	schemaName string
 	config     defaultMigrationConfig
+	keyColumns keyColumnConfig
+	// server is detected by InitDBMigration to adjust the DDL
+	server ServerVersion
 }
 
 type MigrationOptions struct {
 	DBConnection *sql.DB
 	dialect      string
 	schema       string
+	primaryKey   PrimaryKeyType
 }
 
 var queryCollection = map[string]defaultMigrationConfig{
@@ -78,57 +164,139 @@ var queryCollection = map[string]defaultMigrationConfig{
 	},
 }
 
+var keyColumnCollection = map[PrimaryKeyType]keyColumnConfig{
+	AutoIncrementKey: {
+		primaryKey: "INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT",
+		foreignKey: "INT UNSIGNED",
+	},
+	UUIDKey: {
+		primaryKey: "VARCHAR(36) NOT NULL PRIMARY KEY",
+		foreignKey: "VARCHAR(36)",
+	},
+	ULIDKey: {
+		primaryKey: "CHAR(26) NOT NULL PRIMARY KEY",
+		foreignKey: "CHAR(26)",
+	},
+	SnowflakeKey: {
+		primaryKey: "BIGINT UNSIGNED NOT NULL PRIMARY KEY",
+		foreignKey: "BIGINT UNSIGNED",
+	},
+}
+
 func NewMigration(opts MigrationOptions) (*Migration, error) {
 	dc, ok := queryCollection[opts.dialect]
 	if !ok {
 		return nil, errors.New(ErrDialectNotFound)
 	}
 
+	kc, ok := keyColumnCollection[opts.primaryKey]
+	if !ok {
+		return nil, errors.New(ErrPrimaryKeyNotFound)
+	}
+
 	m := &Migration{
 		dialect:    opts.dialect,
 		config:     dc,
 		schemaName: opts.schema,
+		keyColumns: kc,
 	}
 	return m, nil
 }
 
 func (m *Migration) InitDBMigration() error {
-	rawMigrationQuery, err := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), mysqlMigrationPath))
+	rawMigrationQuery, err := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), m.config.migrationPath))
 	if err != nil {
 		return errors.New(fmt.Sprintf(ErrMigration, "failed to open migration file"))
 	}
+	rawMigrationQuery = m.applyKeyColumns(rawMigrationQuery)
 
-	sliceQuery := strings.Split(rawMigrationQuery, delimiterMigration)
-	for i := range sliceQuery {
-		if len(strings.TrimSpace(sliceQuery[i])) == 0 {
-			continue
-		}
-		_, err = dbConnection.Exec(sliceQuery[i])
-		if err != nil {
-			log.Println(err)
-			m.ClearMigration()
-			return errors.New(fmt.Sprintf(ErrMigration, "failed to execute query"))
-		}
+	m.server, err = m.ServerVersion(context.Background())
+	if err != nil {
+		log.Println(err)
+		return errors.New(fmt.Sprintf(ErrMigration, "failed to detect the server version"))
+	}
+	// the tables of an older release hold data, a failed run only drops the tables it created
+	existing, err := m.existingTables()
+	if err != nil {
+		log.Println(err)
+		return errors.New(fmt.Sprintf(ErrMigration, "error while checking the tables"))
+	}
+
+	err = m.ExecScript(context.Background(), m.config.migrationPath, rawMigrationQuery)
+	if err != nil {
+		log.Println(err)
+		m.revertMigration(existing)
+		return err
+	}
+	err = m.migrateColumns()
+	if err != nil {
+		log.Println(err)
+		m.revertMigration(existing)
+		return errors.New(fmt.Sprintf(ErrMigration, "failed to add the missing columns"))
 	}
 	err = m.migrateIndexes()
 	if err != nil {
 		log.Println(err)
-		m.ClearMigration()
+		m.revertMigration(existing)
 		return errors.New(fmt.Sprintf(ErrMigration, "failed to execute query"))
 	}
+	err = m.RefreshViews()
+	if err != nil {
+		m.revertMigration(existing)
+		return err
+	}
 	return nil
 }
 
-func (m *Migration) ClearMigration() {
-	fmt.Println("clear rbac-db")
-	rawMigrationQuery, _ := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), revertMysqlMigrationPath))
+// existingTables returns the rbac tables of the current database
+func (m *Migration) existingTables() (map[string]bool, error) {
+	rows, err := dbConnection.Query("SHOW TABLES")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	sliceQuery := strings.Split(rawMigrationQuery, delimiterMigration)
-	for i := range sliceQuery {
-		if len(strings.TrimSpace(sliceQuery[i])) == 0 {
+	tables := make(map[string]bool)
+	for rows.Next() {
+		var tableName string
+		if err = rows.Scan(&tableName); err != nil {
+			return nil, err
+		}
+		if _, ok := existTable[tableName]; ok {
+			tables[tableName] = true
+		}
+	}
+	return tables, rows.Err()
+}
+
+// revertMigration drops the tables created by a failed InitDBMigration, the tables in existing are kept.
+// The views are dropped only when the run created every table, they're replaced by the next run anyway
+func (m *Migration) revertMigration(existing map[string]bool) {
+	if len(existing) == 0 {
+		m.ClearMigration()
+		return
+	}
+	rawMigrationQuery, _ := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), m.config.revertMigrationPath))
+	for _, statement := range splitStatements(rawMigrationQuery) {
+		fields := strings.Fields(statement.query)
+		if existing[strings.Trim(fields[len(fields)-1], "`")] {
 			continue
 		}
-		_, err := dbConnection.Exec(sliceQuery[i])
+		_, err := dbConnection.Exec(statement.query)
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (m *Migration) ClearMigration() {
+	fmt.Println("clear rbac-db")
+	m.dropViews()
+	rawMigrationQuery, _ := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), m.config.revertMigrationPath))
+
+	// keep dropping the other tables when a statement fails
+	for _, statement := range splitStatements(rawMigrationQuery) {
+		_, err := dbConnection.Exec(statement.query)
 		if err != nil {
 			log.Println(err)
 		}
@@ -194,12 +362,15 @@ func (m *Migration) Run(migration RunMigration) error {
 	return err
 }
 
+// schemaCondition matches Options.SchemaName, or the database of the connection when it's empty
+const schemaCondition = "COALESCE(NULLIF(?, ''), DATABASE())"
+
 func (m *Migration) migrateIndexes() error {
 	querySchema := `SELECT DISTINCT 
 		TABLE_NAME AS table_name,
 		INDEX_NAME AS index_name 
 	FROM INFORMATION_SCHEMA.STATISTICS 
-	WHERE TABLE_SCHEMA = ? 
+	WHERE TABLE_SCHEMA = ` + schemaCondition + `
 	AND INDEX_NAME <> ?`
 
 	rows, err := dbConnection.Query(querySchema, m.schemaName, "PRIMARY")
@@ -208,6 +379,13 @@ func (m *Migration) migrateIndexes() error {
 		return errors.New(fmt.Sprintf(ErrMigration, "error while checking the tables"))
 	}
 
+	defer rows.Close()
+
+	// deleting from the built-in indexes would leave them out of the next run
+	missing := make(map[string]string, len(indexes))
+	for name, ddl := range indexes {
+		missing[name] = ddl
+	}
 	var index indexSchema
 	for rows.Next() {
 		err = rows.Scan(&index.TableName, &index.IndexName)
@@ -215,26 +393,115 @@ func (m *Migration) migrateIndexes() error {
 			log.Println(err)
 			return errors.New(fmt.Sprintf(ErrMigration, "error while checking the tables"))
 		}
-
-		if _, ok := indexes[index.IndexName]; ok {
-			delete(indexes, index.IndexName)
-		}
+		delete(missing, index.IndexName)
 	}
 
-	for k := range indexes {
-		if len(strings.TrimSpace(indexes[k])) == 0 {
+	for k := range missing {
+		if len(strings.TrimSpace(missing[k])) == 0 {
 			continue
 		}
-		_, err = dbConnection.Exec(indexes[k])
+		_, err = dbConnection.Exec(m.indexDDL(k, missing[k]))
 		if err != nil {
 			log.Println(err)
-			m.ClearMigration()
 			return errors.New(fmt.Sprintf(ErrMigration, "failed to execute query"))
 		}
 	}
 	return nil
 }
 
+// migrateColumns runs the column upgrades whose column doesn't exist yet or is too short, so running it again is a no-op
+func (m *Migration) migrateColumns() error {
+	querySchema := `SELECT TABLE_NAME, COLUMN_NAME, CHARACTER_MAXIMUM_LENGTH
+	FROM INFORMATION_SCHEMA.COLUMNS
+	WHERE TABLE_SCHEMA = ` + schemaCondition
+	rows, err := dbConnection.Query(querySchema, m.schemaName)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	existColumn := make(map[string]sql.NullInt64)
+	for rows.Next() {
+		var table, column string
+		var length sql.NullInt64
+		if err = rows.Scan(&table, &column, &length); err != nil {
+			return err
+		}
+		existColumn[strings.ToLower(table+"."+column)] = length
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, upgrade := range columnUpgrades {
+		alteration := "ADD"
+		if length, ok := existColumn[upgrade.table+"."+upgrade.column]; ok {
+			var wanted int64
+			if _, err := fmt.Sscanf(upgrade.definition, "VARCHAR(%d)", &wanted); err != nil || !length.Valid || length.Int64 >= wanted {
+				continue
+			}
+			alteration = "MODIFY"
+		}
+		alterQuery := fmt.Sprintf("ALTER TABLE `%s` %s COLUMN `%s` %s", upgrade.table, alteration, upgrade.column, m.applyKeyColumns(upgrade.definition))
+		if _, err = dbConnection.Exec(alterQuery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EnsureIndex creates the index of table on cols unless an index with the same name already exists,
+// the index is named <table>_<cols>_idx like the built-in indexes. It returns the index name
+func (m *Migration) EnsureIndex(table string, cols []string, unique bool) (string, error) {
+	if !identifierPattern.MatchString(table) || len(cols) == 0 {
+		return "", ErrInvalidIdentifier
+	}
+	for _, col := range cols {
+		if !identifierPattern.MatchString(col) {
+			return "", ErrInvalidIdentifier
+		}
+	}
+	indexName := fmt.Sprintf("%s_%s_idx", table, strings.Join(cols, "_"))
+
+	querySchema := `SELECT COUNT(1)
+	FROM INFORMATION_SCHEMA.STATISTICS
+	WHERE TABLE_SCHEMA = ` + schemaCondition + `
+	AND TABLE_NAME = ?
+	AND INDEX_NAME = ?`
+	var count int64
+	err := queryRow(context.Background(), dbConnection, querySchema, m.schemaName, table, indexName).Scan(&count)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return indexName, nil
+	}
+
+	indexType := "INDEX"
+	if unique {
+		indexType = "UNIQUE INDEX"
+	}
+	_, err = dbConnection.Exec(fmt.Sprintf(
+		"CREATE %s `%s` ON `%s` (`%s`)",
+		indexType,
+		indexName,
+		table,
+		strings.Join(cols, "`, `"),
+	))
+	if err != nil {
+		return "", err
+	}
+	return indexName, nil
+}
+
+func (m *Migration) applyKeyColumns(rawQuery string) string {
+	replacer := strings.NewReplacer(
+		"{{PRIMARY_KEY}}", m.keyColumns.primaryKey,
+		"{{FOREIGN_KEY}}", m.keyColumns.foreignKey,
+	)
+	return replacer.Replace(rawQuery)
+}
+
 func getCurrentPath() string {
 	_, filename, _, ok := runtime.Caller(0)
 	if !ok {
@@ -245,16 +512,9 @@ func getCurrentPath() string {
 }
 
 func openMigration(path string) (string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return "", err
-	}
-	defer file.Close()
-
-	b, err := ioutil.ReadAll(file)
+	b, err := readFile(path)
 	if err != nil {
 		return "", err
 	}
-
 	return string(b), nil
 }
@@ -1,3 +1,16 @@
+DROP TABLE IF EXISTS rbac_api_key;
+DROP TABLE IF EXISTS rbac_token_revocation;
+DROP TABLE IF EXISTS rbac_permission_route;
+DROP TABLE IF EXISTS rbac_name_alias;
+DROP TABLE IF EXISTS rbac_group_role;
+DROP TABLE IF EXISTS rbac_pending_operation;
+DROP TABLE IF EXISTS rbac_user_identity;
+DROP TABLE IF EXISTS rbac_user_permission;
+DROP TABLE IF EXISTS rbac_permission_request;
+DROP TABLE IF EXISTS rbac_review_item;
+DROP TABLE IF EXISTS rbac_review_campaign;
+DROP TABLE IF EXISTS rbac_permission_tag;
+DROP TABLE IF EXISTS rbac_user_notification_pref;
 DROP TABLE IF EXISTS rbac_user_group;
 DROP TABLE IF EXISTS rbac_user_role;
 DROP TABLE IF EXISTS rbac_role_permission;
@@ -5,4 +18,5 @@ DROP TABLE IF EXISTS rbac_user;
 DROP TABLE IF EXISTS rbac_group;
 DROP TABLE IF EXISTS rbac_permission;
 DROP TABLE IF EXISTS rbac_role;
-DROP TABLE IF EXISTS rbac_migration;
\ No newline at end of file
+DROP TABLE IF EXISTS rbac_migration;
+DROP TABLE IF EXISTS rbac_audit_log;
\ No newline at end of file
@@ -1,46 +1,64 @@
 CREATE TABLE IF NOT EXISTS rbac_user (
-	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	id {{PRIMARY_KEY}},
 	username VARCHAR(100) NOT NULL,
 	email VARCHAR(100) NOT NULL,
-	password VARCHAR(100) NOT NULL,
-	active TINYINT NOT NULL DEFAULT 1
+	password VARCHAR(255) NOT NULL,
+	active TINYINT NOT NULL DEFAULT 1,
+	type VARCHAR(20) NOT NULL DEFAULT 'human',
+	merged_into {{FOREIGN_KEY}} NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 CREATE TABLE IF NOT EXISTS rbac_permission (
-	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	id {{PRIMARY_KEY}},
 	name VARCHAR(40) NOT NULL,
 	method VARCHAR(10) NOT NULL,
 	route VARCHAR(100) NOT NULL,
 	description TEXT,
+	display_name VARCHAR(100),
+	metadata TEXT,
+	is_system TINYINT NOT NULL DEFAULT 0,
 
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 CREATE TABLE IF NOT EXISTS rbac_role (
-	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	id {{PRIMARY_KEY}},
 	name VARCHAR(40) NOT NULL,
 	description TEXT,
+	display_name VARCHAR(100),
+	metadata TEXT,
+	is_system TINYINT NOT NULL DEFAULT 0,
 
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
 CREATE TABLE IF NOT EXISTS rbac_role_permission (
 	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
-	role_id INT UNSIGNED NOT NULL,
-	permission_id INT UNSIGNED NOT NULL,
+	role_id {{FOREIGN_KEY}} NOT NULL,
+	permission_id {{FOREIGN_KEY}} NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
 	FOREIGN KEY (role_id) REFERENCES rbac_role(id) ON DELETE CASCADE,
 	FOREIGN KEY (permission_id) REFERENCES rbac_permission(id) ON DELETE CASCADE
 );
 CREATE TABLE IF NOT EXISTS rbac_user_role (
 	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
-	role_id INT UNSIGNED NOT NULL,
-	user_id INT UNSIGNED NOT NULL,
+	role_id {{FOREIGN_KEY}} NOT NULL,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	expires_at TIMESTAMP NULL DEFAULT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
 	FOREIGN KEY (role_id) REFERENCES rbac_role(id) ON DELETE CASCADE,
 	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE
 );
 CREATE TABLE IF NOT EXISTS rbac_group (
-    id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    id {{PRIMARY_KEY}},
 	name VARCHAR(100) NOT NULL,
 
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -48,8 +66,11 @@ CREATE TABLE IF NOT EXISTS rbac_group (
 );
 CREATE TABLE IF NOT EXISTS rbac_user_group (
 	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
-	group_id INT UNSIGNED NOT NULL,
-	user_id INT UNSIGNED NOT NULL,
+	group_id {{FOREIGN_KEY}} NOT NULL,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
 	FOREIGN KEY (group_id) REFERENCES rbac_group(id) ON DELETE CASCADE,
 	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE
@@ -61,3 +82,164 @@ CREATE TABLE IF NOT EXISTS rbac_migration (
 	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
 	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
 );
+CREATE TABLE IF NOT EXISTS rbac_audit_log (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	actor_id VARCHAR(36),
+	action VARCHAR(50) NOT NULL,
+	target VARCHAR(255) NOT NULL,
+	reason TEXT,
+	metadata TEXT,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS rbac_user_notification_pref (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	category VARCHAR(50) NOT NULL,
+	enabled TINYINT NOT NULL DEFAULT 1,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_permission_tag (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	permission_id {{FOREIGN_KEY}} NOT NULL,
+	tag VARCHAR(50) NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (permission_id) REFERENCES rbac_permission(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_review_campaign (
+	id {{PRIMARY_KEY}},
+	name VARCHAR(100) NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	created_by VARCHAR(36),
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	closed_at TIMESTAMP NULL DEFAULT NULL
+);
+CREATE TABLE IF NOT EXISTS rbac_review_item (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	campaign_id {{FOREIGN_KEY}} NOT NULL,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	role_id {{FOREIGN_KEY}} NOT NULL,
+	decision VARCHAR(20) NOT NULL,
+	reviewer_id VARCHAR(36),
+	comment TEXT,
+	decided_at TIMESTAMP NULL DEFAULT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (campaign_id) REFERENCES rbac_review_campaign(id) ON DELETE CASCADE,
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE,
+	FOREIGN KEY (role_id) REFERENCES rbac_role(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_permission_request (
+	id {{PRIMARY_KEY}},
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	permission_id {{FOREIGN_KEY}} NOT NULL,
+	justification TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	role_id {{FOREIGN_KEY}} NULL,
+	reviewer_id VARCHAR(36),
+	review_comment TEXT,
+	decided_at TIMESTAMP NULL DEFAULT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE,
+	FOREIGN KEY (permission_id) REFERENCES rbac_permission(id) ON DELETE CASCADE,
+	FOREIGN KEY (role_id) REFERENCES rbac_role(id) ON DELETE SET NULL
+);
+CREATE TABLE IF NOT EXISTS rbac_user_permission (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	permission_id {{FOREIGN_KEY}} NOT NULL,
+	reason TEXT,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE,
+	FOREIGN KEY (permission_id) REFERENCES rbac_permission(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_user_identity (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	provider VARCHAR(50) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_pending_operation (
+	id {{PRIMARY_KEY}},
+	operation VARCHAR(50) NOT NULL,
+	role_id {{FOREIGN_KEY}} NOT NULL,
+	role_name VARCHAR(40) NOT NULL,
+	user_id {{FOREIGN_KEY}} NULL,
+	group_id {{FOREIGN_KEY}} NULL,
+	status VARCHAR(20) NOT NULL,
+	requested_by VARCHAR(36) NOT NULL,
+	approved_by VARCHAR(36),
+	expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	decided_at TIMESTAMP NULL DEFAULT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS rbac_group_role (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	group_id {{FOREIGN_KEY}} NOT NULL,
+	role_id {{FOREIGN_KEY}} NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (group_id) REFERENCES rbac_group(id) ON DELETE CASCADE,
+	FOREIGN KEY (role_id) REFERENCES rbac_role(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_name_alias (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	kind VARCHAR(20) NOT NULL,
+	alias VARCHAR(40) NOT NULL,
+	target_id {{FOREIGN_KEY}} NOT NULL,
+	expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS rbac_permission_route (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	permission_id {{FOREIGN_KEY}} NOT NULL,
+	route VARCHAR(100) NOT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (permission_id) REFERENCES rbac_permission(id) ON DELETE CASCADE
+);
+CREATE TABLE IF NOT EXISTS rbac_token_revocation (
+	id INT UNSIGNED NOT NULL PRIMARY KEY AUTO_INCREMENT,
+	token_id VARCHAR(36) NULL,
+	user_id {{FOREIGN_KEY}} NULL,
+	issued_before TIMESTAMP NULL DEFAULT NULL,
+	expires_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE IF NOT EXISTS rbac_api_key (
+	id {{PRIMARY_KEY}},
+	user_id {{FOREIGN_KEY}} NOT NULL,
+	name VARCHAR(100) NOT NULL,
+	prefix VARCHAR(20) NOT NULL,
+	key_hash CHAR(64) NOT NULL,
+	scopes TEXT,
+	last_used_at TIMESTAMP NULL DEFAULT NULL,
+	revoked_at TIMESTAMP NULL DEFAULT NULL,
+
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+
+	FOREIGN KEY (user_id) REFERENCES rbac_user(id) ON DELETE CASCADE
+);
\ No newline at end of file
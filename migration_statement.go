@@ -0,0 +1,137 @@
+package pager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// statementExcerptLength bounds the SQL quoted by MigrationStatementError
+const statementExcerptLength = 80
+
+// MigrationStatementError tells which statement of a migration script failed
+type MigrationStatementError struct {
+	Script string
+	// Index is the position of the statement in the script, from 1
+	Index int
+	// Line is the line the statement starts at, from 1
+	Line    int
+	Excerpt string
+	Err     error
+}
+
+func (e *MigrationStatementError) Error() string {
+	reason := fmt.Sprintf("statement %d of %s (line %d) failed: %s: %s", e.Index, e.Script, e.Line, e.Excerpt, e.Err)
+	return fmt.Sprintf(ErrMigration, reason)
+}
+
+func (e *MigrationStatementError) Unwrap() error {
+	return e.Err
+}
+
+type sqlStatement struct {
+	line  int
+	query string
+}
+
+// splitStatements splits script on the semicolons outside of the quoted strings, the quoted identifiers
+// and the comments, so the DSN doesn't need multiStatements=true. The statements holding only
+// comments or blanks are dropped
+func splitStatements(script string) []sqlStatement {
+	var statements []sqlStatement
+	// codeStart and codeLine locate the first byte of the current statement outside of the comments
+	codeStart, line, codeLine := 0, 1, 0
+	flush := func(end int) {
+		if codeLine > 0 {
+			statements = append(statements, sqlStatement{line: codeLine, query: strings.TrimSpace(script[codeStart:end])})
+		}
+		codeLine = 0
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		if codeLine == 0 && c > ' ' && c != ';' && c != '#' && !isDashComment(script[i:]) && !strings.HasPrefix(script[i:], "/*") {
+			codeStart, codeLine = i, line
+		}
+		switch {
+		case c == '\n':
+			line++
+		case c == '\'' || c == '"' || c == '`':
+			for i++; i < len(script) && script[i] != c; i++ {
+				if script[i] == '\\' && c != '`' && i+1 < len(script) {
+					i++
+				}
+				if script[i] == '\n' {
+					line++
+				}
+			}
+		case c == '#' || isDashComment(script[i:]):
+			for i < len(script) && script[i] != '\n' {
+				i++
+			}
+			line++
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			line += strings.Count(script[i:i+2+end], "\n")
+			i += end + 3
+		case c == ';':
+			flush(i)
+		}
+	}
+	flush(len(script))
+	return statements
+}
+
+// isDashComment reports a "-- " comment, MySQL requires the dashes to be followed by a whitespace or the end
+func isDashComment(script string) bool {
+	if !strings.HasPrefix(script, "--") {
+		return false
+	}
+	return len(script) == 2 || script[2] == ' ' || script[2] == '\t' || script[2] == '\n' || script[2] == '\r'
+}
+
+// excerpt collapses the whitespaces of query and cuts it to statementExcerptLength
+func excerpt(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	if len(query) > statementExcerptLength {
+		return query[:statementExcerptLength] + "..."
+	}
+	return query
+}
+
+// ExecScript runs the statements of a SQL script one by one, name identifies the script in the errors.
+// It stops at the first failure and returns a *MigrationStatementError
+func (m *Migration) ExecScript(ctx context.Context, name, script string) error {
+	for i, statement := range splitStatements(script) {
+		if _, err := dbConnection.ExecContext(ctx, statement.query); err != nil {
+			return &MigrationStatementError{
+				Script:  name,
+				Index:   i + 1,
+				Line:    statement.line,
+				Excerpt: excerpt(statement.query),
+				Err:     err,
+			}
+		}
+	}
+	return nil
+}
+
+// readFile reads the whole file at path
+func readFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var buffer bytes.Buffer
+	if _, err = buffer.ReadFrom(file); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,262 @@
+package pager
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// baselineColumns are the columns of the tables created by the first release, and of the pending operations
+// before they held a group
+var baselineColumns = map[string][]string{
+	userTable:           {"id", "username", "email", "password", "active"},
+	permissionTable:     {"id", "name", "method", "route", "description", "created_at", "updated_at"},
+	roleTable:           {"id", "name", "description", "created_at", "updated_at"},
+	rolePermissionTable: {"id", "role_id", "permission_id"},
+	userRoleTable:       {"id", "role_id", "user_id"},
+	groupTable:          {"id", "name", "created_at", "updated_at"},
+	userGroupTable:      {"id", "group_id", "user_id"},
+	migrationTable:      {"id", "migration_key", "created_at", "updated_at"},
+	pendingOperationTable: {
+		"id", "operation", "role_id", "role_name", "user_id", "status",
+		"requested_by", "approved_by", "expires_at", "decided_at", "created_at",
+	},
+}
+
+// baselineLengths are the VARCHAR lengths of the first release, keyed by table.column
+var baselineLengths = map[string]int64{
+	userTable + ".username": 100,
+	userTable + ".email":    100,
+	userTable + ".password": 100,
+}
+
+// schemaColumns answers the INFORMATION_SCHEMA.COLUMNS query with columns and their VARCHAR lengths,
+// and the ALTER TABLE statements by adding or widening their column
+func schemaColumns(columns map[string][]string, lengths map[string]int64) fakeHandler {
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		if strings.Contains(query, "INFORMATION_SCHEMA.COLUMNS") {
+			response := fakeResponse{columns: []string{"TABLE_NAME", "COLUMN_NAME", "CHARACTER_MAXIMUM_LENGTH"}}
+			for table, names := range columns {
+				for _, name := range names {
+					var length driver.Value
+					if l, ok := lengths[strings.ToLower(table)+"."+name]; ok {
+						length = l
+					}
+					response.rows = append(response.rows, []driver.Value{table, name, length})
+				}
+			}
+			return response
+		}
+		if strings.HasPrefix(query, "ALTER TABLE") {
+			fields := strings.Fields(query)
+			table, column := strings.Trim(fields[2], "`"), strings.Trim(fields[5], "`")
+			if fields[3] == "ADD" {
+				columns[table] = append(columns[table], column)
+			}
+			var length int64
+			if _, err := fmt.Sscanf(fields[6], "VARCHAR(%d)", &length); err == nil {
+				lengths[table+"."+column] = length
+			}
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestMigrateColumnsUpgradesBaselineSchema(t *testing.T) {
+	columns := make(map[string][]string)
+	for table, names := range baselineColumns {
+		columns[table] = append([]string(nil), names...)
+	}
+	lengths := make(map[string]int64)
+	for column, length := range baselineLengths {
+		lengths[column] = length
+	}
+	fake, restore := openFakeDB(t, schemaColumns(columns, lengths))
+	defer restore()
+	m := &Migration{schemaName: "pager"}
+
+	if err := m.migrateColumns(); err != nil {
+		t.Fatalf("migrateColumns() = %v", err)
+	}
+	altered := fake.executed("ALTER TABLE")
+	if len(altered) != len(columnUpgrades) {
+		t.Fatalf("migrateColumns() ran %d ALTER TABLE, want %d", len(altered), len(columnUpgrades))
+	}
+	for _, want := range []string{
+		"ALTER TABLE `rbac_user` MODIFY COLUMN `password` VARCHAR(255) NOT NULL",
+		"ALTER TABLE `rbac_user` ADD COLUMN `type` VARCHAR(20) NOT NULL DEFAULT 'human'",
+		"ALTER TABLE `rbac_user` ADD COLUMN `created_at` TIMESTAMP",
+		"ALTER TABLE `rbac_user` ADD COLUMN `updated_at` TIMESTAMP",
+		"ALTER TABLE `rbac_user_role` ADD COLUMN `expires_at` TIMESTAMP NULL",
+	} {
+		if len(fake.executed(want)) != 1 {
+			t.Errorf("migrateColumns() didn't run %q", want)
+		}
+	}
+
+	if err := m.migrateColumns(); err != nil {
+		t.Fatalf("second migrateColumns() = %v", err)
+	}
+	if got := len(fake.executed("ALTER TABLE")); got != len(columnUpgrades) {
+		t.Errorf("second migrateColumns() ran %d more ALTER TABLE, want none", got-len(columnUpgrades))
+	}
+}
+
+func TestMigrateColumnsKeepsCurrentSchema(t *testing.T) {
+	columns := make(map[string][]string)
+	lengths := make(map[string]int64)
+	for _, upgrade := range columnUpgrades {
+		columns[strings.ToUpper(upgrade.table)] = append(columns[strings.ToUpper(upgrade.table)], upgrade.column)
+		var length int64
+		if _, err := fmt.Sscanf(upgrade.definition, "VARCHAR(%d)", &length); err == nil {
+			lengths[upgrade.table+"."+upgrade.column] = length
+		}
+	}
+	fake, restore := openFakeDB(t, schemaColumns(columns, lengths))
+	defer restore()
+
+	if err := (&Migration{schemaName: "pager"}).migrateColumns(); err != nil {
+		t.Fatalf("migrateColumns() = %v", err)
+	}
+	if altered := fake.executed("ALTER TABLE"); len(altered) > 0 {
+		t.Errorf("migrateColumns() altered a current schema: %q", altered[0].query)
+	}
+}
+
+func TestPasswordColumnFitsTaggedHashes(t *testing.T) {
+	script, err := openMigration(fmt.Sprintf("%s/migration/%s", getCurrentPath(), mysqlMigrationPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var length int
+	start := strings.Index(script, "password VARCHAR(")
+	if start < 0 {
+		t.Fatal("the migration doesn't declare rbac_user.password")
+	}
+	if _, err = fmt.Sscanf(script[start:], "password VARCHAR(%d)", &length); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default argon2id settings, pepper included, give the longest built-in hash
+	strategy := NewTaggedPassword(PasswordArgon2id, nil)
+	if err = configurePassword(strategy, []byte("pepper"), 0); err != nil {
+		t.Fatal(err)
+	}
+	hashed, err := hashPassword(strategy, "correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hashed) > length {
+		t.Errorf("the %d characters of %q don't fit rbac_user.password VARCHAR(%d)", len(hashed), hashed, length)
+	}
+	for _, upgrade := range columnUpgrades {
+		if upgrade.table == userTable && upgrade.column == "password" && upgrade.definition != fmt.Sprintf("VARCHAR(%d) NOT NULL", length) {
+			t.Errorf("the upgrade of rbac_user.password %q doesn't match the migration", upgrade.definition)
+		}
+	}
+}
+
+// failingMigration answers a database holding existing tables, the statements containing failing fail
+func failingMigration(existing []string, failing string) fakeHandler {
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		switch {
+		case query == "SELECT VERSION()":
+			return fakeRowsOf([]string{"VERSION()"}, "8.0.30")
+		case query == "SHOW TABLES":
+			response := fakeResponse{columns: []string{"Tables_in_pager"}}
+			for _, table := range existing {
+				response.rows = append(response.rows, []driver.Value{table})
+			}
+			return response
+		case strings.Contains(query, failing):
+			return fakeResponse{err: fmt.Errorf("failed: %s", failing)}
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestFailedMigrationKeepsExistingTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing string
+	}{
+		{"script", "CREATE TABLE IF NOT EXISTS rbac_api_key"},
+		{"columns", "INFORMATION_SCHEMA.COLUMNS"},
+		{"indexes", "CREATE UNIQUE INDEX `rbac_user_email_idx`"},
+		{"views", "CREATE OR REPLACE VIEW"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, restore := openFakeDB(t, failingMigration([]string{userTable, roleTable}, test.failing))
+			defer restore()
+			m, err := NewMigration(MigrationOptions{dialect: MYSQLDialect, schema: "pager"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err = m.InitDBMigration(); err == nil {
+				t.Fatal("InitDBMigration() = nil, want the error of the failed statement")
+			}
+			dropped := make(map[string]int)
+			for _, statement := range fake.executed("DROP TABLE IF EXISTS") {
+				dropped[strings.TrimSuffix(strings.TrimPrefix(statement.query, "DROP TABLE IF EXISTS "), ";")]++
+			}
+			if dropped[userTable] > 0 || dropped[roleTable] > 0 {
+				t.Errorf("the failed migration dropped the existing tables: %v", dropped)
+			}
+			if dropped[apiKeyTable] != 1 {
+				t.Errorf("the failed migration dropped %s %d times, want once", apiKeyTable, dropped[apiKeyTable])
+			}
+			if dropped := fake.executed("DROP VIEW"); len(dropped) > 0 {
+				t.Error("the failed migration dropped the views of the existing install")
+			}
+		})
+	}
+}
+
+func TestFailedFirstMigrationClearsTheTables(t *testing.T) {
+	fake, restore := openFakeDB(t, failingMigration(nil, "CREATE TABLE IF NOT EXISTS rbac_api_key"))
+	defer restore()
+	m, err := NewMigration(MigrationOptions{dialect: MYSQLDialect, schema: "pager"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = m.InitDBMigration(); err == nil {
+		t.Fatal("InitDBMigration() = nil, want the error of the failed statement")
+	}
+	if dropped := fake.executed("DROP TABLE IF EXISTS"); len(dropped) != len(existTable) {
+		t.Errorf("the failed migration dropped %d tables, want %d", len(dropped), len(existTable))
+	}
+}
+
+func TestSchemaQueriesDefaultToTheConnectionDatabase(t *testing.T) {
+	fake, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		if strings.Contains(query, "SELECT COUNT(1)") {
+			return fakeRowsOf([]string{"count"}, int64(1))
+		}
+		return fakeResponse{}
+	})
+	defer restore()
+	m := &Migration{}
+
+	if err := m.migrateColumns(); err != nil {
+		t.Fatalf("migrateColumns() = %v", err)
+	}
+	if err := m.migrateIndexes(); err != nil {
+		t.Fatalf("migrateIndexes() = %v", err)
+	}
+	if _, err := m.EnsureIndex(userTable, []string{"email"}, false); err != nil {
+		t.Fatalf("EnsureIndex() = %v", err)
+	}
+	queries := fake.executed("INFORMATION_SCHEMA")
+	if len(queries) != 3 {
+		t.Fatalf("ran %d INFORMATION_SCHEMA queries, want 3", len(queries))
+	}
+	for _, statement := range queries {
+		if !strings.Contains(statement.query, "DATABASE()") || statement.args[0].Value != "" {
+			t.Errorf("%s %v doesn't fall back to the database of the connection", statement.query, statement.args)
+		}
+	}
+}
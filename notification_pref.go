@@ -0,0 +1,109 @@
+package pager
+
+import (
+	"context"
+)
+
+// Constants for notification categories
+const (
+	NotificationSecurityAlert = "security_alert"
+	NotificationAccessReview  = "access_review"
+)
+
+var notificationEventCategory = map[NotificationEvent]string{
+	EventNewLoginIP:      NotificationSecurityAlert,
+	EventPasswordChanged: NotificationSecurityAlert,
+	EventMFADisabled:     NotificationSecurityAlert,
+	EventAccountLocked:   NotificationSecurityAlert,
+}
+
+func (u *User) SetNotificationPreference(category string, enabled bool) error {
+	return u.SetNotificationPreferenceWithContext(context.Background(), category, enabled)
+}
+
+func (u *User) SetNotificationPreferenceWithContext(ctx context.Context, category string, enabled bool) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	now := clock.Now()
+	upsertQuery := `INSERT INTO rbac_user_notification_pref (
+		user_id,
+		category,
+		enabled,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE enabled = ?, updated_at = ?`
+	_, err := u.db.ExecContext(
+		ctx,
+		upsertQuery,
+		u.ID,
+		category,
+		enabled,
+		now,
+		now,
+		enabled,
+		now,
+	)
+	return err
+}
+
+// GetNotificationPreferences returns the explicitly stored preferences, categories without a row are enabled
+func (u *User) GetNotificationPreferences() (map[string]bool, error) {
+	return u.GetNotificationPreferencesWithContext(context.Background())
+}
+
+func (u *User) GetNotificationPreferencesWithContext(ctx context.Context) (map[string]bool, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	preferences := make(map[string]bool)
+	getQuery := `SELECT category, enabled FROM rbac_user_notification_pref WHERE user_id = ?`
+	result, err := u.db.QueryContext(ctx, getQuery, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	var category string
+	var enabled bool
+	for result.Next() {
+		err = result.Scan(&category, &enabled)
+		if err != nil {
+			return nil, err
+		}
+		preferences[category] = enabled
+	}
+	return preferences, result.Err()
+}
+
+func (u *User) IsNotificationEnabled(category string) bool {
+	return u.IsNotificationEnabledWithContext(context.Background(), category)
+}
+
+func (u *User) IsNotificationEnabledWithContext(ctx context.Context, category string) bool {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	getQuery := `SELECT enabled FROM rbac_user_notification_pref WHERE user_id = ? AND category = ?`
+
+	enabled := true
+	result := queryRow(ctx, u.db, getQuery, u.ID, category)
+	err := result.Scan(&enabled)
+	if err != nil {
+		return true
+	}
+	return enabled
+}
@@ -0,0 +1,126 @@
+package pager
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"text/template"
+	"time"
+)
+
+type NotificationEvent string
+
+// Constants for notification events
+const (
+	EventNewLoginIP      NotificationEvent = "login.new_ip"
+	EventPasswordChanged NotificationEvent = "password.changed"
+	EventMFADisabled     NotificationEvent = "mfa.disabled"
+	EventAccountLocked   NotificationEvent = "account.locked"
+	EventBreakGlass      NotificationEvent = "breakglass.granted"
+	// EventEmailVerification carries the verification token in Data["token"]
+	EventEmailVerification NotificationEvent = "email.verification"
+)
+
+type Notification struct {
+	Event      NotificationEvent
+	User       *User
+	ClientIP   string
+	OccurredAt time.Time
+	Data       map[string]string
+
+	// Subject and Body are rendered from the NotificationTemplate of the event
+	Subject string
+	Body    string
+}
+
+// Notifier delivers security notifications through the application channels (email, chat, push)
+type Notifier interface {
+	Notify(ctx context.Context, notification *Notification) error
+}
+
+// NotificationTemplate is a text/template pair executed against the Notification
+type NotificationTemplate struct {
+	Subject string
+	Body    string
+}
+
+var defaultNotificationTemplates = map[NotificationEvent]NotificationTemplate{
+	EventNewLoginIP: {
+		Subject: "New sign-in to your account",
+		Body:    "Hi {{.User.Username}}, your account was signed in from a new address {{.ClientIP}} at {{.OccurredAt.Format \"2006-01-02 15:04:05 MST\"}}. If this wasn't you, change your password immediately.",
+	},
+	EventPasswordChanged: {
+		Subject: "Your password was changed",
+		Body:    "Hi {{.User.Username}}, the password of your account was changed at {{.OccurredAt.Format \"2006-01-02 15:04:05 MST\"}}. If this wasn't you, contact your administrator.",
+	},
+	EventMFADisabled: {
+		Subject: "Two-factor authentication was disabled",
+		Body:    "Hi {{.User.Username}}, two-factor authentication was disabled on your account at {{.OccurredAt.Format \"2006-01-02 15:04:05 MST\"}}.",
+	},
+	EventAccountLocked: {
+		Subject: "Your account has been locked",
+		Body:    "Hi {{.User.Username}}, your account was locked after repeated failed sign-in attempts at {{.OccurredAt.Format \"2006-01-02 15:04:05 MST\"}}.",
+	},
+	EventBreakGlass: {
+		Subject: "Emergency access granted: {{index .Data \"role\"}}",
+		Body:    "Hi {{.User.Username}}, you were granted the emergency role {{index .Data \"role\"}} until {{index .Data \"expires_at\"}} UTC. Reason: {{index .Data \"reason\"}}. Every action is audited.",
+	},
+	EventEmailVerification: {
+		Subject: "Verify your email address",
+		Body:    "Hi {{.User.Username}}, confirm {{.User.Email}} with the verification code {{index .Data \"token\"}}.",
+	},
+}
+
+type notificationDispatcher struct {
+	notifier  Notifier
+	templates map[NotificationEvent]NotificationTemplate
+}
+
+func newNotificationDispatcher(notifier Notifier, templates map[NotificationEvent]NotificationTemplate) *notificationDispatcher {
+	merged := make(map[NotificationEvent]NotificationTemplate)
+	for event, tmpl := range defaultNotificationTemplates {
+		merged[event] = tmpl
+	}
+	for event, tmpl := range templates {
+		merged[event] = tmpl
+	}
+	return &notificationDispatcher{
+		notifier:  notifier,
+		templates: merged,
+	}
+}
+
+// dispatch renders and delivers the notification, delivery errors are logged and never fail the caller
+func (d *notificationDispatcher) dispatch(ctx context.Context, notification *Notification) {
+	if d == nil || d.notifier == nil {
+		return
+	}
+	category, ok := notificationEventCategory[notification.Event]
+	if ok && notification.User != nil && !notification.User.IsNotificationEnabledWithContext(ctx, category) {
+		return
+	}
+	if notification.OccurredAt.IsZero() {
+		notification.OccurredAt = clock.Now()
+	}
+	if tmpl, ok := d.templates[notification.Event]; ok {
+		notification.Subject = renderNotification(tmpl.Subject, notification)
+		notification.Body = renderNotification(tmpl.Body, notification)
+	}
+	if err := d.notifier.Notify(ctx, notification); err != nil {
+		logf(ctx, "failed to deliver %s notification, err = %s", notification.Event, err)
+	}
+}
+
+func renderNotification(text string, notification *Notification) string {
+	tmpl, err := template.New(string(notification.Event)).Parse(text)
+	if err != nil {
+		log.Println(err)
+		return text
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, notification); err != nil {
+		log.Println(err)
+		return text
+	}
+	return buf.String()
+}
@@ -0,0 +1,160 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var ErrMissingOperationID = errors.New("openapi operation without operationId")
+
+// OpenAPIImportOptions configures the permissions generated from an OpenAPI document
+type OpenAPIImportOptions struct {
+	// Prefix is prepended to the paths of the document, e.g. the base path of its server
+	Prefix string
+	// SkipMissingOperationID ignores the operations without operationId instead of failing
+	SkipMissingOperationID bool
+	// System marks the generated permissions as system permissions
+	System bool
+}
+
+type openAPIDocument struct {
+	Paths map[string]openAPIPathItem `json:"paths" yaml:"paths"`
+}
+
+type openAPIPathItem struct {
+	Get     *openAPIOperation `json:"get" yaml:"get"`
+	Put     *openAPIOperation `json:"put" yaml:"put"`
+	Post    *openAPIOperation `json:"post" yaml:"post"`
+	Delete  *openAPIOperation `json:"delete" yaml:"delete"`
+	Options *openAPIOperation `json:"options" yaml:"options"`
+	Head    *openAPIOperation `json:"head" yaml:"head"`
+	Patch   *openAPIOperation `json:"patch" yaml:"patch"`
+	Trace   *openAPIOperation `json:"trace" yaml:"trace"`
+}
+
+type openAPIOperation struct {
+	OperationID string   `json:"operationId" yaml:"operationId"`
+	Summary     string   `json:"summary" yaml:"summary"`
+	Description string   `json:"description" yaml:"description"`
+	Tags        []string `json:"tags" yaml:"tags"`
+}
+
+func (p openAPIPathItem) operations() map[string]*openAPIOperation {
+	return map[string]*openAPIOperation{
+		http.MethodGet:     p.Get,
+		http.MethodPut:     p.Put,
+		http.MethodPost:    p.Post,
+		http.MethodDelete:  p.Delete,
+		http.MethodOptions: p.Options,
+		http.MethodHead:    p.Head,
+		http.MethodPatch:   p.Patch,
+		http.MethodTrace:   p.Trace,
+	}
+}
+
+var (
+	openAPIParameter = regexp.MustCompile(`\{([^{}/]+)\}`)
+	invalidTagChars  = regexp.MustCompile(`[^a-z0-9_-]+`)
+)
+
+// openAPIRoute converts the {name} templates of an OpenAPI path into the :name parameters of the routes
+func openAPIRoute(prefix, path string) string {
+	route := strings.TrimSuffix(prefix, "/") + openAPIParameter.ReplaceAllString(path, ":$1")
+	if !strings.HasPrefix(route, "/") {
+		route = "/" + route
+	}
+	return route
+}
+
+// openAPITag turns an OpenAPI tag into a permission tag, e.g. "User Management" into user-management
+func openAPITag(tag string) string {
+	tag = strings.Trim(invalidTagChars.ReplaceAllString(strings.ToLower(tag), "-"), "-")
+	if len(tag) > 50 {
+		tag = tag[:50]
+	}
+	return tag
+}
+
+// SeedFromOpenAPI generates the permissions of a decoded OpenAPI document, one per operation named by its
+// operationId, with the summary as display name and the tags of the operation.
+// decoder is json.Unmarshal, or yaml.Unmarshal for YAML documents. The returned seed has no roles,
+// it's the skeleton of the policy to complete before ApplySeed
+func SeedFromOpenAPI(data []byte, decoder SeedDecoder, opts OpenAPIImportOptions) (*Seed, error) {
+	document := &openAPIDocument{}
+	if err := decoder(data, document); err != nil {
+		return nil, fmt.Errorf("failed to decode the openapi document: %w", err)
+	}
+
+	paths := make([]string, 0, len(document.Paths))
+	for path := range document.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	seed := &Seed{}
+	for _, path := range paths {
+		operations := document.Paths[path].operations()
+		methods := make([]string, 0, len(operations))
+		for method, operation := range operations {
+			if operation != nil {
+				methods = append(methods, method)
+			}
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			operation := operations[method]
+			if operation.OperationID == "" {
+				if opts.SkipMissingOperationID {
+					continue
+				}
+				return nil, fmt.Errorf("%w: %s %s", ErrMissingOperationID, method, path)
+			}
+			permission := SeedPermission{
+				Name:        operation.OperationID,
+				Method:      method,
+				Route:       openAPIRoute(opts.Prefix, path),
+				Description: operation.Description,
+				DisplayName: operation.Summary,
+				System:      opts.System,
+			}
+			if len(permission.DisplayName) > 100 {
+				permission.DisplayName = permission.DisplayName[:100]
+			}
+			for _, tag := range operation.Tags {
+				if tag = openAPITag(tag); tag != "" {
+					permission.Tags = append(permission.Tags, tag)
+				}
+			}
+			seed.Permissions = append(seed.Permissions, permission)
+		}
+	}
+	return seed, nil
+}
+
+// ImportOpenAPI creates or updates the permissions of the OpenAPI document at path, see SeedFromOpenAPI.
+// The document is decoded by the seed decoder of its extension, see RegisterSeedDecoder
+func (m *Migration) ImportOpenAPI(ctx context.Context, path string, opts OpenAPIImportOptions) (*Seed, error) {
+	mutexSeedLock.RLock()
+	decoder, ok := seedDecoders[strings.ToLower(filepath.Ext(path))]
+	mutexSeedLock.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedSeedFormat
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := SeedFromOpenAPI(data, decoder, opts)
+	if err != nil {
+		return nil, err
+	}
+	return seed, m.ApplySeed(ctx, seed)
+}
@@ -0,0 +1,82 @@
+package pager
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const petstore = `{
+	"paths": {
+		"/pets/{petId}": {
+			"get": {"operationId": "showPet", "summary": "Show a pet", "tags": ["Pet Store", "read only"]},
+			"delete": {"operationId": "deletePet", "description": "Removes a pet"}
+		},
+		"/pets": {
+			"post": {"operationId": "createPet", "tags": ["!!!"]},
+			"get": {"summary": "List the pets"}
+		}
+	}
+}`
+
+func TestSeedFromOpenAPI(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		opts     OpenAPIImportOptions
+		want     []SeedPermission
+		wantErr  error
+	}{
+		{
+			name:     "missing operation id",
+			document: petstore,
+			wantErr:  ErrMissingOperationID,
+		},
+		{
+			name:     "skip missing operation id",
+			document: petstore,
+			opts:     OpenAPIImportOptions{Prefix: "/api/", SkipMissingOperationID: true, System: true},
+			want: []SeedPermission{
+				{Name: "createPet", Method: "POST", Route: "/api/pets", System: true},
+				{Name: "deletePet", Method: "DELETE", Route: "/api/pets/:petId", Description: "Removes a pet", System: true},
+				{Name: "showPet", Method: "GET", Route: "/api/pets/:petId", DisplayName: "Show a pet", Tags: []string{"pet-store", "read-only"}, System: true},
+			},
+		},
+		{
+			name:     "relative prefix",
+			document: `{"paths": {"/users/{id}/roles/{role}": {"put": {"operationId": "assignRole"}}}}`,
+			opts:     OpenAPIImportOptions{Prefix: "v1"},
+			want:     []SeedPermission{{Name: "assignRole", Method: "PUT", Route: "/v1/users/:id/roles/:role"}},
+		},
+		{
+			name:     "long summary",
+			document: `{"paths": {"/": {"get": {"operationId": "index", "summary": "` + strings.Repeat("a", 120) + `"}}}}`,
+			want:     []SeedPermission{{Name: "index", Method: "GET", Route: "/", DisplayName: strings.Repeat("a", 100)}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seed, err := SeedFromOpenAPI([]byte(test.document), json.Unmarshal, test.opts)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("SeedFromOpenAPI() = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if len(seed.Roles) != 0 {
+				t.Errorf("Roles = %v, want none", seed.Roles)
+			}
+			if !reflect.DeepEqual(seed.Permissions, test.want) {
+				t.Errorf("Permissions = %+v\nwant %+v", seed.Permissions, test.want)
+			}
+		})
+	}
+}
+
+func TestSeedFromOpenAPIRejectsInvalidDocuments(t *testing.T) {
+	if _, err := SeedFromOpenAPI([]byte(`{"paths": []}`), json.Unmarshal, OpenAPIImportOptions{}); err == nil {
+		t.Error("SeedFromOpenAPI() accepted an invalid document")
+	}
+}
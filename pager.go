@@ -4,19 +4,21 @@ import (
 	"database/sql"
 	"github.com/go-redis/redis"
 	"log"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 )
 
-type AuthManager interface {
-	GenerateToken()
-}
-
 // Constants for Error Messaging
 const (
-	ErrMigration       = "error while migrating rbac-database, reason = %s"
-	ErrDialectNotFound = "invalid dialect"
+	ErrMigration          = "error while migrating rbac-database, reason = %s"
+	ErrDialectNotFound    = "invalid dialect"
+	ErrPrimaryKeyNotFound = "invalid primary key type"
 )
 
+const defaultCacheKeyPrefix = "pager:session:"
+
 const (
 	mysqlMigrationPath       = "mysql_migration.sql"
 	revertMysqlMigrationPath = "mysql_cleanup_migration.sql"
@@ -24,20 +26,38 @@ const (
 
 // Constants for TableName
 const (
-	userTable           = "rbac_user"
-	permissionTable     = "rbac_permission"
-	roleTable           = "rbac_role"
-	groupTable          = "rbac_group"
-	rolePermissionTable = "rbac_role_permission"
-	userRoleTable       = "rbac_user_role"
-	userGroupTable      = "rbac_user_group"
-	migrationTable      = "rbac_migration"
+	userTable              = "rbac_user"
+	permissionTable        = "rbac_permission"
+	roleTable              = "rbac_role"
+	groupTable             = "rbac_group"
+	rolePermissionTable    = "rbac_role_permission"
+	userRoleTable          = "rbac_user_role"
+	userGroupTable         = "rbac_user_group"
+	migrationTable         = "rbac_migration"
+	auditLogTable          = "rbac_audit_log"
+	notificationPrefTable  = "rbac_user_notification_pref"
+	permissionTagTable     = "rbac_permission_tag"
+	reviewCampaignTable    = "rbac_review_campaign"
+	reviewItemTable        = "rbac_review_item"
+	permissionRequestTable = "rbac_permission_request"
+	userPermissionTable    = "rbac_user_permission"
+	userIdentityTable      = "rbac_user_identity"
+	pendingOperationTable  = "rbac_pending_operation"
+	groupRoleTable         = "rbac_group_role"
+	nameAliasTable         = "rbac_name_alias"
+	permissionRouteTable   = "rbac_permission_route"
+	tokenRevocationTable   = "rbac_token_revocation"
+	apiKeyTable            = "rbac_api_key"
 )
 
 type Pager struct {
 	Dialect   string
 	Migration *Migration
 	Auth      *Auth
+
+	description Description
+	// db is the connection opened by Connect
+	db *sql.DB
 }
 
 type SessionOptions struct {
@@ -45,28 +65,146 @@ type SessionOptions struct {
 	SessionName      string
 	Origin           string
 	ExpiredInSeconds int64
+	// RefreshExpiredInSeconds is the lifetime of the refresh tokens of SignInWithRefresh, 30 days when zero
+	RefreshExpiredInSeconds int64
+	// CookieDomain shares the session cookie with its subdomains, e.g. example.com for app.example.com and admin.example.com.
+	// SubdomainCookie derives it from the host of Origin instead. Cross-origin requests from other hosts are rejected
+	CookieDomain    string
+	SubdomainCookie bool
+	// CookieSameSite is the SameSite attribute of the session cookie, http.SameSiteLaxMode when zero.
+	// http.SameSiteNoneMode makes the cookie Secure
+	CookieSameSite http.SameSite
+	// SecureCookie always marks the session cookie Secure, by default it's Secure on the HTTPS requests only,
+	// including the requests forwarded with X-Forwarded-Proto: https by TrustedProxies
+	SecureCookie bool
+	// Principal picks what the middleware stores in the request context, PrincipalFull by default
+	Principal PrincipalMode
+	// GenericLoginError returns ErrInvalidCredentials for inactive users too, the precise reason goes to the audit log
+	GenericLoginError bool
+	// LegacyAuthorization accepts the Authorization headers of the releases before the Bearer scheme was required,
+	// the token alone or behind another scheme, so the existing clients keep working while they move to "Bearer <token>"
+	LegacyAuthorization bool
+	// JWT enables the stateless tokens of SignInWithJWT, verified by ProtectRouteUsingJWT
+	JWT *JWTOptions
+	// Stateless makes SignIn and SignInWithCookie issue JWTs instead of storing sessions, it requires JWT.
+	// No session store is needed to authenticate, at the cost of immediate revocation: the revoked tokens
+	// are only rejected through RevocationList, and refresh tokens are unsupported
+	Stateless bool
+	// RevocationList holds the revoked stateless tokens, a MemoryRevocationList local to the instance by default
+	RevocationList RevocationList
 }
 type Options struct {
 	DbConnection *sql.DB
-	CacheClient  *redis.Client
-	Dialect      string
-	SchemaName   string
-	Session      SessionOptions
+	// Pool tunes DbConnection when set, the zero settings are left untouched
+	Pool    *PoolOptions
+	Metrics Metrics
+	// SessionStore takes precedence over CacheClient, CacheClient takes precedence over Redis.
+	// A MemorySessionStore is used when none is set
+	SessionStore   SessionStore
+	CacheClient    *redis.Client
+	Redis          *RedisOptions
+	CacheKeyPrefix string
+	// SessionEncryptionKeys enables AES-GCM encryption of the stored sessions, the first key encrypts
+	SessionEncryptionKeys []EncryptionKey
+	LoginThrottle         *LoginThrottleOptions
+	// PasswordPepper is combined with the passwords hashed by the built-in strategies, keep it out of the database.
+	// BuildPager fails when it's set with a custom PasswordGenerator
+	PasswordPepper []byte
+	// PasswordCost is the bcrypt cost of DefaultBcryptPassword, also inside a TaggedPassword, bcrypt.DefaultCost
+	// when zero. The passwords hashed with a lower cost are rehashed on their next successful login.
+	// BuildPager fails when it's set with a custom PasswordGenerator
+	PasswordCost int
+	// BreachedPasswords rejects or reports the known-breached passwords on registration and password change
+	BreachedPasswords *BreachedPasswordOptions
+	// UsernamePolicy normalizes the usernames of Register, RegisterWithOptions and User.UpdateProfile
+	UsernamePolicy *UsernamePolicy
+	// DualControl requires a second user to confirm the role deletions and the revocations of the protected roles
+	DualControl *DualControlOptions
+	// TrustedProxies lists the CIDRs of the reverse proxies whose forwarding headers are honored by Auth.ClientIP
+	TrustedProxies []string
+	// RequestID extracts the correlation id stored by the middleware, audit records and pager log lines carry it
+	RequestID RequestIDExtractor
+	QueryLog  *QueryLogOptions
+	// Retry retries the statements of the connection failing with transient errors, the statements of the
+	// transactions are left to TxRetry, see RetryPolicy
+	Retry *RetryPolicy
+	// TxRetry bounds the retries of the deadlocked Pager.RunInTx transactions, 3 attempts by default
+	TxRetry *RetryPolicy
+	// PermissionCacheTTL enables the in-memory cache of the effective permissions used by CanAccess and HasPermission
+	PermissionCacheTTL time.Duration
+	// ExplainAccess enables WithAccessExplain, meant for debugging the policies in staging
+	ExplainAccess bool
+	// QueryTimeout bounds the context of the WithContext entity methods when the caller's context has no deadline
+	QueryTimeout time.Duration
+	// DBMiddleware wraps the connection used by every entity operation, including transactions.
+	// The first middleware wraps the connection (after the query logger), the last one is the outermost
+	DBMiddleware []func(DbContract) DbContract
+	// LoginPages enables Auth.LoginPageHandler and Auth.LogoutPageHandler
+	LoginPages *LoginPageOptions
+	// ErrorTemplates customizes the problem bodies written by the middleware and the built-in handlers
+	ErrorTemplates *ErrorTemplates
+	// NotificationTemplates overrides the default subject/body templates per event
+	NotificationTemplates map[NotificationEvent]NotificationTemplate
+	Dialect               string
+	SchemaName            string
+	PrimaryKey            PrimaryKeyType
+	// SnowflakeNodeID is the node id of the SnowflakeKey ids, 0 to 1023, distinct for every process writing to the
+	// same tables. It's required with SnowflakeKey unless SetIDGenerator is called
+	SnowflakeNodeID *int64
+	Clock           Clock
+	Session         SessionOptions
 }
 
-var dbConnection *sql.DB
+// sqlConnection is the raw connection used to begin transactions,
+// dbConnection is the same connection wrapped by the configured DbContract wrappers
+var sqlConnection *sql.DB
+var dbConnection DbContract
+var dbWrappers []dbWrapper
 var mutexDbLock = &sync.Mutex{}
 
-func setDatabaseConnection(db *sql.DB) {
+// dbWrapper is a DbContract wrapper of the connection
+type dbWrapper struct {
+	wrap func(DbContract) DbContract
+	// connectionOnly leaves the wrapper out of the transactions, e.g. the statement retries:
+	// MySQL rolls back the whole transaction on a deadlock, retrying the statement would run it outside of it
+	connectionOnly bool
+}
+
+func setDatabaseConnection(db *sql.DB, wrappers ...dbWrapper) {
 	mutexDbLock.Lock()
-	dbConnection = db
+	sqlConnection = db
+	dbWrappers = wrappers
+	dbConnection = wrapDB(db)
 	mutexDbLock.Unlock()
 }
 
+func wrapDB(db DbContract) DbContract {
+	return applyDBWrappers(db, false)
+}
+
+// wrapTx wraps a transaction with the wrappers of the connection, except the connectionOnly ones
+func wrapTx(tx DbContract) DbContract {
+	return applyDBWrappers(tx, true)
+}
+
+func applyDBWrappers(db DbContract, inTx bool) DbContract {
+	for _, wrapper := range dbWrappers {
+		if inTx && wrapper.connectionOnly {
+			continue
+		}
+		db = wrapper.wrap(db)
+	}
+	return db
+}
+
 type pagerBuilder struct {
 	pagerOptions     *Options
 	tokenStrategy    TokenGenerator
 	passwordStrategy PasswordGenerator
+	idStrategy       IDGenerator
+	hooks            *EntityHooks
+	notifier         Notifier
+	permissionFlags  PermissionFlags
 }
 
 func NewPager(opts *Options) *pagerBuilder {
@@ -90,28 +228,205 @@ func (p *pagerBuilder) SetPasswordGenerator(generator PasswordGenerator) *pagerB
 	return p
 }
 
+// SetIDGenerator overrides the generator picked from Options.PrimaryKey,
+// the generated value must fit the primary key column created by the migration
+func (p *pagerBuilder) SetIDGenerator(generator IDGenerator) *pagerBuilder {
+	p.idStrategy = generator
+	return p
+}
+
+func (p *pagerBuilder) SetEntityHooks(hooks *EntityHooks) *pagerBuilder {
+	p.hooks = hooks
+	return p
+}
+
+// SetPermissionFlags lets CanAccess and HasPermission consult a feature-flag provider for staged rollouts
+func (p *pagerBuilder) SetPermissionFlags(flags PermissionFlags) *pagerBuilder {
+	p.permissionFlags = flags
+	return p
+}
+
+// settings returns the settings of the built Pager, the unset options get their defaults
+func (p *pagerBuilder) settings() *settings {
+	built := defaultSettings()
+	built.queryTimeout = p.pagerOptions.QueryTimeout
+	built.idGenerator = p.idStrategy
+	if p.hooks != nil {
+		built.entityHooks = p.hooks
+	}
+	if p.permissionFlags != nil {
+		built.permissionFlags = p.permissionFlags
+	}
+	built.accessExplain = p.pagerOptions.ExplainAccess
+	built.dualControl = newDualControl(p.pagerOptions.DualControl)
+	built.usernamePolicy = newUsernamePolicy(p.pagerOptions.UsernamePolicy)
+	built.txRetryPolicy = newTxRetryPolicy(p.pagerOptions.TxRetry)
+	if p.pagerOptions.Retry != nil {
+		retryPolicy := p.pagerOptions.Retry.withDefaults()
+		built.retryPolicy = &retryPolicy
+	}
+	built.permCache = newPermissionCache(p.pagerOptions.PermissionCacheTTL)
+	return built
+}
+
+func (p *pagerBuilder) buildSessionStore() SessionStore {
+	switch {
+	case p.pagerOptions.SessionStore != nil:
+		return p.pagerOptions.SessionStore
+	case p.pagerOptions.CacheClient != nil:
+		return NewRedisSessionStore(p.pagerOptions.CacheClient)
+	case p.pagerOptions.Redis != nil && len(p.pagerOptions.Redis.Shards) > 0:
+		if p.pagerOptions.Redis.TLSConfig != nil {
+			log.Fatal("redis shards don't support TLSConfig")
+		}
+		return NewShardedRedisSessionStore(NewRedisRing(*p.pagerOptions.Redis))
+	case p.pagerOptions.Redis != nil:
+		return NewRedisSessionStore(NewRedisClient(*p.pagerOptions.Redis))
+	}
+	if !p.pagerOptions.Session.Stateless {
+		log.Println("no session store configured, sessions are kept in memory and lost on restart")
+	}
+	return NewMemorySessionStore(0)
+}
+
+func (p *pagerBuilder) SetNotifier(notifier Notifier) *pagerBuilder {
+	p.notifier = notifier
+	return p
+}
+
 func (p *pagerBuilder) BuildPager() *Pager {
 	rbac := &Pager{}
+	if err := configurePassword(p.passwordStrategy, p.pagerOptions.PasswordPepper, p.pagerOptions.PasswordCost); err != nil {
+		log.Fatal(err)
+	}
+	if err := validatePasswordGenerator(p.passwordStrategy); err != nil {
+		log.Fatal(err)
+	}
+	cacheKeyPrefix := defaultCacheKeyPrefix
+	if p.pagerOptions.CacheKeyPrefix != "" {
+		cacheKeyPrefix = p.pagerOptions.CacheKeyPrefix
+	}
 	authModule := &Auth{
-		SessionName:      p.pagerOptions.Session.SessionName,
-		origin:           p.pagerOptions.Session.Origin,
-		expiredInSeconds: p.pagerOptions.Session.ExpiredInSeconds,
-		loginMethod:      p.pagerOptions.Session.LoginMethod,
-		cacheClient:      p.pagerOptions.CacheClient,
-		tokenStrategy:    p.tokenStrategy,
-		passwordStrategy: p.passwordStrategy,
+		SessionName:             p.pagerOptions.Session.SessionName,
+		origin:                  p.pagerOptions.Session.Origin,
+		expiredInSeconds:        p.pagerOptions.Session.ExpiredInSeconds,
+		refreshExpiredInSeconds: p.pagerOptions.Session.RefreshExpiredInSeconds,
+		loginMethod:             p.pagerOptions.Session.LoginMethod,
+		principalMode:           p.pagerOptions.Session.Principal,
+		genericLoginError:       p.pagerOptions.Session.GenericLoginError,
+		legacyAuthorization:     p.pagerOptions.Session.LegacyAuthorization,
+		sessionStore:            p.buildSessionStore(),
+		cacheKeyPrefix:          cacheKeyPrefix,
+		tokenStrategy:           p.tokenStrategy,
+		passwordStrategy:        p.passwordStrategy,
+		requestID:               p.pagerOptions.RequestID,
+		breachedPasswords:       p.pagerOptions.BreachedPasswords,
+		cookieSameSite:          p.pagerOptions.Session.CookieSameSite,
+		secureCookie:            p.pagerOptions.Session.SecureCookie,
+	}
+	if cookieDomain := p.pagerOptions.Session.CookieDomain; cookieDomain != "" {
+		authModule.cookieDomain = strings.ToLower(strings.TrimPrefix(cookieDomain, "."))
+	} else if p.pagerOptions.Session.SubdomainCookie {
+		cookieDomain, err := cookieDomainFromOrigin(p.pagerOptions.Session.Origin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authModule.cookieDomain = cookieDomain
+	}
+	if jwtOptions := p.pagerOptions.Session.JWT; jwtOptions != nil {
+		if err := jwtOptions.validate(); err != nil {
+			log.Fatal(err)
+		}
+		copied := *jwtOptions
+		authModule.jwt = &copied
+	}
+	if p.pagerOptions.Session.Stateless {
+		if authModule.jwt == nil {
+			log.Fatal(ErrStatelessRequiresJWT)
+		}
+		authModule.stateless = true
+		authModule.revocations = p.pagerOptions.Session.RevocationList
+		if authModule.revocations == nil {
+			authModule.revocations = NewMemoryRevocationList()
+		}
+	} else {
+		authModule.revocations = p.pagerOptions.Session.RevocationList
+	}
+	if len(p.pagerOptions.TrustedProxies) > 0 {
+		trustedProxies, err := parseTrustedProxies(p.pagerOptions.TrustedProxies)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authModule.trustedProxies = trustedProxies
+	}
+	if p.pagerOptions.ErrorTemplates != nil {
+		errorRenderer, err := newErrorRenderer(*p.pagerOptions.ErrorTemplates)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authModule.errorRenderer = errorRenderer
+	}
+	if p.pagerOptions.LoginPages != nil {
+		loginPages, err := newLoginPages(*p.pagerOptions.LoginPages)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authModule.loginPages = loginPages
+	}
+	if len(p.pagerOptions.SessionEncryptionKeys) > 0 {
+		sessionCipher, err := NewSessionCipher(p.pagerOptions.SessionEncryptionKeys...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		authModule.sessionCipher = sessionCipher
+	}
+	if p.notifier != nil {
+		authModule.notifications = newNotificationDispatcher(p.notifier, p.pagerOptions.NotificationTemplates)
+	}
+	if p.pagerOptions.LoginThrottle != nil {
+		authModule.loginThrottle = newLoginThrottle(*p.pagerOptions.LoginThrottle, authModule.sessionStore, authModule.cacheKey)
 	}
 	migrator, err := NewMigration(MigrationOptions{
-		dialect: p.pagerOptions.Dialect,
-		schema:  p.pagerOptions.SchemaName,
+		dialect:    p.pagerOptions.Dialect,
+		schema:     p.pagerOptions.SchemaName,
+		primaryKey: p.pagerOptions.PrimaryKey,
 	})
-	setDatabaseConnection(p.pagerOptions.DbConnection)
+	var dbWrappers []dbWrapper
+	if p.pagerOptions.QueryLog != nil {
+		dbWrappers = append(dbWrappers, dbWrapper{wrap: NewQueryLogger(*p.pagerOptions.QueryLog)})
+	}
+	if p.pagerOptions.Retry != nil {
+		dbWrappers = append(dbWrappers, dbWrapper{wrap: NewRetryMiddleware(*p.pagerOptions.Retry), connectionOnly: true})
+	}
+	for _, middleware := range p.pagerOptions.DBMiddleware {
+		dbWrappers = append(dbWrappers, dbWrapper{wrap: middleware})
+	}
+	if p.pagerOptions.Pool != nil {
+		p.pagerOptions.Pool.apply(p.pagerOptions.DbConnection)
+	}
+	setDatabaseConnection(p.pagerOptions.DbConnection, dbWrappers...)
+	if p.pagerOptions.Metrics != nil {
+		setMetrics(p.pagerOptions.Metrics)
+	}
+	if p.idStrategy == nil {
+		generator, err := defaultIDGenerator(p.pagerOptions)
+		if err != nil {
+			log.Fatal(err)
+		}
+		p.idStrategy = generator
+	}
+	installSettings(p.settings())
+	if p.pagerOptions.Clock != nil {
+		setClock(p.pagerOptions.Clock)
+	}
 
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	rbac.Dialect = p.pagerOptions.Dialect
 	rbac.Migration = migrator
 	rbac.Auth = authModule
+	rbac.description = p.describe(authModule)
 	return rbac
 }
@@ -0,0 +1,73 @@
+// Package pagerecho adapts the pager middleware to echo. The middleware return an *echo.HTTPError
+// carrying the pager problem details instead of writing the response, so the HTTPErrorHandler of the
+// application renders them:
+//
+//	e := echo.New()
+//	admin := e.Group("/admin", pagerecho.TokenAuth(auth), pagerecho.RBAC(auth))
+//	admin.GET("/reports", reports)
+//
+// The principal is stored into the request context, pager.GetPrincipal(c.Request()) and
+// pager.GetUserLogin(c.Request()) work in the echo handlers
+package pagerecho
+
+import (
+	"github.com/dhanarJkusuma/pager/v2"
+	"github.com/labstack/echo"
+)
+
+// CookieAuth authenticates the requests with the session cookie, as Auth.ProtectRoute
+func CookieAuth(auth *pager.Auth) echo.MiddlewareFunc {
+	return authenticate(auth, pager.CookieBasedAuth)
+}
+
+// TokenAuth authenticates the requests with the Authorization header, as Auth.ProtectRouteUsingToken
+func TokenAuth(auth *pager.Auth) echo.MiddlewareFunc {
+	return authenticate(auth, pager.TokenBasedAuth)
+}
+
+// APIKeyAuth authenticates the requests with the X-API-Key header, as Auth.ProtectRouteUsingAPIKey
+func APIKeyAuth(auth *pager.Auth) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r, err := auth.AuthenticateAPIKey(c.Request())
+			if err != nil {
+				return HTTPError(auth, c, err)
+			}
+			c.SetRequest(r)
+			return next(c)
+		}
+	}
+}
+
+// RBAC checks the route against the permissions of the principal, as Auth.ProtectWithRBAC.
+// It must run after CookieAuth, TokenAuth or APIKeyAuth
+func RBAC(auth *pager.Auth) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if err := auth.AuthorizeRequest(c.Request()); err != nil {
+				return HTTPError(auth, c, err)
+			}
+			return next(c)
+		}
+	}
+}
+
+// HTTPError converts err into an *echo.HTTPError with the status of Auth.ErrorToStatus,
+// the message is the pager problem details and the internal error is err
+func HTTPError(auth *pager.Auth, c echo.Context, err error) *echo.HTTPError {
+	problem := auth.Problem(c.Request(), err)
+	return echo.NewHTTPError(problem.Status, problem).SetInternal(err)
+}
+
+func authenticate(auth *pager.Auth, strategy pager.AuthStrategy) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			r, err := auth.AuthenticateRequest(c.Response(), c.Request(), strategy)
+			if err != nil {
+				return HTTPError(auth, c, err)
+			}
+			c.SetRequest(r)
+			return next(c)
+		}
+	}
+}
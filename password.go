@@ -1,16 +1,172 @@
 package pager
 
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidPasswordCost = errors.New("invalid bcrypt cost")
+	ErrPasswordPepper      = errors.New("failed to get the password pepper")
+	ErrPasswordHash        = errors.New("failed to hash the password")
+	// ErrUnsupportedPasswordOption is returned for Options.PasswordPepper and PasswordCost
+	// when the password strategy would ignore them
+	ErrUnsupportedPasswordOption = errors.New("password option unsupported by the password strategy")
+)
+
 type PasswordGenerator interface {
 	HashPassword(password string) string
 	ValidatePassword(storedPassword, password string) bool
 }
 
-type DefaultBcryptPassword struct{}
+// PasswordHasher is implemented by the PasswordGenerator able to tell why a password couldn't be hashed,
+// HashPassword returns an empty hash then. The built-in strategies implement it
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+}
+
+// hashPassword hashes password with generator, an empty hash is an error
+func hashPassword(generator PasswordGenerator, password string) (string, error) {
+	if hasher, ok := generator.(PasswordHasher); ok {
+		return hasher.Hash(password)
+	}
+	hashed := generator.HashPassword(password)
+	if hashed == "" {
+		return "", ErrPasswordHash
+	}
+	return hashed, nil
+}
+
+// PasswordRehasher is implemented by the PasswordGenerator able to tell outdated hashes,
+// they are rehashed with the current settings on the next successful login
+type PasswordRehasher interface {
+	NeedsRehash(storedPassword string) bool
+}
+
+// pepperedPrefix marks the hashes of peppered passwords, the hashes without it predate the pepper
+const pepperedPrefix = "peppered$"
+
+// DefaultBcryptPassword hashes with Cost, bcrypt.DefaultCost when zero.
+// When a pepper is set (Pepper, or PepperProvider for a secrets manager) the password is HMAC-SHA256'd
+// with it before bcrypt, the hashes created before are still accepted and rehashed on the next login
+type DefaultBcryptPassword struct {
+	Cost           int
+	Pepper         []byte
+	PepperProvider func() ([]byte, error)
+}
+
+// loadPepper returns pepper, or the pepper of provider when set. An empty pepper is an error,
+// a hash marked as peppered must never be stored unpeppered
+func loadPepper(pepper []byte, provider func() ([]byte, error)) ([]byte, error) {
+	if provider != nil {
+		var err error
+		if pepper, err = provider(); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrPasswordPepper, err)
+		}
+	}
+	if len(pepper) == 0 {
+		return nil, fmt.Errorf("%w: empty pepper", ErrPasswordPepper)
+	}
+	return pepper, nil
+}
+
+func (d *DefaultBcryptPassword) peppered() bool {
+	return len(d.Pepper) > 0 || d.PepperProvider != nil
+}
+
+// pepperPassword keeps the digest under the 72 bytes bcrypt reads
+func pepperPassword(pepper []byte, password string) string {
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte(password))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// validate rejects the costs bcrypt would fail to hash with, the passwords would be stored empty
+func (d *DefaultBcryptPassword) validate() error {
+	if d.Cost != 0 && (d.Cost < bcrypt.MinCost || d.Cost > bcrypt.MaxCost) {
+		return fmt.Errorf("%w: %d, expected %d to %d", ErrInvalidPasswordCost, d.Cost, bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	return nil
+}
+
+func (d *DefaultBcryptPassword) cost() int {
+	if d.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return d.Cost
+}
 
 func (d *DefaultBcryptPassword) HashPassword(password string) string {
-	return hash(password)
+	hashed, err := d.Hash(password)
+	if err != nil {
+		log.Printf("failed to hash the password, err = %s", err)
+		return ""
+	}
+	return hashed
+}
+
+func (d *DefaultBcryptPassword) Hash(password string) (string, error) {
+	if !d.peppered() {
+		return bcryptHash(password, d.cost())
+	}
+	pepper, err := loadPepper(d.Pepper, d.PepperProvider)
+	if err != nil {
+		return "", err
+	}
+	hashed, err := bcryptHash(pepperPassword(pepper, password), d.cost())
+	if err != nil {
+		return "", err
+	}
+	return pepperedPrefix + hashed, nil
 }
 
 func (d *DefaultBcryptPassword) ValidatePassword(storedPassword, password string) bool {
-	return compareHash(storedPassword, password)
+	if !strings.HasPrefix(storedPassword, pepperedPrefix) {
+		return compareHash(storedPassword, password)
+	}
+	pepper, err := loadPepper(d.Pepper, d.PepperProvider)
+	if err != nil {
+		log.Print(err)
+		return false
+	}
+	return compareHash(strings.TrimPrefix(storedPassword, pepperedPrefix), pepperPassword(pepper, password))
+}
+
+// NeedsRehash reports hashes created with a lower cost than the configured one, or without the configured pepper
+func (d *DefaultBcryptPassword) NeedsRehash(storedPassword string) bool {
+	if d.peppered() != strings.HasPrefix(storedPassword, pepperedPrefix) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(strings.TrimPrefix(storedPassword, pepperedPrefix)))
+	return err == nil && cost < d.cost()
+}
+
+// rehashPassword upgrades the stored hash of user after a successful login, failures are logged
+// and never fail the login. The update is skipped when the password changed in between
+func (a *Auth) rehashPassword(ctx context.Context, user *User, password string) {
+	rehasher, ok := a.passwordStrategy.(PasswordRehasher)
+	if !ok || !rehasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	rehashed, err := hashPassword(a.passwordStrategy, password)
+	if err != nil {
+		logf(ctx, "failed to rehash the password of user %s, err = %s", user.ID, err)
+		return
+	}
+	updateQuery := `UPDATE rbac_user SET password = ? WHERE id = ? AND password = ?`
+	_, err = dbConnection.ExecContext(ctx, updateQuery, rehashed, user.ID, user.Password)
+	if err != nil {
+		logf(ctx, "failed to rehash the password of user %s, err = %s", user.ID, err)
+		return
+	}
+	user.Password = rehashed
 }
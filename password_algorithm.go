@@ -0,0 +1,322 @@
+package pager
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Constants for password algorithm identifiers
+const (
+	PasswordBcrypt   = "bcrypt"
+	PasswordArgon2id = "argon2id"
+)
+
+// Bounds of the settings read from the stored Argon2id hashes, a hash outside them is rejected before hashing,
+// so a tampered row can't make a login allocate gigabytes or spin for minutes
+const (
+	argon2MaxMemory = 2 * 1024 * 1024
+	argon2MaxTime   = 16
+	argon2MinSalt   = 8
+	argon2MinKeyLen = 16
+	argon2MaxKeyLen = 128
+)
+
+var (
+	ErrInvalidPasswordHash   = errors.New("invalid password hash")
+	ErrInvalidArgon2Settings = errors.New("invalid argon2 settings")
+)
+
+// TaggedPassword prefixes the stored hashes with the identifier of their algorithm, e.g. {argon2id}$argon2id$v=19$...,
+// and validates them with the algorithm they were created with. New hashes use Current, the others are rehashed
+// on the next login, so an installation can move from bcrypt to Argon2 gradually.
+// Hashes without a tag predate the tagging and are validated by the Legacy algorithm, PasswordBcrypt when empty
+type TaggedPassword struct {
+	Current    string
+	Legacy     string
+	Algorithms map[string]PasswordGenerator
+}
+
+// NewTaggedPassword hashes with current, bcrypt and argon2id are registered unless overridden in algorithms
+func NewTaggedPassword(current string, algorithms map[string]PasswordGenerator) *TaggedPassword {
+	registered := map[string]PasswordGenerator{
+		PasswordBcrypt:   &DefaultBcryptPassword{},
+		PasswordArgon2id: &Argon2idPassword{},
+	}
+	for id, algorithm := range algorithms {
+		registered[id] = algorithm
+	}
+	return &TaggedPassword{
+		Current:    current,
+		Legacy:     PasswordBcrypt,
+		Algorithms: registered,
+	}
+}
+
+// splitPasswordTag returns the algorithm identifier and the hash, the identifier is empty for untagged hashes
+func splitPasswordTag(storedPassword string) (string, string) {
+	if !strings.HasPrefix(storedPassword, "{") {
+		return "", storedPassword
+	}
+	end := strings.Index(storedPassword, "}")
+	if end < 0 {
+		return "", storedPassword
+	}
+	return storedPassword[1:end], storedPassword[end+1:]
+}
+
+func (t *TaggedPassword) legacy() string {
+	if t.Legacy == "" {
+		return PasswordBcrypt
+	}
+	return t.Legacy
+}
+
+func (t *TaggedPassword) HashPassword(password string) string {
+	hashed, err := t.Hash(password)
+	if err != nil {
+		log.Printf("failed to hash the password, err = %s", err)
+		return ""
+	}
+	return hashed
+}
+
+func (t *TaggedPassword) Hash(password string) (string, error) {
+	algorithm, ok := t.Algorithms[t.Current]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown algorithm %q", ErrPasswordHash, t.Current)
+	}
+	hashed, err := hashPassword(algorithm, password)
+	if err != nil {
+		return "", err
+	}
+	return "{" + t.Current + "}" + hashed, nil
+}
+
+func (t *TaggedPassword) ValidatePassword(storedPassword, password string) bool {
+	id, hashed := splitPasswordTag(storedPassword)
+	if id == "" {
+		id = t.legacy()
+	}
+	algorithm, ok := t.Algorithms[id]
+	if !ok {
+		return false
+	}
+	return algorithm.ValidatePassword(hashed, password)
+}
+
+// NeedsRehash reports the hashes of another algorithm than Current, and the outdated hashes of Current
+func (t *TaggedPassword) NeedsRehash(storedPassword string) bool {
+	id, hashed := splitPasswordTag(storedPassword)
+	if id != t.Current {
+		return true
+	}
+	rehasher, ok := t.Algorithms[id].(PasswordRehasher)
+	return ok && rehasher.NeedsRehash(hashed)
+}
+
+// Argon2idPassword hashes into the PHC string format, the zero settings use the RFC 9106 second recommended option.
+// The pepper works as the one of DefaultBcryptPassword
+type Argon2idPassword struct {
+	Time           uint32
+	Memory         uint32
+	Threads        uint8
+	KeyLen         uint32
+	SaltLen        uint32
+	Pepper         []byte
+	PepperProvider func() ([]byte, error)
+}
+
+func (a *Argon2idPassword) peppered() bool {
+	return len(a.Pepper) > 0 || a.PepperProvider != nil
+}
+
+type argon2Params struct {
+	time    uint32
+	memory  uint32
+	threads uint8
+}
+
+func (a *Argon2idPassword) params() (argon2Params, uint32, uint32) {
+	params := argon2Params{time: a.Time, memory: a.Memory, threads: a.Threads}
+	if params.time == 0 {
+		params.time = 3
+	}
+	if params.memory == 0 {
+		params.memory = 64 * 1024
+	}
+	if params.threads == 0 {
+		params.threads = 4
+	}
+	keyLen, saltLen := a.KeyLen, a.SaltLen
+	if keyLen == 0 {
+		keyLen = 32
+	}
+	if saltLen == 0 {
+		saltLen = 16
+	}
+	return params, keyLen, saltLen
+}
+
+// validate rejects the settings out of the bounds of parseArgon2id, their hashes could never be validated
+func (a *Argon2idPassword) validate() error {
+	params, keyLen, saltLen := a.params()
+	switch {
+	case params.time > argon2MaxTime:
+		return fmt.Errorf("%w: time %d, expected at most %d", ErrInvalidArgon2Settings, params.time, argon2MaxTime)
+	case params.memory < 8*uint32(params.threads) || params.memory > argon2MaxMemory:
+		return fmt.Errorf("%w: memory %d KiB, expected %d to %d", ErrInvalidArgon2Settings, params.memory, 8*uint32(params.threads), argon2MaxMemory)
+	case keyLen < argon2MinKeyLen || keyLen > argon2MaxKeyLen:
+		return fmt.Errorf("%w: key length %d, expected %d to %d", ErrInvalidArgon2Settings, keyLen, argon2MinKeyLen, argon2MaxKeyLen)
+	case saltLen < argon2MinSalt:
+		return fmt.Errorf("%w: salt length %d, expected at least %d", ErrInvalidArgon2Settings, saltLen, argon2MinSalt)
+	}
+	return nil
+}
+
+// configurePassword applies Options.PasswordPepper and PasswordCost to the built-in strategies, the algorithms
+// of a TaggedPassword included. The pepper and cost set on a strategy take precedence. PasswordCost is a bcrypt cost,
+// the Argon2id settings are left untouched. The options a custom strategy would ignore are rejected
+func configurePassword(generator PasswordGenerator, pepper []byte, cost int) error {
+	switch g := generator.(type) {
+	case *DefaultBcryptPassword:
+		if len(pepper) > 0 && !g.peppered() {
+			g.Pepper = pepper
+		}
+		if cost != 0 && g.Cost == 0 {
+			g.Cost = cost
+		}
+	case *Argon2idPassword:
+		if len(pepper) > 0 && !g.peppered() {
+			g.Pepper = pepper
+		}
+	case *TaggedPassword:
+		for id, algorithm := range g.Algorithms {
+			if err := configurePassword(algorithm, pepper, cost); err != nil {
+				return fmt.Errorf("%s: %w", id, err)
+			}
+		}
+	default:
+		if len(pepper) > 0 || cost != 0 {
+			return fmt.Errorf("%w: %T ignores PasswordPepper and PasswordCost", ErrUnsupportedPasswordOption, generator)
+		}
+	}
+	return nil
+}
+
+// validatePasswordGenerator checks the settings of the built-in strategies, the algorithms of a TaggedPassword included
+func validatePasswordGenerator(generator PasswordGenerator) error {
+	switch g := generator.(type) {
+	case *DefaultBcryptPassword:
+		return g.validate()
+	case *Argon2idPassword:
+		return g.validate()
+	case *TaggedPassword:
+		for _, algorithm := range g.Algorithms {
+			if err := validatePasswordGenerator(algorithm); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *Argon2idPassword) HashPassword(password string) string {
+	hashed, err := a.Hash(password)
+	if err != nil {
+		log.Printf("failed to hash the password, err = %s", err)
+		return ""
+	}
+	return hashed
+}
+
+func (a *Argon2idPassword) Hash(password string) (string, error) {
+	prefix := ""
+	if a.peppered() {
+		pepper, err := loadPepper(a.Pepper, a.PepperProvider)
+		if err != nil {
+			return "", err
+		}
+		prefix, password = pepperedPrefix, pepperPassword(pepper, password)
+	}
+	params, keyLen, saltLen := a.params()
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrPasswordHash, err)
+	}
+	key := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, keyLen)
+	return fmt.Sprintf(
+		"%s$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		prefix,
+		argon2.Version,
+		params.memory,
+		params.time,
+		params.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func parseArgon2id(hashed string) (argon2Params, []byte, []byte, error) {
+	var params argon2Params
+	parts := strings.Split(hashed, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != PasswordArgon2id {
+		return params, nil, nil, ErrInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("%w: unsupported argon2 version %q", ErrInvalidPasswordHash, parts[2])
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.time, &params.threads); err != nil {
+		return params, nil, nil, fmt.Errorf("%w: %s", ErrInvalidPasswordHash, err)
+	}
+	if params.threads == 0 || params.time == 0 || params.time > argon2MaxTime ||
+		params.memory < 8*uint32(params.threads) || params.memory > argon2MaxMemory {
+		return params, nil, nil, fmt.Errorf("%w: argon2 settings %s out of bounds", ErrInvalidPasswordHash, parts[3])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < argon2MinSalt {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 salt", ErrInvalidPasswordHash)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < argon2MinKeyLen || len(key) > argon2MaxKeyLen {
+		return params, nil, nil, fmt.Errorf("%w: invalid argon2 key", ErrInvalidPasswordHash)
+	}
+	return params, salt, key, nil
+}
+
+func (a *Argon2idPassword) ValidatePassword(storedPassword, password string) bool {
+	params, salt, key, err := parseArgon2id(strings.TrimPrefix(storedPassword, pepperedPrefix))
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(storedPassword, pepperedPrefix) {
+		pepper, err := loadPepper(a.Pepper, a.PepperProvider)
+		if err != nil {
+			log.Print(err)
+			return false
+		}
+		password = pepperPassword(pepper, password)
+	}
+	computed := argon2.IDKey([]byte(password), salt, params.time, params.memory, params.threads, uint32(len(key)))
+	return subtle.ConstantTimeCompare(key, computed) == 1
+}
+
+// NeedsRehash reports the hashes created with weaker settings than the configured ones, or without the configured pepper
+func (a *Argon2idPassword) NeedsRehash(storedPassword string) bool {
+	if a.peppered() != strings.HasPrefix(storedPassword, pepperedPrefix) {
+		return true
+	}
+	current, keyLen, _ := a.params()
+	params, _, key, err := parseArgon2id(strings.TrimPrefix(storedPassword, pepperedPrefix))
+	if err != nil {
+		return true
+	}
+	return params.time < current.time || params.memory < current.memory || uint32(len(key)) < keyLen
+}
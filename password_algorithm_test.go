@@ -0,0 +1,81 @@
+package pager
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fastArgon2 keeps the hashes of the tests cheap
+var fastArgon2 = &Argon2idPassword{Time: 1, Memory: 64, Threads: 1}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	hashed := fastArgon2.HashPassword("correct horse")
+	if !strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$") {
+		t.Fatalf("HashPassword() = %q", hashed)
+	}
+	if !fastArgon2.ValidatePassword(hashed, "correct horse") {
+		t.Error("ValidatePassword() = false for the hashed password")
+	}
+	if fastArgon2.ValidatePassword(hashed, "battery staple") {
+		t.Error("ValidatePassword() = true for another password")
+	}
+}
+
+func TestParseArgon2idRejectsOutOfBoundsHashes(t *testing.T) {
+	const salt, key = "c2FsdHNhbHRzYWx0", "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5"
+	tests := []struct {
+		name   string
+		hashed string
+	}{
+		{name: "no memory", hashed: "$argon2id$v=19$m=0,t=1,p=1$" + salt + "$" + key},
+		{name: "memory under 8 per thread", hashed: "$argon2id$v=19$m=15,t=1,p=2$" + salt + "$" + key},
+		{name: "huge memory", hashed: "$argon2id$v=19$m=4294967295,t=1,p=1$" + salt + "$" + key},
+		{name: "memory overflow", hashed: "$argon2id$v=19$m=4294967296,t=1,p=1$" + salt + "$" + key},
+		{name: "no time", hashed: "$argon2id$v=19$m=64,t=0,p=1$" + salt + "$" + key},
+		{name: "huge time", hashed: "$argon2id$v=19$m=64,t=100000,p=1$" + salt + "$" + key},
+		{name: "no threads", hashed: "$argon2id$v=19$m=64,t=1,p=0$" + salt + "$" + key},
+		{name: "threads overflow", hashed: "$argon2id$v=19$m=64,t=1,p=256$" + salt + "$" + key},
+		{name: "short salt", hashed: "$argon2id$v=19$m=64,t=1,p=1$c2FsdA$" + key},
+		{name: "short key", hashed: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$a2V5"},
+		{name: "huge key", hashed: "$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + strings.Repeat("a2V5", 100)},
+		{name: "version", hashed: "$argon2id$v=16$m=64,t=1,p=1$" + salt + "$" + key},
+		{name: "prefix", hashed: "x$argon2id$v=19$m=64,t=1,p=1$" + salt + "$" + key},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, _, err := parseArgon2id(test.hashed); !errors.Is(err, ErrInvalidPasswordHash) {
+				t.Errorf("parseArgon2id() = %v, want ErrInvalidPasswordHash", err)
+			}
+			if fastArgon2.ValidatePassword(test.hashed, "password") {
+				t.Error("ValidatePassword() = true")
+			}
+			if !fastArgon2.NeedsRehash(test.hashed) {
+				t.Error("NeedsRehash() = false")
+			}
+		})
+	}
+}
+
+func TestValidatePasswordGenerator(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator PasswordGenerator
+		err       error
+	}{
+		{name: "defaults", generator: NewTaggedPassword(PasswordArgon2id, nil)},
+		{name: "bcrypt cost", generator: &DefaultBcryptPassword{Cost: 40}, err: ErrInvalidPasswordCost},
+		{name: "argon2 memory", generator: &Argon2idPassword{Memory: 4 * 1024 * 1024}, err: ErrInvalidArgon2Settings},
+		{name: "argon2 key", generator: &Argon2idPassword{KeyLen: 8}, err: ErrInvalidArgon2Settings},
+		{name: "tagged argon2", generator: NewTaggedPassword(PasswordArgon2id, map[string]PasswordGenerator{
+			PasswordArgon2id: &Argon2idPassword{Time: 100},
+		}), err: ErrInvalidArgon2Settings},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := validatePasswordGenerator(test.generator); !errors.Is(err, test.err) {
+				t.Errorf("validatePasswordGenerator() = %v, want %v", err, test.err)
+			}
+		})
+	}
+}
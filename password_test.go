@@ -0,0 +1,118 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var errSecretsManager = errors.New("secrets manager unavailable")
+
+func failingPepper() ([]byte, error) {
+	return nil, errSecretsManager
+}
+
+// customPassword is a PasswordGenerator unknown to pager
+type customPassword struct{}
+
+func (customPassword) HashPassword(password string) string {
+	return "custom$" + password
+}
+
+func (customPassword) ValidatePassword(storedPassword, password string) bool {
+	return storedPassword == "custom$"+password
+}
+
+func TestPepperProviderFailure(t *testing.T) {
+	tests := []struct {
+		name      string
+		generator PasswordGenerator
+	}{
+		{name: "bcrypt", generator: &DefaultBcryptPassword{Cost: bcrypt.MinCost, PepperProvider: failingPepper}},
+		{name: "argon2id", generator: &Argon2idPassword{Time: 1, Memory: 64, Threads: 1, PepperProvider: failingPepper}},
+		{name: "tagged", generator: NewTaggedPassword(PasswordBcrypt, map[string]PasswordGenerator{
+			PasswordBcrypt: &DefaultBcryptPassword{Cost: bcrypt.MinCost, PepperProvider: failingPepper},
+		})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if hashed, err := hashPassword(test.generator, "password"); !errors.Is(err, ErrPasswordPepper) || hashed != "" {
+				t.Errorf("hashPassword() = %q, %v, want ErrPasswordPepper", hashed, err)
+			} else if !strings.Contains(err.Error(), errSecretsManager.Error()) {
+				t.Errorf("hashPassword() = %v, want the error of the provider", err)
+			}
+			if hashed := test.generator.HashPassword("password"); hashed != "" {
+				t.Errorf("HashPassword() = %q, want an empty hash", hashed)
+			}
+		})
+	}
+
+	auth := &Auth{passwordStrategy: &DefaultBcryptPassword{PepperProvider: failingPepper}}
+	if err := auth.ChangePassword(context.Background(), &User{ID: "1"}, "password"); !errors.Is(err, ErrPasswordPepper) {
+		t.Errorf("ChangePassword() = %v, want ErrPasswordPepper", err)
+	}
+}
+
+func TestHashPasswordOfCustomGenerator(t *testing.T) {
+	if hashed, err := hashPassword(customPassword{}, "password"); err != nil || hashed != "custom$password" {
+		t.Errorf("hashPassword() = %q, %v", hashed, err)
+	}
+}
+
+func TestArgon2idPepper(t *testing.T) {
+	peppered := &Argon2idPassword{Time: 1, Memory: 64, Threads: 1, Pepper: []byte("pepper")}
+	hashed, err := peppered.Hash("password")
+	if err != nil || !strings.HasPrefix(hashed, pepperedPrefix+"$argon2id$") {
+		t.Fatalf("Hash() = %q, %v", hashed, err)
+	}
+	if !peppered.ValidatePassword(hashed, "password") || peppered.NeedsRehash(hashed) {
+		t.Error("the peppered hash isn't accepted")
+	}
+	if fastArgon2.ValidatePassword(hashed, "password") {
+		t.Error("ValidatePassword() = true without the pepper")
+	}
+
+	unpeppered := fastArgon2.HashPassword("password")
+	if !peppered.ValidatePassword(unpeppered, "password") || !peppered.NeedsRehash(unpeppered) {
+		t.Error("the hash created before the pepper isn't accepted and rehashed")
+	}
+}
+
+func TestConfigurePassword(t *testing.T) {
+	pepper := []byte("pepper")
+	tagged := NewTaggedPassword(PasswordArgon2id, nil)
+	if err := configurePassword(tagged, pepper, 12); err != nil {
+		t.Fatalf("configurePassword(TaggedPassword) = %v", err)
+	}
+	bcryptPassword := tagged.Algorithms[PasswordBcrypt].(*DefaultBcryptPassword)
+	if string(bcryptPassword.Pepper) != "pepper" || bcryptPassword.Cost != 12 {
+		t.Errorf("bcrypt = %+v, want the pepper and cost", bcryptPassword)
+	}
+	if argon2Password := tagged.Algorithms[PasswordArgon2id].(*Argon2idPassword); string(argon2Password.Pepper) != "pepper" {
+		t.Errorf("argon2id = %+v, want the pepper", argon2Password)
+	}
+
+	own := &DefaultBcryptPassword{Cost: 11, PepperProvider: failingPepper}
+	if err := configurePassword(own, pepper, 12); err != nil || own.Cost != 11 || own.Pepper != nil {
+		t.Errorf("configurePassword() = %v, %+v, want the settings of the strategy kept", err, own)
+	}
+
+	for _, test := range []struct {
+		pepper []byte
+		cost   int
+	}{{pepper: pepper}, {cost: 12}} {
+		if err := configurePassword(customPassword{}, test.pepper, test.cost); !errors.Is(err, ErrUnsupportedPasswordOption) {
+			t.Errorf("configurePassword(custom, %q, %d) = %v, want ErrUnsupportedPasswordOption", test.pepper, test.cost, err)
+		}
+	}
+	if err := configurePassword(customPassword{}, nil, 0); err != nil {
+		t.Errorf("configurePassword(custom) = %v", err)
+	}
+	custom := NewTaggedPassword(PasswordBcrypt, map[string]PasswordGenerator{"custom": customPassword{}})
+	if err := configurePassword(custom, pepper, 0); !errors.Is(err, ErrUnsupportedPasswordOption) {
+		t.Errorf("configurePassword(TaggedPassword with custom) = %v, want ErrUnsupportedPasswordOption", err)
+	}
+}
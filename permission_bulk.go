@@ -0,0 +1,107 @@
+package pager
+
+import "context"
+
+// RevokeFromAllRoles removes the permission from every role in a single statement, e.g. when retiring an endpoint.
+// Each removed binding is recorded into the audit log, it returns the number of roles the permission was removed from
+func (p *Permission) RevokeFromAllRoles(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return 0, ErrInvalidPermissionID
+	}
+
+	getQuery := `SELECT r.id, r.name
+	FROM rbac_role_permission rp
+	JOIN rbac_role r ON r.id = rp.role_id
+	WHERE rp.permission_id = ?`
+	result, err := p.db.QueryContext(ctx, getQuery, p.ID)
+	if err != nil {
+		return 0, err
+	}
+	roles := make([]*Role, 0)
+	for result.Next() {
+		role := &Role{}
+		if err = result.Scan(&role.ID, &role.Name); err != nil {
+			result.Close()
+			return 0, err
+		}
+		roles = append(roles, role)
+	}
+	result.Close()
+	if err = result.Err(); err != nil {
+		return 0, err
+	}
+
+	deleted, err := p.db.ExecContext(ctx, `DELETE FROM rbac_role_permission WHERE permission_id = ?`, p.ID)
+	if err != nil {
+		return 0, err
+	}
+	purgePermissionCache()
+
+	for _, role := range roles {
+		err = auditRolePermission(ctx, p.db, AuditRolePermissionRemoved, role, p)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return deleted.RowsAffected()
+}
+
+// RemoveAllPermissions removes every permission of the role in a single statement,
+// each removed binding is recorded into the audit log. It returns the number of permissions removed.
+// It requires a confirmation when DualControlOptions.DeleteRole is set, see Role.RequestRemoveAllPermissions
+func (r *Role) RemoveAllPermissions(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return 0, ErrInvalidRoleID
+	}
+	if err := requireDualControl(ctx, r.db, OperationClearRolePermissions, r); err != nil {
+		return 0, err
+	}
+
+	getQuery := `SELECT p.id, p.name
+	FROM rbac_role_permission rp
+	JOIN rbac_permission p ON p.id = rp.permission_id
+	WHERE rp.role_id = ?`
+	result, err := r.db.QueryContext(ctx, getQuery, r.ID)
+	if err != nil {
+		return 0, err
+	}
+	permissions := make([]*Permission, 0)
+	for result.Next() {
+		permission := &Permission{}
+		if err = result.Scan(&permission.ID, &permission.Name); err != nil {
+			result.Close()
+			return 0, err
+		}
+		permissions = append(permissions, permission)
+	}
+	result.Close()
+	if err = result.Err(); err != nil {
+		return 0, err
+	}
+
+	deleted, err := r.db.ExecContext(ctx, `DELETE FROM rbac_role_permission WHERE role_id = ?`, r.ID)
+	if err != nil {
+		return 0, err
+	}
+	purgePermissionCache()
+
+	for _, permission := range permissions {
+		err = auditRolePermission(ctx, r.db, AuditRolePermissionRemoved, r, permission)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return deleted.RowsAffected()
+}
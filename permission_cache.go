@@ -0,0 +1,237 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrPermissionCacheDisabled = errors.New("permission cache is disabled, set Options.PermissionCacheTTL")
+
+// warmCacheBatchSize bounds the number of user ids bound to a single query
+const warmCacheBatchSize = 500
+
+// effectivePermissions are the permissions granted to a user through the non-expired roles
+type effectivePermissions struct {
+	names  map[string]bool
+	routes map[string]bool
+	// patterns are the wildcard and parameterized routes per method
+	patterns map[string][]string
+	// namedRoutes are the route keys per permission name, see restrict
+	namedRoutes map[string][]string
+	expiresAt   time.Time
+}
+
+func newEffectivePermissions(expiresAt time.Time) *effectivePermissions {
+	return &effectivePermissions{
+		names:       make(map[string]bool),
+		routes:      make(map[string]bool),
+		patterns:    make(map[string][]string),
+		namedRoutes: make(map[string][]string),
+		expiresAt:   expiresAt,
+	}
+}
+
+func routeKey(method, path string) string {
+	return strings.ToUpper(method) + " " + path
+}
+
+// permissionCache keeps the effective permissions per user in memory for ttl,
+// the entries of other instances are only refreshed once their ttl passes
+type permissionCache struct {
+	mutex sync.RWMutex
+	ttl   time.Duration
+	users map[string]*effectivePermissions
+}
+
+// newPermissionCache returns nil unless ttl is positive, the cache is disabled then
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &permissionCache{ttl: ttl, users: make(map[string]*effectivePermissions)}
+}
+
+func setPermissionCache(ttl time.Duration) {
+	updateSettings(func(s *settings) {
+		s.permCache = newPermissionCache(ttl)
+	})
+}
+
+func (c *permissionCache) get(userID string) (*effectivePermissions, bool) {
+	c.mutex.RLock()
+	permissions, ok := c.users[userID]
+	c.mutex.RUnlock()
+	if !ok || !clock.Now().Before(permissions.expiresAt) {
+		return nil, false
+	}
+	return permissions, true
+}
+
+func (c *permissionCache) set(userID string, permissions *effectivePermissions) {
+	c.mutex.Lock()
+	c.users[userID] = permissions
+	c.mutex.Unlock()
+}
+
+func (c *permissionCache) invalidate(userIDs ...string) {
+	c.mutex.Lock()
+	for _, userID := range userIDs {
+		delete(c.users, userID)
+	}
+	c.mutex.Unlock()
+}
+
+func (c *permissionCache) purge() {
+	c.mutex.Lock()
+	c.users = make(map[string]*effectivePermissions)
+	c.mutex.Unlock()
+}
+
+// load reads the effective permissions of userIDs and caches them
+func (c *permissionCache) load(ctx context.Context, userIDs []string) (map[string]*effectivePermissions, error) {
+	loaded, err := loadEffectivePermissions(ctx, dbConnection, userIDs, c.ttl)
+	if err != nil {
+		return nil, err
+	}
+	for userID, permissions := range loaded {
+		c.set(userID, permissions)
+	}
+	return loaded, nil
+}
+
+// loadEffectivePermissions reads the permissions of userIDs, granted by roles, group roles or directly.
+// They expire after ttl or with the earliest role grant
+func loadEffectivePermissions(ctx context.Context, db DbContract, userIDs []string, ttl time.Duration) (map[string]*effectivePermissions, error) {
+	now := clock.Now()
+	loaded := make(map[string]*effectivePermissions, len(userIDs))
+	args := make([]interface{}, 0, 3*len(userIDs)+1)
+	for _, userID := range userIDs {
+		loaded[userID] = newEffectivePermissions(now.Add(ttl))
+		args = append(args, userID)
+	}
+	args = append(args, now)
+	args = append(args, args[:len(userIDs)]...)
+	args = append(args, args[:len(userIDs)]...)
+
+	placeholders := `(?` + strings.Repeat(",?", len(userIDs)-1) + `)`
+	getQuery := `SELECT
+		ur.user_id,
+		p.name,
+		p.method,
+		p.route,
+		ur.expires_at
+	FROM rbac_user_role ur
+	JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
+	JOIN ` + permissionRoutes + ` ON p.id = rp.permission_id
+	WHERE ur.user_id IN ` + placeholders + `
+	AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+	UNION ALL
+	SELECT
+		ug.user_id,
+		p.name,
+		p.method,
+		p.route,
+		NULL
+	FROM rbac_user_group ug
+	JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+	JOIN rbac_role_permission rp ON gr.role_id = rp.role_id
+	JOIN ` + permissionRoutes + ` ON p.id = rp.permission_id
+	WHERE ug.user_id IN ` + placeholders + `
+	UNION ALL
+	SELECT
+		up.user_id,
+		p.name,
+		p.method,
+		p.route,
+		NULL
+	FROM rbac_user_permission up
+	JOIN ` + permissionRoutes + ` ON p.id = up.permission_id
+	WHERE up.user_id IN ` + placeholders
+
+	result, err := db.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	for result.Next() {
+		var userID, name, method, route string
+		var expiresAt time.Time
+		err = result.Scan(&userID, &name, &method, &route, timestamp{&expiresAt})
+		if err != nil {
+			return nil, err
+		}
+		permissions, ok := loaded[userID]
+		if !ok {
+			continue
+		}
+		permissions.addRoute(name, method, route)
+		if !expiresAt.IsZero() && expiresAt.Before(permissions.expiresAt) {
+			permissions.expiresAt = expiresAt
+		}
+	}
+	if err = result.Err(); err != nil {
+		return nil, err
+	}
+	return loaded, nil
+}
+
+// cachedPermissions returns the cached permissions of u, loading them on a miss.
+// It reports false when the cache is disabled, u runs inside a PagerTx or loading fails
+func cachedPermissions(ctx context.Context, u *User) (*effectivePermissions, bool) {
+	permissions, ok, err := loadCachedPermissions(ctx, u)
+	return permissions, ok && err == nil
+}
+
+// loadCachedPermissions is cachedPermissions returning the error of a failed load
+func loadCachedPermissions(ctx context.Context, u *User) (*effectivePermissions, bool, error) {
+	permCache := loadSettings().permCache
+	if permCache == nil || u.ID == "" || u.db != dbConnection {
+		return nil, false, nil
+	}
+	if permissions, ok := permCache.get(u.ID); ok {
+		return permissions, true, nil
+	}
+	loaded, err := permCache.load(ctx, []string{u.ID})
+	if err != nil {
+		return nil, false, err
+	}
+	return loaded[u.ID], true, nil
+}
+
+func invalidateUserPermissions(userIDs ...string) {
+	if permCache := loadSettings().permCache; permCache != nil {
+		permCache.invalidate(userIDs...)
+	}
+}
+
+func purgePermissionCache() {
+	current := loadSettings()
+	if current.permCache != nil {
+		current.permCache.purge()
+	}
+	current.roleIndex.purge()
+}
+
+// WarmCache preloads the effective permissions of userIDs into the permission cache,
+// e.g. after a deploy or a cache flush
+func (p *Pager) WarmCache(ctx context.Context, userIDs []string) error {
+	permCache := loadSettings().permCache
+	if permCache == nil {
+		return ErrPermissionCacheDisabled
+	}
+	for start := 0; start < len(userIDs); start += warmCacheBatchSize {
+		end := start + warmCacheBatchSize
+		if end > len(userIDs) {
+			end = len(userIDs)
+		}
+		_, err := permCache.load(ctx, userIDs[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Constants for permission request status
+const (
+	PermissionRequestPending  = "pending"
+	PermissionRequestApproved = "approved"
+	PermissionRequestDenied   = "denied"
+)
+
+// Constants for permission request audit actions
+const (
+	AuditPermissionRequestApproved = "permission_request.approved"
+	AuditPermissionRequestDenied   = "permission_request.denied"
+)
+
+var (
+	ErrPermissionNotFound         = errors.New("permission not found")
+	ErrInvalidPermissionRequestID = errors.New("invalid permission request id")
+	ErrPermissionRequestPending   = errors.New("a request for this permission is already pending")
+	ErrPermissionRequestDecided   = errors.New("permission request has already been decided")
+	ErrJustificationRequired      = errors.New("permission request requires a justification")
+)
+
+type PermissionRequest struct {
+	ID             string    `db:"id" json:"id"`
+	UserID         string    `db:"user_id" json:"user_id"`
+	PermissionID   string    `db:"permission_id" json:"permission_id"`
+	PermissionName string    `db:"permission_name" json:"permission_name"`
+	Justification  string    `db:"justification" json:"justification"`
+	Status         string    `db:"status" json:"status"`
+	RoleID         string    `db:"role_id" json:"role_id,omitempty"`
+	ReviewerID     string    `db:"reviewer_id" json:"reviewer_id,omitempty"`
+	ReviewComment  string    `db:"review_comment" json:"review_comment,omitempty"`
+	DecidedAt      time.Time `db:"decided_at" json:"decided_at,omitempty"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+
+	db DbContract
+}
+
+func (ptx *PagerTx) PermissionRequest(request *PermissionRequest) *PermissionRequest {
+	request.db = ptx.db
+	return request
+}
+
+func (u *User) RequestPermission(permissionName, justification string) (*PermissionRequest, error) {
+	return u.RequestPermissionWithContext(context.Background(), permissionName, justification)
+}
+
+// RequestPermissionWithContext queues a pending request of the user for permissionName
+func (u *User) RequestPermissionWithContext(ctx context.Context, permissionName, justification string) (*PermissionRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+	if justification == "" {
+		return nil, ErrJustificationRequired
+	}
+
+	request := &PermissionRequest{
+		UserID:         u.ID,
+		PermissionName: permissionName,
+		Justification:  justification,
+		Status:         PermissionRequestPending,
+		db:             u.db,
+	}
+	err := queryRow(ctx, u.db, `SELECT id FROM rbac_permission WHERE name = ?`, permissionName).Scan(&request.PermissionID)
+	if err == sql.ErrNoRows {
+		return nil, ErrPermissionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending int64
+	countQuery := `SELECT COUNT(1) FROM rbac_permission_request WHERE user_id = ? AND permission_id = ? AND status = ?`
+	err = queryRow(ctx, u.db, countQuery, u.ID, request.PermissionID, PermissionRequestPending).Scan(&pending)
+	if err != nil {
+		return nil, err
+	}
+	if pending > 0 {
+		return nil, ErrPermissionRequestPending
+	}
+
+	request.ID = newPrimaryKey()
+	request.CreatedAt = clock.Now()
+	insertQuery := `INSERT INTO rbac_permission_request (
+		id,
+		user_id,
+		permission_id,
+		justification,
+		status,
+		created_at) VALUES (?,?,?,?,?,?)`
+	result, err := u.db.ExecContext(
+		ctx,
+		insertQuery,
+		primaryKeyValue(request.ID),
+		request.UserID,
+		request.PermissionID,
+		request.Justification,
+		request.Status,
+		request.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	request.ID, err = insertedID(request.ID, result)
+	if err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+const permissionRequestColumns = `SELECT
+		pr.id,
+		pr.user_id,
+		pr.permission_id,
+		p.name,
+		pr.justification,
+		pr.status,
+		pr.role_id,
+		pr.reviewer_id,
+		pr.review_comment,
+		pr.decided_at,
+		pr.created_at
+	FROM rbac_permission_request pr
+	JOIN rbac_permission p ON p.id = pr.permission_id`
+
+func (r *PermissionRequest) scanFields() []interface{} {
+	return []interface{}{
+		&r.ID,
+		&r.UserID,
+		&r.PermissionID,
+		&r.PermissionName,
+		&r.Justification,
+		&r.Status,
+		textColumn{&r.RoleID},
+		textColumn{&r.ReviewerID},
+		textColumn{&r.ReviewComment},
+		timestamp{&r.DecidedAt},
+		timestamp{&r.CreatedAt},
+	}
+}
+
+func FindPermissionRequest(ctx context.Context, id string, ptx *PagerTx) (*PermissionRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	request := &PermissionRequest{db: db}
+	err := queryRow(ctx, db, permissionRequestColumns+` WHERE pr.id = ?`, id).Scan(request.scanFields()...)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return request, nil
+}
+
+// FindPendingPermissionRequests returns the requests waiting for a review, oldest first
+func FindPendingPermissionRequests(ctx context.Context, ptx *PagerTx) ([]PermissionRequest, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	result, err := db.QueryContext(ctx, permissionRequestColumns+` WHERE pr.status = ? ORDER BY pr.created_at`, PermissionRequestPending)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	requests := make([]PermissionRequest, 0)
+	for result.Next() {
+		request := PermissionRequest{db: db}
+		err = result.Scan(request.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, request)
+	}
+	return requests, result.Err()
+}
+
+// Approve grants the permission through role, the role receives the permission if it lacks it.
+// A nil role grants the permission directly to the requester.
+// The reviewer is the authenticated user in ctx, bind the request with PagerTx.PermissionRequest to apply it atomically
+func (r *PermissionRequest) Approve(ctx context.Context, role *Role, comment string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return ErrInvalidPermissionRequestID
+	}
+	if r.Status != PermissionRequestPending {
+		return ErrPermissionRequestDecided
+	}
+	if role != nil && role.ID == "" {
+		return ErrInvalidRoleID
+	}
+
+	var roleID, grantedBy string
+	if role != nil {
+		roleID, grantedBy = role.ID, role.Name
+	}
+	err := r.decide(ctx, PermissionRequestApproved, roleID, comment)
+	if err != nil {
+		return err
+	}
+
+	if role == nil {
+		user := &User{ID: r.UserID, db: r.db}
+		err = user.GrantPermissionWithContext(ctx, &Permission{ID: r.PermissionID}, r.Justification)
+		if err != nil {
+			return err
+		}
+	} else {
+		now := clock.Now()
+		_, err = r.db.ExecContext(
+			ctx,
+			`INSERT IGNORE INTO rbac_role_permission (role_id, permission_id, created_at, updated_at) VALUES (?,?,?,?)`,
+			role.ID,
+			r.PermissionID,
+			now,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+		_, err = r.db.ExecContext(
+			ctx,
+			`INSERT IGNORE INTO rbac_user_role (role_id, user_id, created_at, updated_at) VALUES (?,?,?,?)`,
+			role.ID,
+			r.UserID,
+			now,
+			now,
+		)
+		if err != nil {
+			return err
+		}
+		purgePermissionCache()
+	}
+
+	return writeAudit(ctx, r.db, &AuditEntry{
+		ActorID:  r.ReviewerID,
+		Action:   AuditPermissionRequestApproved,
+		Target:   r.UserID,
+		Reason:   comment,
+		Metadata: map[string]string{"permission": r.PermissionName, "role": grantedBy},
+	})
+}
+
+// Deny rejects the request, the reviewer is the authenticated user in ctx
+func (r *PermissionRequest) Deny(ctx context.Context, comment string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return ErrInvalidPermissionRequestID
+	}
+	if r.Status != PermissionRequestPending {
+		return ErrPermissionRequestDecided
+	}
+
+	err := r.decide(ctx, PermissionRequestDenied, "", comment)
+	if err != nil {
+		return err
+	}
+	return writeAudit(ctx, r.db, &AuditEntry{
+		ActorID:  r.ReviewerID,
+		Action:   AuditPermissionRequestDenied,
+		Target:   r.UserID,
+		Reason:   comment,
+		Metadata: map[string]string{"permission": r.PermissionName},
+	})
+}
+
+// decide moves the request out of pending, failing when a concurrent review decided it first
+func (r *PermissionRequest) decide(ctx context.Context, status, roleID, comment string) error {
+	reviewerID := actorFromContext(ctx)
+	decidedAt := clock.Now()
+	updateQuery := `UPDATE rbac_permission_request
+	SET status = ?, role_id = ?, reviewer_id = ?, review_comment = ?, decided_at = ?
+	WHERE id = ? AND status = ?`
+	result, err := r.db.ExecContext(
+		ctx,
+		updateQuery,
+		status,
+		primaryKeyValue(roleID),
+		primaryKeyValue(reviewerID),
+		comment,
+		decidedAt,
+		r.ID,
+		PermissionRequestPending,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrPermissionRequestDecided
+	}
+
+	r.Status = status
+	r.RoleID = roleID
+	r.ReviewerID = reviewerID
+	r.ReviewComment = comment
+	r.DecidedAt = decidedAt
+	return nil
+}
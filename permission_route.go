@@ -0,0 +1,111 @@
+package pager
+
+import (
+	"context"
+
+	"github.com/dhanarJkusuma/pager/v2/matcher"
+)
+
+// permissionRoutes lists every route of the permissions, their own route and the alias ones, as p
+const permissionRoutes = `(
+		SELECT id, name, method, route FROM rbac_permission
+		UNION ALL
+		SELECT pp.id, pp.name, pp.method, pr.route
+		FROM rbac_permission_route pr
+		JOIN rbac_permission pp ON pp.id = pr.permission_id
+	) p`
+
+func (p *Permission) AddRoute(route string) error {
+	return p.AddRouteWithContext(context.Background(), route)
+}
+
+// AddRouteWithContext lets the permission grant route too, e.g. "/v2/users" next to "/v1/users", with the same method.
+// Adding it again is a no-op
+func (p *Permission) AddRouteWithContext(ctx context.Context, route string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+	if !routePattern.MatchString(route) {
+		return ValidationErrors{}.add("route", "must start with '/', contain no whitespace and be at most 100 characters")
+	}
+	if _, err := matcher.Compile(route); err != nil {
+		return ValidationErrors{}.add("route", "has an invalid parameter constraint")
+	}
+
+	insertQuery := `INSERT IGNORE INTO rbac_permission_route (
+		permission_id,
+		route,
+		created_at
+	) VALUES (?,?,?)`
+	_, err := p.db.ExecContext(ctx, insertQuery, p.ID, route, clock.Now())
+	if err != nil {
+		return err
+	}
+	purgePermissionCache()
+	return nil
+}
+
+func (p *Permission) RemoveRoute(route string) error {
+	return p.RemoveRouteWithContext(context.Background(), route)
+}
+
+// RemoveRouteWithContext removes an alias route, the own route of the permission is left untouched
+func (p *Permission) RemoveRouteWithContext(ctx context.Context, route string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+
+	deleteQuery := `DELETE FROM rbac_permission_route WHERE permission_id = ? AND route = ?`
+	_, err := p.db.ExecContext(ctx, deleteQuery, p.ID, route)
+	if err != nil {
+		return err
+	}
+	purgePermissionCache()
+	return nil
+}
+
+func (p *Permission) GetRoutes() ([]string, error) {
+	return p.GetRoutesWithContext(context.Background())
+}
+
+// GetRoutesWithContext returns the alias routes of the permission, without its own route
+func (p *Permission) GetRoutesWithContext(ctx context.Context) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return nil, ErrInvalidPermissionID
+	}
+
+	getQuery := `SELECT route FROM rbac_permission_route WHERE permission_id = ? ORDER BY route`
+	result, err := p.db.QueryContext(ctx, getQuery, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	routes := make([]string, 0)
+	for result.Next() {
+		var route string
+		if err = result.Scan(&route); err != nil {
+			return nil, err
+		}
+		routes = append(routes, route)
+	}
+	return routes, result.Err()
+}
@@ -0,0 +1,200 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var ErrInvalidTag = errors.New("invalid tag, must be 1-50 characters of lowercase letters, digits, '-' or '_'")
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !tagPattern.MatchString(tag) {
+		return "", ErrInvalidTag
+	}
+	return tag, nil
+}
+
+func (p *Permission) AddTag(tag string) error {
+	return p.AddTagWithContext(context.Background(), tag)
+}
+
+func (p *Permission) AddTagWithContext(ctx context.Context, tag string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	insertQuery := `INSERT IGNORE INTO rbac_permission_tag (
+		permission_id,
+		tag,
+		created_at
+	) VALUES (?,?,?)`
+	_, err = p.db.ExecContext(
+		ctx,
+		insertQuery,
+		p.ID,
+		tag,
+		clock.Now(),
+	)
+	return err
+}
+
+func (p *Permission) RemoveTag(tag string) error {
+	return p.RemoveTagWithContext(context.Background(), tag)
+}
+
+func (p *Permission) RemoveTagWithContext(ctx context.Context, tag string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	deleteQuery := `DELETE FROM rbac_permission_tag WHERE permission_id = ? AND tag = ?`
+	_, err = p.db.ExecContext(
+		ctx,
+		deleteQuery,
+		p.ID,
+		tag,
+	)
+	return err
+}
+
+func (p *Permission) GetTags() ([]string, error) {
+	return p.GetTagsWithContext(context.Background())
+}
+
+func (p *Permission) GetTagsWithContext(ctx context.Context) ([]string, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	getQuery := `SELECT tag FROM rbac_permission_tag WHERE permission_id = ? ORDER BY tag`
+	result, err := p.db.QueryContext(ctx, getQuery, p.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	tags := make([]string, 0)
+	var tag string
+	for result.Next() {
+		err = result.Scan(&tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, result.Err()
+}
+
+// AddChildrenByTag grants every permission carrying tag to the role and returns the number of new grants
+func (r *Role) AddChildrenByTag(tag string) (int64, error) {
+	return r.AddChildrenByTagWithContext(context.Background(), tag)
+}
+
+func (r *Role) AddChildrenByTagWithContext(ctx context.Context, tag string) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return 0, ErrInvalidRoleID
+	}
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return 0, err
+	}
+
+	now := clock.Now()
+	insertQuery := `INSERT IGNORE INTO rbac_role_permission (
+		role_id,
+		permission_id,
+		created_at,
+		updated_at
+	) SELECT ?, pt.permission_id, ?, ? FROM rbac_permission_tag pt WHERE pt.tag = ?`
+	result, err := r.db.ExecContext(
+		ctx,
+		insertQuery,
+		r.ID,
+		now,
+		now,
+		tag,
+	)
+	if err != nil {
+		return 0, err
+	}
+	purgePermissionCache()
+	return result.RowsAffected()
+}
+
+func FindPermissionsByTag(tag string, ptx *PagerTx) ([]Permission, error) {
+	return FindPermissionsByTagWithContext(context.Background(), tag, ptx)
+}
+
+func FindPermissionsByTagWithContext(ctx context.Context, tag string, ptx *PagerTx) ([]Permission, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+	tag, err := normalizeTag(tag)
+	if err != nil {
+		return nil, err
+	}
+
+	getQuery := `SELECT ` + permissionColumns("p") + `
+	FROM rbac_permission_tag pt
+	JOIN rbac_permission p ON pt.permission_id = p.id
+	WHERE pt.tag = ?
+	ORDER BY p.name`
+	result, err := db.QueryContext(ctx, getQuery, tag)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	permissions := make([]Permission, 0)
+	for result.Next() {
+		var permission Permission
+		err = result.Scan(permission.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, result.Err()
+}
@@ -0,0 +1,227 @@
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// PolicySnapshot is the portable form of the roles, permissions and role-permission matrix,
+// used to compare the policy between environments
+type PolicySnapshot struct {
+	Roles       []PolicyRole       `json:"roles"`
+	Permissions []PolicyPermission `json:"permissions"`
+}
+
+type PolicyRole struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+type PolicyPermission struct {
+	Name        string `json:"name"`
+	Method      string `json:"method"`
+	Route       string `json:"route"`
+	Description string `json:"description,omitempty"`
+}
+
+// PolicyDiff lists what the other snapshot has on top of the current policy (Added),
+// what it lacks (Removed) and the permissions whose method or route differ (Changed).
+// Grants are rendered as "role => permission"
+type PolicyDiff struct {
+	AddedRoles         []string `json:"added_roles"`
+	RemovedRoles       []string `json:"removed_roles"`
+	AddedPermissions   []string `json:"added_permissions"`
+	RemovedPermissions []string `json:"removed_permissions"`
+	ChangedPermissions []string `json:"changed_permissions"`
+	AddedGrants        []string `json:"added_grants"`
+	RemovedGrants      []string `json:"removed_grants"`
+}
+
+func (d *PolicyDiff) Empty() bool {
+	return len(d.AddedRoles)+len(d.RemovedRoles)+
+		len(d.AddedPermissions)+len(d.RemovedPermissions)+len(d.ChangedPermissions)+
+		len(d.AddedGrants)+len(d.RemovedGrants) == 0
+}
+
+func (d *PolicyDiff) String() string {
+	var b strings.Builder
+	write := func(sign, kind string, items []string) {
+		for _, item := range items {
+			fmt.Fprintf(&b, "%s %s %s\n", sign, kind, item)
+		}
+	}
+	write("+", "role", d.AddedRoles)
+	write("-", "role", d.RemovedRoles)
+	write("+", "permission", d.AddedPermissions)
+	write("-", "permission", d.RemovedPermissions)
+	write("~", "permission", d.ChangedPermissions)
+	write("+", "grant", d.AddedGrants)
+	write("-", "grant", d.RemovedGrants)
+	return b.String()
+}
+
+// ExportPolicy writes the current policy as a JSON PolicySnapshot
+func (p *Pager) ExportPolicy(ctx context.Context, w io.Writer) error {
+	snapshot, err := loadPolicySnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(snapshot)
+}
+
+// DiffPolicy compares the current policy with the snapshot exported by ExportPolicy from another environment
+func (p *Pager) DiffPolicy(ctx context.Context, other io.Reader) (*PolicyDiff, error) {
+	var otherSnapshot PolicySnapshot
+	err := json.NewDecoder(other).Decode(&otherSnapshot)
+	if err != nil {
+		return nil, err
+	}
+	current, err := loadPolicySnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return diffPolicy(current, &otherSnapshot), nil
+}
+
+func loadPolicySnapshot(ctx context.Context) (*PolicySnapshot, error) {
+	snapshot := &PolicySnapshot{}
+	roleIndex := make(map[string]int)
+
+	result, err := dbConnection.QueryContext(ctx, `SELECT id, name, description FROM rbac_role ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+	for result.Next() {
+		var id string
+		role := PolicyRole{Permissions: []string{}}
+		err = result.Scan(&id, &role.Name, textColumn{&role.Description})
+		if err != nil {
+			return nil, err
+		}
+		roleIndex[id] = len(snapshot.Roles)
+		snapshot.Roles = append(snapshot.Roles, role)
+	}
+	if err = result.Err(); err != nil {
+		return nil, err
+	}
+
+	permissionRows, err := dbConnection.QueryContext(ctx, `SELECT name, method, route, description FROM rbac_permission ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer permissionRows.Close()
+	for permissionRows.Next() {
+		var permission PolicyPermission
+		err = permissionRows.Scan(&permission.Name, &permission.Method, &permission.Route, textColumn{&permission.Description})
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Permissions = append(snapshot.Permissions, permission)
+	}
+	if err = permissionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	grantRows, err := dbConnection.QueryContext(ctx, `SELECT rp.role_id, p.name
+	FROM rbac_role_permission rp
+	JOIN rbac_permission p ON p.id = rp.permission_id
+	ORDER BY p.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer grantRows.Close()
+	for grantRows.Next() {
+		var roleID, permissionName string
+		err = grantRows.Scan(&roleID, &permissionName)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := roleIndex[roleID]; ok {
+			snapshot.Roles[i].Permissions = append(snapshot.Roles[i].Permissions, permissionName)
+		}
+	}
+	return snapshot, grantRows.Err()
+}
+
+func diffPolicy(current, other *PolicySnapshot) *PolicyDiff {
+	diff := &PolicyDiff{}
+
+	currentRoles := make(map[string]bool)
+	currentGrants := make(map[string]bool)
+	for _, role := range current.Roles {
+		currentRoles[role.Name] = true
+		for _, permission := range role.Permissions {
+			currentGrants[role.Name+" => "+permission] = true
+		}
+	}
+	otherRoles := make(map[string]bool)
+	otherGrants := make(map[string]bool)
+	for _, role := range other.Roles {
+		otherRoles[role.Name] = true
+		for _, permission := range role.Permissions {
+			otherGrants[role.Name+" => "+permission] = true
+		}
+	}
+	diff.AddedRoles, diff.RemovedRoles = diffKeys(currentRoles, otherRoles)
+	diff.AddedGrants, diff.RemovedGrants = diffKeys(currentGrants, otherGrants)
+
+	currentPermissions := make(map[string]PolicyPermission)
+	for _, permission := range current.Permissions {
+		currentPermissions[permission.Name] = permission
+	}
+	otherPermissions := make(map[string]PolicyPermission)
+	for _, permission := range other.Permissions {
+		otherPermissions[permission.Name] = permission
+		if existing, ok := currentPermissions[permission.Name]; ok &&
+			(!strings.EqualFold(existing.Method, permission.Method) || existing.Route != permission.Route) {
+			diff.ChangedPermissions = append(diff.ChangedPermissions, fmt.Sprintf(
+				"%s: %s %s => %s %s",
+				permission.Name,
+				existing.Method,
+				existing.Route,
+				permission.Method,
+				permission.Route,
+			))
+		}
+	}
+	sort.Strings(diff.ChangedPermissions)
+	for name := range currentPermissions {
+		if _, ok := otherPermissions[name]; !ok {
+			diff.RemovedPermissions = append(diff.RemovedPermissions, name)
+		}
+	}
+	for name := range otherPermissions {
+		if _, ok := currentPermissions[name]; !ok {
+			diff.AddedPermissions = append(diff.AddedPermissions, name)
+		}
+	}
+	sort.Strings(diff.AddedPermissions)
+	sort.Strings(diff.RemovedPermissions)
+	return diff
+}
+
+// diffKeys returns the sorted keys only present in other (added) and only present in current (removed)
+func diffKeys(current, other map[string]bool) ([]string, []string) {
+	var added, removed []string
+	for key := range other {
+		if !current[key] {
+			added = append(added, key)
+		}
+	}
+	for key := range current {
+		if !other[key] {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
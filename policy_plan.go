@@ -0,0 +1,391 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrPolicyPlanStale = errors.New("the policy changed since the plan was made, plan again")
+
+// Constants for policy change actions
+const (
+	PolicyCreate = "create"
+	PolicyUpdate = "update"
+	PolicyDelete = "delete"
+)
+
+// Constants for policy change kinds
+const (
+	PolicyKindPermission = "permission"
+	PolicyKindRole       = "role"
+	PolicyKindGrant      = "grant"
+)
+
+// PolicyChange is a step of a PolicyPlan, the grants are named "role => permission"
+type PolicyChange struct {
+	Action string `json:"action"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	// Detail describes the updated fields, e.g. route: /users => /members
+	Detail string `json:"detail,omitempty"`
+}
+
+func (c PolicyChange) String() string {
+	sign := map[string]string{PolicyCreate: "+", PolicyUpdate: "~", PolicyDelete: "-"}[c.Action]
+	if c.Detail == "" {
+		return fmt.Sprintf("%s %s %s", sign, c.Kind, c.Name)
+	}
+	return fmt.Sprintf("%s %s %s (%s)", sign, c.Kind, c.Name, c.Detail)
+}
+
+// PolicyPlan is the set of changes bringing the stored policy to a policy file, made by PlanPolicy
+// and executed by ApplyPolicy. The file is the desired state: the roles, permissions and grants it
+// doesn't declare are deleted, except the system ones
+type PolicyPlan struct {
+	Changes []PolicyChange `json:"changes"`
+
+	seed *Seed
+}
+
+func (p *PolicyPlan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// String renders the plan one change per line, e.g. + role auditor
+func (p *PolicyPlan) String() string {
+	var b strings.Builder
+	for _, change := range p.Changes {
+		b.WriteString(change.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// PlanPolicyFile reads the policy file at path with the seed decoder of its extension and plans it, see PlanPolicy
+func (m *Migration) PlanPolicyFile(ctx context.Context, path string) (*PolicyPlan, error) {
+	mutexSeedLock.RLock()
+	decoder, ok := seedDecoders[strings.ToLower(filepath.Ext(path))]
+	mutexSeedLock.RUnlock()
+	if !ok {
+		return nil, ErrUnsupportedSeedFormat
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed := &Seed{}
+	if err = decoder(data, seed); err != nil {
+		return nil, fmt.Errorf("failed to decode the policy %s: %w", path, err)
+	}
+	return m.PlanPolicy(ctx, seed)
+}
+
+// PlanPolicy compares the stored policy with seed and returns the changes ApplyPolicy would make,
+// nothing is written. The alias routes and the tags of the permissions are added, never planned nor deleted
+func (m *Migration) PlanPolicy(ctx context.Context, seed *Seed) (*PolicyPlan, error) {
+	state, err := loadPolicyState(ctx, dbConnection)
+	if err != nil {
+		return nil, err
+	}
+	return planPolicy(state, seed)
+}
+
+// ApplyPolicy executes plan in one transaction. The plan is made again within the transaction and
+// ErrPolicyPlanStale is returned when the stored policy changed since PlanPolicy
+func (m *Migration) ApplyPolicy(ctx context.Context, plan *PolicyPlan) error {
+	if plan.seed == nil {
+		return ErrPolicyPlanStale
+	}
+	err := runInTx(ctx, func(tx *PagerTx) error {
+		state, err := loadPolicyState(ctx, tx.db)
+		if err != nil {
+			return err
+		}
+		current, err := planPolicy(state, plan.seed)
+		if err != nil {
+			return err
+		}
+		if current.String() != plan.String() {
+			return ErrPolicyPlanStale
+		}
+		return applyPolicyPlan(ctx, tx, state, current)
+	})
+	if err == nil && !plan.Empty() {
+		purgePermissionCache()
+	}
+	return err
+}
+
+type policyPermissionState struct {
+	id          string
+	method      string
+	route       string
+	description string
+	displayName string
+	system      bool
+}
+
+type policyRoleState struct {
+	id          string
+	description string
+	displayName string
+	system      bool
+}
+
+// policyState is the stored policy keyed by name, the grants by "role => permission"
+type policyState struct {
+	permissions map[string]policyPermissionState
+	roles       map[string]policyRoleState
+	grants      map[string]bool
+}
+
+func grantName(role, permission string) string {
+	return role + " => " + permission
+}
+
+func loadPolicyState(ctx context.Context, db DbContract) (*policyState, error) {
+	state := &policyState{
+		permissions: make(map[string]policyPermissionState),
+		roles:       make(map[string]policyRoleState),
+		grants:      make(map[string]bool),
+	}
+
+	permissionRows, err := db.QueryContext(ctx, `SELECT id, name, method, route, description, display_name, is_system FROM rbac_permission`)
+	if err != nil {
+		return nil, err
+	}
+	defer permissionRows.Close()
+	for permissionRows.Next() {
+		var name string
+		var permission policyPermissionState
+		err = permissionRows.Scan(
+			&permission.id,
+			&name,
+			&permission.method,
+			&permission.route,
+			textColumn{&permission.description},
+			textColumn{&permission.displayName},
+			&permission.system,
+		)
+		if err != nil {
+			return nil, err
+		}
+		state.permissions[name] = permission
+	}
+	if err = permissionRows.Err(); err != nil {
+		return nil, err
+	}
+
+	roleRows, err := db.QueryContext(ctx, `SELECT id, name, description, display_name, is_system FROM rbac_role`)
+	if err != nil {
+		return nil, err
+	}
+	defer roleRows.Close()
+	for roleRows.Next() {
+		var name string
+		var role policyRoleState
+		err = roleRows.Scan(&role.id, &name, textColumn{&role.description}, textColumn{&role.displayName}, &role.system)
+		if err != nil {
+			return nil, err
+		}
+		state.roles[name] = role
+	}
+	if err = roleRows.Err(); err != nil {
+		return nil, err
+	}
+
+	grantRows, err := db.QueryContext(ctx, `SELECT r.name, p.name
+	FROM rbac_role_permission rp
+	JOIN rbac_role r ON r.id = rp.role_id
+	JOIN rbac_permission p ON p.id = rp.permission_id`)
+	if err != nil {
+		return nil, err
+	}
+	defer grantRows.Close()
+	for grantRows.Next() {
+		var role, permission string
+		if err = grantRows.Scan(&role, &permission); err != nil {
+			return nil, err
+		}
+		state.grants[grantName(role, permission)] = true
+	}
+	return state, grantRows.Err()
+}
+
+// fieldChanges describes the differing fields as "field: before => after"
+func fieldChanges(fields ...string) string {
+	var changes []string
+	for i := 0; i+2 < len(fields); i += 3 {
+		if fields[i+1] != fields[i+2] {
+			changes = append(changes, fmt.Sprintf("%s: %s => %s", fields[i], fields[i+1], fields[i+2]))
+		}
+	}
+	return strings.Join(changes, ", ")
+}
+
+func planPolicy(state *policyState, seed *Seed) (*PolicyPlan, error) {
+	plan := &PolicyPlan{seed: seed}
+	add := func(action, kind, name, detail string) {
+		plan.Changes = append(plan.Changes, PolicyChange{Action: action, Kind: kind, Name: name, Detail: detail})
+	}
+
+	declaredPermissions := make(map[string]bool, len(seed.Permissions))
+	for _, declared := range seed.Permissions {
+		declaredPermissions[declared.Name] = true
+		stored, ok := state.permissions[declared.Name]
+		if !ok {
+			add(PolicyCreate, PolicyKindPermission, declared.Name, "")
+			continue
+		}
+		detail := fieldChanges(
+			"method", stored.method, strings.ToUpper(declared.Method),
+			"route", stored.route, declared.Route,
+			"description", stored.description, declared.Description,
+			"display_name", stored.displayName, declared.DisplayName,
+			"system", fmt.Sprint(stored.system), fmt.Sprint(declared.System),
+		)
+		if detail != "" {
+			add(PolicyUpdate, PolicyKindPermission, declared.Name, detail)
+		}
+	}
+
+	declaredRoles := make(map[string]bool, len(seed.Roles))
+	declaredGrants := make(map[string]bool)
+	for _, declared := range seed.Roles {
+		declaredRoles[declared.Name] = true
+		stored, ok := state.roles[declared.Name]
+		if !ok {
+			add(PolicyCreate, PolicyKindRole, declared.Name, "")
+		} else if detail := fieldChanges(
+			"description", stored.description, declared.Description,
+			"display_name", stored.displayName, declared.DisplayName,
+			"system", fmt.Sprint(stored.system), fmt.Sprint(declared.System),
+		); detail != "" {
+			add(PolicyUpdate, PolicyKindRole, declared.Name, detail)
+		}
+
+		for _, permission := range declared.Permissions {
+			if _, ok := state.permissions[permission]; !ok && !declaredPermissions[permission] {
+				return nil, fmt.Errorf("role %s: %w: %s", declared.Name, ErrPermissionNotFound, permission)
+			}
+			declaredGrants[grantName(declared.Name, permission)] = true
+		}
+	}
+
+	var grants []string
+	for grant := range declaredGrants {
+		if !state.grants[grant] {
+			grants = append(grants, grant)
+		}
+	}
+	sort.Strings(grants)
+	for _, grant := range grants {
+		add(PolicyCreate, PolicyKindGrant, grant, "")
+	}
+
+	grants = grants[:0]
+	for grant := range state.grants {
+		role := strings.SplitN(grant, " => ", 2)[0]
+		// the grants of the deleted roles go with them, the kept system roles are left untouched
+		if !declaredGrants[grant] && declaredRoles[role] {
+			grants = append(grants, grant)
+		}
+	}
+	sort.Strings(grants)
+	for _, grant := range grants {
+		add(PolicyDelete, PolicyKindGrant, grant, "")
+	}
+
+	var deleted []string
+	for name, role := range state.roles {
+		if !declaredRoles[name] && !role.system {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		add(PolicyDelete, PolicyKindRole, name, "")
+	}
+
+	deleted = deleted[:0]
+	for name, permission := range state.permissions {
+		if !declaredPermissions[name] && !permission.system {
+			deleted = append(deleted, name)
+		}
+	}
+	sort.Strings(deleted)
+	for _, name := range deleted {
+		add(PolicyDelete, PolicyKindPermission, name, "")
+	}
+	return plan, nil
+}
+
+func applyPolicyPlan(ctx context.Context, tx *PagerTx, state *policyState, plan *PolicyPlan) error {
+	changed := make(map[string]bool, len(plan.Changes))
+	for _, change := range plan.Changes {
+		changed[change.Kind+":"+change.Name] = true
+	}
+
+	permissions := make(map[string]*Permission)
+	for _, declared := range plan.seed.Permissions {
+		if !changed[PolicyKindPermission+":"+declared.Name] {
+			continue
+		}
+		permission, err := seedPermission(ctx, tx, declared)
+		if err != nil {
+			return fmt.Errorf("permission %s: %w", declared.Name, err)
+		}
+		permissions[declared.Name] = permission
+	}
+	roles := make(map[string]*Role)
+	for _, declared := range plan.seed.Roles {
+		if !changed[PolicyKindRole+":"+declared.Name] {
+			continue
+		}
+		role, err := seedRole(ctx, tx, declared)
+		if err != nil {
+			return fmt.Errorf("role %s: %w", declared.Name, err)
+		}
+		roles[declared.Name] = role
+	}
+
+	permissionOf := func(name string) *Permission {
+		if permission, ok := permissions[name]; ok {
+			return permission
+		}
+		return tx.Permission(&Permission{ID: state.permissions[name].id, Name: name})
+	}
+	roleOf := func(name string) *Role {
+		if role, ok := roles[name]; ok {
+			return role
+		}
+		return tx.Role(&Role{ID: state.roles[name].id, Name: name})
+	}
+
+	for _, change := range plan.Changes {
+		var err error
+		switch {
+		case change.Kind == PolicyKindGrant:
+			names := strings.SplitN(change.Name, " => ", 2)
+			role, permission := roleOf(names[0]), permissionOf(names[1])
+			if change.Action == PolicyCreate {
+				err = seedRolePermission(ctx, tx, role, permission)
+			} else {
+				err = role.RemoveChildWithContext(ctx, permission)
+			}
+		case change.Kind == PolicyKindRole && change.Action == PolicyDelete:
+			err = roleOf(change.Name).DeleteRoleWithContext(ctx)
+		case change.Kind == PolicyKindPermission && change.Action == PolicyDelete:
+			err = permissionOf(change.Name).DeletePermissionWithContext(ctx)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", change, err)
+		}
+	}
+	return nil
+}
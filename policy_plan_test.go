@@ -0,0 +1,107 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// storedPolicyState is an editor role granted users.read, next to the system admin role and audit.read permission
+func storedPolicyState() *policyState {
+	return &policyState{
+		permissions: map[string]policyPermissionState{
+			"users.read": {id: "1", method: "GET", route: "/users"},
+			"audit.read": {id: "2", method: "GET", route: "/audit", system: true},
+		},
+		roles: map[string]policyRoleState{
+			"editor": {id: "1", description: "Edits the users"},
+			"admin":  {id: "2", system: true},
+		},
+		grants: map[string]bool{
+			grantName("editor", "users.read"): true,
+			grantName("admin", "audit.read"):  true,
+		},
+	}
+}
+
+func TestPlanPolicy(t *testing.T) {
+	usersRead := SeedPermission{Name: "users.read", Method: "get", Route: "/users"}
+	editor := SeedRole{Name: "editor", Description: "Edits the users", Permissions: []string{"users.read"}}
+	tests := []struct {
+		name    string
+		seed    Seed
+		want    string
+		wantErr error
+	}{
+		{
+			name: "up to date",
+			seed: Seed{Permissions: []SeedPermission{usersRead}, Roles: []SeedRole{editor}},
+		},
+		{
+			name: "created",
+			seed: Seed{
+				Permissions: []SeedPermission{usersRead, {Name: "users.write", Method: "POST", Route: "/users"}},
+				Roles: []SeedRole{
+					{Name: "editor", Description: "Edits the users", Permissions: []string{"users.read", "users.write"}},
+					{Name: "auditor", Permissions: []string{"audit.read"}},
+				},
+			},
+			want: "+ permission users.write\n" +
+				"+ role auditor\n" +
+				"+ grant auditor => audit.read\n" +
+				"+ grant editor => users.write\n",
+		},
+		{
+			name: "updated",
+			seed: Seed{
+				Permissions: []SeedPermission{{Name: "users.read", Method: "GET", Route: "/members", DisplayName: "Read users"}},
+				Roles:       []SeedRole{{Name: "editor", Description: "Edits the members", Permissions: []string{"users.read"}}},
+			},
+			want: "~ permission users.read (route: /users => /members, display_name:  => Read users)\n" +
+				"~ role editor (description: Edits the users => Edits the members)\n",
+		},
+		{
+			name: "deleted",
+			seed: Seed{},
+			want: "- role editor\n" +
+				"- permission users.read\n",
+		},
+		{
+			name: "revoked",
+			seed: Seed{
+				Permissions: []SeedPermission{usersRead},
+				Roles:       []SeedRole{{Name: "editor", Description: "Edits the users"}},
+			},
+			want: "- grant editor => users.read\n",
+		},
+		{
+			name:    "unknown permission",
+			seed:    Seed{Roles: []SeedRole{{Name: "editor", Permissions: []string{"users.delete"}}}},
+			wantErr: ErrPermissionNotFound,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plan, err := planPolicy(storedPolicyState(), &test.seed)
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("planPolicy() = %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := plan.String(); got != test.want {
+				t.Errorf("plan =\n%s\nwant\n%s", got, test.want)
+			}
+			if plan.Empty() != (test.want == "") {
+				t.Errorf("Empty() = %v", plan.Empty())
+			}
+		})
+	}
+}
+
+func TestApplyPolicyRejectsForeignPlans(t *testing.T) {
+	plan := &PolicyPlan{Changes: []PolicyChange{{Action: PolicyDelete, Kind: PolicyKindRole, Name: "editor"}}}
+	if err := (&Migration{}).ApplyPolicy(context.Background(), plan); err != ErrPolicyPlanStale {
+		t.Errorf("ApplyPolicy() = %v, want %v", err, ErrPolicyPlanStale)
+	}
+}
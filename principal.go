@@ -0,0 +1,227 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+)
+
+type PrincipalMode int
+
+// Constants for the principal stored in the request context by the middleware.
+// Every mode rejects the requests of a deleted, inactive or merged user, the JWTs verified with VerifyOffline don't
+// go through the principal modes and are accepted until they expire
+const (
+	// PrincipalFull loads the *User on every request, GetUserLogin returns it right away
+	PrincipalFull PrincipalMode = 0
+	// PrincipalSlim keeps the user id and the role names, loaded with a single lightweight query
+	PrincipalSlim PrincipalMode = 1
+	// PrincipalLazy keeps the user id, the *User is loaded on the first GetUserLogin.
+	// The user is checked with a primary key lookup of its active and merged_into columns
+	PrincipalLazy PrincipalMode = 2
+
+	PrincipalKey string = "Principal"
+)
+
+// Principal is the authenticated user of the request, whatever the PrincipalMode
+type Principal struct {
+	UserID string
+	// Roles are the names of the non-expired roles, only set by PrincipalSlim
+	Roles []string
+	// APIKeyID and Scopes are set when the request is authenticated by an API key. The scopes are permission names,
+	// the principal of a scoped key only holds the permissions of the owner named by its scopes
+	APIKeyID string
+	Scopes   []string
+
+	mutex  sync.Mutex
+	user   *User
+	err    error
+	loaded bool
+
+	permissionsOnce sync.Once
+	permissions     *effectivePermissions
+	scopedOnce      sync.Once
+	scoped          *effectivePermissions
+	// prefetched is set when the permissions come from the claims of a JWT
+	prefetched bool
+}
+
+// User returns the authenticated user, loading it at most once per request
+func (p *Principal) User() (*User, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if !p.loaded {
+		p.user, p.err = FindUser(map[string]interface{}{
+			"id": p.UserID,
+		}, nil)
+		if p.err == nil && p.user == nil {
+			p.err = ErrUserNotFound
+		}
+		p.loaded = true
+	}
+	return p.user, p.err
+}
+
+// accessUser returns the loaded user, or a user holding the id only, which is enough for the permission checks
+func (p *Principal) accessUser() *User {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.user != nil {
+		return p.user
+	}
+	return &User{ID: p.UserID}
+}
+
+// Can reports whether the principal holds permissionName, the permissions are resolved at most once per request
+func (p *Principal) Can(permissionName string) bool {
+	permissions := p.effectivePermissions()
+	if permissions == nil {
+		return false
+	}
+	if !permissions.names[permissionName] {
+		if name, ok := resolveAlias(context.Background(), dbConnection, aliasPermission, permissionName); ok {
+			permissionName = name
+		}
+	}
+	return permissions.names[permissionName] || !permissionEnforced(context.Background(), p.accessUser(), permissionName)
+}
+
+// CanAccess is the route counterpart of Can
+func (p *Principal) CanAccess(method, path string) bool {
+	permissions := p.effectivePermissions()
+	if permissions == nil {
+		return false
+	}
+	return permissions.canAccess(method, path) || !routeEnforced(context.Background(), p.accessUser(), method, path)
+}
+
+// effectivePermissions loads the permissions on first use, through the permission cache when it's enabled.
+// A failed load denies every check of the request
+func (p *Principal) effectivePermissions() *effectivePermissions {
+	p.permissionsOnce.Do(func() {
+		ctx, cancel := withQueryTimeout(context.Background())
+		defer cancel()
+
+		user := &User{ID: p.UserID, db: dbConnection}
+		if permissions, ok := cachedPermissions(ctx, user); ok {
+			p.permissions = permissions
+			return
+		}
+		loaded, err := loadEffectivePermissions(ctx, dbConnection, []string{p.UserID}, 0)
+		if err == nil {
+			p.permissions = loaded[p.UserID]
+		}
+	})
+	if len(p.Scopes) > 0 && p.permissions != nil {
+		p.scopedOnce.Do(func() {
+			p.scoped = p.permissions.restrict(p.Scopes)
+		})
+		return p.scoped
+	}
+	return p.permissions
+}
+
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if ctx == nil {
+		return nil
+	}
+	principal, _ := ctx.Value(PrincipalKey).(*Principal)
+	return principal
+}
+
+func GetPrincipal(r *http.Request) *Principal {
+	return PrincipalFromContext(r.Context())
+}
+
+// principalContext stores the principal of userID into ctx according to the configured PrincipalMode
+func (a *Auth) principalContext(ctx context.Context, userID string) (context.Context, error) {
+	principal := &Principal{UserID: userID}
+	switch a.principalMode {
+	case PrincipalSlim:
+		roles, err := activeRoleNames(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		principal.Roles = roles
+	case PrincipalLazy:
+		if err := checkActiveUser(ctx, userID); err != nil {
+			return nil, err
+		}
+	default:
+		user, err := principal.User()
+		if err != nil {
+			return nil, err
+		}
+		if user.IsMerged() {
+			return nil, ErrUserMerged
+		}
+		if !user.Active {
+			return nil, ErrUserNotActive
+		}
+		ctx = context.WithValue(ctx, UserPrinciple, user)
+	}
+	return context.WithValue(ctx, PrincipalKey, principal), nil
+}
+
+// checkActiveUser returns ErrUserNotFound, ErrUserMerged or ErrUserNotActive unless userID is an active user
+func checkActiveUser(ctx context.Context, userID string) error {
+	var active, merged bool
+	getQuery := `SELECT active, merged_into IS NOT NULL FROM rbac_user WHERE id = ?`
+	err := queryRow(ctx, dbConnection, getQuery, userID).Scan(&active, &merged)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return userStatusError(active, merged)
+}
+
+// userStatusError is the error of authenticating a user with the active and merged status, checked like authenticate
+func userStatusError(active, merged bool) error {
+	if merged {
+		return ErrUserMerged
+	}
+	if !active {
+		return ErrUserNotActive
+	}
+	return nil
+}
+
+// activeRoleNames returns the role names of userID, along with the check of checkActiveUser
+func activeRoleNames(ctx context.Context, userID string) ([]string, error) {
+	getQuery := `SELECT u.active, u.merged_into IS NOT NULL, r.name
+	FROM rbac_user u
+	LEFT JOIN rbac_role r ON (` + grantedRoleCondition + `)
+	WHERE u.id = ?`
+	result, err := dbConnection.QueryContext(ctx, getQuery, append(grantedRoleArgs(userID), userID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	found := false
+	roles := make([]string, 0)
+	for result.Next() {
+		var active, merged bool
+		var name sql.NullString
+		if err = result.Scan(&active, &merged, &name); err != nil {
+			return nil, err
+		}
+		if err = userStatusError(active, merged); err != nil {
+			return nil, err
+		}
+		found = true
+		if name.Valid {
+			roles = append(roles, name.String)
+		}
+	}
+	if err = result.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrUserNotFound
+	}
+	return roles, nil
+}
@@ -0,0 +1,101 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storedUser answers the loads of user 1 in every principal mode, the user is missing unless exists
+// and merged into user 2 when merged
+func storedUser(exists, active, merged bool) fakeHandler {
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		if !exists {
+			return fakeResponse{}
+		}
+		var mergedInto driver.Value
+		if merged {
+			mergedInto = "2"
+		}
+		switch {
+		case strings.HasPrefix(query, "SELECT active, merged_into IS NOT NULL FROM rbac_user"):
+			return fakeRowsOf([]string{"active", "merged"}, active, merged)
+		case strings.HasPrefix(query, "SELECT u.active, u.merged_into IS NOT NULL, r.name"):
+			return fakeResponse{
+				columns: []string{"active", "merged", "name"},
+				rows:    [][]driver.Value{{active, merged, "editor"}, {active, merged, "reporter"}},
+			}
+		case strings.HasPrefix(query, "SELECT "+userColumns("")+" FROM rbac_user"):
+			now := time.Now()
+			return fakeRowsOf(
+				[]string{"id", "email", "username", "password", "active", "type", "merged_into", "created_at", "updated_at"},
+				"1", "john@example.com", "john", "-", active, string(UserTypeHuman), mergedInto, now, now,
+			)
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestPrincipalContextChecksTheUser(t *testing.T) {
+	modes := []struct {
+		name string
+		mode PrincipalMode
+	}{
+		{"full", PrincipalFull},
+		{"slim", PrincipalSlim},
+		{"lazy", PrincipalLazy},
+	}
+	users := []struct {
+		name   string
+		exists bool
+		active bool
+		merged bool
+		err    error
+	}{
+		{name: "active", exists: true, active: true},
+		{name: "inactive", exists: true, err: ErrUserNotActive},
+		{name: "merged", exists: true, merged: true, err: ErrUserMerged},
+		{name: "missing", err: ErrUserNotFound},
+	}
+	for _, mode := range modes {
+		for _, user := range users {
+			t.Run(mode.name+"/"+user.name, func(t *testing.T) {
+				_, restore := openFakeDB(t, storedUser(user.exists, user.active, user.merged))
+				defer restore()
+
+				auth := &Auth{principalMode: mode.mode}
+				ctx, err := auth.principalContext(context.Background(), "1")
+				if err != user.err {
+					t.Fatalf("principalContext() = %v, want %v", err, user.err)
+				}
+				if err != nil {
+					return
+				}
+				principal := PrincipalFromContext(ctx)
+				if principal == nil || principal.UserID != "1" {
+					t.Fatalf("principal = %+v", principal)
+				}
+				if mode.mode == PrincipalSlim && strings.Join(principal.Roles, ",") != "editor,reporter" {
+					t.Errorf("Roles = %v", principal.Roles)
+				}
+			})
+		}
+	}
+}
+
+func TestSlimPrincipalWithoutRoles(t *testing.T) {
+	_, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		return fakeRowsOf([]string{"active", "merged", "name"}, true, false, nil)
+	})
+	defer restore()
+
+	ctx, err := (&Auth{principalMode: PrincipalSlim}).principalContext(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("principalContext() = %v", err)
+	}
+	if roles := PrincipalFromContext(ctx).Roles; roles == nil || len(roles) != 0 {
+		t.Errorf("Roles = %#v, want none", roles)
+	}
+}
@@ -0,0 +1,222 @@
+package pager
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+)
+
+// Problem is an RFC 7807 problem details body, the built-in handlers and the middleware respond with it
+type Problem struct {
+	XMLName  xml.Name `json:"-" xml:"urn:ietf:rfc:7807 problem"`
+	Type     string   `json:"type" xml:"type"`
+	Title    string   `json:"title" xml:"title"`
+	Status   int      `json:"status" xml:"status"`
+	Detail   string   `json:"detail,omitempty" xml:"detail,omitempty"`
+	Instance string   `json:"instance,omitempty" xml:"instance,omitempty"`
+
+	// Code is the message key of the error, see ErrorMessageKey
+	Code      string             `json:"code,omitempty" xml:"code,omitempty"`
+	Errors    []*ValidationError `json:"errors,omitempty" xml:"error,omitempty"`
+	RequestID string             `json:"request_id,omitempty" xml:"request_id,omitempty"`
+	// Access explains the route checks of the requests traced by WithAccessExplain
+	Access []AccessTrace `json:"access,omitempty" xml:"access,omitempty"`
+}
+
+const problemJSON = "application/problem+json"
+
+// Constants for problem types, clients can switch on Problem.Type instead of parsing the detail
+const (
+	ProblemInvalidRequest   = "urn:pager:problem:invalid-request"
+	ProblemValidation       = "urn:pager:problem:validation"
+	ProblemUnauthorized     = "urn:pager:problem:unauthorized"
+	ProblemForbidden        = "urn:pager:problem:forbidden"
+	ProblemNotFound         = "urn:pager:problem:not-found"
+	ProblemMethodNotAllowed = "urn:pager:problem:method-not-allowed"
+	ProblemConflict         = "urn:pager:problem:conflict"
+	ProblemRateLimited      = "urn:pager:problem:rate-limited"
+	ProblemInternal         = "urn:pager:problem:internal"
+	ProblemNotImplemented   = "urn:pager:problem:not-implemented"
+	ProblemTimeout          = "urn:pager:problem:timeout"
+)
+
+var problemTypes = map[int]string{
+	http.StatusBadRequest:          ProblemInvalidRequest,
+	http.StatusUnprocessableEntity: ProblemValidation,
+	http.StatusUnauthorized:        ProblemUnauthorized,
+	http.StatusForbidden:           ProblemForbidden,
+	http.StatusNotFound:            ProblemNotFound,
+	http.StatusMethodNotAllowed:    ProblemMethodNotAllowed,
+	http.StatusConflict:            ProblemConflict,
+	http.StatusTooManyRequests:     ProblemRateLimited,
+	http.StatusInternalServerError: ProblemInternal,
+	http.StatusNotImplemented:      ProblemNotImplemented,
+	http.StatusGatewayTimeout:      ProblemTimeout,
+}
+
+// statusMessageKeys are the generic messages of the responses written without an error
+var statusMessageKeys = map[int]string{
+	http.StatusBadRequest:       MsgBadRequest,
+	http.StatusUnauthorized:     MsgUnauthorized,
+	http.StatusForbidden:        MsgForbidden,
+	http.StatusNotFound:         MsgNotFound,
+	http.StatusMethodNotAllowed: MsgMethodNotAllowed,
+}
+
+var errorStatuses = map[error]int{
+	ErrInvalidCredentials:   http.StatusUnauthorized,
+	ErrInvalidPasswordLogin: http.StatusUnauthorized,
+	ErrInvalidUserLogin:     http.StatusUnauthorized,
+	ErrInvalidCookie:        http.StatusUnauthorized,
+	ErrInvalidAuthorization: http.StatusUnauthorized,
+	ErrValidateCookie:       http.StatusUnauthorized,
+	ErrTokenExpired:         http.StatusUnauthorized,
+	ErrTokenRevoked:         http.StatusUnauthorized,
+	ErrActorRequired:        http.StatusUnauthorized,
+	ErrStalePermissions:     http.StatusUnauthorized,
+	ErrUnauthenticated:      http.StatusUnauthorized,
+
+	ErrUserNotActive:        http.StatusForbidden,
+	ErrUserMerged:           http.StatusForbidden,
+	ErrPermissionDenied:     http.StatusForbidden,
+	ErrOriginNotAllowed:     http.StatusForbidden,
+	ErrInvalidCSRFToken:     http.StatusForbidden,
+	ErrSystemEntity:         http.StatusForbidden,
+	ErrNotServiceAccount:    http.StatusForbidden,
+	ErrSelfTestAccessDenied: http.StatusForbidden,
+	ErrDualControlRequired:  http.StatusForbidden,
+	ErrSameApprover:         http.StatusForbidden,
+
+	ErrUserNotFound:       http.StatusNotFound,
+	ErrRoleNotFound:       http.StatusNotFound,
+	ErrPermissionNotFound: http.StatusNotFound,
+	ErrReviewItemNotFound: http.StatusNotFound,
+	ErrSessionNotFound:    http.StatusNotFound,
+	ErrAPIKeyNotFound:     http.StatusNotFound,
+
+	ErrUserExists:               http.StatusConflict,
+	ErrIdentityAlreadyLinked:    http.StatusConflict,
+	ErrPermissionRequestPending: http.StatusConflict,
+	ErrPermissionRequestDecided: http.StatusConflict,
+	ErrCampaignClosed:           http.StatusConflict,
+	ErrBreakGlassHeldRole:       http.StatusConflict,
+	ErrMergeSameUser:            http.StatusConflict,
+	ErrPendingOperationDecided:  http.StatusConflict,
+	ErrNameTaken:                http.StatusConflict,
+
+	ErrInvalidUserID:              http.StatusBadRequest,
+	ErrInvalidRoleID:              http.StatusBadRequest,
+	ErrInvalidPermissionID:        http.StatusBadRequest,
+	ErrInvalidPermissionRequestID: http.StatusBadRequest,
+	ErrInvalidCampaignID:          http.StatusBadRequest,
+	ErrInvalidDecision:            http.StatusBadRequest,
+	ErrInvalidIdentity:            http.StatusBadRequest,
+	ErrInvalidGroupID:             http.StatusBadRequest,
+	ErrInvalidPendingOperationID:  http.StatusBadRequest,
+	ErrInvalidTag:                 http.StatusBadRequest,
+	ErrInvalidVerificationToken:   http.StatusBadRequest,
+	ErrJustificationRequired:      http.StatusBadRequest,
+	ErrBreakGlassReason:           http.StatusBadRequest,
+	ErrBreakGlassTTL:              http.StatusBadRequest,
+	ErrInvalidAPIKeyID:            http.StatusBadRequest,
+	ErrInvalidAPIKeyName:          http.StatusBadRequest,
+	ErrInvalidAPIKeyScope:         http.StatusBadRequest,
+	ErrBreachedPassword:           http.StatusUnprocessableEntity,
+	ErrReservedUsername:           http.StatusUnprocessableEntity,
+	ErrConfusableUsername:         http.StatusUnprocessableEntity,
+
+	ErrLoginThrottled:          http.StatusTooManyRequests,
+	ErrTooManyAttempts:         http.StatusTooManyRequests,
+	ErrUnsupportedSessionStore: http.StatusNotImplemented,
+}
+
+// ErrorToStatus returns the HTTP status matching err, validation errors are 422 and unknown errors 500
+func (a *Auth) ErrorToStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		return http.StatusUnprocessableEntity
+	}
+	for target, status := range errorStatuses {
+		if errors.Is(err, target) {
+			return status
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// Problem describes err for r with the status of ErrorToStatus
+func (a *Auth) Problem(r *http.Request, err error) *Problem {
+	return a.problem(r, a.ErrorToStatus(err), err)
+}
+
+// problem describes err with status, the detail is translated with Accept-Language.
+// The details of the server errors are never exposed, nil errors get the generic message of the status
+func (a *Auth) problem(r *http.Request, status int, err error) *Problem {
+	problemType, ok := problemTypes[status]
+	if !ok {
+		problemType = "about:blank"
+	}
+	problem := &Problem{
+		Type:      problemType,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.Path,
+		RequestID: RequestIDFromContext(r.Context()),
+	}
+	if explanation := AccessExplanationFromContext(r.Context()); explanation != nil {
+		problem.Access = explanation.Traces()
+	}
+
+	key := MsgInternal
+	if err != nil {
+		key = ErrorMessageKey(err)
+	}
+	if statusKey, ok := statusMessageKeys[status]; ok && key == MsgInternal {
+		key = statusKey
+	}
+	switch {
+	case status >= http.StatusInternalServerError:
+		problem.Code = MsgInternal
+		problem.Detail = Translate(MsgInternal, r.Header.Get("Accept-Language"))
+	case key != MsgInternal:
+		problem.Code = key
+		problem.Detail = Translate(key, r.Header.Get("Accept-Language"))
+	default:
+		problem.Detail = err.Error()
+	}
+
+	var validationErrs ValidationErrors
+	if errors.As(err, &validationErrs) {
+		problem.Errors = validationErrs
+	}
+	return problem
+}
+
+// WriteProblem writes err as problem details, application/problem+json unless the client asks for XML or HTML
+func (a *Auth) WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	status := a.ErrorToStatus(err)
+	if status >= http.StatusInternalServerError {
+		logf(r.Context(), "%s %s failed, err = %s", r.Method, r.URL.Path, err)
+	}
+	a.writeError(w, r, status, err)
+}
+
+// HandlerFunc is a handler returning its error instead of writing it
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// HandleErrors adapts h into a http.Handler writing the returned error as problem details with
+// the status of ErrorToStatus, the handler must not have written the response when it returns an error
+func (a *Auth) HandleErrors(h HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := h(w, r); err != nil {
+			a.WriteProblem(w, r, err)
+		}
+	})
+}
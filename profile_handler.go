@@ -0,0 +1,73 @@
+package pager
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type profileResponse struct {
+	User        *User               `json:"user"`
+	Roles       []profileRole       `json:"roles"`
+	Permissions []profilePermission `json:"permissions"`
+}
+
+type profileRole struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+type profilePermission struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name,omitempty"`
+	Method      string `json:"method"`
+	Route       string `json:"route"`
+}
+
+// ProfileHandler serves the authenticated user's profile, roles and effective permissions (/me) as JSON.
+// Mount it behind ProtectRoute or ProtectRouteUsingToken, the password hash is never included
+func (a *Auth) ProfileHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUserLogin(r)
+		if user == nil {
+			a.writeError(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		roles, err := user.GetRolesWithContext(r.Context())
+		if err != nil {
+			a.WriteProblem(w, r, err)
+			return
+		}
+		permissions, err := user.GetEffectivePermissionsWithContext(r.Context())
+		if err != nil {
+			a.WriteProblem(w, r, err)
+			return
+		}
+
+		response := profileResponse{
+			User:        user,
+			Roles:       make([]profileRole, 0, len(roles)),
+			Permissions: make([]profilePermission, 0, len(permissions)),
+		}
+		for _, role := range roles {
+			response.Roles = append(response.Roles, profileRole{
+				Name:        role.Name,
+				DisplayName: role.DisplayName,
+			})
+		}
+		for _, permission := range permissions {
+			response.Permissions = append(response.Permissions, profilePermission{
+				Name:        permission.Name,
+				DisplayName: permission.DisplayName,
+				Method:      permission.Method,
+				Route:       permission.Route,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err = json.NewEncoder(w).Encode(response); err != nil {
+			logError(r.Context(), err)
+		}
+	})
+}
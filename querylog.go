@@ -0,0 +1,130 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryLogOptions configures the query logger. When SlowOnly is set only the statements
+// taking at least SlowThreshold are logged, otherwise every statement is logged and slow ones are flagged
+type QueryLogOptions struct {
+	Logger        *log.Logger
+	SlowThreshold time.Duration
+	SlowOnly      bool
+}
+
+type queryLogger struct {
+	next DbContract
+	opts QueryLogOptions
+}
+
+// NewQueryLogger returns a DbContract wrapper logging statements, durations and affected rows
+func NewQueryLogger(opts QueryLogOptions) func(DbContract) DbContract {
+	if opts.Logger == nil {
+		opts.Logger = log.New(os.Stderr, "[pager] ", log.LstdFlags)
+	}
+	return func(next DbContract) DbContract {
+		return &queryLogger{next: next, opts: opts}
+	}
+}
+
+func (q *queryLogger) log(ctx context.Context, query string, args []interface{}, start time.Time, rows int64, err error) {
+	elapsed := time.Since(start)
+	slow := q.opts.SlowThreshold > 0 && elapsed >= q.opts.SlowThreshold
+	if q.opts.SlowOnly && !slow {
+		return
+	}
+
+	label := "query"
+	if slow {
+		label = "slow query"
+	}
+	rowsInfo := "-"
+	if rows >= 0 {
+		rowsInfo = strconv.FormatInt(rows, 10)
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		label += " request_id=" + requestID
+	}
+	if err != nil {
+		q.opts.Logger.Printf("%s [%s] rows=%s args=%d err=%s: %s", label, elapsed, rowsInfo, len(args), err, compactQuery(query))
+		return
+	}
+	q.opts.Logger.Printf("%s [%s] rows=%s args=%d: %s", label, elapsed, rowsInfo, len(args), compactQuery(query))
+}
+
+// Unwrap returns the wrapped DbContract
+func (q *queryLogger) Unwrap() DbContract {
+	return q.next
+}
+
+func (q *queryLogger) Prepare(query string) (*sql.Stmt, error) {
+	return q.next.Prepare(query)
+}
+
+func (q *queryLogger) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return q.next.PrepareContext(ctx, query)
+}
+
+func (q *queryLogger) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.next.Query(query, args...)
+	q.log(context.Background(), query, args, start, -1, err)
+	return rows, err
+}
+
+func (q *queryLogger) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := q.next.QueryContext(ctx, query, args...)
+	q.log(ctx, query, args, start, -1, err)
+	return rows, err
+}
+
+func (q *queryLogger) QueryRow(query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := q.next.QueryRow(query, args...)
+	q.log(context.Background(), query, args, start, -1, nil)
+	return row
+}
+
+func (q *queryLogger) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	start := time.Now()
+	row := q.next.QueryRowContext(ctx, query, args...)
+	q.log(ctx, query, args, start, -1, nil)
+	return row
+}
+
+func (q *queryLogger) Exec(query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := q.next.Exec(query, args...)
+	q.log(context.Background(), query, args, start, affectedRows(result, err), err)
+	return result, err
+}
+
+func (q *queryLogger) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	result, err := q.next.ExecContext(ctx, query, args...)
+	q.log(ctx, query, args, start, affectedRows(result, err), err)
+	return result, err
+}
+
+func affectedRows(result sql.Result, err error) int64 {
+	if err != nil || result == nil {
+		return -1
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return -1
+	}
+	return rows
+}
+
+// compactQuery collapses the whitespaces of the multi-line queries into a single line
+func compactQuery(query string) string {
+	return strings.Join(strings.Fields(query), " ")
+}
@@ -1,37 +1,123 @@
 package pager
 
 import (
+	"context"
 	"database/sql"
 	"log"
 )
 
+// newTxRetryPolicy returns policy with its defaults, the default policy when nil
+func newTxRetryPolicy(policy *RetryPolicy) RetryPolicy {
+	if policy == nil {
+		return RetryPolicy{}.withDefaults()
+	}
+	return policy.withDefaults()
+}
+
+func setTxRetryPolicy(policy *RetryPolicy) {
+	updateSettings(func(s *settings) {
+		s.txRetryPolicy = newTxRetryPolicy(policy)
+	})
+}
+
 type PagerTx struct {
 	dbTx *sql.Tx
+	db   DbContract
+	ctx  context.Context
 }
 
 func (ptx *PagerTx) BeginTx() error {
-	tx, err := dbConnection.Begin()
+	tx, err := sqlConnection.Begin()
 	ptx.dbTx = tx
+	ptx.db = wrapTx(tx)
 	return err
 }
 
+// BeginTxWithContext begins a transaction bound to ctx, the driver rolls it back when ctx is done before Commit
+func (ptx *PagerTx) BeginTxWithContext(ctx context.Context) error {
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	ptx.dbTx = tx
+	ptx.db = wrapTx(tx)
+	ptx.ctx = ctx
+	return nil
+}
+
+// Context returns the context the transaction is bound to, for the WithContext methods called inside it
+func (ptx *PagerTx) Context() context.Context {
+	if ptx.ctx == nil {
+		return context.Background()
+	}
+	return ptx.ctx
+}
+
+func (ptx *PagerTx) Commit() error {
+	if ptx.dbTx == nil {
+		return ErrTxWithNoBegin
+	}
+	return ptx.dbTx.Commit()
+}
+
+func (ptx *PagerTx) Rollback() error {
+	if ptx.dbTx == nil {
+		return ErrTxWithNoBegin
+	}
+	return ptx.dbTx.Rollback()
+}
+
+// RunInTx runs fn inside a transaction bound to ctx, committed when fn returns nil and rolled back otherwise.
+// A panic in fn rolls the transaction back before being re-raised.
+// The transactions failing with a deadlock or a lock wait timeout are retried along Options.TxRetry,
+// fn may thus run several times and must not keep side effects of a failed attempt
+func (p *Pager) RunInTx(ctx context.Context, fn func(tx *PagerTx) error) error {
+	return runInTx(ctx, fn)
+}
+
+func runInTx(ctx context.Context, fn func(tx *PagerTx) error) error {
+	policy := loadSettings().txRetryPolicy
+	return retryWithBackoff(ctx, policy, IsDeadlockError, func() error {
+		return runInTxOnce(ctx, fn)
+	})
+}
+
+func runInTxOnce(ctx context.Context, fn func(tx *PagerTx) error) (err error) {
+	ptx := &PagerTx{}
+	if err = ptx.BeginTxWithContext(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			ptx.dbTx.Rollback()
+			panic(recovered)
+		}
+	}()
+
+	if err = fn(ptx); err != nil {
+		ptx.dbTx.Rollback()
+		return err
+	}
+	return ptx.dbTx.Commit()
+}
+
 func (ptx *PagerTx) User(user *User) *User {
-	user.db = ptx.dbTx
+	user.db = ptx.db
 	return user
 }
 
 func (ptx *PagerTx) Role(role *Role) *Role {
-	role.db = ptx.dbTx
+	role.db = ptx.db
 	return role
 }
 
 func (ptx *PagerTx) Group(group *Group) *Group {
-	group.db = ptx.dbTx
+	group.db = ptx.db
 	return group
 }
 
 func (ptx *PagerTx) Permission(permission *Permission) *Permission {
-	permission.db = ptx.dbTx
+	permission.db = ptx.db
 	return permission
 }
 
@@ -0,0 +1,147 @@
+package pager
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	AuditRefreshTokenReused = "refresh_token.reused"
+
+	defaultRefreshExpiration = 30 * 24 * time.Hour
+)
+
+// TokenPair is a short-lived access token with the refresh token exchanging it, the expirations are in seconds
+type TokenPair struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	RefreshExpiresIn int64  `json:"refresh_expires_in"`
+}
+
+func (a *Auth) refreshKey(token string) string {
+	return a.cacheKey("refresh:" + token)
+}
+
+func (a *Auth) refreshExpiration() time.Duration {
+	if a.refreshExpiredInSeconds > 0 {
+		return time.Duration(a.refreshExpiredInSeconds) * time.Second
+	}
+	return defaultRefreshExpiration
+}
+
+// SignInWithRefresh is SignIn issuing a refresh token along with the access token
+func (a *Auth) SignInWithRefresh(params LoginParams) (*User, *TokenPair, error) {
+	if a.stateless {
+		return nil, nil, ErrUnsupportedSessionStore
+	}
+	loggedUser, err := a.Authenticate(params)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pair, err := a.issueTokenPair(loggedUser.ID, params.Claims)
+	if err != nil {
+		return nil, nil, ErrCreatingCookie
+	}
+	return loggedUser, pair, nil
+}
+
+func (a *Auth) issueTokenPair(userID string, claims map[string]string) (*TokenPair, error) {
+	now := clock.Now()
+	pair := &TokenPair{
+		AccessToken:      a.tokenStrategy.GenerateToken(),
+		RefreshToken:     a.tokenStrategy.GenerateToken(),
+		ExpiresIn:        a.expiredInSeconds,
+		RefreshExpiresIn: int64(a.refreshExpiration() / time.Second),
+	}
+	err := a.storeSessionData(a.cacheKey(pair.AccessToken), &SessionData{
+		UserID:   userID,
+		Claims:   claims,
+		IssuedAt: now,
+	}, time.Duration(a.expiredInSeconds)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	err = a.storeSessionData(a.refreshKey(pair.RefreshToken), &SessionData{
+		UserID:      userID,
+		Claims:      claims,
+		IssuedAt:    now,
+		AccessToken: pair.AccessToken,
+	}, a.refreshExpiration())
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+func (a *Auth) Refresh(refreshToken string) (*TokenPair, error) {
+	return a.RefreshWithContext(context.Background(), refreshToken)
+}
+
+// RefreshWithContext exchanges the refresh token for a new pair. The refresh token is single use: it's revoked
+// with its access token, and presenting it again is audited as a reuse and rejected with ErrTokenRevoked
+func (a *Auth) RefreshWithContext(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	raw, err := a.sessionStore.Get(a.refreshKey(refreshToken))
+	if err == ErrSessionNotFound {
+		return nil, a.refreshTokenMissing(ctx, refreshToken)
+	}
+	if err != nil {
+		return nil, err
+	}
+	session, err := a.decodeSession(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	// the counter lets a single concurrent refresh win the rotation
+	used, err := a.sessionStore.Increment(a.cacheKey("refresh_used:"+refreshToken), a.refreshExpiration())
+	if err != nil {
+		return nil, err
+	}
+	if used > 1 {
+		a.auditRefreshReuse(ctx, session.UserID)
+		return nil, ErrTokenRevoked
+	}
+	err = a.sessionStore.Set(a.cacheKey("refresh_revoked:"+refreshToken), session.UserID, a.refreshExpiration())
+	if err != nil {
+		return nil, err
+	}
+	err = a.sessionStore.Delete(a.refreshKey(refreshToken), a.cacheKey(session.AccessToken))
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := FindUserWithContext(ctx, map[string]interface{}{"id": session.UserID}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	if !user.Active {
+		return nil, ErrUserNotActive
+	}
+	return a.issueTokenPair(user.ID, session.Claims)
+}
+
+// refreshTokenMissing tells a reused refresh token from an expired one, a reuse may be a stolen token
+func (a *Auth) refreshTokenMissing(ctx context.Context, refreshToken string) error {
+	userID, err := a.sessionStore.Get(a.cacheKey("refresh_revoked:" + refreshToken))
+	if err != nil {
+		return ErrTokenExpired
+	}
+	a.auditRefreshReuse(ctx, userID)
+	return ErrTokenRevoked
+}
+
+func (a *Auth) auditRefreshReuse(ctx context.Context, userID string) {
+	err := WriteAudit(ctx, &AuditEntry{
+		ActorID: actorFromContext(ctx),
+		Action:  AuditRefreshTokenReused,
+		Target:  userID,
+	}, nil)
+	if err != nil {
+		logf(ctx, "failed to audit the refresh token reuse, err = %s", err)
+	}
+}
@@ -0,0 +1,186 @@
+package pager
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// sequenceTokens generates distinct tokens without the bcrypt cost of DefaultTokenGenerator
+type sequenceTokens struct {
+	mutex sync.Mutex
+	next  int
+}
+
+func (g *sequenceTokens) GenerateToken() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.next++
+	return "token-" + strconv.Itoa(g.next)
+}
+
+func (g *sequenceTokens) GenerateCookie() string {
+	return g.GenerateToken()
+}
+
+func refreshAuth() (*Auth, func()) {
+	store := NewMemorySessionStore(time.Hour)
+	auth := &Auth{
+		sessionStore:     store,
+		cacheKeyPrefix:   defaultCacheKeyPrefix,
+		tokenStrategy:    &sequenceTokens{},
+		expiredInSeconds: 60,
+	}
+	return auth, store.Close
+}
+
+func TestRefreshRotatesTheTokens(t *testing.T) {
+	_, restore := openFakeDB(t, storedUser(true, true, false))
+	defer restore()
+	auth, closeStore := refreshAuth()
+	defer closeStore()
+
+	first, err := auth.issueTokenPair("1", map[string]string{"tenant": "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := auth.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh() = %v", err)
+	}
+	if second.AccessToken == first.AccessToken || second.RefreshToken == first.RefreshToken {
+		t.Fatal("Refresh() didn't rotate the tokens")
+	}
+
+	tests := []struct {
+		name  string
+		key   string
+		alive bool
+	}{
+		{"previous access token", auth.cacheKey(first.AccessToken), false},
+		{"previous refresh token", auth.refreshKey(first.RefreshToken), false},
+		{"new access token", auth.cacheKey(second.AccessToken), true},
+		{"new refresh token", auth.refreshKey(second.RefreshToken), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := auth.sessionStore.Get(test.key)
+			if alive := err == nil; alive != test.alive {
+				t.Errorf("stored = %v, want %v", alive, test.alive)
+			}
+		})
+	}
+	raw, err := auth.sessionStore.Get(auth.cacheKey(second.AccessToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := auth.decodeSession(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserID != "1" || session.Claims["tenant"] != "acme" {
+		t.Errorf("session = %+v, want the user and the claims of the previous pair", session)
+	}
+}
+
+func TestRefreshDetectsReuse(t *testing.T) {
+	tests := []struct {
+		name    string
+		refresh func(auth *Auth, pair *TokenPair) error
+		wantErr error
+		audited bool
+	}{
+		{
+			name: "reused after the rotation",
+			refresh: func(auth *Auth, pair *TokenPair) error {
+				if _, err := auth.Refresh(pair.RefreshToken); err != nil {
+					return err
+				}
+				_, err := auth.Refresh(pair.RefreshToken)
+				return err
+			},
+			wantErr: ErrTokenRevoked,
+			audited: true,
+		},
+		{
+			name: "unknown token",
+			refresh: func(auth *Auth, pair *TokenPair) error {
+				_, err := auth.Refresh("unknown")
+				return err
+			},
+			wantErr: ErrTokenExpired,
+		},
+		{
+			name: "access token",
+			refresh: func(auth *Auth, pair *TokenPair) error {
+				_, err := auth.Refresh(pair.AccessToken)
+				return err
+			},
+			wantErr: ErrTokenExpired,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, restore := openFakeDB(t, storedUser(true, true, false))
+			defer restore()
+			auth, closeStore := refreshAuth()
+			defer closeStore()
+
+			pair, err := auth.issueTokenPair("1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = test.refresh(auth, pair); err != test.wantErr {
+				t.Fatalf("Refresh() = %v, want %v", err, test.wantErr)
+			}
+			audited := fake.executed("INSERT INTO rbac_audit_log")
+			if (len(audited) > 0) != test.audited {
+				t.Errorf("audited = %v, want %v", len(audited) > 0, test.audited)
+			}
+			if test.audited && audited[0].args[1].Value != AuditRefreshTokenReused {
+				t.Errorf("audit action = %v, want %s", audited[0].args[1].Value, AuditRefreshTokenReused)
+			}
+		})
+	}
+}
+
+func TestConcurrentRefreshesRotateOnce(t *testing.T) {
+	_, restore := openFakeDB(t, storedUser(true, true, false))
+	defer restore()
+	auth, closeStore := refreshAuth()
+	defer closeStore()
+
+	pair, err := auth.issueTokenPair("1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const attempts = 8
+	errs := make(chan error, attempts)
+	var wg sync.WaitGroup
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := auth.RefreshWithContext(context.Background(), pair.RefreshToken)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	rotated := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			rotated++
+		case ErrTokenRevoked:
+		default:
+			t.Errorf("Refresh() = %v", err)
+		}
+	}
+	if rotated != 1 {
+		t.Errorf("%d refreshes rotated the token, want 1", rotated)
+	}
+}
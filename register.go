@@ -0,0 +1,170 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const defaultVerificationTTL = 24 * time.Hour
+
+var (
+	ErrUserExists               = errors.New("email or username is already registered")
+	ErrRoleNotFound             = errors.New("role not found")
+	ErrNotifierRequired         = errors.New("a notifier is required to send the verification")
+	ErrInvalidVerificationToken = errors.New("verification token is invalid or expired")
+)
+
+type RegisterOptions struct {
+	// CheckUnique rejects the registration with ErrUserExists when the email or username is taken
+	CheckUnique bool
+	// DefaultRoles are the names of the roles assigned to the new user
+	DefaultRoles []string
+	// SendVerification creates the user inactive and sends EventEmailVerification, ConfirmEmail activates it
+	SendVerification bool
+	VerificationTTL  time.Duration
+	// OnUserCreated is invoked once the registration is committed
+	OnUserCreated func(ctx context.Context, user *User)
+}
+
+// RegisterWithOptions hashes the password and creates the user with its default roles in one transaction
+func (a *Auth) RegisterWithOptions(ctx context.Context, user *User, opts RegisterOptions) error {
+	if opts.SendVerification && a.notifications == nil {
+		return ErrNotifierRequired
+	}
+	username, err := NormalizeUsername(user.Username)
+	if err != nil {
+		return err
+	}
+	user.Username = username
+	// validate before hashing, the hash of an empty password isn't empty
+	if err := user.Validate(); err != nil {
+		return err
+	}
+	if err := a.checkBreachedPassword(ctx, user, user.Password); err != nil {
+		return err
+	}
+
+	user.Password, err = hashPassword(a.passwordStrategy, user.Password)
+	if err != nil {
+		return err
+	}
+	id := user.ID
+	err = runInTx(ctx, func(ptx *PagerTx) error {
+		// a deadlocked attempt is retried, drop the id it generated
+		user.ID = id
+		return a.register(ctx, ptx, user, opts)
+	})
+	if err != nil {
+		return err
+	}
+	user.db = dbConnection
+
+	if opts.SendVerification {
+		err = a.SendVerification(ctx, user, opts.VerificationTTL)
+		if err != nil {
+			return err
+		}
+	}
+	if opts.OnUserCreated != nil {
+		opts.OnUserCreated(ctx, user)
+	}
+	return nil
+}
+
+func (a *Auth) register(ctx context.Context, ptx *PagerTx, user *User, opts RegisterOptions) error {
+	if opts.CheckUnique {
+		var count int64
+		countQuery := `SELECT COUNT(1) FROM rbac_user WHERE email = ? OR username = ? FOR UPDATE`
+		err := queryRow(ctx, ptx.db, countQuery, user.Email, user.Username).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrUserExists
+		}
+	}
+
+	err := ptx.User(user).CreateUserWithContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range opts.DefaultRoles {
+		role, err := GetRoleContext(ctx, name, ptx)
+		if err != nil {
+			return err
+		}
+		if role == nil {
+			return ErrRoleNotFound
+		}
+		err = ptx.Role(role).AssignWithContext(ctx, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.SendVerification {
+		_, err = ptx.db.ExecContext(ctx, `UPDATE rbac_user SET active = 0 WHERE id = ?`, user.ID)
+		if err != nil {
+			return err
+		}
+		user.Active = false
+	} else {
+		user.Active = true
+	}
+	return nil
+}
+
+func (a *Auth) verificationKey(token string) string {
+	return a.cacheKey("verify:" + token)
+}
+
+// SendVerification stores a single-use token and sends it through EventEmailVerification
+func (a *Auth) SendVerification(ctx context.Context, user *User, ttl time.Duration) error {
+	if a.notifications == nil {
+		return ErrNotifierRequired
+	}
+	if ttl <= 0 {
+		ttl = defaultVerificationTTL
+	}
+	token := a.tokenStrategy.GenerateToken()
+	err := a.sessionStore.Set(a.verificationKey(token), user.ID, ttl)
+	if err != nil {
+		return err
+	}
+	a.notifications.dispatch(ctx, &Notification{
+		Event: EventEmailVerification,
+		User:  user,
+		Data:  map[string]string{"token": token},
+	})
+	return nil
+}
+
+// ConfirmEmail consumes the verification token and activates its user
+func (a *Auth) ConfirmEmail(ctx context.Context, token string) (*User, error) {
+	userID, err := a.sessionStore.Get(a.verificationKey(token))
+	if err == ErrSessionNotFound {
+		return nil, ErrInvalidVerificationToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = a.sessionStore.Delete(a.verificationKey(token))
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = dbConnection.ExecContext(ctx, `UPDATE rbac_user SET active = 1, updated_at = ? WHERE id = ? AND merged_into IS NULL`, clock.Now(), userID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := FindUserWithContext(ctx, map[string]interface{}{"id": userID}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
@@ -0,0 +1,55 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegisterRequiresAPassword(t *testing.T) {
+	auth := &Auth{passwordStrategy: customPassword{}}
+	tests := []struct {
+		name     string
+		register func(user *User) error
+	}{
+		{"Register", auth.Register},
+		{"RegisterWithOptions", func(user *User) error {
+			return auth.RegisterWithOptions(context.Background(), user, RegisterOptions{})
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, restore := openFakeDB(t, nil)
+			defer restore()
+
+			err := test.register(&User{Email: "john@example.com", Username: "john"})
+			var validationErrs ValidationErrors
+			if !errors.As(err, &validationErrs) || len(validationErrs) != 1 || validationErrs[0].Field != "password" {
+				t.Fatalf("%s() = %v, want a password validation error", test.name, err)
+			}
+			if inserted := fake.executed("INSERT INTO rbac_user"); len(inserted) != 0 {
+				t.Error("the user was created without a password")
+			}
+		})
+	}
+}
+
+func TestAdminCreateRequiresAPassword(t *testing.T) {
+	fake, restore := openFakeDB(t, nil)
+	defer restore()
+
+	admin := &Admin{auth: &Auth{passwordStrategy: customPassword{}}}
+	body := strings.NewReader(`{"email": "john@example.com", "username": "john"}`)
+	recorder := httptest.NewRecorder()
+	admin.proceedUsers(recorder, httptest.NewRequest(http.MethodPost, "/users", body), nil)
+
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusUnprocessableEntity)
+	}
+	if inserted := fake.executed("INSERT INTO rbac_user"); len(inserted) != 0 {
+		t.Error("the user was created without a password")
+	}
+}
@@ -0,0 +1,200 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Constants for the kinds of renamed entities
+const (
+	aliasRole       = "role"
+	aliasPermission = "permission"
+)
+
+// Constants for rename audit actions
+const (
+	AuditRoleRenamed       = "role.renamed"
+	AuditPermissionRenamed = "permission.renamed"
+)
+
+var ErrNameTaken = errors.New("name is already used by another role or permission")
+
+func (r *Role) Rename(ctx context.Context, newName string) error {
+	return r.RenameWithAlias(ctx, newName, 0)
+}
+
+// RenameWithAlias renames the role, HasRole keeps matching the old name until the grace period passes.
+// A zero grace drops the old name right away
+func (r *Role) RenameWithAlias(ctx context.Context, newName string, grace time.Duration) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return ErrInvalidRoleID
+	}
+	if !namePattern.MatchString(newName) {
+		return ValidationErrors{}.add("name", "must be 1-40 characters of letters, digits, '.', '_', ':' or '-'")
+	}
+
+	oldName, err := renameEntity(ctx, r.db, aliasRole, "rbac_role", r.ID, newName, grace)
+	if err != nil {
+		return err
+	}
+	r.Name = newName
+	// the role index of the offline JWT verification is keyed by the role names
+	purgePermissionCache()
+	return writeAudit(ctx, r.db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditRoleRenamed,
+		Target:   r.ID,
+		Metadata: map[string]string{"old_name": oldName, "new_name": newName},
+	})
+}
+
+func (p *Permission) Rename(ctx context.Context, newName string) error {
+	return p.RenameWithAlias(ctx, newName, 0)
+}
+
+// RenameWithAlias renames the permission, HasPermission and Can keep matching the old name until the grace period passes.
+// A zero grace drops the old name right away
+func (p *Permission) RenameWithAlias(ctx context.Context, newName string, grace time.Duration) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+	if !namePattern.MatchString(newName) {
+		return ValidationErrors{}.add("name", "must be 1-40 characters of letters, digits, '.', '_', ':' or '-'")
+	}
+
+	oldName, err := renameEntity(ctx, p.db, aliasPermission, "rbac_permission", p.ID, newName, grace)
+	if err != nil {
+		return err
+	}
+	p.Name = newName
+	purgePermissionCache()
+	return writeAudit(ctx, p.db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditPermissionRenamed,
+		Target:   p.ID,
+		Metadata: map[string]string{"old_name": oldName, "new_name": newName},
+	})
+}
+
+// renameEntity updates the name of the row of table and keeps the old name as an alias for grace,
+// in the transaction of db or in its own one
+func renameEntity(ctx context.Context, db DbContract, kind, table, id, newName string, grace time.Duration) (string, error) {
+	if db != dbConnection {
+		return renameEntityTx(ctx, db, kind, table, id, newName, grace)
+	}
+	var oldName string
+	err := runInTx(ctx, func(tx *PagerTx) error {
+		var err error
+		oldName, err = renameEntityTx(ctx, tx.db, kind, table, id, newName, grace)
+		return err
+	})
+	return oldName, err
+}
+
+func renameEntityTx(ctx context.Context, db DbContract, kind, table, id, newName string, grace time.Duration) (string, error) {
+	if err := checkSystemEntity(ctx, db, table, id); err != nil {
+		return "", err
+	}
+
+	var oldName string
+	err := queryRow(ctx, db, `SELECT name FROM `+table+` WHERE id = ? FOR UPDATE`, id).Scan(&oldName)
+	if err == sql.ErrNoRows {
+		if kind == aliasRole {
+			return "", ErrRoleNotFound
+		}
+		return "", ErrPermissionNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if oldName == newName {
+		return oldName, nil
+	}
+
+	var taken int64
+	err = queryRow(ctx, db, `SELECT COUNT(1) FROM `+table+` WHERE name = ?`, newName).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+	if taken > 0 {
+		return "", ErrNameTaken
+	}
+
+	now := clock.Now()
+	_, err = db.ExecContext(ctx, `UPDATE `+table+` SET name = ?, updated_at = ? WHERE id = ?`, newName, now, id)
+	if err != nil {
+		return "", err
+	}
+	// the new name is real from now on, it can't keep resolving to another entity
+	if err = dropAlias(ctx, db, kind, newName); err != nil {
+		return "", err
+	}
+	if grace <= 0 {
+		return oldName, nil
+	}
+
+	insertQuery := `INSERT INTO rbac_name_alias (
+		kind,
+		alias,
+		target_id,
+		expires_at,
+		created_at) VALUES (?,?,?,?,?)
+	ON DUPLICATE KEY UPDATE target_id = ?, expires_at = ?`
+	expiresAt := now.Add(grace)
+	_, err = db.ExecContext(ctx, insertQuery, kind, oldName, id, expiresAt, now, id, expiresAt)
+	if err != nil {
+		return "", err
+	}
+	return oldName, nil
+}
+
+// dropAlias deletes the alias of the renamed role or permission named name, called once an entity takes the name
+func dropAlias(ctx context.Context, db DbContract, kind, name string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM rbac_name_alias WHERE kind = ? AND alias = ?`, kind, name)
+	return err
+}
+
+// resolveAlias returns the current name of the role or permission renamed from alias, if its grace period is running
+func resolveAlias(ctx context.Context, db DbContract, kind, alias string) (string, bool) {
+	name, ok, err := lookupAlias(ctx, db, kind, alias)
+	return name, ok && err == nil
+}
+
+// lookupAlias is resolveAlias returning the errors of the query
+func lookupAlias(ctx context.Context, db DbContract, kind, alias string) (string, bool, error) {
+	table := "rbac_role"
+	if kind == aliasPermission {
+		table = "rbac_permission"
+	}
+	var name string
+	getQuery := `SELECT t.name
+	FROM rbac_name_alias a
+	JOIN ` + table + ` t ON t.id = a.target_id
+	WHERE a.kind = ? AND a.alias = ? AND a.expires_at > ?
+	AND NOT EXISTS (SELECT 1 FROM ` + table + ` taken WHERE taken.name = a.alias)`
+	err := queryRow(ctx, db, getQuery, kind, alias, clock.Now()).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if name == alias {
+		return "", false, nil
+	}
+	return name, true, nil
+}
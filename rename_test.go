@@ -0,0 +1,86 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renamedRole answers the rename of role 1 from reporter
+func renamedRole(query string, args []driver.NamedValue) fakeResponse {
+	switch {
+	case strings.HasPrefix(query, "SELECT is_system"):
+		return fakeRowsOf([]string{"is_system"}, false)
+	case strings.HasPrefix(query, "SELECT name FROM rbac_role"):
+		return fakeRowsOf([]string{"name"}, "reporter")
+	case strings.HasPrefix(query, "SELECT COUNT(1)"):
+		return fakeRowsOf([]string{"count"}, int64(0))
+	}
+	return fakeResponse{affected: 1}
+}
+
+func TestRoleRenamePurgesTheRoleIndex(t *testing.T) {
+	_, restore := openFakeDB(t, renamedRole)
+	defer restore()
+	setPermissionCache(time.Minute)
+	defer setPermissionCache(0)
+
+	current := loadSettings()
+	current.permCache.set("7", newEffectivePermissions(time.Now().Add(time.Minute)))
+	roleIndex := current.roleIndex
+	roleIndex.mutex.Lock()
+	roleIndex.roles["reporter"] = newEffectivePermissions(time.Time{})
+	roleIndex.mutex.Unlock()
+
+	role := &Role{ID: "1", Name: "reporter"}
+	if err := role.RenameWithAlias(context.Background(), "analyst", time.Hour); err != nil {
+		t.Fatalf("RenameWithAlias() = %v", err)
+	}
+	roleIndex.mutex.RLock()
+	indexed := len(roleIndex.roles)
+	roleIndex.mutex.RUnlock()
+	if indexed != 0 {
+		t.Error("the role index keeps the permissions of the old name")
+	}
+	if _, ok := current.permCache.get("7"); ok {
+		t.Error("the permission cache keeps the permissions loaded before the rename")
+	}
+}
+
+func TestCreateDropsTheAliasOfTheName(t *testing.T) {
+	fake, restore := openFakeDB(t, nil)
+	defer restore()
+
+	if err := (&Role{Name: "reporter"}).CreateRoleWithContext(context.Background()); err != nil {
+		t.Fatalf("CreateRole() = %v", err)
+	}
+	permission := &Permission{Name: "reports.read", Method: "GET", Route: "/reports"}
+	if err := permission.CreatePermissionWithContext(context.Background()); err != nil {
+		t.Fatalf("CreatePermission() = %v", err)
+	}
+
+	dropped := fake.executed("DELETE FROM rbac_name_alias")
+	if len(dropped) != 2 {
+		t.Fatalf("dropped %d aliases, want 2", len(dropped))
+	}
+	for i, want := range [][2]string{{aliasRole, "reporter"}, {aliasPermission, "reports.read"}} {
+		if dropped[i].args[0].Value != want[0] || dropped[i].args[1].Value != want[1] {
+			t.Errorf("dropped alias %v, want %v", dropped[i].args, want)
+		}
+	}
+}
+
+func TestAliasOfATakenNameIsIgnored(t *testing.T) {
+	fake, restore := openFakeDB(t, nil)
+	defer restore()
+
+	if _, ok, err := lookupAlias(context.Background(), dbConnection, aliasRole, "reporter"); ok || err != nil {
+		t.Fatalf("lookupAlias() = %v, %v", ok, err)
+	}
+	lookups := fake.executed("FROM rbac_name_alias")
+	if len(lookups) != 1 || !strings.Contains(lookups[0].query, "NOT EXISTS (SELECT 1 FROM rbac_role taken WHERE taken.name = a.alias)") {
+		t.Errorf("the alias lookup doesn't skip the names taken by a role: %v", lookups)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 )
 
 var (
@@ -12,9 +13,10 @@ var (
 	ErrInvalidPermissionID = errors.New("invalid permission id")
 	ErrInvalidRoleID       = errors.New("invalid role id")
 	ErrTxWithNoBegin       = errors.New("error dbTx without begin()")
+	ErrSystemEntity        = errors.New("system role or permission can't be deleted or renamed")
 )
 
-type dbContract interface {
+type DbContract interface {
 	Prepare(query string) (*sql.Stmt, error)
 	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
 	Query(query string, args ...interface{}) (*sql.Rows, error)
@@ -27,134 +29,281 @@ type dbContract interface {
 
 // User Repository
 type User struct {
-	ID       int64  `db:"id" json:"id"`
+	ID       string `db:"id" json:"id"`
 	Username string `db:"username" json:"username"`
 	Email    string `db:"email" json:"email"`
 	Password string `db:"password" json:"-"`
 	Active   bool   `db:"active" json:"active"`
+	// Type is UserTypeHuman unless set, see UserTypeService
+	Type UserType `db:"type" json:"type"`
+	// MergedInto is the id of the primary account of a user folded by Pager.MergeUsers, such a user can't sign in
+	// or be activated again
+	MergedInto string `db:"merged_into" json:"merged_into,omitempty"`
 
-	db dbContract
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	db DbContract
+}
+
+func userColumns(alias string) string {
+	return prefixColumns(alias, "id", "email", "username", "password", "active", "type", "merged_into", "created_at", "updated_at")
+}
+
+func (u *User) scanFields() []interface{} {
+	return []interface{}{
+		&u.ID,
+		&u.Email,
+		&u.Username,
+		&u.Password,
+		&u.Active,
+		&u.Type,
+		textColumn{&u.MergedInto},
+		timestamp{&u.CreatedAt},
+		timestamp{&u.UpdatedAt},
+	}
 }
 
 func (u *User) CreateUser() error {
 	if u.db == nil {
 		u.db = dbConnection
 	}
+	if err := runUserHooks(context.Background(), BeforeCreate, u); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.Type == "" {
+		u.Type = UserTypeHuman
+	}
+	if u.ID == "" {
+		u.ID = newPrimaryKey()
+	}
+	u.CreatedAt = clock.Now()
+	u.UpdatedAt = u.CreatedAt
 	insertQuery := `INSERT INTO rbac_user (
+		id,
 		email, 
 		username,
-		password) VALUES (?,?,?)`
+		password,
+		type,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?)`
 
 	result, err := u.db.Exec(
 		insertQuery,
+		primaryKeyValue(u.ID),
 		u.Email,
 		u.Username,
 		u.Password,
+		u.Type,
+		u.CreatedAt,
+		u.UpdatedAt,
 	)
 
 	if err != nil {
 		return err
 	}
 
-	u.ID, err = result.LastInsertId()
+	u.ID, err = insertedID(u.ID, result)
+	if err != nil {
+		return err
+	}
 	u.Active = true
-	return nil
+	return runUserHooks(context.Background(), AfterCreate, u)
 }
 
 func (u *User) CreateUserWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
+	if err := runUserHooks(ctx, BeforeCreate, u); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.Type == "" {
+		u.Type = UserTypeHuman
+	}
+	if u.ID == "" {
+		u.ID = newPrimaryKey()
+	}
+	u.CreatedAt = clock.Now()
+	u.UpdatedAt = u.CreatedAt
 	insertQuery := `INSERT INTO rbac_user (
+		id,
 		email, 
 		username,
-		password) VALUES (?,?,?)`
+		password,
+		type,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?)`
 
 	result, err := u.db.ExecContext(
 		ctx,
 		insertQuery,
+		primaryKeyValue(u.ID),
 		u.Email,
 		u.Username,
 		u.Password,
+		u.Type,
+		u.CreatedAt,
+		u.UpdatedAt,
 	)
 
 	if err != nil {
 		return err
 	}
 
-	u.ID, err = result.LastInsertId()
+	u.ID, err = insertedID(u.ID, result)
+	if err != nil {
+		return err
+	}
 	u.Active = true
-	return nil
+	return runUserHooks(ctx, AfterCreate, u)
 }
 
 func (u *User) Save() error {
 	if u.db == nil {
 		u.db = dbConnection
 	}
+	if err := runUserHooks(context.Background(), BeforeSave, u); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.MergedInto != "" && u.Active {
+		return ErrUserMerged
+	}
+	if u.Type == "" {
+		u.Type = UserTypeHuman
+	}
+	if u.ID == "" {
+		u.ID = newPrimaryKey()
+	}
+	u.UpdatedAt = clock.Now()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = u.UpdatedAt
+	}
 	saveQuery := `INSERT INTO rbac_user (
+		id,
 		email,
 		username,
 		password,
-		active
-	) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE email = ?, username = ?, password = ?, active = ?`
+		active,
+		type,
+		created_at,
+		updated_at
+	) VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE email = ?, username = ?, password = ?, active = IF(merged_into IS NULL, ?, 0), type = ?, updated_at = ?`
 
 	result, err := u.db.Exec(
 		saveQuery,
+		primaryKeyValue(u.ID),
 		u.Email,
 		u.Username,
 		u.Password,
 		u.Active,
+		u.Type,
+		u.CreatedAt,
+		u.UpdatedAt,
 		u.Email,
 		u.Username,
 		u.Password,
 		u.Active,
+		u.Type,
+		u.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	u.ID, _ = result.LastInsertId()
-	return nil
+	if u.ID == "" {
+		u.ID, _ = insertedID(u.ID, result)
+	}
+	return runUserHooks(context.Background(), AfterSave, u)
 }
 
 func (u *User) SaveWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
+	if err := runUserHooks(ctx, BeforeSave, u); err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+	if u.MergedInto != "" && u.Active {
+		return ErrUserMerged
+	}
+	if u.Type == "" {
+		u.Type = UserTypeHuman
+	}
+	if u.ID == "" {
+		u.ID = newPrimaryKey()
+	}
+	u.UpdatedAt = clock.Now()
+	if u.CreatedAt.IsZero() {
+		u.CreatedAt = u.UpdatedAt
+	}
 	saveQuery := `INSERT INTO rbac_user (
+		id,
 		email,
 		username,
 		password,
-		active
-	) VALUES(?, ?, ?, ?) ON DUPLICATE KEY UPDATE email = ?, username = ?, password = ?, active = ?`
+		active,
+		type,
+		created_at,
+		updated_at
+	) VALUES(?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE email = ?, username = ?, password = ?, active = IF(merged_into IS NULL, ?, 0), type = ?, updated_at = ?`
 
 	result, err := u.db.ExecContext(
 		ctx,
 		saveQuery,
+		primaryKeyValue(u.ID),
 		u.Email,
 		u.Username,
 		u.Password,
 		u.Active,
+		u.Type,
+		u.CreatedAt,
+		u.UpdatedAt,
 		u.Email,
 		u.Username,
 		u.Password,
 		u.Active,
+		u.Type,
+		u.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	u.ID, _ = result.LastInsertId()
-	return nil
+	if u.ID == "" {
+		u.ID, _ = insertedID(u.ID, result)
+	}
+	return runUserHooks(ctx, AfterSave, u)
 }
 
 func (u *User) Delete() error {
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
+	if err := runUserHooks(context.Background(), BeforeDelete, u); err != nil {
+		return err
+	}
 
 	deleteQuery := `DELETE FROM rbac_user WHERE id = ?`
 
@@ -165,16 +314,23 @@ func (u *User) Delete() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	invalidateUserPermissions(u.ID)
+	return runUserHooks(context.Background(), AfterDelete, u)
 }
 
 func (u *User) DeleteWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
+	if err := runUserHooks(ctx, BeforeDelete, u); err != nil {
+		return err
+	}
 
 	deleteQuery := `DELETE FROM rbac_user WHERE id = ?`
 
@@ -186,99 +342,110 @@ func (u *User) DeleteWithContext(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	invalidateUserPermissions(u.ID)
+	return runUserHooks(ctx, AfterDelete, u)
 }
 
 func (u *User) CanAccess(method, path string) bool {
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	getQuery := `SELECT 
-		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
-	JOIN rbac_permission p ON p.id = rp. permission_id 
-	WHERE ur.user_id = ? AND p.method = ? AND p.route = ?`
-
-	rowData := struct {
-		count int64 `db:"count"`
-	}{}
-
-	result := u.db.QueryRow(getQuery, u.ID, method, path)
-	err := result.Scan(&rowData.count)
-	if err != nil {
-		return false
-	}
-	return rowData.count > 0
+	granted, err := u.canAccess(context.Background(), method, path)
+	return granted && err == nil
 }
 
 func (u *User) CanAccessWithContext(ctx context.Context, method, path string) bool {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	getQuery := `SELECT 
-		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
-	JOIN rbac_permission p ON p.id = rp. permission_id 
-	WHERE ur.user_id = ? AND p.method = ? AND p.route = ?`
-
-	rowData := struct {
-		count int64 `db:"count"`
-	}{}
+	granted, err := u.canAccess(ctx, method, path)
+	granted = granted && err == nil
+	explainAccess(ctx, u, method, path, granted)
+	return granted
+}
 
-	result := u.db.QueryRowContext(ctx, getQuery, u.ID, method, path)
-	err := result.Scan(&rowData.count)
+// canAccess is the check of CanAccess, it returns the errors of the queries instead of denying
+func (u *User) canAccess(ctx context.Context, method, path string) (bool, error) {
+	permissions, ok, err := loadCachedPermissions(ctx, u)
 	if err != nil {
-		return false
+		return false, err
 	}
-	return rowData.count > 0
+	var granted bool
+	if ok {
+		granted = permissions.canAccess(method, path)
+	} else if granted, err = u.grantedRoute(ctx, method, path); err != nil {
+		return false, err
+	}
+	if granted {
+		return true, nil
+	}
+	enforced, err := routeEnforcement(ctx, u, method, path)
+	if err != nil {
+		return false, err
+	}
+	return !enforced, nil
 }
 
 func (u *User) HasPermission(permissionName string) bool {
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	getQuery := `SELECT 
-		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
-	JOIN rbac_permission p ON p.id = rp. permission_id 
-	WHERE ur.user_id = ? AND p.name = ?`
-
-	rowData := struct {
-		count int64 `db:"count"`
-	}{}
-
-	result := u.db.QueryRow(getQuery, u.ID, permissionName)
-	err := result.Scan(&rowData.count)
-	if err != nil {
-		return false
-	}
-	return rowData.count > 0
+	granted, err := u.hasPermission(context.Background(), permissionName)
+	return granted && err == nil
 }
 
 func (u *User) HasPermissionWithContext(ctx context.Context, permissionName string) bool {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
-	getQuery := `SELECT 
-		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
-	JOIN rbac_permission p ON p.id = rp. permission_id 
-	WHERE ur.user_id = ? AND p.name = ?`
+	granted, err := u.hasPermission(ctx, permissionName)
+	return granted && err == nil
+}
 
-	rowData := struct {
-		count int64 `db:"count"`
-	}{}
+// hasPermission is the check of HasPermission, it returns the errors of the queries instead of denying
+func (u *User) hasPermission(ctx context.Context, permissionName string) (bool, error) {
+	permissions, ok, err := loadCachedPermissions(ctx, u)
+	if err != nil {
+		return false, err
+	}
+	var granted bool
+	if ok {
+		granted = permissions.names[permissionName]
+	} else {
+		getQuery := `SELECT 
+			COUNT(1) as count
+		FROM rbac_permission p
+		WHERE p.name = ?
+		AND (` + grantedPermissionCondition + `)`
+
+		rowData := struct {
+			count int64 `db:"count"`
+		}{}
+
+		result := queryRow(ctx, u.db, getQuery, grantedPermissionArgs(u.ID, permissionName)...)
+		if err = result.Scan(&rowData.count); err != nil {
+			return false, err
+		}
+		granted = rowData.count > 0
+	}
+	if granted {
+		return true, nil
+	}
 
-	result := u.db.QueryRowContext(ctx, getQuery, u.ID, permissionName)
-	err := result.Scan(&rowData.count)
+	name, renamed, err := lookupAlias(ctx, u.db, aliasPermission, permissionName)
 	if err != nil {
-		return false
+		return false, err
 	}
-	return rowData.count > 0
+	if renamed {
+		return u.hasPermission(ctx, name)
+	}
+	return !permissionEnforced(ctx, u, permissionName), nil
 }
 
 func (u *User) HasRole(roleName string) bool {
@@ -287,41 +454,54 @@ func (u *User) HasRole(roleName string) bool {
 	}
 	getQuery := `SELECT 
 		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role r ON ur.role_id = r.id 
-	WHERE ur.user_id = ? AND r.name = ?`
+	FROM rbac_role r
+	WHERE r.name = ?
+	AND (` + grantedRoleCondition + `)`
 
 	rowData := struct {
 		count int64 `db:"count"`
 	}{}
 
-	result := u.db.QueryRow(getQuery, u.ID, roleName)
+	result := queryRow(context.Background(), u.db, getQuery, append([]interface{}{roleName}, grantedRoleArgs(u.ID)...)...)
 	err := result.Scan(&rowData.count)
 	if err != nil {
 		return false
 	}
+	if rowData.count == 0 {
+		if name, ok := resolveAlias(context.Background(), u.db, aliasRole, roleName); ok {
+			return u.HasRole(name)
+		}
+	}
 	return rowData.count > 0
 }
 
 func (u *User) HasRoleWithContext(ctx context.Context, roleName string) bool {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
 	getQuery := `SELECT 
 		COUNT(1) as count
-	FROM rbac_user_role ur 
-	JOIN rbac_role r ON ur.role_id = r.id 
-	WHERE ur.user_id = ? AND r.name = ?`
+	FROM rbac_role r
+	WHERE r.name = ?
+	AND (` + grantedRoleCondition + `)`
 
 	rowData := struct {
 		count int64 `db:"count"`
 	}{}
 
-	result := u.db.QueryRowContext(ctx, getQuery, u.ID, roleName)
+	result := queryRow(ctx, u.db, getQuery, append([]interface{}{roleName}, grantedRoleArgs(u.ID)...)...)
 	err := result.Scan(&rowData.count)
 	if err != nil {
 		return false
 	}
+	if rowData.count == 0 {
+		if name, ok := resolveAlias(ctx, u.db, aliasRole, roleName); ok {
+			return u.HasRoleWithContext(ctx, name)
+		}
+	}
 	return rowData.count > 0
 }
 
@@ -330,17 +510,13 @@ func (u *User) GetRoles() ([]Role, error) {
 		u.db = dbConnection
 	}
 	var roles []Role
-	getQuery := `SELECT
-		r.id,
-		r.name,
-		r.description,
-		r.created_at,
-		r.updated_at
+	getQuery := `SELECT ` + roleColumns("r") + `
 	FROM rbac_user_role ur
-	JOIN rbac_role r WHERE ur.user_id = ?`
+	JOIN rbac_role r ON ur.role_id = r.id
+	WHERE ur.user_id = ? AND (ur.expires_at IS NULL OR ur.expires_at > ?)`
 
 	roles = make([]Role, 0)
-	result, err := u.db.Query(getQuery, u.ID)
+	result, err := u.db.Query(getQuery, u.ID, clock.Now())
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return roles, nil
@@ -350,7 +526,7 @@ func (u *User) GetRoles() ([]Role, error) {
 
 	var role Role
 	for result.Next() {
-		err = result.Scan(&role)
+		err = result.Scan(role.scanFields()...)
 		if err == nil {
 			roles = append(roles, role)
 		}
@@ -359,21 +535,20 @@ func (u *User) GetRoles() ([]Role, error) {
 }
 
 func (u *User) GetRolesWithContext(ctx context.Context) ([]Role, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if u.db == nil {
 		u.db = dbConnection
 	}
 	var roles []Role
-	getQuery := `SELECT
-		r.id,
-		r.name,
-		r.description,
-		r.created_at,
-		r.updated_at
+	getQuery := `SELECT ` + roleColumns("r") + `
 	FROM rbac_user_role ur
-	JOIN rbac_role r WHERE ur.user_id = ?`
+	JOIN rbac_role r ON ur.role_id = r.id
+	WHERE ur.user_id = ? AND (ur.expires_at IS NULL OR ur.expires_at > ?)`
 
 	roles = make([]Role, 0)
-	result, err := u.db.QueryContext(ctx, getQuery, u.ID)
+	result, err := u.db.QueryContext(ctx, getQuery, u.ID, clock.Now())
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return roles, nil
@@ -383,7 +558,7 @@ func (u *User) GetRolesWithContext(ctx context.Context) ([]Role, error) {
 
 	var role Role
 	for result.Next() {
-		err = result.Scan(&role)
+		err = result.Scan(role.scanFields()...)
 		if err == nil {
 			roles = append(roles, role)
 		}
@@ -392,21 +567,21 @@ func (u *User) GetRolesWithContext(ctx context.Context) ([]Role, error) {
 }
 
 func GetUser(email string, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var user = new(User)
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE email = ?`
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE email = ?`
 
-	result := db.QueryRow(getQuery, email)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(context.Background(), db, getQuery, email)
+	err := result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -418,21 +593,24 @@ func GetUser(email string, ptx *PagerTx) (*User, error) {
 }
 
 func GetUserWithContext(ctx context.Context, email string, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var user = new(User)
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE email = ?`
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE email = ?`
 
-	result := db.QueryRowContext(ctx, getQuery, email)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(ctx, db, getQuery, email)
+	err := result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -444,21 +622,21 @@ func GetUserWithContext(ctx context.Context, email string, ptx *PagerTx) (*User,
 }
 
 func FindUserByUsernameOrEmail(params string, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var user = new(User)
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE email = ? OR username = ?`
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE email = ? OR username = ?`
 
-	result := db.QueryRow(getQuery, params, params)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(context.Background(), db, getQuery, params, params)
+	err := result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -469,21 +647,24 @@ func FindUserByUsernameOrEmail(params string, ptx *PagerTx) (*User, error) {
 }
 
 func FindUserByUsernameOrEmailWithContext(ctx context.Context, params string, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var user = new(User)
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE email = ? OR username = ?`
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE email = ? OR username = ?`
 
-	result := db.QueryRowContext(ctx, getQuery, params, params)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(ctx, db, getQuery, params, params)
+	err := result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -494,33 +675,24 @@ func FindUserByUsernameOrEmailWithContext(ctx context.Context, params string, pt
 }
 
 func FindUser(params map[string]interface{}, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
-	var user = new(User)
-	var result *sql.Row
-	paramsLength := len(params)
-
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE `
-
-	values := make([]interface{}, 0)
-	index := 0
-	for k := range params {
-		getQuery += fmt.Sprintf("%s = ?", k)
-		if index < paramsLength-1 {
-			getQuery += ` AND `
-		}
-		values = append(values, params[k])
+	conditions, values, err := queryConditions(params, userQueryColumns)
+	if err != nil {
+		return nil, err
 	}
+	var user = new(User)
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE ` + conditions
 
-	result = db.QueryRow(getQuery, values...)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(context.Background(), db, getQuery, values...)
+	err = result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -532,33 +704,27 @@ func FindUser(params map[string]interface{}, ptx *PagerTx) (*User, error) {
 }
 
 func FindUserWithContext(ctx context.Context, params map[string]interface{}, ptx *PagerTx) (*User, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
-	var user = new(User)
-	var result *sql.Row
-	paramsLength := len(params)
-
-	getQuery := `SELECT id, email, username, password, active FROM rbac_user WHERE `
-
-	values := make([]interface{}, 0)
-	index := 0
-	for k := range params {
-		getQuery += fmt.Sprintf("%s = ?", k)
-		if index < paramsLength-1 {
-			getQuery += ` AND `
-		}
-		values = append(values, params[k])
+	conditions, values, err := queryConditions(params, userQueryColumns)
+	if err != nil {
+		return nil, err
 	}
+	var user = new(User)
+	getQuery := `SELECT ` + userColumns("") + ` FROM rbac_user WHERE ` + conditions
 
-	result = db.QueryRowContext(ctx, getQuery, values...)
-	err := result.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.Active)
+	result := queryRow(ctx, db, getQuery, values...)
+	err = result.Scan(user.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -571,54 +737,140 @@ func FindUserWithContext(ctx context.Context, params map[string]interface{}, ptx
 
 // Role Repository
 type Role struct {
-	ID          int64  `db:"id" json:"id"`
-	Name        string `db:"name" json:"name"`
-	Description string `db:"description" json:"description"`
+	ID          string                 `db:"id" json:"id"`
+	Name        string                 `db:"name" json:"name"`
+	Description string                 `db:"description" json:"description"`
+	DisplayName string                 `db:"display_name" json:"display_name"`
+	Metadata    map[string]interface{} `db:"metadata" json:"metadata,omitempty"`
+	IsSystem    bool                   `db:"is_system" json:"is_system"`
 
-	db dbContract
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	db DbContract
+}
+
+func roleColumns(alias string) string {
+	return prefixColumns(alias, "id", "name", "description", "display_name", "metadata", "is_system", "created_at", "updated_at")
+}
+
+func (r *Role) scanFields() []interface{} {
+	return []interface{}{
+		&r.ID,
+		&r.Name,
+		textColumn{&r.Description},
+		textColumn{&r.DisplayName},
+		jsonColumn{&r.Metadata},
+		&r.IsSystem,
+		timestamp{&r.CreatedAt},
+		timestamp{&r.UpdatedAt},
+	}
 }
 
 func (r *Role) CreateRole() error {
 	if r.db == nil {
 		r.db = dbConnection
 	}
+	if err := runRoleHooks(context.Background(), BeforeCreate, r); err != nil {
+		return err
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
 
+	if r.ID == "" {
+		r.ID = newPrimaryKey()
+	}
+	r.CreatedAt = clock.Now()
+	r.UpdatedAt = r.CreatedAt
+	metadata, err := jsonValue(r.Metadata)
+	if err != nil {
+		return err
+	}
 	insertQuery := `INSERT INTO rbac_role (
+		id,
 		name, 
-		description) VALUES (?,?)`
+		description,
+		display_name,
+		metadata,
+		is_system,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?,?)`
 	result, err := r.db.Exec(
 		insertQuery,
+		primaryKeyValue(r.ID),
 		r.Name,
 		r.Description,
+		r.DisplayName,
+		metadata,
+		r.IsSystem,
+		r.CreatedAt,
+		r.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	r.ID, _ = result.LastInsertId()
-	return nil
+	r.ID, _ = insertedID(r.ID, result)
+	if err = dropAlias(context.Background(), r.db, aliasRole, r.Name); err != nil {
+		return err
+	}
+	return runRoleHooks(context.Background(), AfterCreate, r)
 }
 
 func (r *Role) CreateRoleWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
+	if err := runRoleHooks(ctx, BeforeCreate, r); err != nil {
+		return err
+	}
+	if err := r.Validate(); err != nil {
+		return err
+	}
 
+	if r.ID == "" {
+		r.ID = newPrimaryKey()
+	}
+	r.CreatedAt = clock.Now()
+	r.UpdatedAt = r.CreatedAt
+	metadata, err := jsonValue(r.Metadata)
+	if err != nil {
+		return err
+	}
 	insertQuery := `INSERT INTO rbac_role (
+		id,
 		name, 
-		description) VALUES (?,?)`
+		description,
+		display_name,
+		metadata,
+		is_system,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?,?)`
 	result, err := r.db.ExecContext(
 		ctx,
 		insertQuery,
+		primaryKeyValue(r.ID),
 		r.Name,
 		r.Description,
+		r.DisplayName,
+		metadata,
+		r.IsSystem,
+		r.CreatedAt,
+		r.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	r.ID, _ = result.LastInsertId()
-	return nil
+	r.ID, _ = insertedID(r.ID, result)
+	if err = dropAlias(ctx, r.db, aliasRole, r.Name); err != nil {
+		return err
+	}
+	return runRoleHooks(ctx, AfterCreate, r)
 }
 
 func (r *Role) DeleteRole() error {
@@ -626,9 +878,18 @@ func (r *Role) DeleteRole() error {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
+	if err := runRoleHooks(context.Background(), BeforeDelete, r); err != nil {
+		return err
+	}
+	if err := checkSystemEntity(context.Background(), r.db, "rbac_role", r.ID); err != nil {
+		return err
+	}
+	if err := requireDualControl(context.Background(), r.db, OperationDeleteRole, r); err != nil {
+		return err
+	}
 	deleteQuery := `DELETE FROM rbac_role WHERE id = ?`
 	_, err := r.db.Exec(
 		deleteQuery,
@@ -637,17 +898,30 @@ func (r *Role) DeleteRole() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return runRoleHooks(context.Background(), AfterDelete, r)
 }
 
 func (r *Role) DeleteRoleWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
+	if err := runRoleHooks(ctx, BeforeDelete, r); err != nil {
+		return err
+	}
+	if err := checkSystemEntity(ctx, r.db, "rbac_role", r.ID); err != nil {
+		return err
+	}
+	if err := requireDualControl(ctx, r.db, OperationDeleteRole, r); err != nil {
+		return err
+	}
 	deleteQuery := `DELETE FROM rbac_role WHERE id = ?`
 	_, err := r.db.ExecContext(
 		ctx,
@@ -657,6 +931,47 @@ func (r *Role) DeleteRoleWithContext(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	purgePermissionCache()
+	return runRoleHooks(ctx, AfterDelete, r)
+}
+
+// UpdateDisplay changes the human-friendly label and metadata, the name used in checks is left untouched
+func (r *Role) UpdateDisplay(displayName string, metadata map[string]interface{}) error {
+	return r.UpdateDisplayWithContext(context.Background(), displayName, metadata)
+}
+
+func (r *Role) UpdateDisplayWithContext(ctx context.Context, displayName string, metadata map[string]interface{}) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if r.db == nil {
+		r.db = dbConnection
+	}
+	if r.ID == "" {
+		return ErrInvalidRoleID
+	}
+	rawMetadata, err := jsonValue(metadata)
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	updateQuery := `UPDATE rbac_role SET display_name = ?, metadata = ?, updated_at = ? WHERE id = ?`
+	_, err = r.db.ExecContext(
+		ctx,
+		updateQuery,
+		displayName,
+		rawMetadata,
+		now,
+		r.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	r.DisplayName = displayName
+	r.Metadata = metadata
+	r.UpdatedAt = now
 	return nil
 }
 
@@ -664,55 +979,70 @@ func (r *Role) Assign(u *User) error {
 	if r.db == nil {
 		r.db = dbConnection
 	}
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
 
+	now := clock.Now()
 	insertQuery := `INSERT INTO rbac_user_role (
 		role_id, 
-		user_id
-	) VALUES (?,?)`
+		user_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	_, err := r.db.Exec(
 		insertQuery,
 		r.ID,
 		u.ID,
+		now,
+		now,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	invalidateUserPermissions(u.ID)
+	return auditRoleAssignment(context.Background(), r.db, AuditRoleAssigned, r, u, nil)
 }
 
 func (r *Role) AssignWithContext(ctx context.Context, u *User) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
 
+	now := clock.Now()
 	insertQuery := `INSERT INTO rbac_user_role (
 		role_id, 
-		user_id
-	) VALUES (?,?)`
+		user_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	_, err := r.db.ExecContext(
 		ctx,
 		insertQuery,
 		r.ID,
 		u.ID,
+		now,
+		now,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	invalidateUserPermissions(u.ID)
+	return auditRoleAssignment(ctx, r.db, AuditRoleAssigned, r, u, nil)
 }
 
 func (r *Role) Revoke(u *User) error {
@@ -720,13 +1050,16 @@ func (r *Role) Revoke(u *User) error {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
+	if err := requireDualControl(context.Background(), r.db, OperationRevokeRole, r); err != nil {
+		return err
+	}
 
 	revokeQuery := `DELETE FROM rbac_user_role WHERE role_id = ? AND user_id = ?`
 	_, err := r.db.Exec(
@@ -737,22 +1070,29 @@ func (r *Role) Revoke(u *User) error {
 	if err != nil {
 		return err
 	}
+	invalidateUserPermissions(u.ID)
 
-	return nil
+	return auditRoleAssignment(context.Background(), r.db, AuditRoleRevoked, r, u, nil)
 }
 
 func (r *Role) RevokeWithContext(ctx context.Context, u *User) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if u.ID <= 0 {
+	if u.ID == "" {
 		return ErrInvalidUserID
 	}
+	if err := requireDualControl(ctx, r.db, OperationRevokeRole, r); err != nil {
+		return err
+	}
 
 	revokeQuery := `DELETE FROM rbac_user_role WHERE role_id = ? AND user_id = ?`
 	_, err := r.db.ExecContext(
@@ -764,8 +1104,9 @@ func (r *Role) RevokeWithContext(ctx context.Context, u *User) error {
 	if err != nil {
 		return err
 	}
+	invalidateUserPermissions(u.ID)
 
-	return nil
+	return auditRoleAssignment(ctx, r.db, AuditRoleRevoked, r, u, nil)
 }
 
 func (r *Role) AddChild(p *Permission) error {
@@ -773,56 +1114,71 @@ func (r *Role) AddChild(p *Permission) error {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
 
+	now := clock.Now()
 	insertQuery := `INSERT INTO rbac_role_permission (
 		role_id, 
-		permission_id
-	) VALUES (?,?)`
+		permission_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	_, err := r.db.Exec(
 		insertQuery,
 		r.ID,
 		p.ID,
+		now,
+		now,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return auditRolePermission(context.Background(), r.db, AuditRolePermissionAdded, r, p)
 }
 
 func (r *Role) AddChildWithContext(ctx context.Context, p *Permission) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
 
+	now := clock.Now()
 	insertQuery := `INSERT INTO rbac_role_permission (
 		role_id, 
-		permission_id
-	) VALUES (?,?)`
+		permission_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	_, err := r.db.ExecContext(
 		ctx,
 		insertQuery,
 		r.ID,
 		p.ID,
+		now,
+		now,
 	)
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return auditRolePermission(ctx, r.db, AuditRolePermissionAdded, r, p)
 }
 
 func (r *Role) RemoveChild(p *Permission) error {
@@ -830,11 +1186,11 @@ func (r *Role) RemoveChild(p *Permission) error {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
 
@@ -847,19 +1203,23 @@ func (r *Role) RemoveChild(p *Permission) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return auditRolePermission(context.Background(), r.db, AuditRolePermissionRemoved, r, p)
 }
 
 func (r *Role) RemoveChildWithContext(ctx context.Context, p *Permission) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
 
-	if r.ID <= 0 {
+	if r.ID == "" {
 		return ErrInvalidRoleID
 	}
 
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
 
@@ -873,94 +1233,59 @@ func (r *Role) RemoveChildWithContext(ctx context.Context, p *Permission) error
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return auditRolePermission(ctx, r.db, AuditRolePermissionRemoved, r, p)
 }
 
 func (r *Role) GetPermission() ([]Permission, error) {
-	if r.db == nil {
-		r.db = dbConnection
-	}
-	var permissions []Permission
-	getQuery := `SELECT
-		p.id,
-		p.name,
-		p.method,
-		p.route,
-		p.description
-	FROM rbac_role_permission rp
-	JOIN rbac_permission p WHERE rp.role_id = ?`
-
-	permissions = make([]Permission, 0)
-	result, err := r.db.Query(getQuery, r.ID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return permissions, nil
-		}
-		return nil, err
-	}
-
-	var permission Permission
-	for result.Next() {
-		err = result.Scan(&permission.ID, &permission.Name, &permission.Method, &permission.Route, &permission.Description)
-		if err == nil {
-			permissions = append(permissions, permission)
-		}
-	}
-	return permissions, nil
+	return r.GetPermissionWithContext(context.Background())
 }
 
+// GetPermissionWithContext returns the permissions of the role ordered by name
 func (r *Role) GetPermissionWithContext(ctx context.Context) ([]Permission, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if r.db == nil {
 		r.db = dbConnection
 	}
-	var permissions []Permission
-	getQuery := `SELECT
-		p.id,
-		p.name,
-		p.method,
-		p.route,
-		p.description
+	getQuery := `SELECT ` + permissionColumns("p") + `
 	FROM rbac_role_permission rp
-	JOIN rbac_permission p WHERE rp.role_id = ?`
-
-	permissions = make([]Permission, 0)
+	JOIN rbac_permission p ON p.id = rp.permission_id
+	WHERE rp.role_id = ?
+	ORDER BY p.name, p.id`
 	result, err := r.db.QueryContext(ctx, getQuery, r.ID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return permissions, nil
-		}
 		return nil, err
 	}
+	defer result.Close()
 
-	var permission Permission
+	permissions := make([]Permission, 0)
 	for result.Next() {
-		err = result.Scan(&permission.ID, &permission.Name, &permission.Method, &permission.Route, &permission.Description)
-		if err == nil {
-			permissions = append(permissions, permission)
+		var permission Permission
+		if err = result.Scan(permission.scanFields()...); err != nil {
+			return nil, err
 		}
+		permissions = append(permissions, permission)
 	}
-	return permissions, nil
+	return permissions, result.Err()
 }
 
 func GetRole(name string, ptx *PagerTx) (*Role, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 	var role = new(Role)
-	getQuery := `SELECT
-		id,
-		name,
-		description 
-	FROM rbac_role WHERE name = ?`
+	getQuery := `SELECT ` + roleColumns("") + ` FROM rbac_role WHERE name = ?`
 
-	result := db.QueryRow(getQuery, name)
-	err := result.Scan(&role.ID, &role.Name, &role.Description)
+	result := queryRow(context.Background(), db, getQuery, name)
+	err := result.Scan(role.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -971,24 +1296,23 @@ func GetRole(name string, ptx *PagerTx) (*Role, error) {
 }
 
 func GetRoleContext(ctx context.Context, name string, ptx *PagerTx) (*Role, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 	var role = new(Role)
-	getQuery := `SELECT
-		id,
-		name,
-		description 
-	FROM rbac_role WHERE name = ?`
+	getQuery := `SELECT ` + roleColumns("") + ` FROM rbac_role WHERE name = ?`
 
-	result := db.QueryRowContext(ctx, getQuery, name)
-	err := result.Scan(&role.ID, &role.Name, &role.Description)
+	result := queryRow(ctx, db, getQuery, name)
+	err := result.Scan(role.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -998,73 +1322,199 @@ func GetRoleContext(ctx context.Context, name string, ptx *PagerTx) (*Role, erro
 	return role, nil
 }
 
+// FindRoles returns a page of the roles ordered by name
+func FindRoles(ctx context.Context, page, size int64, ptx *PagerTx) ([]Role, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+	getQuery := `SELECT ` + roleColumns("") + ` FROM rbac_role ORDER BY name LIMIT ? OFFSET ?`
+	result, err := db.QueryContext(ctx, getQuery, size, pageOffset(page, size))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	roles := make([]Role, 0)
+	for result.Next() {
+		var role Role
+		if err = result.Scan(role.scanFields()...); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, result.Err()
+}
+
 // Permission Repository
 type Permission struct {
-	ID          int64  `db:"id"`
-	Name        string `db:"name"`
-	Method      string `db:"method"`
-	Route       string `db:"route"`
-	Description string `db:"description"`
+	ID          string                 `db:"id" json:"id"`
+	Name        string                 `db:"name" json:"name"`
+	Method      string                 `db:"method" json:"method"`
+	Route       string                 `db:"route" json:"route"`
+	Description string                 `db:"description" json:"description"`
+	DisplayName string                 `db:"display_name" json:"display_name"`
+	Metadata    map[string]interface{} `db:"metadata" json:"metadata,omitempty"`
+	IsSystem    bool                   `db:"is_system" json:"is_system"`
+
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+
+	db DbContract
+}
+
+func permissionColumns(alias string) string {
+	return prefixColumns(alias, "id", "name", "method", "route", "description", "display_name", "metadata", "is_system", "created_at", "updated_at")
+}
 
-	db dbContract
+func (p *Permission) scanFields() []interface{} {
+	return []interface{}{
+		&p.ID,
+		&p.Name,
+		&p.Method,
+		&p.Route,
+		textColumn{&p.Description},
+		textColumn{&p.DisplayName},
+		jsonColumn{&p.Metadata},
+		&p.IsSystem,
+		timestamp{&p.CreatedAt},
+		timestamp{&p.UpdatedAt},
+	}
 }
 
 func (p *Permission) CreatePermission() error {
 	if p.db == nil {
 		p.db = dbConnection
 	}
+	if err := runPermissionHooks(context.Background(), BeforeCreate, p); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if p.ID == "" {
+		p.ID = newPrimaryKey()
+	}
+	p.CreatedAt = clock.Now()
+	p.UpdatedAt = p.CreatedAt
+	metadata, err := jsonValue(p.Metadata)
+	if err != nil {
+		return err
+	}
 	insertQuery := `INSERT INTO rbac_permission (
+		id,
 		name, 
 		method,
 		route,
-		description) VALUES (?,?,?,?)`
+		description,
+		display_name,
+		metadata,
+		is_system,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?,?,?,?)`
 	result, err := p.db.Exec(
 		insertQuery,
+		primaryKeyValue(p.ID),
 		p.Name,
 		p.Method,
 		p.Route,
 		p.Description,
+		p.DisplayName,
+		metadata,
+		p.IsSystem,
+		p.CreatedAt,
+		p.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	p.ID, _ = result.LastInsertId()
-	return nil
+	p.ID, _ = insertedID(p.ID, result)
+	if err = dropAlias(context.Background(), p.db, aliasPermission, p.Name); err != nil {
+		return err
+	}
+	return runPermissionHooks(context.Background(), AfterCreate, p)
 }
 
 func (p *Permission) CreatePermissionWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if p.db == nil {
 		p.db = dbConnection
 	}
+	if err := runPermissionHooks(ctx, BeforeCreate, p); err != nil {
+		return err
+	}
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if p.ID == "" {
+		p.ID = newPrimaryKey()
+	}
+	p.CreatedAt = clock.Now()
+	p.UpdatedAt = p.CreatedAt
+	metadata, err := jsonValue(p.Metadata)
+	if err != nil {
+		return err
+	}
 	insertQuery := `INSERT INTO rbac_permission (
+		id,
 		name, 
 		method,
 		route,
-		description) VALUES (?,?,?,?)`
+		description,
+		display_name,
+		metadata,
+		is_system,
+		created_at,
+		updated_at) VALUES (?,?,?,?,?,?,?,?,?,?)`
 	result, err := p.db.ExecContext(
 		ctx,
 		insertQuery,
+		primaryKeyValue(p.ID),
 		p.Name,
 		p.Method,
 		p.Route,
 		p.Description,
+		p.DisplayName,
+		metadata,
+		p.IsSystem,
+		p.CreatedAt,
+		p.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	p.ID, _ = result.LastInsertId()
-	return nil
+	p.ID, _ = insertedID(p.ID, result)
+	if err = dropAlias(ctx, p.db, aliasPermission, p.Name); err != nil {
+		return err
+	}
+	return runPermissionHooks(ctx, AfterCreate, p)
 }
 
 func (p *Permission) DeletePermission() error {
 	if p.db == nil {
 		p.db = dbConnection
 	}
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
+	if err := runPermissionHooks(context.Background(), BeforeDelete, p); err != nil {
+		return err
+	}
+	if err := checkSystemEntity(context.Background(), p.db, "rbac_permission", p.ID); err != nil {
+		return err
+	}
 	deleteQuery := `DELETE FROM rbac_permission WHERE id = ?`
 	_, err := p.db.Exec(
 		deleteQuery,
@@ -1073,16 +1523,26 @@ func (p *Permission) DeletePermission() error {
 	if err != nil {
 		return err
 	}
-	return nil
+	purgePermissionCache()
+	return runPermissionHooks(context.Background(), AfterDelete, p)
 }
 
 func (p *Permission) DeletePermissionWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if p.db == nil {
 		p.db = dbConnection
 	}
-	if p.ID <= 0 {
+	if p.ID == "" {
 		return ErrInvalidPermissionID
 	}
+	if err := runPermissionHooks(ctx, BeforeDelete, p); err != nil {
+		return err
+	}
+	if err := checkSystemEntity(ctx, p.db, "rbac_permission", p.ID); err != nil {
+		return err
+	}
 	deleteQuery := `DELETE FROM rbac_permission WHERE id = ?`
 	_, err := p.db.ExecContext(
 		ctx,
@@ -1092,31 +1552,66 @@ func (p *Permission) DeletePermissionWithContext(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	purgePermissionCache()
+	return runPermissionHooks(ctx, AfterDelete, p)
+}
+
+// UpdateDisplay changes the human-friendly label and metadata, the name used in checks is left untouched
+func (p *Permission) UpdateDisplay(displayName string, metadata map[string]interface{}) error {
+	return p.UpdateDisplayWithContext(context.Background(), displayName, metadata)
+}
+
+func (p *Permission) UpdateDisplayWithContext(ctx context.Context, displayName string, metadata map[string]interface{}) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if p.db == nil {
+		p.db = dbConnection
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+	rawMetadata, err := jsonValue(metadata)
+	if err != nil {
+		return err
+	}
+
+	now := clock.Now()
+	updateQuery := `UPDATE rbac_permission SET display_name = ?, metadata = ?, updated_at = ? WHERE id = ?`
+	_, err = p.db.ExecContext(
+		ctx,
+		updateQuery,
+		displayName,
+		rawMetadata,
+		now,
+		p.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	p.DisplayName = displayName
+	p.Metadata = metadata
+	p.UpdatedAt = now
 	return nil
 }
 
 func GetPermission(name string, ptx *PagerTx) (*Permission, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var permission = new(Permission)
-	getQuery := `SELECT
-		id,
-		name,
-		method,
-		route,
-		description
-	FROM rbac_permission WHERE name = ?`
+	getQuery := `SELECT ` + permissionColumns("") + ` FROM rbac_permission WHERE name = ?`
 
-	result := db.QueryRow(getQuery, name)
-	err := result.Scan(&permission.ID, &permission.Name, &permission.Method, &permission.Route, &permission.Description)
+	result := queryRow(context.Background(), db, getQuery, name)
+	err := result.Scan(permission.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -1127,27 +1622,24 @@ func GetPermission(name string, ptx *PagerTx) (*Permission, error) {
 }
 
 func GetPermissionWithContext(ctx context.Context, name string, ptx *PagerTx) (*Permission, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var permission = new(Permission)
-	getQuery := `SELECT
-		id,
-		name,
-		method,
-		route,
-		description
-	FROM rbac_permission WHERE name = ?`
+	getQuery := `SELECT ` + permissionColumns("") + ` FROM rbac_permission WHERE name = ?`
 
-	result := db.QueryRowContext(ctx, getQuery, name)
-	err := result.Scan(&permission.ID, &permission.Name, &permission.Method, &permission.Route, &permission.Description)
+	result := queryRow(ctx, db, getQuery, name)
+	err := result.Scan(permission.scanFields()...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -1157,50 +1649,110 @@ func GetPermissionWithContext(ctx context.Context, name string, ptx *PagerTx) (*
 	return permission, nil
 }
 
+// FindPermissions returns a page of the permissions ordered by name
+func FindPermissions(ctx context.Context, page, size int64, ptx *PagerTx) ([]Permission, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+	getQuery := `SELECT ` + permissionColumns("") + ` FROM rbac_permission ORDER BY name LIMIT ? OFFSET ?`
+	result, err := db.QueryContext(ctx, getQuery, size, pageOffset(page, size))
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	permissions := make([]Permission, 0)
+	for result.Next() {
+		var permission Permission
+		if err = result.Scan(permission.scanFields()...); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, result.Err()
+}
+
 // Group Repository
 type Group struct {
-	ID   int64  `db:"id"`
+	ID   string `db:"id"`
 	Name string `db:"name"`
 
-	db dbContract
+	CreatedAt time.Time `db:"created_at"`
+	UpdatedAt time.Time `db:"updated_at"`
+
+	db DbContract
 }
 
 func (g *Group) CreateGroup() error {
 	if g.db == nil {
 		g.db = dbConnection
 	}
+	if g.ID == "" {
+		g.ID = newPrimaryKey()
+	}
+	g.CreatedAt = clock.Now()
+	g.UpdatedAt = g.CreatedAt
 	insertQuery := `INSERT INTO rbac_group (
-		name
-	) VALUES (?)`
+		id,
+		name,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	result, err := g.db.Exec(
 		insertQuery,
+		primaryKeyValue(g.ID),
 		g.Name,
+		g.CreatedAt,
+		g.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	g.ID, _ = result.LastInsertId()
+	g.ID, _ = insertedID(g.ID, result)
 	return nil
 }
 
 func (g *Group) CreateGroupWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if g.db == nil {
 		g.db = dbConnection
 	}
+	if g.ID == "" {
+		g.ID = newPrimaryKey()
+	}
+	g.CreatedAt = clock.Now()
+	g.UpdatedAt = g.CreatedAt
 	insertQuery := `INSERT INTO rbac_group (
-		name
-	) VALUES (?)`
+		id,
+		name,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
 	result, err := g.db.ExecContext(
 		ctx,
 		insertQuery,
+		primaryKeyValue(g.ID),
 		g.Name,
+		g.CreatedAt,
+		g.UpdatedAt,
 	)
 	if err != nil {
 		return err
 	}
 
-	g.ID, _ = result.LastInsertId()
+	g.ID, _ = insertedID(g.ID, result)
 	return nil
 }
 
@@ -1208,8 +1760,8 @@ func (g *Group) DeleteGroup() error {
 	if g.db == nil {
 		g.db = dbConnection
 	}
-	if g.ID <= 0 {
-		return ErrInvalidPermissionID
+	if g.ID == "" {
+		return ErrInvalidGroupID
 	}
 	deleteQuery := `DELETE FROM rbac_group WHERE id = ?`
 	_, err := g.db.Exec(
@@ -1219,15 +1771,19 @@ func (g *Group) DeleteGroup() error {
 	if err != nil {
 		return err
 	}
+	purgePermissionCache()
 	return nil
 }
 
 func (g *Group) DeleteGroupWithContext(ctx context.Context) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	if g.db == nil {
 		g.db = dbConnection
 	}
-	if g.ID <= 0 {
-		return ErrInvalidPermissionID
+	if g.ID == "" {
+		return ErrInvalidGroupID
 	}
 	deleteQuery := `DELETE FROM rbac_group WHERE id = ?`
 	_, err := g.db.ExecContext(
@@ -1238,6 +1794,7 @@ func (g *Group) DeleteGroupWithContext(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
+	purgePermissionCache()
 	return nil
 }
 
@@ -1253,12 +1810,7 @@ func (g *Group) GetUsers(page, size int64) ([]User, error) {
 		offset = page * size
 	}
 
-	getQuery := `SELECT 
-		u.id, 
-		u.email, 
-		u.username, 
-		u.password, 
-		u.active 
+	getQuery := `SELECT ` + userColumns("u") + `
 	FROM rbac_user_group g 
 	JOIN rbac_user u ON g.user_id = u.id 
 	WHERE g.group_id = ? 
@@ -1267,13 +1819,7 @@ func (g *Group) GetUsers(page, size int64) ([]User, error) {
 	result, err := g.db.Query(getQuery, g.ID, size, offset)
 
 	for result.Next() {
-		err = result.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Username,
-			&user.Password,
-			&user.Active,
-		)
+		err = result.Scan(user.scanFields()...)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
@@ -1288,6 +1834,9 @@ func (g *Group) GetUsers(page, size int64) ([]User, error) {
 }
 
 func (g *Group) GetUsersWithContext(ctx context.Context, page, size int64) ([]User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
 	var user User
 	var err error
 	users := make([]User, 0)
@@ -1299,12 +1848,7 @@ func (g *Group) GetUsersWithContext(ctx context.Context, page, size int64) ([]Us
 		offset = page * size
 	}
 
-	getQuery := `SELECT 
-		u.id, 
-		u.email, 
-		u.username, 
-		u.password, 
-		u.active 
+	getQuery := `SELECT ` + userColumns("u") + `
 	FROM rbac_user_group g 
 	JOIN rbac_user u ON g.user_id = u.id 
 	WHERE g.group_id = ? 
@@ -1313,13 +1857,7 @@ func (g *Group) GetUsersWithContext(ctx context.Context, page, size int64) ([]Us
 	result, err := g.db.QueryContext(ctx, getQuery, g.ID, size, offset)
 
 	for result.Next() {
-		err = result.Scan(
-			&user.ID,
-			&user.Email,
-			&user.Username,
-			&user.Password,
-			&user.Active,
-		)
+		err = result.Scan(user.scanFields()...)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return nil, nil
@@ -1334,24 +1872,26 @@ func (g *Group) GetUsersWithContext(ctx context.Context, page, size int64) ([]Us
 }
 
 func GetGroup(name string, ptx *PagerTx) (*Group, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var group = new(Group)
 	getQuery := `SELECT
 		id,
-		name
+		name,
+		created_at,
+		updated_at
 	FROM rbac_group WHERE name = ?`
 
-	result := db.QueryRow(getQuery, name)
-	err := result.Scan(&group.ID, &group.Name)
+	result := queryRow(context.Background(), db, getQuery, name)
+	err := result.Scan(&group.ID, &group.Name, timestamp{&group.CreatedAt}, timestamp{&group.UpdatedAt})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -1362,24 +1902,29 @@ func GetGroup(name string, ptx *PagerTx) (*Group, error) {
 }
 
 func GetGroupWithContext(ctx context.Context, name string, ptx *PagerTx) (*Group, error) {
-	var db dbContract
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return nil, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 
 	var group = new(Group)
 	getQuery := `SELECT
 		id,
-		name
+		name,
+		created_at,
+		updated_at
 	FROM rbac_group WHERE name = ?`
 
-	result := db.QueryRowContext(ctx, getQuery, name)
-	err := result.Scan(&group.ID, &group.Name)
+	result := queryRow(ctx, db, getQuery, name)
+	err := result.Scan(&group.ID, &group.Name, timestamp{&group.CreatedAt}, timestamp{&group.UpdatedAt})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -1389,22 +1934,39 @@ func GetGroupWithContext(ctx context.Context, name string, ptx *PagerTx) (*Group
 	return group, nil
 }
 
+// checkSystemEntity returns ErrSystemEntity when the row of table is flagged with is_system
+func checkSystemEntity(ctx context.Context, db DbContract, table, id string) error {
+	var isSystem bool
+	result := queryRow(ctx, db, fmt.Sprintf("SELECT is_system FROM %s WHERE id = ?", table), id)
+	err := result.Scan(&isSystem)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	if isSystem {
+		return ErrSystemEntity
+	}
+	return nil
+}
+
 // Migration Repository
 func checkExistMigration(ptx *PagerTx, migrationType string) (bool, error) {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return false, ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
 	rawResult := struct {
 		MigrationKey string `db:"migration_key"`
 	}{}
 	selectQuery := `SELECT migration_key FROM rbac_migration WHERE migration_key = ? LIMIT 1`
-	result := db.QueryRow(selectQuery, migrationType)
+	result := queryRow(context.Background(), db, selectQuery, migrationType)
 	err := result.Scan(&rawResult.MigrationKey)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1416,19 +1978,22 @@ func checkExistMigration(ptx *PagerTx, migrationType string) (bool, error) {
 }
 
 func insertMigration(ptx *PagerTx, migrationType string) error {
-	var db dbContract
+	var db DbContract
 	if ptx == nil {
 		db = dbConnection
 	} else {
 		if ptx.dbTx == nil {
 			return ErrTxWithNoBegin
 		}
-		db = ptx.dbTx
+		db = ptx.db
 	}
-	insertQuery := `INSERT INTO rbac_migration(migration_key) VALUES (?)`
+	now := clock.Now()
+	insertQuery := `INSERT INTO rbac_migration(migration_key, created_at, updated_at) VALUES (?,?,?)`
 	_, err := db.Exec(
 		insertQuery,
 		migrationType,
+		now,
+		now,
 	)
 	return err
 }
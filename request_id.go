@@ -0,0 +1,57 @@
+package pager
+
+import (
+	"context"
+	"log"
+	"net/http"
+)
+
+type requestIDKey struct{}
+
+// RequestIDExtractor returns the correlation id of the request, empty when there's none
+type RequestIDExtractor func(r *http.Request) string
+
+// RequestIDHeader reads the correlation id from the header name, e.g. X-Request-ID
+func RequestIDHeader(name string) RequestIDExtractor {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// WithRequestID stores the correlation id into ctx, for the calls made outside of the pager middleware
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// requestContext carries the client address and the correlation id of r into the request context
+func (a *Auth) requestContext(r *http.Request) context.Context {
+	ctx := withClientIP(r.Context(), a.ClientIP(r))
+	if a.requestID != nil {
+		ctx = WithRequestID(ctx, a.requestID(r))
+	}
+	return ctx
+}
+
+// logf prefixes the log line with the correlation id of ctx
+func logf(ctx context.Context, format string, args ...interface{}) {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		log.Printf("request_id=%s "+format, append([]interface{}{requestID}, args...)...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func logError(ctx context.Context, err error) {
+	logf(ctx, "%s", err)
+}
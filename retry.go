@@ -0,0 +1,223 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Constants for MySQL error numbers
+const (
+	mysqlErrDuplicateEntry  = 1062
+	mysqlErrLockWaitTimeout = 1205
+	mysqlErrDeadlock        = 1213
+	mysqlErrServerGone      = 2006
+	mysqlErrServerLost      = 2013
+)
+
+// RetryPolicy retries the statements failing with transient errors. Reads are retried on any transient error,
+// writes only on deadlocks and lock wait timeouts, which guarantee the statement wasn't applied.
+// Statements running inside a transaction are never retried, since MySQL rolls back the whole transaction:
+// BuildPager leaves the policy out of the transactions, Options.TxRetry retries them as a whole.
+// QueryRow and QueryRowContext defer their error into Scan, the single-row entity lookups retry the whole
+// query and Scan instead, with the policy of Options.Retry
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+type retryDB struct {
+	next   DbContract
+	policy RetryPolicy
+}
+
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 20 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = time.Second
+	}
+	return p
+}
+
+// NewRetryMiddleware retries the statements of the connection with policy. The middlewares of
+// Options.DBMiddleware are also applied to the transactions, a transaction is left unwrapped when it's
+// found behind the middlewares implementing Unwrap, as the query logger does
+func NewRetryMiddleware(policy RetryPolicy) func(DbContract) DbContract {
+	policy = policy.withDefaults()
+	return func(next DbContract) DbContract {
+		if isTx(next) {
+			return next
+		}
+		return &retryDB{next: next, policy: policy}
+	}
+}
+
+// isTx reports whether db is a transaction, or wraps one behind middlewares implementing Unwrap
+func isTx(db DbContract) bool {
+	for {
+		if _, ok := db.(*sql.Tx); ok {
+			return true
+		}
+		wrapper, ok := db.(interface{ Unwrap() DbContract })
+		if !ok {
+			return false
+		}
+		db = wrapper.Unwrap()
+	}
+}
+
+// mysqlErrorNumber returns the number of the MySQL error wrapped by err, 0 when there is none
+func mysqlErrorNumber(err error) int {
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) {
+		return 0
+	}
+	return int(mysqlErr.Number)
+}
+
+// IsDeadlockError reports whether err wraps a MySQL deadlock (1213) or lock wait timeout (1205) error
+func IsDeadlockError(err error) bool {
+	if err == nil {
+		return false
+	}
+	number := mysqlErrorNumber(err)
+	return number == mysqlErrDeadlock || number == mysqlErrLockWaitTimeout
+}
+
+func isDuplicateEntryError(err error) bool {
+	return err != nil && mysqlErrorNumber(err) == mysqlErrDuplicateEntry
+}
+
+// IsTransientError reports whether err is likely to succeed when retried
+func IsTransientError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if IsDeadlockError(err) || errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, mysql.ErrInvalidConn) {
+		return true
+	}
+	switch mysqlErrorNumber(err) {
+	case mysqlErrServerGone, mysqlErrServerLost:
+		return true
+	}
+	message := err.Error()
+	return strings.Contains(message, "connection reset by peer") ||
+		strings.Contains(message, "broken pipe") ||
+		strings.Contains(message, "invalid connection")
+}
+
+func (r *retryDB) do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	return retryWithBackoff(ctx, r.policy, retryable, fn)
+}
+
+// retryWithBackoff runs fn until it succeeds, fails with a non retryable error or runs out of attempts.
+// The backoff doubles on each attempt and is jittered, so concurrent callers don't retry in lockstep
+func retryWithBackoff(ctx context.Context, policy RetryPolicy, retryable func(error) bool, fn func() error) error {
+	backoff := policy.InitialBackoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= policy.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		jittered := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		timer := time.NewTimer(jittered)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+	}
+}
+
+// Unwrap returns the wrapped DbContract
+func (r *retryDB) Unwrap() DbContract {
+	return r.next
+}
+
+// queryRow is QueryRowContext retrying the transient errors of Scan with Options.Retry, unless db runs a transaction
+func queryRow(ctx context.Context, db DbContract, query string, args ...interface{}) *retryRow {
+	return &retryRow{ctx: ctx, db: db, query: query, args: args}
+}
+
+type retryRow struct {
+	ctx   context.Context
+	db    DbContract
+	query string
+	args  []interface{}
+}
+
+func (r *retryRow) Scan(dest ...interface{}) error {
+	scan := func() error {
+		return r.db.QueryRowContext(r.ctx, r.query, r.args...).Scan(dest...)
+	}
+	policy := loadSettings().retryPolicy
+	if policy == nil || r.db != dbConnection {
+		return scan()
+	}
+	return retryWithBackoff(r.ctx, *policy, IsTransientError, scan)
+}
+
+func (r *retryDB) Prepare(query string) (*sql.Stmt, error) {
+	return r.next.Prepare(query)
+}
+
+func (r *retryDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return r.next.PrepareContext(ctx, query)
+}
+
+func (r *retryDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+func (r *retryDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := r.do(ctx, IsTransientError, func() error {
+		var err error
+		rows, err = r.next.QueryContext(ctx, query, args...)
+		return err
+	})
+	return rows, err
+}
+
+func (r *retryDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return r.next.QueryRow(query, args...)
+}
+
+func (r *retryDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return r.next.QueryRowContext(ctx, query, args...)
+}
+
+func (r *retryDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.ExecContext(context.Background(), query, args...)
+}
+
+func (r *retryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := r.do(ctx, IsDeadlockError, func() error {
+		var err error
+		result, err = r.next.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
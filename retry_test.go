@@ -0,0 +1,141 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var errFakeDeadlock = &mysql.MySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}
+
+func TestIsDeadlockError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"deadlock", errFakeDeadlock, true},
+		{"lock wait timeout", &mysql.MySQLError{Number: 1205, Message: "Lock wait timeout exceeded"}, true},
+		{"wrapped deadlock", fmt.Errorf("assign role: %w", errFakeDeadlock), true},
+		{"duplicate entry", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry 'admin' for key 'name'"}, false},
+		{"deadlock message", errors.New("Error 1213: Deadlock found when trying to get lock"), false},
+		{"nil", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := IsDeadlockError(test.err); got != test.want {
+				t.Errorf("IsDeadlockError(%v) = %v, want %v", test.err, got, test.want)
+			}
+		})
+	}
+}
+
+func TestRetryMiddlewareLeftOutOfTransactions(t *testing.T) {
+	fake, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		return fakeResponse{err: errFakeDeadlock}
+	})
+	defer restore()
+
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	// the query logger comes first, as in BuildPager, so the retry middleware never wraps the *sql.Tx itself
+	setDatabaseConnection(sqlConnection,
+		dbWrapper{wrap: NewQueryLogger(QueryLogOptions{Logger: log.New(ioutil.Discard, "", 0)})},
+		dbWrapper{wrap: NewRetryMiddleware(policy), connectionOnly: true},
+	)
+	ctx := context.Background()
+
+	if _, err := dbConnection.ExecContext(ctx, "UPDATE connection_statement"); !IsDeadlockError(err) {
+		t.Fatalf("connection statement error = %v, want a deadlock", err)
+	}
+	if got := len(fake.executed("connection_statement")); got != policy.MaxAttempts {
+		t.Errorf("connection statement ran %d times, want %d", got, policy.MaxAttempts)
+	}
+
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err = wrapTx(tx).ExecContext(ctx, "UPDATE tx_statement"); !IsDeadlockError(err) {
+		t.Fatalf("transaction statement error = %v, want a deadlock", err)
+	}
+	statements := fake.executed("tx_statement")
+	if len(statements) != 1 {
+		t.Fatalf("transaction statement ran %d times, want 1", len(statements))
+	}
+	if !statements[0].inTx {
+		t.Error("transaction statement ran outside of the transaction")
+	}
+}
+
+func TestRunInTxRetriesWholeTransaction(t *testing.T) {
+	_, restore := openFakeDB(t, nil)
+	defer restore()
+	setTxRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	defer setTxRetryPolicy(nil)
+
+	attempts := 0
+	err := runInTx(context.Background(), func(tx *PagerTx) error {
+		attempts++
+		if attempts < 3 {
+			return errFakeDeadlock
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("runInTx() = %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("transaction ran %d times, want 3", attempts)
+	}
+}
+
+func TestRetryMiddlewareSkipsWrappedTransactions(t *testing.T) {
+	_, restore := openFakeDB(t, nil)
+	defer restore()
+
+	tx, err := sqlConnection.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	logged := NewQueryLogger(QueryLogOptions{Logger: log.New(ioutil.Discard, "", 0)})(tx)
+	if wrapped := NewRetryMiddleware(RetryPolicy{})(logged); wrapped != logged {
+		t.Errorf("NewRetryMiddleware() = %T, want the transaction behind the query logger left unwrapped", wrapped)
+	}
+}
+
+func TestQueryRowRetriesTransientErrors(t *testing.T) {
+	attempts := 0
+	fake, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		attempts++
+		if attempts < 3 {
+			return fakeResponse{err: errFakeDeadlock}
+		}
+		return fakeRowsOf([]string{"name"}, "admin")
+	})
+	defer restore()
+	previous := loadSettings()
+	defer installSettings(previous)
+	updateSettings(func(s *settings) {
+		s.retryPolicy = &RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	})
+
+	var name string
+	if err := queryRow(context.Background(), dbConnection, "SELECT name FROM rbac_role WHERE id = ?", "1").Scan(&name); err != nil {
+		t.Fatalf("Scan() = %v", err)
+	}
+	if name != "admin" {
+		t.Errorf("name = %q, want %q", name, "admin")
+	}
+	if got := len(fake.executed("SELECT name FROM rbac_role")); got != 3 {
+		t.Errorf("query ran %d times, want 3", got)
+	}
+}
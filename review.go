@@ -0,0 +1,435 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Constants for review campaign status and review decisions
+const (
+	ReviewCampaignOpen   = "open"
+	ReviewCampaignClosed = "closed"
+
+	ReviewPending  = "pending"
+	ReviewApproved = "approved"
+	ReviewRevoked  = "revoked"
+)
+
+// Constants for review audit actions
+const (
+	AuditReviewOpened = "review.opened"
+	AuditReviewClosed = "review.closed"
+)
+
+var (
+	ErrInvalidCampaignID  = errors.New("invalid review campaign id")
+	ErrCampaignClosed     = errors.New("review campaign is already closed")
+	ErrReviewItemNotFound = errors.New("review item not found in the campaign")
+	ErrInvalidDecision    = errors.New("invalid review decision")
+)
+
+// ReviewCampaign is an access recertification: a snapshot of every user-role assignment
+// that reviewers approve or revoke, the revocations are applied when the campaign is closed
+type ReviewCampaign struct {
+	ID        string    `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Status    string    `db:"status" json:"status"`
+	CreatedBy string    `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ClosedAt  time.Time `db:"closed_at" json:"closed_at,omitempty"`
+
+	db DbContract
+}
+
+type ReviewItem struct {
+	ID         string    `db:"id" json:"id"`
+	CampaignID string    `db:"campaign_id" json:"campaign_id"`
+	UserID     string    `db:"user_id" json:"user_id"`
+	Username   string    `db:"username" json:"username"`
+	RoleID     string    `db:"role_id" json:"role_id"`
+	RoleName   string    `db:"role_name" json:"role_name"`
+	Decision   string    `db:"decision" json:"decision"`
+	ReviewerID string    `db:"reviewer_id" json:"reviewer_id,omitempty"`
+	Comment    string    `db:"comment" json:"comment,omitempty"`
+	DecidedAt  time.Time `db:"decided_at" json:"decided_at,omitempty"`
+}
+
+type ReviewProgress struct {
+	Total    int64 `json:"total"`
+	Pending  int64 `json:"pending"`
+	Approved int64 `json:"approved"`
+	Revoked  int64 `json:"revoked"`
+}
+
+func (p *ReviewProgress) Completed() bool {
+	return p.Pending == 0
+}
+
+func (ptx *PagerTx) ReviewCampaign(campaign *ReviewCampaign) *ReviewCampaign {
+	campaign.db = ptx.db
+	return campaign
+}
+
+// OpenReviewCampaign creates a campaign with a pending review item for every active user-role assignment
+func OpenReviewCampaign(ctx context.Context, name string, ptx *PagerTx) (*ReviewCampaign, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	campaign := &ReviewCampaign{
+		ID:        newPrimaryKey(),
+		Name:      name,
+		Status:    ReviewCampaignOpen,
+		CreatedBy: actorFromContext(ctx),
+		CreatedAt: clock.Now(),
+		db:        db,
+	}
+	insertQuery := `INSERT INTO rbac_review_campaign (
+		id,
+		name,
+		status,
+		created_by,
+		created_at) VALUES (?,?,?,?,?)`
+	result, err := db.ExecContext(
+		ctx,
+		insertQuery,
+		primaryKeyValue(campaign.ID),
+		campaign.Name,
+		campaign.Status,
+		primaryKeyValue(campaign.CreatedBy),
+		campaign.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	campaign.ID, err = insertedID(campaign.ID, result)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotQuery := `INSERT INTO rbac_review_item (
+		campaign_id,
+		user_id,
+		role_id,
+		decision,
+		created_at
+	) SELECT ?, ur.user_id, ur.role_id, ?, ?
+	FROM rbac_user_role ur
+	WHERE ur.expires_at IS NULL OR ur.expires_at > ?`
+	_, err = db.ExecContext(
+		ctx,
+		snapshotQuery,
+		campaign.ID,
+		ReviewPending,
+		campaign.CreatedAt,
+		campaign.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeAudit(ctx, db, &AuditEntry{
+		ActorID: campaign.CreatedBy,
+		Action:  AuditReviewOpened,
+		Target:  campaign.ID,
+		Reason:  campaign.Name,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+func FindReviewCampaign(ctx context.Context, id string, ptx *PagerTx) (*ReviewCampaign, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	campaign := &ReviewCampaign{db: db}
+	getQuery := `SELECT
+		id,
+		name,
+		status,
+		created_by,
+		created_at,
+		closed_at
+	FROM rbac_review_campaign WHERE id = ?`
+	err := queryRow(ctx, db, getQuery, id).Scan(
+		&campaign.ID,
+		&campaign.Name,
+		&campaign.Status,
+		textColumn{&campaign.CreatedBy},
+		timestamp{&campaign.CreatedAt},
+		timestamp{&campaign.ClosedAt},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// Approve keeps the assignment of the review item, the reviewer is the authenticated user in ctx
+func (c *ReviewCampaign) Approve(ctx context.Context, itemID, comment string) error {
+	return c.Decide(ctx, itemID, ReviewApproved, comment)
+}
+
+// Revoke marks the assignment of the review item to be removed when the campaign is closed
+func (c *ReviewCampaign) Revoke(ctx context.Context, itemID, comment string) error {
+	return c.Decide(ctx, itemID, ReviewRevoked, comment)
+}
+
+// Decide records the decision of the review item, it can be changed until the campaign is closed
+func (c *ReviewCampaign) Decide(ctx context.Context, itemID, decision, comment string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if c.db == nil {
+		c.db = dbConnection
+	}
+	if c.ID == "" {
+		return ErrInvalidCampaignID
+	}
+	if decision != ReviewApproved && decision != ReviewRevoked {
+		return ErrInvalidDecision
+	}
+
+	updateQuery := `UPDATE rbac_review_item ri
+	JOIN rbac_review_campaign rc ON rc.id = ri.campaign_id
+	SET ri.decision = ?, ri.reviewer_id = ?, ri.comment = ?, ri.decided_at = ?
+	WHERE ri.id = ? AND ri.campaign_id = ? AND rc.status = ?`
+	result, err := c.db.ExecContext(
+		ctx,
+		updateQuery,
+		decision,
+		primaryKeyValue(actorFromContext(ctx)),
+		comment,
+		clock.Now(),
+		itemID,
+		c.ID,
+		ReviewCampaignOpen,
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	err = c.refreshStatus(ctx)
+	if err != nil {
+		return err
+	}
+	if c.Status == ReviewCampaignClosed {
+		return ErrCampaignClosed
+	}
+	return ErrReviewItemNotFound
+}
+
+// Items lists the review items of the campaign, only the undecided ones when pendingOnly is set
+func (c *ReviewCampaign) Items(ctx context.Context, pendingOnly bool) ([]ReviewItem, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if c.db == nil {
+		c.db = dbConnection
+	}
+	if c.ID == "" {
+		return nil, ErrInvalidCampaignID
+	}
+
+	getQuery := `SELECT
+		ri.id,
+		ri.campaign_id,
+		ri.user_id,
+		u.username,
+		ri.role_id,
+		r.name,
+		ri.decision,
+		ri.reviewer_id,
+		ri.comment,
+		ri.decided_at
+	FROM rbac_review_item ri
+	JOIN rbac_user u ON u.id = ri.user_id
+	JOIN rbac_role r ON r.id = ri.role_id
+	WHERE ri.campaign_id = ?`
+	args := []interface{}{c.ID}
+	if pendingOnly {
+		getQuery += ` AND ri.decision = ?`
+		args = append(args, ReviewPending)
+	}
+	getQuery += ` ORDER BY ri.id`
+
+	result, err := c.db.QueryContext(ctx, getQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	items := make([]ReviewItem, 0)
+	for result.Next() {
+		var item ReviewItem
+		err = result.Scan(
+			&item.ID,
+			&item.CampaignID,
+			&item.UserID,
+			&item.Username,
+			&item.RoleID,
+			&item.RoleName,
+			&item.Decision,
+			textColumn{&item.ReviewerID},
+			textColumn{&item.Comment},
+			timestamp{&item.DecidedAt},
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, result.Err()
+}
+
+// Progress counts the review items per decision
+func (c *ReviewCampaign) Progress(ctx context.Context) (*ReviewProgress, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if c.db == nil {
+		c.db = dbConnection
+	}
+	if c.ID == "" {
+		return nil, ErrInvalidCampaignID
+	}
+
+	getQuery := `SELECT decision, COUNT(1) FROM rbac_review_item WHERE campaign_id = ? GROUP BY decision`
+	result, err := c.db.QueryContext(ctx, getQuery, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	progress := &ReviewProgress{}
+	for result.Next() {
+		var decision string
+		var count int64
+		err = result.Scan(&decision, &count)
+		if err != nil {
+			return nil, err
+		}
+		switch decision {
+		case ReviewPending:
+			progress.Pending = count
+		case ReviewApproved:
+			progress.Approved = count
+		case ReviewRevoked:
+			progress.Revoked = count
+		}
+		progress.Total += count
+	}
+	return progress, result.Err()
+}
+
+// Close removes the revoked assignments and closes the campaign, pending items keep their assignment.
+// Unless the campaign runs inside a PagerTx, everything is applied in its own transaction
+func (c *ReviewCampaign) Close(ctx context.Context) (int64, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if c.db == nil {
+		c.db = dbConnection
+	}
+	if c.ID == "" {
+		return 0, ErrInvalidCampaignID
+	}
+	if c.db != dbConnection {
+		return c.close(ctx, c.db)
+	}
+
+	tx, err := sqlConnection.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := c.close(ctx, wrapTx(tx))
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	return revoked, tx.Commit()
+}
+
+func (c *ReviewCampaign) close(ctx context.Context, db DbContract) (int64, error) {
+	closedAt := clock.Now()
+	updateQuery := `UPDATE rbac_review_campaign SET status = ?, closed_at = ? WHERE id = ? AND status = ?`
+	result, err := db.ExecContext(ctx, updateQuery, ReviewCampaignClosed, closedAt, c.ID, ReviewCampaignOpen)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if affected == 0 {
+		return 0, ErrCampaignClosed
+	}
+
+	deleteQuery := `DELETE ur FROM rbac_user_role ur
+	JOIN rbac_review_item ri ON ri.user_id = ur.user_id AND ri.role_id = ur.role_id
+	WHERE ri.campaign_id = ? AND ri.decision = ?`
+	result, err = db.ExecContext(ctx, deleteQuery, c.ID, ReviewRevoked)
+	if err != nil {
+		return 0, err
+	}
+	revoked, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	err = writeAudit(ctx, db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditReviewClosed,
+		Target:   c.ID,
+		Reason:   c.Name,
+		Metadata: map[string]string{"revoked": strconv.FormatInt(revoked, 10)},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	c.Status = ReviewCampaignClosed
+	c.ClosedAt = closedAt
+	purgePermissionCache()
+	return revoked, nil
+}
+
+func (c *ReviewCampaign) refreshStatus(ctx context.Context) error {
+	getQuery := `SELECT status FROM rbac_review_campaign WHERE id = ?`
+	err := queryRow(ctx, c.db, getQuery, c.ID).Scan(&c.Status)
+	if err == sql.ErrNoRows {
+		return ErrInvalidCampaignID
+	}
+	return err
+}
@@ -0,0 +1,56 @@
+package pager
+
+import (
+	"context"
+	"time"
+)
+
+// Constants for the audit actions of the role mutations
+const (
+	AuditRoleAssigned          = "role.assigned"
+	AuditRoleRevoked           = "role.revoked"
+	AuditRolePermissionAdded   = "role_permission.added"
+	AuditRolePermissionRemoved = "role_permission.removed"
+)
+
+type actorKey struct{}
+
+// WithActor attributes the mutations made with ctx to actorID, it takes precedence over the authenticated user.
+// Use it for the jobs and the admin tools running outside of the pager middleware
+func WithActor(ctx context.Context, actorID string) context.Context {
+	if actorID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// auditRoleAssignment records who granted or revoked role to user
+func auditRoleAssignment(ctx context.Context, db DbContract, action string, role *Role, user *User, expiresAt *time.Time) error {
+	metadata := map[string]string{
+		"role_id": role.ID,
+		"role":    role.Name,
+	}
+	if expiresAt != nil {
+		metadata["expires_at"] = expiresAt.UTC().Format(time.RFC3339)
+	}
+	return writeAudit(ctx, db, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   action,
+		Target:   user.ID,
+		Metadata: metadata,
+	})
+}
+
+// auditRolePermission records who added or removed permission from role
+func auditRolePermission(ctx context.Context, db DbContract, action string, role *Role, permission *Permission) error {
+	return writeAudit(ctx, db, &AuditEntry{
+		ActorID: actorFromContext(ctx),
+		Action:  action,
+		Target:  role.ID,
+		Metadata: map[string]string{
+			"role":          role.Name,
+			"permission_id": permission.ID,
+			"permission":    permission.Name,
+		},
+	})
+}
@@ -0,0 +1,103 @@
+package pager
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/dhanarJkusuma/pager/v2/matcher"
+)
+
+// routePatternCondition selects the permissions of the exact route and every route pattern,
+// the patterns are matched by MatchRoute afterwards
+const routePatternCondition = `p.route = ? OR p.route LIKE '%*%' OR p.route LIKE '%/:%'`
+
+// compiledRoutes caches the matchers of the permission routes, keyed by route
+var compiledRoutes sync.Map
+
+func isRoutePattern(route string) bool {
+	return matcher.IsPattern(route)
+}
+
+// MatchRoute reports whether path matches the permission route, see the matcher package for the pattern syntax
+func MatchRoute(route, path string) bool {
+	if route == path {
+		return true
+	}
+	if !isRoutePattern(route) {
+		return false
+	}
+	if m, ok := compiledRoutes.Load(route); ok {
+		return m.(*matcher.Matcher).Match(path)
+	}
+	m, err := matcher.Compile(route)
+	if err != nil {
+		return false
+	}
+	compiledRoutes.Store(route, m)
+	return m.Match(path)
+}
+
+func (p *effectivePermissions) canAccess(method, path string) bool {
+	method = strings.ToUpper(method)
+	if p.routes[routeKey(method, path)] {
+		return true
+	}
+	for _, route := range p.patterns[method] {
+		if MatchRoute(route, path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *effectivePermissions) addRoute(name, method, route string) {
+	method = strings.ToUpper(method)
+	p.names[name] = true
+	p.namedRoutes[name] = append(p.namedRoutes[name], routeKey(method, route))
+	if isRoutePattern(route) {
+		p.patterns[method] = append(p.patterns[method], route)
+		return
+	}
+	p.routes[routeKey(method, route)] = true
+}
+
+// restrict keeps the permissions named in scopes, with their routes
+func (p *effectivePermissions) restrict(scopes []string) *effectivePermissions {
+	restricted := newEffectivePermissions(p.expiresAt)
+	for _, name := range scopes {
+		if !p.names[name] {
+			continue
+		}
+		restricted.names[name] = true
+		for _, key := range p.namedRoutes[name] {
+			route := strings.SplitN(key, " ", 2)
+			restricted.addRoute(name, route[0], route[1])
+		}
+	}
+	return restricted
+}
+
+// grantedRoute reports whether u holds a permission whose route matches path
+func (u *User) grantedRoute(ctx context.Context, method, path string) (bool, error) {
+	getQuery := `SELECT p.route
+	FROM ` + grantedPermissionIDs + `
+	JOIN ` + permissionRoutes + ` ON p.id = granted.permission_id
+	WHERE p.method = ? AND (` + routePatternCondition + `)`
+
+	result, err := u.db.QueryContext(ctx, getQuery, append(grantedPermissionArgs(u.ID), method, path)...)
+	if err != nil {
+		return false, err
+	}
+	defer result.Close()
+	for result.Next() {
+		var route string
+		if err = result.Scan(&route); err != nil {
+			return false, err
+		}
+		if MatchRoute(route, path) {
+			return true, nil
+		}
+	}
+	return false, result.Err()
+}
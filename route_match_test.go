@@ -0,0 +1,111 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dhanarJkusuma/pager/v2/matcher"
+)
+
+// routeMatchTests are the permission routes checked by the matcher package, MatchRoute and ProtectWithRBAC
+var routeMatchTests = []struct {
+	route string
+	path  string
+	want  bool
+}{
+	{"/users", "/users", true},
+	{"/users", "/users/42", false},
+	{"/users/:id", "/users/42", true},
+	{"/users/:id", "/users", false},
+	{"/users/:id", "/users/42/posts", false},
+	{`/users/:id(\d+)`, "/users/42", true},
+	{`/users/:id(\d+)`, "/users/me", false},
+	{"/users/*/posts", "/users/42/posts", true},
+	{"/users/*/posts", "/users/42/43/posts", false},
+	{"/files/*", "/files/2024/report.pdf", true},
+	{"/files/*", "/files", false},
+	{"/users/:id(", "/users/42", false},
+}
+
+// routePermission answers the permission loads of a user holding GET route, the route query is filtered
+// like routePatternCondition does
+func routePermission(route string) fakeHandler {
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		if strings.Contains(query, "JOIN "+permissionRoutes+" ON p.id = granted.permission_id") {
+			path := args[len(args)-1].Value.(string)
+			if route != path && !strings.Contains(route, "*") && !strings.Contains(route, "/:") {
+				return fakeResponse{columns: []string{"route"}}
+			}
+			return fakeRowsOf([]string{"route"}, route)
+		}
+		if strings.Contains(query, "WHERE ur.user_id IN") {
+			return fakeRowsOf([]string{"user_id", "name", "method", "route", "expires_at"}, "1", "route.read", "GET", route, nil)
+		}
+		return fakeResponse{}
+	}
+}
+
+func TestRouteMatchingAgreesWithMatcher(t *testing.T) {
+	for _, test := range routeMatchTests {
+		if got := matcher.Match(test.route, test.path); got != test.want {
+			t.Errorf("matcher.Match(%q, %q) = %v, want %v", test.route, test.path, got, test.want)
+		}
+		if got := MatchRoute(test.route, test.path); got != test.want {
+			t.Errorf("MatchRoute(%q, %q) = %v, want %v", test.route, test.path, got, test.want)
+		}
+		permissions := newEffectivePermissions(time.Time{})
+		permissions.addRoute("route.read", "get", test.route)
+		if got := permissions.canAccess("GET", test.path); got != test.want {
+			t.Errorf("cached permission %q on %q = %v, want %v", test.route, test.path, got, test.want)
+		}
+	}
+}
+
+func TestProtectWithRBACAgreesWithMatcher(t *testing.T) {
+	auth := &Auth{}
+	handler := auth.ProtectWithRBAC(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, test := range routeMatchTests {
+		for _, prefetched := range []bool{false, true} {
+			_, restore := openFakeDB(t, routePermission(test.route))
+			principal := &Principal{UserID: "1", prefetched: prefetched}
+			r := httptest.NewRequest("GET", test.path, nil)
+			r = r.WithContext(context.WithValue(r.Context(), PrincipalKey, principal))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			restore()
+
+			if granted := w.Code == http.StatusOK; granted != test.want {
+				t.Errorf("ProtectWithRBAC(%q) on %q with prefetched %v = %d, want granted %v", test.route, test.path, prefetched, w.Code, test.want)
+			}
+		}
+	}
+}
+
+func TestRouteAccessWalksGroupGrants(t *testing.T) {
+	// the fake answers like a database where the route is only granted through a group role
+	_, restore := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResponse {
+		if !strings.Contains(query, "JOIN rbac_group_role gr ON gr.group_id = ug.group_id") {
+			return fakeResponse{}
+		}
+		if strings.Contains(query, "JOIN "+permissionRoutes+" ON p.id = granted.permission_id") {
+			return fakeRowsOf([]string{"route"}, "/reports/:id")
+		}
+		if strings.Contains(query, "WHERE ur.user_id IN") {
+			return fakeRowsOf([]string{"user_id", "name", "method", "route", "expires_at"}, "1", "reports.read", "GET", "/reports/:id", nil)
+		}
+		return fakeResponse{}
+	})
+	defer restore()
+
+	if !(&User{ID: "1"}).CanAccessWithContext(context.Background(), "GET", "/reports/7") {
+		t.Error("CanAccess() = false for a route granted through a group role")
+	}
+	if !(&Principal{UserID: "1"}).CanAccess("GET", "/reports/7") {
+		t.Error("Principal.CanAccess() = false for a route granted through a group role")
+	}
+}
@@ -0,0 +1,220 @@
+package pager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrUnsupportedSeedFormat = errors.New("unsupported seed file format, register a decoder with RegisterSeedDecoder")
+
+// Seed declares the roles, the permissions and their mapping provisioned by Migration.Seed
+type Seed struct {
+	Permissions []SeedPermission `json:"permissions" yaml:"permissions"`
+	Roles       []SeedRole       `json:"roles" yaml:"roles"`
+}
+
+type SeedPermission struct {
+	Name        string `json:"name" yaml:"name"`
+	Method      string `json:"method" yaml:"method"`
+	Route       string `json:"route" yaml:"route"`
+	Description string `json:"description" yaml:"description"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	System      bool   `json:"system" yaml:"system"`
+	// Routes are the alias routes of the permission, see Permission.AddRoute
+	Routes []string `json:"routes" yaml:"routes"`
+	// Tags are added to the permission, see Permission.AddTag
+	Tags []string `json:"tags" yaml:"tags"`
+}
+
+type SeedRole struct {
+	Name        string `json:"name" yaml:"name"`
+	Description string `json:"description" yaml:"description"`
+	DisplayName string `json:"display_name" yaml:"display_name"`
+	System      bool   `json:"system" yaml:"system"`
+	// Permissions are the names of the permissions granted by the role, declared in the seed or already stored
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// SeedDecoder unmarshals a seed file, e.g. json.Unmarshal or yaml.Unmarshal
+type SeedDecoder func(data []byte, v interface{}) error
+
+var seedDecoders = map[string]SeedDecoder{
+	".json": json.Unmarshal,
+}
+var mutexSeedLock = &sync.RWMutex{}
+
+// RegisterSeedDecoder decodes the seed files with extension, e.g. RegisterSeedDecoder(".yaml", yaml.Unmarshal).
+// JSON is supported out of the box
+func RegisterSeedDecoder(extension string, decoder SeedDecoder) {
+	mutexSeedLock.Lock()
+	seedDecoders[strings.ToLower(extension)] = decoder
+	mutexSeedLock.Unlock()
+}
+
+// Seed reads the seed file at path and upserts it, see ApplySeed
+func (m *Migration) Seed(path string) error {
+	mutexSeedLock.RLock()
+	decoder, ok := seedDecoders[strings.ToLower(filepath.Ext(path))]
+	mutexSeedLock.RUnlock()
+	if !ok {
+		return ErrUnsupportedSeedFormat
+	}
+
+	data, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	seed := &Seed{}
+	if err = decoder(data, seed); err != nil {
+		return fmt.Errorf("failed to decode the seed %s: %w", path, err)
+	}
+	return m.ApplySeed(context.Background(), seed)
+}
+
+// ApplySeed creates the missing permissions and roles and updates the existing ones, matched by name,
+// then grants the listed permissions to the roles. It runs in one transaction and can be applied repeatedly.
+// Permissions and grants missing from the seed are left untouched
+func (m *Migration) ApplySeed(ctx context.Context, seed *Seed) error {
+	return runInTx(ctx, func(tx *PagerTx) error {
+		permissions := make(map[string]*Permission, len(seed.Permissions))
+		for _, declared := range seed.Permissions {
+			permission, err := seedPermission(ctx, tx, declared)
+			if err != nil {
+				return fmt.Errorf("permission %s: %w", declared.Name, err)
+			}
+			permissions[permission.Name] = permission
+		}
+
+		for _, declared := range seed.Roles {
+			role, err := seedRole(ctx, tx, declared)
+			if err != nil {
+				return fmt.Errorf("role %s: %w", declared.Name, err)
+			}
+			for _, name := range declared.Permissions {
+				permission, ok := permissions[name]
+				if !ok {
+					permission, err = GetPermissionWithContext(ctx, name, tx)
+					if err != nil {
+						return err
+					}
+					if permission == nil {
+						return fmt.Errorf("role %s: %w: %s", declared.Name, ErrPermissionNotFound, name)
+					}
+					permissions[name] = permission
+				}
+				if err = seedRolePermission(ctx, tx, role, permission); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func seedPermission(ctx context.Context, tx *PagerTx, declared SeedPermission) (*Permission, error) {
+	permission, err := GetPermissionWithContext(ctx, declared.Name, tx)
+	if err != nil {
+		return nil, err
+	}
+	if permission == nil {
+		permission = tx.Permission(&Permission{
+			Name:        declared.Name,
+			Method:      strings.ToUpper(declared.Method),
+			Route:       declared.Route,
+			Description: declared.Description,
+			DisplayName: declared.DisplayName,
+			IsSystem:    declared.System,
+		})
+		err = permission.CreatePermissionWithContext(ctx)
+	} else {
+		tx.Permission(permission)
+		permission.Method = strings.ToUpper(declared.Method)
+		permission.Route = declared.Route
+		permission.Description = declared.Description
+		permission.DisplayName = declared.DisplayName
+		permission.IsSystem = declared.System
+		if err = permission.Validate(); err != nil {
+			return nil, err
+		}
+		updateQuery := `UPDATE rbac_permission
+		SET method = ?, route = ?, description = ?, display_name = ?, is_system = ?, updated_at = ?
+		WHERE id = ?`
+		_, err = tx.db.ExecContext(
+			ctx,
+			updateQuery,
+			permission.Method,
+			permission.Route,
+			permission.Description,
+			permission.DisplayName,
+			permission.IsSystem,
+			clock.Now(),
+			permission.ID,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, route := range declared.Routes {
+		if err = permission.AddRouteWithContext(ctx, route); err != nil {
+			return nil, err
+		}
+	}
+	for _, tag := range declared.Tags {
+		if err = permission.AddTagWithContext(ctx, tag); err != nil {
+			return nil, err
+		}
+	}
+	return permission, nil
+}
+
+func seedRole(ctx context.Context, tx *PagerTx, declared SeedRole) (*Role, error) {
+	role, err := GetRoleContext(ctx, declared.Name, tx)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		role = tx.Role(&Role{
+			Name:        declared.Name,
+			Description: declared.Description,
+			DisplayName: declared.DisplayName,
+			IsSystem:    declared.System,
+		})
+		return role, role.CreateRoleWithContext(ctx)
+	}
+
+	tx.Role(role)
+	role.Description = declared.Description
+	role.DisplayName = declared.DisplayName
+	role.IsSystem = declared.System
+	updateQuery := `UPDATE rbac_role SET description = ?, display_name = ?, is_system = ?, updated_at = ? WHERE id = ?`
+	_, err = tx.db.ExecContext(ctx, updateQuery, role.Description, role.DisplayName, role.IsSystem, clock.Now(), role.ID)
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func seedRolePermission(ctx context.Context, tx *PagerTx, role *Role, permission *Permission) error {
+	now := clock.Now()
+	insertQuery := `INSERT IGNORE INTO rbac_role_permission (
+		role_id,
+		permission_id,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?)`
+	result, err := tx.db.ExecContext(ctx, insertQuery, role.ID, permission.ID, now, now)
+	if err != nil {
+		return err
+	}
+	purgePermissionCache()
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return nil
+	}
+	return auditRolePermission(ctx, tx.db, AuditRolePermissionAdded, role, permission)
+}
@@ -0,0 +1,118 @@
+package pager
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// storedPolicy answers the lookups of the stored users.read permission, the other names are missing
+func storedPolicy() fakeHandler {
+	var lastID int64 = 100
+	return func(query string, args []driver.NamedValue) fakeResponse {
+		switch {
+		case strings.HasPrefix(query, "SELECT "+permissionColumns("")+" FROM rbac_permission WHERE name = ?"):
+			if args[0].Value != "users.read" {
+				return fakeResponse{}
+			}
+			now := time.Now()
+			return fakeRowsOf(
+				[]string{"id", "name", "method", "route", "description", "display_name", "metadata", "is_system", "created_at", "updated_at"},
+				"7", "users.read", "GET", "/users", "", "", nil, false, now, now,
+			)
+		case strings.HasPrefix(query, "INSERT"):
+			return fakeResponse{affected: 1, lastInsertID: atomic.AddInt64(&lastID, 1)}
+		}
+		return fakeResponse{affected: 1}
+	}
+}
+
+func TestApplySeed(t *testing.T) {
+	tests := []struct {
+		name    string
+		seed    Seed
+		wantErr error
+		// wantInvalid expects the ValidationErrors of an invalid entity
+		wantInvalid  bool
+		wantInserted map[string]int
+		wantUpdated  int
+	}{
+		{
+			name: "creates and updates",
+			seed: Seed{
+				Permissions: []SeedPermission{
+					{Name: "users.read", Method: "get", Route: "/api/users"},
+					{Name: "users.write", Method: "POST", Route: "/api/users"},
+				},
+				Roles: []SeedRole{{Name: "editor", Permissions: []string{"users.read", "users.write"}}},
+			},
+			wantInserted: map[string]int{"rbac_permission": 1, "rbac_role": 1, "rbac_role_permission": 2},
+			wantUpdated:  1,
+		},
+		{
+			name:         "grants a stored permission",
+			seed:         Seed{Roles: []SeedRole{{Name: "reader", Permissions: []string{"users.read"}}}},
+			wantInserted: map[string]int{"rbac_permission": 0, "rbac_role": 1, "rbac_role_permission": 1},
+		},
+		{
+			name:    "unknown permission",
+			seed:    Seed{Roles: []SeedRole{{Name: "editor", Permissions: []string{"users.delete"}}}},
+			wantErr: ErrPermissionNotFound,
+		},
+		{
+			name:        "invalid permission",
+			seed:        Seed{Permissions: []SeedPermission{{Name: "users.write", Method: "FETCH", Route: "/api/users"}}},
+			wantInvalid: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, restore := openFakeDB(t, storedPolicy())
+			defer restore()
+
+			err := (&Migration{}).ApplySeed(context.Background(), &test.seed)
+			if test.wantErr != nil || test.wantInvalid {
+				var validationErrs ValidationErrors
+				if test.wantInvalid && !errors.As(err, &validationErrs) {
+					t.Fatalf("ApplySeed() = %v, want validation errors", err)
+				}
+				if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+					t.Fatalf("ApplySeed() = %v, want %v", err, test.wantErr)
+				}
+				if !fake.rolledBack() {
+					t.Error("the failed seed wasn't rolled back")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ApplySeed() = %v", err)
+			}
+			for table, want := range test.wantInserted {
+				inserted := fake.executed("INTO " + table + " (")
+				if len(inserted) != want {
+					t.Errorf("%d rows inserted into %s, want %d", len(inserted), table, want)
+				}
+				for _, statement := range inserted {
+					if !statement.inTx {
+						t.Errorf("%s ran outside of the transaction", statement.query)
+					}
+				}
+			}
+			if updated := fake.executed("UPDATE rbac_permission"); len(updated) != test.wantUpdated {
+				t.Errorf("%d permissions updated, want %d", len(updated), test.wantUpdated)
+			} else if len(updated) > 0 && updated[0].args[0].Value != "GET" {
+				t.Errorf("method = %v, want it upper-cased", updated[0].args[0].Value)
+			}
+		})
+	}
+}
+
+func TestSeedRejectsUnknownFormats(t *testing.T) {
+	if err := (&Migration{}).Seed("policy.toml"); err != ErrUnsupportedSeedFormat {
+		t.Errorf("Seed() = %v, want %v", err, ErrUnsupportedSeedFormat)
+	}
+}
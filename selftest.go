@@ -0,0 +1,123 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	ErrSelfTestTokenMismatch = errors.New("verified token belongs to another user")
+	ErrSelfTestAccessDenied  = errors.New("user was denied the permission of its role")
+)
+
+// SelfTestError reports the step of SelfTest that failed
+type SelfTestError struct {
+	Step string
+	Err  error
+}
+
+func (e *SelfTestError) Error() string {
+	return "self-test failed at " + e.Step + ": " + e.Err.Error()
+}
+
+func (e *SelfTestError) Unwrap() error {
+	return e.Err
+}
+
+// SelfTest runs a full round trip against the configured database and session store:
+// it creates a temporary user, role and permission, signs in, verifies the token, checks the permission
+// and removes everything it created. It's meant for deployment smoke tests
+func (p *Pager) SelfTest(ctx context.Context) (err error) {
+	suffix := strings.Replace(uuid.NewV4().String(), "-", "", -1)[:16]
+	name := "selftest-" + suffix
+	password := uuid.NewV4().String()
+	hashed, err := hashPassword(p.Auth.passwordStrategy, password)
+	if err != nil {
+		return &SelfTestError{Step: "hash password", Err: err}
+	}
+
+	user := &User{
+		Username: name,
+		Email:    name + "@selftest.invalid",
+		Password: hashed,
+	}
+	role := &Role{Name: name}
+	permission := &Permission{
+		Name:   name,
+		Method: http.MethodGet,
+		Route:  "/" + name,
+	}
+	var token string
+
+	defer func() {
+		cleanupErr := p.cleanupSelfTest(ctx, token, user, role, permission)
+		if err == nil && cleanupErr != nil {
+			err = &SelfTestError{Step: "cleanup", Err: cleanupErr}
+		}
+	}()
+
+	if err = user.CreateUserWithContext(ctx); err != nil {
+		return &SelfTestError{Step: "create user", Err: err}
+	}
+	if err = role.CreateRoleWithContext(ctx); err != nil {
+		return &SelfTestError{Step: "create role", Err: err}
+	}
+	if err = permission.CreatePermissionWithContext(ctx); err != nil {
+		return &SelfTestError{Step: "create permission", Err: err}
+	}
+	if err = role.AddChildWithContext(ctx, permission); err != nil {
+		return &SelfTestError{Step: "add permission", Err: err}
+	}
+	if err = role.AssignWithContext(ctx, user); err != nil {
+		return &SelfTestError{Step: "assign role", Err: err}
+	}
+
+	identifier := user.Email
+	if p.Auth.loginMethod == LoginUsername {
+		identifier = user.Username
+	}
+	_, token, err = p.Auth.SignIn(LoginParams{Identifier: identifier, Password: password})
+	if err != nil {
+		return &SelfTestError{Step: "sign in", Err: err}
+	}
+
+	userID, err := p.Auth.VerifyToken(token)
+	if err != nil {
+		return &SelfTestError{Step: "verify token", Err: err}
+	}
+	if userID != user.ID {
+		return &SelfTestError{Step: "verify token", Err: ErrSelfTestTokenMismatch}
+	}
+
+	if !user.CanAccessWithContext(ctx, permission.Method, permission.Route) {
+		return &SelfTestError{Step: "check permission", Err: ErrSelfTestAccessDenied}
+	}
+	return nil
+}
+
+func (p *Pager) cleanupSelfTest(ctx context.Context, token string, user *User, role *Role, permission *Permission) error {
+	var firstErr error
+	keep := func(err error) {
+		if firstErr == nil && err != nil {
+			firstErr = err
+		}
+	}
+
+	if token != "" {
+		keep(p.Auth.sessionStore.Delete(p.Auth.cacheKey(token)))
+	}
+	if permission.ID != "" {
+		keep(permission.DeletePermissionWithContext(ctx))
+	}
+	if role.ID != "" {
+		keep(role.DeleteRoleWithContext(ctx))
+	}
+	if user.ID != "" {
+		keep(user.DeleteWithContext(ctx))
+	}
+	return firstErr
+}
@@ -0,0 +1,97 @@
+package pager
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// Constants for database server flavors
+const (
+	ServerMySQL   = "mysql"
+	ServerMariaDB = "mariadb"
+)
+
+// ServerVersion is the database server the migration runs against
+type ServerVersion struct {
+	Flavor string
+	Major  int
+	Minor  int
+	Patch  int
+	// Raw is the output of VERSION()
+	Raw string
+}
+
+// smallIndexPrefixKeys are the DDL of the indexes exceeding the 767 bytes keys of the servers without large
+// index prefixes, the long columns are indexed on their 191 first characters (764 bytes in utf8mb4)
+var smallIndexPrefixKeys = map[string]string{
+	"rbac_user_identity_provider_subject_idx": "CREATE UNIQUE INDEX `rbac_user_identity_provider_subject_idx` on rbac_user_identity (provider, subject(191))",
+}
+
+// parseServerVersion reads a VERSION() output, e.g. 8.0.34, 5.7.44-log or 10.6.12-MariaDB-1:10.6.12+maria~ubu2004.
+// The 5.5.5- prefix MariaDB adds for the old clients is skipped
+func parseServerVersion(raw string) ServerVersion {
+	version := ServerVersion{Flavor: ServerMySQL, Raw: raw}
+	if strings.Contains(strings.ToLower(raw), "mariadb") {
+		version.Flavor = ServerMariaDB
+		raw = strings.TrimPrefix(raw, "5.5.5-")
+	}
+	if end := strings.IndexAny(raw, "-+~ "); end >= 0 {
+		raw = raw[:end]
+	}
+
+	parts := strings.SplitN(raw, ".", 3)
+	numbers := []*int{&version.Major, &version.Minor, &version.Patch}
+	for i, part := range parts {
+		*numbers[i], _ = strconv.Atoi(part)
+	}
+	return version
+}
+
+// AtLeast reports whether the server version is major.minor or newer
+func (v ServerVersion) AtLeast(major, minor int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	return v.Minor >= minor
+}
+
+// MariaDB reports whether the server is a MariaDB
+func (v ServerVersion) MariaDB() bool {
+	return v.Flavor == ServerMariaDB
+}
+
+// largeIndexPrefix reports the servers creating DYNAMIC InnoDB tables by default, with keys up to 3072 bytes:
+// MySQL 5.7 and MariaDB 10.2 onwards. An unknown version is assumed recent
+func (v ServerVersion) largeIndexPrefix() bool {
+	if v.Major == 0 {
+		return true
+	}
+	if v.MariaDB() {
+		return v.AtLeast(10, 2)
+	}
+	return v.AtLeast(5, 7)
+}
+
+// ServerVersion detects the flavor and the version of the database server
+func (m *Migration) ServerVersion(ctx context.Context) (ServerVersion, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var raw string
+	if err := queryRow(ctx, dbConnection, `SELECT VERSION()`).Scan(&raw); err != nil {
+		return ServerVersion{}, err
+	}
+	return parseServerVersion(raw), nil
+}
+
+// indexDDL returns the DDL of the index name adjusted to the server
+func (m *Migration) indexDDL(name, ddl string) string {
+	if m.server.largeIndexPrefix() {
+		return ddl
+	}
+	if adjusted, ok := smallIndexPrefixKeys[name]; ok {
+		return adjusted
+	}
+	return ddl
+}
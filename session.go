@@ -0,0 +1,215 @@
+package pager
+
+import (
+	"crypto/tls"
+	"errors"
+	"github.com/go-redis/redis"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSessionNotFound         = errors.New("session not found")
+	ErrUnsupportedSessionStore = errors.New("operation is not supported by the session store")
+)
+
+type SessionStore interface {
+	Set(key, value string, expiration time.Duration) error
+	Get(key string) (string, error)
+	Delete(keys ...string) error
+	// Increment increases the counter stored in key, expiration is applied when the counter is created
+	Increment(key string, expiration time.Duration) (int64, error)
+}
+
+type RedisOptions struct {
+	Addr string
+	// Shards spreads the sessions over several redis endpoints (shard name => addr) with consistent hashing,
+	// Addr is ignored when set. Renaming a shard remaps its keys, so keep the names stable
+	Shards map[string]string
+	// Password is used as is, PasswordEnv names an environment variable read when Password is empty
+	Password    string
+	PasswordEnv string
+	DB          int
+	TLSConfig   *tls.Config
+
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+func NewRedisClient(opts RedisOptions) *redis.Client {
+	password := opts.Password
+	if password == "" && opts.PasswordEnv != "" {
+		password = os.Getenv(opts.PasswordEnv)
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:         opts.Addr,
+		Password:     password,
+		DB:           opts.DB,
+		TLSConfig:    opts.TLSConfig,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+	})
+}
+
+// NewRedisRing creates a consistent-hash ring over opts.Shards, sharing the remaining settings.
+// The ring doesn't support TLSConfig
+func NewRedisRing(opts RedisOptions) *redis.Ring {
+	password := opts.Password
+	if password == "" && opts.PasswordEnv != "" {
+		password = os.Getenv(opts.PasswordEnv)
+	}
+	return redis.NewRing(&redis.RingOptions{
+		Addrs:        opts.Shards,
+		Password:     password,
+		DB:           opts.DB,
+		PoolSize:     opts.PoolSize,
+		MinIdleConns: opts.MinIdleConns,
+		DialTimeout:  opts.DialTimeout,
+		ReadTimeout:  opts.ReadTimeout,
+		WriteTimeout: opts.WriteTimeout,
+		IdleTimeout:  opts.IdleTimeout,
+	})
+}
+
+type RedisSessionStore struct {
+	client *redis.Client
+	ring   *redis.Ring
+	cmd    redis.Cmdable
+}
+
+func NewRedisSessionStore(client *redis.Client) *RedisSessionStore {
+	return &RedisSessionStore{client: client, cmd: client}
+}
+
+// NewShardedRedisSessionStore stores every key in the shard picked by the ring's consistent hash
+func NewShardedRedisSessionStore(ring *redis.Ring) *RedisSessionStore {
+	return &RedisSessionStore{ring: ring, cmd: ring}
+}
+
+// Client returns nil for a sharded store, see Ring
+func (s *RedisSessionStore) Client() *redis.Client {
+	return s.client
+}
+
+func (s *RedisSessionStore) Ring() *redis.Ring {
+	return s.ring
+}
+
+func (s *RedisSessionStore) Set(key, value string, expiration time.Duration) error {
+	return s.cmd.Set(key, value, expiration).Err()
+}
+
+func (s *RedisSessionStore) Get(key string) (string, error) {
+	result, err := s.cmd.Get(key).Result()
+	if err == redis.Nil {
+		return "", ErrSessionNotFound
+	}
+	return result, err
+}
+
+func (s *RedisSessionStore) Delete(keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if s.ring == nil {
+		return s.client.Del(keys...).Err()
+	}
+	// the ring routes a command by its first key, the keys may live in different shards
+	for _, key := range keys {
+		err := s.ring.Del(key).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) Increment(key string, expiration time.Duration) (int64, error) {
+	return incrementScript.Run(s.cmd, []string{key}, milliseconds(expiration)).Int64()
+}
+
+// MigrateKeys renames the keys matching the redis glob pattern into the prefixed keyspace
+func (s *RedisSessionStore) MigrateKeys(match, prefix string) (int, error) {
+	if s.ring == nil {
+		return migrateKeys(s.client, match, prefix, func(key string) error {
+			return s.client.RenameNX(key, prefix+key).Err()
+		})
+	}
+
+	// the prefixed key may hash into another shard, so it's copied through the ring instead of renamed
+	var migrated int
+	err := s.ring.ForEachShard(func(shard *redis.Client) error {
+		count, err := migrateKeys(shard, match, prefix, func(key string) error {
+			value, err := shard.Dump(key).Result()
+			if err == redis.Nil {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			ttl, err := shard.PTTL(key).Result()
+			if err != nil {
+				return err
+			}
+			if ttl < 0 {
+				ttl = 0
+			}
+			err = s.ring.RestoreReplace(prefix+key, ttl, value).Err()
+			if err != nil {
+				return err
+			}
+			return shard.Del(key).Err()
+		})
+		mutexMigrateLock.Lock()
+		migrated += count
+		mutexMigrateLock.Unlock()
+		return err
+	})
+	return migrated, err
+}
+
+var mutexMigrateLock = &sync.Mutex{}
+
+func migrateKeys(client *redis.Client, match, prefix string, migrate func(key string) error) (int, error) {
+	var cursor uint64
+	var migrated int
+	for {
+		keys, nextCursor, err := client.Scan(cursor, match, 100).Result()
+		if err != nil {
+			return migrated, err
+		}
+		for _, key := range keys {
+			if prefix == "" || strings.HasPrefix(key, prefix) {
+				continue
+			}
+			err = migrate(key)
+			if err != nil {
+				return migrated, err
+			}
+			migrated++
+		}
+		cursor = nextCursor
+		if cursor == 0 {
+			return migrated, nil
+		}
+	}
+}
+
+// SessionData is the payload stored for every issued token or cookie
+type SessionData struct {
+	UserID   string            `json:"uid"`
+	Claims   map[string]string `json:"claims,omitempty"`
+	IssuedAt time.Time         `json:"iat"`
+	// AccessToken is only set on the refresh tokens, it's revoked when the refresh token is rotated
+	AccessToken string `json:"at,omitempty"`
+}
@@ -0,0 +1,83 @@
+package pager
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	ErrNoEncryptionKey      = errors.New("at least one session encryption key is required")
+	ErrUnknownEncryptionKey = errors.New("unknown session encryption key")
+	ErrMalformedCiphertext  = errors.New("malformed session ciphertext")
+)
+
+// EncryptionKey is an AES key (16, 24 or 32 bytes) identified by ID,
+// the ID is stored next to the ciphertext so rotated keys can still decrypt older sessions
+type EncryptionKey struct {
+	ID     string
+	Secret []byte
+}
+
+// SessionCipher encrypts session payloads with AES-GCM. The first key is used to encrypt,
+// every key is accepted to decrypt
+type SessionCipher struct {
+	activeKeyID string
+	aeads       map[string]cipher.AEAD
+}
+
+func NewSessionCipher(keys ...EncryptionKey) (*SessionCipher, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoEncryptionKey
+	}
+	c := &SessionCipher{
+		activeKeyID: keys[0].ID,
+		aeads:       make(map[string]cipher.AEAD),
+	}
+	for _, key := range keys {
+		if key.ID == "" || strings.Contains(key.ID, ".") {
+			return nil, errors.New("session encryption key id must be non-empty and must not contain '.'")
+		}
+		block, err := aes.NewCipher(key.Secret)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads[key.ID] = aead
+	}
+	return c, nil
+}
+
+func (c *SessionCipher) Encrypt(plaintext []byte) (string, error) {
+	aead := c.aeads[c.activeKeyID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, plaintext, []byte(c.activeKeyID))
+	return c.activeKeyID + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (c *SessionCipher) Decrypt(value string) ([]byte, error) {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return nil, ErrMalformedCiphertext
+	}
+	aead, ok := c.aeads[parts[0]]
+	if !ok {
+		return nil, ErrUnknownEncryptionKey
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, []byte(parts[0]))
+}
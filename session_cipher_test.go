@@ -0,0 +1,116 @@
+package pager
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var (
+	oldSessionKey = EncryptionKey{ID: "2023", Secret: bytes.Repeat([]byte{1}, 32)}
+	newSessionKey = EncryptionKey{ID: "2024", Secret: bytes.Repeat([]byte{2}, 32)}
+)
+
+func TestSessionCipherKeyRotation(t *testing.T) {
+	before, err := NewSessionCipher(oldSessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := NewSessionCipher(newSessionKey, oldSessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	retired, err := NewSessionCipher(newSessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := []byte(`{"user_id":"1"}`)
+	oldCiphertext, err := before.Encrypt(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCiphertext, err := rotated.Encrypt(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(newCiphertext, newSessionKey.ID+".") {
+		t.Errorf("Encrypt() = %q, want it encrypted with the first key", newCiphertext)
+	}
+
+	tests := []struct {
+		name       string
+		cipher     *SessionCipher
+		ciphertext string
+		wantErr    error
+	}{
+		{name: "old session after the rotation", cipher: rotated, ciphertext: oldCiphertext},
+		{name: "new session after the rotation", cipher: rotated, ciphertext: newCiphertext},
+		{name: "old session after the key is retired", cipher: retired, ciphertext: oldCiphertext, wantErr: ErrUnknownEncryptionKey},
+		{name: "new session before the rotation", cipher: before, ciphertext: newCiphertext, wantErr: ErrUnknownEncryptionKey},
+		{name: "missing key id", cipher: rotated, ciphertext: "ciphertext", wantErr: ErrMalformedCiphertext},
+		{name: "invalid base64", cipher: rotated, ciphertext: newSessionKey.ID + ".!!!", wantErr: ErrMalformedCiphertext},
+		{name: "truncated", cipher: rotated, ciphertext: newSessionKey.ID + ".AAAA", wantErr: ErrMalformedCiphertext},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			plaintext, err := test.cipher.Decrypt(test.ciphertext)
+			if err != test.wantErr {
+				t.Fatalf("Decrypt() = %v, want %v", err, test.wantErr)
+			}
+			if err == nil && !bytes.Equal(plaintext, payload) {
+				t.Errorf("Decrypt() = %q, want %q", plaintext, payload)
+			}
+		})
+	}
+}
+
+func TestSessionCipherRejectsTamperedSessions(t *testing.T) {
+	sameSecret := EncryptionKey{ID: "copy", Secret: newSessionKey.Secret}
+	c, err := NewSessionCipher(newSessionKey, sameSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := c.Encrypt([]byte(`{"user_id":"1"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed := strings.TrimPrefix(ciphertext, newSessionKey.ID+".")
+	flipped := []byte(sealed)
+	// the last character may only carry padding bits
+	flipped[len(flipped)/2] ^= 'A' ^ 'B'
+
+	tests := []struct {
+		name       string
+		ciphertext string
+	}{
+		// the key id is authenticated, a session can't be moved under another id
+		{"swapped key id", sameSecret.ID + "." + sealed},
+		{"modified ciphertext", newSessionKey.ID + "." + string(flipped)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := c.Decrypt(test.ciphertext); err == nil {
+				t.Error("Decrypt() accepted a tampered session")
+			}
+		})
+	}
+}
+
+func TestNewSessionCipherValidatesTheKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []EncryptionKey
+	}{
+		{"no key", nil},
+		{"empty id", []EncryptionKey{{Secret: newSessionKey.Secret}}},
+		{"dotted id", []EncryptionKey{{ID: "2024.1", Secret: newSessionKey.Secret}}},
+		{"short secret", []EncryptionKey{{ID: "2024", Secret: []byte("short")}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewSessionCipher(test.keys...); err == nil {
+				t.Error("NewSessionCipher() accepted invalid keys")
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package pager
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Constants for session metric names
+const (
+	MetricSessionsCreated = "pager_sessions_created_total"
+	MetricSessionsEnded   = "pager_sessions_ended_total"
+	MetricSessionsExpired = "pager_sessions_expired_total"
+	MetricSessionTTL      = "pager_session_ttl_seconds"
+	MetricSessionAge      = "pager_session_age_seconds"
+)
+
+// Constants for the kind label of the session metrics
+const (
+	sessionKindSession = "session"
+	sessionKindRefresh = "refresh"
+)
+
+// SessionSampler is implemented by the session stores able to read the remaining ttl of a sample of their keys
+type SessionSampler interface {
+	// SampleTTLs returns the ttl of at most limit keys matching the glob pattern and accepted by filter
+	SampleTTLs(ctx context.Context, match string, limit int, filter func(key string) bool) (map[string]time.Duration, error)
+}
+
+// ExpirationWatcher is implemented by the session stores notified of their expired keys
+type ExpirationWatcher interface {
+	// WatchExpirations invokes fn with every expired key until ctx is done
+	WatchExpirations(ctx context.Context, fn func(key string)) error
+}
+
+// sessionKind classifies a stored key: the sessions are the bare tokens and the refresh tokens are prefixed,
+// the other keys of pager (indexes, throttling, revocations) all hold a ':' and are ignored
+func (a *Auth) sessionKind(key string) string {
+	if !strings.HasPrefix(key, a.cacheKeyPrefix) {
+		return ""
+	}
+	key = strings.TrimPrefix(key, a.cacheKeyPrefix)
+	if strings.HasPrefix(key, "refresh:") {
+		return sessionKindRefresh
+	}
+	if strings.Contains(key, ":") {
+		return ""
+	}
+	return sessionKindSession
+}
+
+func (a *Auth) observeSessionCreated(key string) {
+	if kind := a.sessionKind(key); kind != "" {
+		metrics.IncCounter(MetricSessionsCreated, map[string]string{"kind": kind})
+	}
+}
+
+func observeSessionEnded(reason string) {
+	metrics.IncCounter(MetricSessionsEnded, map[string]string{"reason": reason})
+}
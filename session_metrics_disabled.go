@@ -0,0 +1,20 @@
+//go:build pager_notelemetry
+// +build pager_notelemetry
+
+package pager
+
+import (
+	"context"
+	"time"
+)
+
+func (a *Auth) SampleSessionTTLs(ctx context.Context, sample int) error {
+	return ErrTelemetryDisabled
+}
+
+func (a *Auth) WatchSessionExpirations(ctx context.Context) error {
+	return ErrTelemetryDisabled
+}
+
+// ScheduleSessionMetrics does nothing without telemetry
+func (a *Auth) ScheduleSessionMetrics(ctx context.Context, interval time.Duration, sample int) {}
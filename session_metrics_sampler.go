@@ -0,0 +1,165 @@
+//go:build !pager_notelemetry
+// +build !pager_notelemetry
+
+package pager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// defaultSessionSampleSize bounds the number of keys read by SampleSessionTTLs
+const defaultSessionSampleSize = 1000
+
+// SampleSessionTTLs observes the remaining ttl and the age of a sample of the stored sessions
+// as MetricSessionTTL and MetricSessionAge, e.g. to tune ExpiredInSeconds. A zero sample reads 1000 keys
+func (a *Auth) SampleSessionTTLs(ctx context.Context, sample int) error {
+	sampler, ok := a.sessionStore.(SessionSampler)
+	if !ok {
+		return ErrUnsupportedSessionStore
+	}
+	if sample <= 0 {
+		sample = defaultSessionSampleSize
+	}
+	ttls, err := sampler.SampleTTLs(ctx, a.cacheKeyPrefix+"*", sample, func(key string) bool {
+		return a.sessionKind(key) != ""
+	})
+	if err != nil {
+		return err
+	}
+
+	for key, ttl := range ttls {
+		kind := a.sessionKind(key)
+		lifetime := time.Duration(a.expiredInSeconds) * time.Second
+		if kind == sessionKindRefresh {
+			lifetime = a.refreshExpiration()
+		}
+		labels := map[string]string{"kind": kind}
+		metrics.ObserveDuration(MetricSessionTTL, ttl, labels)
+		if lifetime > ttl {
+			metrics.ObserveDuration(MetricSessionAge, lifetime-ttl, labels)
+		}
+	}
+	return nil
+}
+
+// WatchSessionExpirations counts the expired sessions as MetricSessionsExpired until ctx is done.
+// The redis servers must publish the expiry events, e.g. CONFIG SET notify-keyspace-events Ex
+func (a *Auth) WatchSessionExpirations(ctx context.Context) error {
+	watcher, ok := a.sessionStore.(ExpirationWatcher)
+	if !ok {
+		return ErrUnsupportedSessionStore
+	}
+	return watcher.WatchExpirations(ctx, func(key string) {
+		if kind := a.sessionKind(key); kind != "" {
+			metrics.IncCounter(MetricSessionsExpired, map[string]string{"kind": kind})
+		}
+	})
+}
+
+// ScheduleSessionMetrics samples the session ttls every interval and watches the expirations until ctx is done
+func (a *Auth) ScheduleSessionMetrics(ctx context.Context, interval time.Duration, sample int) {
+	go func() {
+		if err := a.WatchSessionExpirations(ctx); err != nil && ctx.Err() == nil {
+			logf(ctx, "failed to watch the session expirations, err = %s", err)
+		}
+	}()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := a.SampleSessionTTLs(ctx, sample); err != nil && ctx.Err() == nil {
+				logf(ctx, "failed to sample the session ttls, err = %s", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *RedisSessionStore) SampleTTLs(ctx context.Context, match string, limit int, filter func(key string) bool) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	var mutex sync.Mutex
+	collect := func(node *redis.Client, limit int) error {
+		var keys []string
+		var cursor uint64
+		for len(keys) < limit {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			scanned, nextCursor, err := node.Scan(cursor, match, 100).Result()
+			if err != nil {
+				return err
+			}
+			for _, key := range scanned {
+				if filter(key) && len(keys) < limit {
+					keys = append(keys, key)
+				}
+			}
+			cursor = nextCursor
+			if cursor == 0 {
+				break
+			}
+		}
+		sampled, err := s.pttl(keys)
+		if err != nil {
+			return err
+		}
+		mutex.Lock()
+		for i, ttl := range sampled {
+			if ttl > 0 {
+				ttls[keys[i]] = ttl
+			}
+		}
+		mutex.Unlock()
+		return nil
+	}
+
+	var err error
+	if s.ring == nil {
+		err = collect(s.client, limit)
+	} else if shards := s.ring.Len(); shards > 0 {
+		// every shard is sampled for its share of limit
+		err = s.ring.ForEachShard(func(node *redis.Client) error {
+			return collect(node, limit/shards+1)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ttls, nil
+}
+
+func (s *RedisSessionStore) WatchExpirations(ctx context.Context, fn func(key string)) error {
+	watch := func(node *redis.Client) error {
+		pubsub := node.PSubscribe("__keyevent@*__:expired")
+		defer pubsub.Close()
+		if _, err := pubsub.Receive(); err != nil {
+			return err
+		}
+		messages := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case message, ok := <-messages:
+				if !ok {
+					return nil
+				}
+				fn(message.Payload)
+			}
+		}
+	}
+
+	if s.ring == nil {
+		return watch(s.client)
+	}
+	return s.ring.ForEachShard(watch)
+}
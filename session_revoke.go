@@ -0,0 +1,134 @@
+package pager
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+const (
+	AuditSessionsRevoked = "sessions.revoked"
+
+	defaultRevokeBatchSize = 100
+)
+
+// BulkDeleter is implemented by the session stores able to delete many keys in batches
+type BulkDeleter interface {
+	// DeleteBatched deletes keys batchSize at a time, progress is invoked after every batch
+	DeleteBatched(keys []string, batchSize int, progress func(deleted, total int)) error
+}
+
+type RevokeOptions struct {
+	// BatchSize is the number of sessions deleted per round trip, 100 by default
+	BatchSize int
+	// Progress is invoked after every batch with the number of revoked sessions so far
+	Progress func(revoked, total int)
+	Reason   string
+}
+
+// DeleteBatched pipelines UNLINK commands, so the memory is reclaimed without blocking redis
+func (s *RedisSessionStore) DeleteBatched(keys []string, batchSize int, progress func(deleted, total int)) error {
+	if batchSize <= 0 {
+		batchSize = defaultRevokeBatchSize
+	}
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		pipe := s.cmd.Pipeline()
+		for _, key := range keys[start:end] {
+			pipe.Unlink(key)
+		}
+		_, err := pipe.Exec()
+		pipe.Close()
+		if err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(end, len(keys))
+		}
+	}
+	return nil
+}
+
+// RevokeAllSessions signs the user out of every device, it requires a session store implementing SessionIndexer.
+// The revocation is recorded into the audit log, the actor is the authenticated user stored in ctx
+func (a *Auth) RevokeAllSessions(ctx context.Context, userID string, opts RevokeOptions) (int, error) {
+	if a.stateless {
+		// the number of outstanding stateless tokens is unknown
+		if err := a.revokeStatelessUser(ctx, userID); err != nil {
+			return 0, err
+		}
+		return 0, WriteAudit(ctx, &AuditEntry{
+			ActorID: actorFromContext(ctx),
+			Action:  AuditSessionsRevoked,
+			Target:  userID,
+			Reason:  opts.Reason,
+		}, nil)
+	}
+	indexer, ok := a.sessionStore.(SessionIndexer)
+	if !ok {
+		return 0, ErrUnsupportedSessionStore
+	}
+	indexKey := a.sessionIndexKey(userID)
+	keys, err := indexer.IndexMembers(indexKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if deleter, ok := a.sessionStore.(BulkDeleter); ok {
+		err = deleter.DeleteBatched(keys, opts.BatchSize, opts.Progress)
+	} else {
+		err = a.sessionStore.Delete(keys...)
+		if err == nil && opts.Progress != nil {
+			opts.Progress(len(keys), len(keys))
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	err = a.sessionStore.Delete(indexKey)
+	if err != nil {
+		return 0, err
+	}
+
+	revoked := len(keys)
+	for i := 0; i < revoked; i++ {
+		observeSessionEnded("revoked")
+	}
+	err = WriteAudit(ctx, &AuditEntry{
+		ActorID:  actorFromContext(ctx),
+		Action:   AuditSessionsRevoked,
+		Target:   userID,
+		Reason:   opts.Reason,
+		Metadata: map[string]string{"sessions": strconv.Itoa(revoked)},
+	}, nil)
+	return revoked, err
+}
+
+// SignOutAllHandler revokes every session of the authenticated user and clears the session cookie,
+// mount it behind ProtectRoute or ProtectRouteUsingToken. Only POST is accepted
+func (a *Auth) SignOutAllHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			a.writeError(w, r, http.StatusMethodNotAllowed, nil)
+			return
+		}
+		principal := GetPrincipal(r)
+		if principal == nil {
+			a.writeError(w, r, http.StatusUnauthorized, nil)
+			return
+		}
+
+		_, err := a.RevokeAllSessions(r.Context(), principal.UserID, RevokeOptions{Reason: "signed out of all devices"})
+		if err != nil {
+			a.WriteProblem(w, r, err)
+			return
+		}
+
+		http.SetCookie(w, a.sessionCookie("", -1, a.secureRequest(r)))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
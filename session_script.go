@@ -0,0 +1,66 @@
+package pager
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// SessionIndexer is implemented by the session stores able to track the sessions of every user,
+// the index enables revoking all the sessions of a user
+type SessionIndexer interface {
+	// SetIndexed stores key and adds it to the set indexKey, the set lives as long as its newest member
+	SetIndexed(key, value string, expiration time.Duration, indexKey string) error
+	IndexMembers(indexKey string) ([]string, error)
+}
+
+// The scripts run with EVALSHA, falling back to EVAL when the script isn't cached by redis yet
+var (
+	incrementScript = redis.NewScript(`
+local counter = redis.call("INCR", KEYS[1])
+if counter == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return counter`)
+
+	setIndexedScript = redis.NewScript(`
+redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+redis.call("SADD", KEYS[2], KEYS[1])
+if redis.call("PTTL", KEYS[2]) < tonumber(ARGV[2]) then
+	redis.call("PEXPIRE", KEYS[2], ARGV[2])
+end
+return 1`)
+)
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// SetIndexed runs atomically on a single redis, the ring may place the session and its index on different
+// shards so it falls back to separate commands there
+func (s *RedisSessionStore) SetIndexed(key, value string, expiration time.Duration, indexKey string) error {
+	if s.ring == nil {
+		return setIndexedScript.Run(s.client, []string{key, indexKey}, value, milliseconds(expiration)).Err()
+	}
+
+	err := s.ring.Set(key, value, expiration).Err()
+	if err != nil {
+		return err
+	}
+	err = s.ring.SAdd(indexKey, key).Err()
+	if err != nil {
+		return err
+	}
+	ttl, err := s.ring.PTTL(indexKey).Result()
+	if err != nil {
+		return err
+	}
+	if ttl < expiration {
+		return s.ring.PExpire(indexKey, expiration).Err()
+	}
+	return nil
+}
+
+func (s *RedisSessionStore) IndexMembers(indexKey string) ([]string, error) {
+	return s.cmd.SMembers(indexKey).Result()
+}
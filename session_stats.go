@@ -0,0 +1,117 @@
+package pager
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// SessionStats feeds the capacity dashboards, SessionsPerUser maps a session count to the number of users having it
+type SessionStats struct {
+	ActiveSessions  int64         `json:"active_sessions"`
+	Users           int64         `json:"users"`
+	SessionsPerUser map[int]int64 `json:"sessions_per_user"`
+	AverageAge      time.Duration `json:"average_age"`
+}
+
+// SessionStatsSource is implemented by the session stores able to walk the session indexes of SessionIndexer
+type SessionStatsSource interface {
+	// SessionStats walks the indexes matching the glob pattern, the age of a session is lifetime minus its ttl
+	SessionStats(ctx context.Context, indexMatch string, lifetime time.Duration) (*SessionStats, error)
+}
+
+// SessionStats scans the session indexes, which costs a SCAN over the keyspace plus a pipelined PTTL per index
+func (a *Auth) SessionStats(ctx context.Context) (*SessionStats, error) {
+	source, ok := a.sessionStore.(SessionStatsSource)
+	if !ok {
+		return nil, ErrUnsupportedSessionStore
+	}
+	return source.SessionStats(ctx, a.sessionIndexKey("*"), time.Duration(a.expiredInSeconds)*time.Second)
+}
+
+func (s *RedisSessionStore) SessionStats(ctx context.Context, indexMatch string, lifetime time.Duration) (*SessionStats, error) {
+	stats := &SessionStats{SessionsPerUser: make(map[int]int64)}
+	var totalAge time.Duration
+	var mutex sync.Mutex
+
+	collect := func(node *redis.Client) error {
+		var cursor uint64
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			indexKeys, nextCursor, err := node.Scan(cursor, indexMatch, 100).Result()
+			if err != nil {
+				return err
+			}
+			for _, indexKey := range indexKeys {
+				members, err := node.SMembers(indexKey).Result()
+				if err != nil {
+					return err
+				}
+				ttls, err := s.pttl(members)
+				if err != nil {
+					return err
+				}
+
+				mutex.Lock()
+				active := 0
+				for _, ttl := range ttls {
+					if ttl <= 0 {
+						continue
+					}
+					active++
+					totalAge += lifetime - ttl
+				}
+				if active > 0 {
+					stats.Users++
+					stats.ActiveSessions += int64(active)
+					stats.SessionsPerUser[active]++
+				}
+				mutex.Unlock()
+			}
+			cursor = nextCursor
+			if cursor == 0 {
+				return nil
+			}
+		}
+	}
+
+	var err error
+	if s.ring == nil {
+		err = collect(s.client)
+	} else {
+		err = s.ring.ForEachShard(collect)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stats.ActiveSessions > 0 {
+		stats.AverageAge = totalAge / time.Duration(stats.ActiveSessions)
+	}
+	return stats, nil
+}
+
+// pttl returns the remaining ttl of keys in one round trip, a missing key has a negative ttl
+func (s *RedisSessionStore) pttl(keys []string) ([]time.Duration, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	pipe := s.cmd.Pipeline()
+	defer pipe.Close()
+	cmds := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		cmds[i] = pipe.PTTL(key)
+	}
+	_, err := pipe.Exec()
+	if err != nil {
+		return nil, err
+	}
+	ttls := make([]time.Duration, len(keys))
+	for i, cmd := range cmds {
+		ttls[i] = cmd.Val()
+	}
+	return ttls, nil
+}
@@ -0,0 +1,63 @@
+package pager
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// settings gathers the configuration of the entity methods, which aren't bound to a Pager.
+// BuildPager replaces it as a whole, so the options left unset go back to their defaults,
+// and the readers get a consistent snapshot from loadSettings without locking
+type settings struct {
+	queryTimeout    time.Duration
+	idGenerator     IDGenerator
+	entityHooks     *EntityHooks
+	permissionFlags PermissionFlags
+	accessExplain   bool
+	dualControl     *DualControlOptions
+	usernamePolicy  *UsernamePolicy
+	txRetryPolicy   RetryPolicy
+	// retryPolicy retries the single-row lookups of the connection, nil unless Options.Retry is set
+	retryPolicy *RetryPolicy
+	// permCache is nil unless Options.PermissionCacheTTL is set
+	permCache *permissionCache
+	roleIndex *rolePermissionIndex
+}
+
+func defaultSettings() *settings {
+	return &settings{
+		entityHooks:     NewEntityHooks(),
+		permissionFlags: nopPermissionFlags{},
+		txRetryPolicy:   RetryPolicy{}.withDefaults(),
+		roleIndex:       newRolePermissionIndex(),
+	}
+}
+
+var activeSettings atomic.Value
+var mutexSettingsLock = &sync.Mutex{}
+
+func init() {
+	activeSettings.Store(defaultSettings())
+}
+
+// loadSettings returns the installed settings, they must not be modified
+func loadSettings() *settings {
+	return activeSettings.Load().(*settings)
+}
+
+func installSettings(s *settings) {
+	mutexSettingsLock.Lock()
+	activeSettings.Store(s)
+	mutexSettingsLock.Unlock()
+}
+
+// updateSettings installs a copy of the current settings changed by update
+func updateSettings(update func(s *settings)) {
+	mutexSettingsLock.Lock()
+	defer mutexSettingsLock.Unlock()
+
+	copied := *loadSettings()
+	update(&copied)
+	activeSettings.Store(&copied)
+}
@@ -0,0 +1,43 @@
+package pager
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBuildPagerResetsTheUnsetSettings(t *testing.T) {
+	_, restore := openFakeDB(t, nil)
+	defer restore()
+	previous := loadSettings()
+	defer installSettings(previous)
+
+	hooks := NewEntityHooks().OnUser(BeforeCreate, func(ctx context.Context, user *User) error {
+		return nil
+	})
+	NewPager(&Options{
+		Dialect:            MYSQLDialect,
+		DualControl:        &DualControlOptions{DeleteRole: true},
+		PermissionCacheTTL: time.Minute,
+		QueryTimeout:       time.Second,
+	}).SetEntityHooks(hooks).BuildPager()
+	configured := loadSettings()
+	if configured.dualControl == nil || configured.dualControl.TTL != defaultPendingOperationTTL {
+		t.Errorf("dualControl = %+v, want the options with the default TTL", configured.dualControl)
+	}
+	if configured.permCache == nil || configured.queryTimeout != time.Second || configured.entityHooks != hooks {
+		t.Errorf("settings = %+v, want the configured ones", configured)
+	}
+
+	NewPager(&Options{Dialect: MYSQLDialect}).BuildPager()
+	reset := loadSettings()
+	if reset.dualControl != nil || reset.permCache != nil || reset.queryTimeout != 0 {
+		t.Errorf("settings = %+v, the options of the previous Pager were kept", reset)
+	}
+	if len(reset.entityHooks.user[BeforeCreate]) != 0 {
+		t.Error("the hooks of the previous Pager were kept")
+	}
+	if reset.roleIndex == configured.roleIndex {
+		t.Error("the role index is shared with the previous Pager")
+	}
+}
@@ -0,0 +1,165 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrStatelessRequiresJWT = errors.New("stateless sessions require SessionOptions.JWT")
+
+// RevocationList rejects the stateless tokens invalidated before they expire, e.g. after a leak.
+// MemoryRevocationList is used by default, it only covers the instance it runs on: use NewDBRevocationList
+// or another shared implementation when running several instances
+type RevocationList interface {
+	// Revoke rejects the token id until expiresAt
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	// RevokeUser rejects the tokens of userID issued up to issuedBefore, until expiresAt
+	RevokeUser(ctx context.Context, userID string, issuedBefore, expiresAt time.Time) error
+	Revoked(ctx context.Context, id, userID string, issuedAt time.Time) (bool, error)
+}
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+type MemoryRevocationList struct {
+	mutex  sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]userRevocation
+}
+
+func NewMemoryRevocationList() *MemoryRevocationList {
+	return &MemoryRevocationList{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]userRevocation),
+	}
+}
+
+func (l *MemoryRevocationList) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune()
+	l.tokens[id] = expiresAt
+	return nil
+}
+
+func (l *MemoryRevocationList) RevokeUser(ctx context.Context, userID string, issuedBefore, expiresAt time.Time) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.prune()
+	l.users[userID] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (l *MemoryRevocationList) Revoked(ctx context.Context, id, userID string, issuedAt time.Time) (bool, error) {
+	now := time.Now()
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if expiresAt, ok := l.tokens[id]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	if revocation, ok := l.users[userID]; ok && now.Before(revocation.expiresAt) {
+		return !issuedAt.After(revocation.issuedBefore), nil
+	}
+	return false, nil
+}
+
+// prune drops the expired entries, the caller holds the lock
+func (l *MemoryRevocationList) prune() {
+	now := time.Now()
+	for id, expiresAt := range l.tokens {
+		if !now.Before(expiresAt) {
+			delete(l.tokens, id)
+		}
+	}
+	for userID, revocation := range l.users {
+		if !now.Before(revocation.expiresAt) {
+			delete(l.users, userID)
+		}
+	}
+}
+
+// DBRevocationList shares the revocations between the instances through the rbac_token_revocation table,
+// it costs a query per authenticated request
+type DBRevocationList struct{}
+
+func NewDBRevocationList() *DBRevocationList {
+	return &DBRevocationList{}
+}
+
+func (l *DBRevocationList) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	insertQuery := `INSERT INTO rbac_token_revocation (token_id, expires_at, created_at) VALUES (?,?,?)`
+	_, err := dbConnection.ExecContext(ctx, insertQuery, id, expiresAt, clock.Now())
+	return err
+}
+
+func (l *DBRevocationList) RevokeUser(ctx context.Context, userID string, issuedBefore, expiresAt time.Time) error {
+	insertQuery := `INSERT INTO rbac_token_revocation (user_id, issued_before, expires_at, created_at) VALUES (?,?,?,?)`
+	_, err := dbConnection.ExecContext(ctx, insertQuery, userID, issuedBefore, expiresAt, clock.Now())
+	return err
+}
+
+func (l *DBRevocationList) Revoked(ctx context.Context, id, userID string, issuedAt time.Time) (bool, error) {
+	var revoked int64
+	getQuery := `SELECT COUNT(1) FROM rbac_token_revocation
+	WHERE expires_at > ?
+	AND (token_id = ? OR (user_id = ? AND issued_before >= ?))`
+	err := queryRow(ctx, dbConnection, getQuery, time.Now(), id, userID, issuedAt).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked > 0, nil
+}
+
+// PurgeRevocations deletes the expired revocations, run it periodically
+func (l *DBRevocationList) PurgeRevocations(ctx context.Context) (int64, error) {
+	result, err := dbConnection.ExecContext(ctx, `DELETE FROM rbac_token_revocation WHERE expires_at <= ?`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// jwtExpiration is the lifetime of the issued JWTs, ExpiredInSeconds or an hour, capped by JWTOptions.OfflineMaxAge
+// with VerifyOffline
+func (a *Auth) jwtExpiration() time.Duration {
+	expiration := defaultJWTExpiration
+	if a.expiredInSeconds > 0 {
+		expiration = time.Duration(a.expiredInSeconds) * time.Second
+	}
+	if a.jwt != nil && a.jwt.Verification == VerifyOffline && expiration > a.jwt.offlineMaxAge() {
+		return a.jwt.offlineMaxAge()
+	}
+	return expiration
+}
+
+// statelessSession reads the session carried by a stateless token
+func (a *Auth) statelessSession(token string) (*SessionData, error) {
+	claims, err := a.parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	return &SessionData{
+		UserID:   claims.Subject,
+		Claims:   claims.Claims,
+		IssuedAt: time.Unix(claims.IssuedAt, 0),
+	}, nil
+}
+
+// revokeStateless adds the token to the revocation list until it expires
+func (a *Auth) revokeStateless(ctx context.Context, token string) (*jwtClaims, error) {
+	claims, err := a.parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+	return claims, a.revocations.Revoke(ctx, claims.ID, time.Unix(claims.ExpiresAt, 0))
+}
+
+// revokeStatelessUser rejects every token of userID issued so far, they can't outlive the token lifetime
+func (a *Auth) revokeStatelessUser(ctx context.Context, userID string) error {
+	now := time.Now()
+	return a.revocations.RevokeUser(ctx, userID, now, now.Add(a.jwtExpiration()))
+}
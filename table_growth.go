@@ -0,0 +1,135 @@
+package pager
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Constants for table growth metric names
+const (
+	MetricTableRows          = "pager_table_rows"
+	MetricTableGrowthPerHour = "pager_table_growth_rows_per_hour"
+	MetricTableQuotaExceeded = "pager_table_quota_exceeded_total"
+)
+
+// TableGrowthOptions configures the soft quotas of the rbac_* tables, keyed by table name.
+// Exceeding a quota never blocks writes, it's only reported
+type TableGrowthOptions struct {
+	// MaxRows warns when a table holds more rows
+	MaxRows map[string]int64
+	// MaxGrowthPerHour warns when a table grows faster, measured between two checks
+	MaxGrowthPerHour map[string]float64
+	// OnWarning is called for every table over its quota, the warning is logged when nil
+	OnWarning func(ctx context.Context, stats TableStats)
+}
+
+type TableStats struct {
+	Table string `json:"table"`
+	// Rows is the estimate of the storage engine, exact counts would scan the big tables
+	Rows          int64   `json:"rows"`
+	GrowthPerHour float64 `json:"growth_per_hour"`
+	OverQuota     bool    `json:"over_quota"`
+}
+
+type tableSample struct {
+	rows int64
+	at   time.Time
+}
+
+// TableGrowthMonitor keeps the previous samples to compute the growth rate of the tables
+type TableGrowthMonitor struct {
+	opts TableGrowthOptions
+
+	mutex   sync.Mutex
+	samples map[string]tableSample
+}
+
+func (p *Pager) NewTableGrowthMonitor(opts TableGrowthOptions) *TableGrowthMonitor {
+	return &TableGrowthMonitor{
+		opts:    opts,
+		samples: make(map[string]tableSample),
+	}
+}
+
+// Check samples the row count of the rbac_* tables, publishes them as gauges and reports the tables over quota.
+// The growth rate is zero until the second check
+func (m *TableGrowthMonitor) Check(ctx context.Context) ([]TableStats, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	getQuery := `SELECT TABLE_NAME, COALESCE(TABLE_ROWS, 0)
+	FROM information_schema.TABLES
+	WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME LIKE 'rbac\_%'
+	ORDER BY TABLE_NAME`
+	result, err := dbConnection.QueryContext(ctx, getQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	now := clock.Now()
+	tables := make([]TableStats, 0)
+	for result.Next() {
+		var stats TableStats
+		if err = result.Scan(&stats.Table, &stats.Rows); err != nil {
+			return nil, err
+		}
+		tables = append(tables, stats)
+	}
+	if err = result.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	for i := range tables {
+		stats := &tables[i]
+		if previous, ok := m.samples[stats.Table]; ok && now.After(previous.at) {
+			stats.GrowthPerHour = float64(stats.Rows-previous.rows) / now.Sub(previous.at).Hours()
+		}
+		m.samples[stats.Table] = tableSample{rows: stats.Rows, at: now}
+
+		if max, ok := m.opts.MaxRows[stats.Table]; ok && stats.Rows > max {
+			stats.OverQuota = true
+		}
+		if max, ok := m.opts.MaxGrowthPerHour[stats.Table]; ok && stats.GrowthPerHour > max {
+			stats.OverQuota = true
+		}
+	}
+	m.mutex.Unlock()
+
+	for _, stats := range tables {
+		labels := map[string]string{"table": stats.Table}
+		metrics.SetGauge(MetricTableRows, float64(stats.Rows), labels)
+		metrics.SetGauge(MetricTableGrowthPerHour, stats.GrowthPerHour, labels)
+		if !stats.OverQuota {
+			continue
+		}
+		metrics.IncCounter(MetricTableQuotaExceeded, labels)
+		if m.opts.OnWarning != nil {
+			m.opts.OnWarning(ctx, stats)
+		} else {
+			logf(ctx, "table %s is over its soft quota, rows = %d, growth = %.1f rows/hour", stats.Table, stats.Rows, stats.GrowthPerHour)
+		}
+	}
+	return tables, nil
+}
+
+// Schedule runs Check every interval until ctx is done
+func (m *TableGrowthMonitor) Schedule(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if _, err := m.Check(ctx); err != nil {
+				logf(ctx, "failed to check the table growth, err = %s", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,153 @@
+package pager
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrLoginThrottled  = errors.New("too many failed login attempts, try again later")
+	ErrTooManyAttempts = errors.New("too many failed login attempts, wait before trying again")
+)
+
+// LoginDelayError is returned by Authenticate while the address has to wait before its next attempt,
+// errors.Is(err, ErrTooManyAttempts) matches it
+type LoginDelayError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginDelayError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+func (e *LoginDelayError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LoginThrottleOptions configures per-IP throttling of Authenticate. After FreeAttempts failures
+// inside Window every further failure makes the address wait BaseDelay doubled per attempt (capped at MaxDelay),
+// Authenticate returns a *LoginDelayError until then instead of blocking the caller.
+// Reaching BanAfter failures bans the address for BanDuration
+type LoginThrottleOptions struct {
+	FreeAttempts int64
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	BanAfter     int64
+	BanDuration  time.Duration
+	Window       time.Duration
+}
+
+type loginThrottle struct {
+	opts  LoginThrottleOptions
+	store SessionStore
+	key   func(key string) string
+}
+
+func newLoginThrottle(opts LoginThrottleOptions, store SessionStore, key func(string) string) *loginThrottle {
+	if opts.Window <= 0 {
+		opts.Window = 15 * time.Minute
+	}
+	if opts.BanDuration <= 0 {
+		opts.BanDuration = opts.Window
+	}
+	return &loginThrottle{
+		opts:  opts,
+		store: store,
+		key:   key,
+	}
+}
+
+func (t *loginThrottle) check(ip string) error {
+	if ip == "" {
+		return nil
+	}
+	_, err := t.store.Get(t.key("ban:ip:" + ip))
+	if err == nil {
+		return ErrLoginThrottled
+	}
+	if err != ErrSessionNotFound {
+		return err
+	}
+
+	// the delay key holds the end of the delay, the store expires it then
+	until, err := t.store.Get(t.key("delay:ip:" + ip))
+	if err == ErrSessionNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	end, err := strconv.ParseInt(until, 10, 64)
+	if err != nil {
+		return err
+	}
+	if retryAfter := time.Unix(0, end).Sub(clock.Now()); retryAfter > 0 {
+		return &LoginDelayError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// fail records a failed attempt of ip, the returned *LoginDelayError holds the progressive delay
+func (t *loginThrottle) fail(ip string) error {
+	if ip == "" {
+		return nil
+	}
+	attempts, err := t.store.Increment(t.key("throttle:ip:"+ip), t.opts.Window)
+	if err != nil {
+		return err
+	}
+	if t.opts.BanAfter > 0 && attempts >= t.opts.BanAfter {
+		err = t.store.Set(t.key("ban:ip:"+ip), "1", t.opts.BanDuration)
+		if err != nil {
+			return err
+		}
+		_ = t.store.Delete(t.key("throttle:ip:" + ip))
+		return ErrLoginThrottled
+	}
+	delay := t.delay(attempts)
+	if delay <= 0 {
+		return nil
+	}
+	until := strconv.FormatInt(clock.Now().Add(delay).UnixNano(), 10)
+	if err = t.store.Set(t.key("delay:ip:"+ip), until, delay); err != nil {
+		return err
+	}
+	return &LoginDelayError{RetryAfter: delay}
+}
+
+func (t *loginThrottle) succeed(ip string) {
+	if ip == "" {
+		return
+	}
+	_ = t.store.Delete(t.key("throttle:ip:" + ip))
+}
+
+func (t *loginThrottle) delay(attempts int64) time.Duration {
+	over := attempts - t.opts.FreeAttempts
+	if over <= 0 || t.opts.BaseDelay <= 0 {
+		return 0
+	}
+	delay := t.opts.BaseDelay
+	for i := int64(1); i < over; i++ {
+		delay *= 2
+		if t.opts.MaxDelay > 0 && delay >= t.opts.MaxDelay {
+			return t.opts.MaxDelay
+		}
+	}
+	if t.opts.MaxDelay > 0 && delay > t.opts.MaxDelay {
+		return t.opts.MaxDelay
+	}
+	return delay
+}
+
+// setRetryAfter sets the Retry-After header in whole seconds when err carries a login delay
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var delayErr *LoginDelayError
+	if !errors.As(err, &delayErr) {
+		return
+	}
+	seconds := int64((delayErr.RetryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+}
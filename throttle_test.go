@@ -0,0 +1,114 @@
+package pager
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoginThrottleFail(t *testing.T) {
+	opts := LoginThrottleOptions{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		BanAfter:     7,
+		Window:       time.Minute,
+	}
+	tests := []struct {
+		attempt        int
+		wantRetryAfter time.Duration
+		wantErr        error
+	}{
+		{attempt: 1},
+		{attempt: 2},
+		{attempt: 3, wantRetryAfter: time.Second, wantErr: ErrTooManyAttempts},
+		{attempt: 4, wantRetryAfter: 2 * time.Second, wantErr: ErrTooManyAttempts},
+		{attempt: 5, wantRetryAfter: 4 * time.Second, wantErr: ErrTooManyAttempts},
+		{attempt: 6, wantRetryAfter: 4 * time.Second, wantErr: ErrTooManyAttempts},
+		{attempt: 7, wantErr: ErrLoginThrottled},
+	}
+
+	store := NewMemorySessionStore(time.Hour)
+	defer store.Close()
+	throttle := newLoginThrottle(opts, store, func(key string) string { return key })
+	for _, test := range tests {
+		started := time.Now()
+		err := throttle.fail("10.0.0.1")
+		if elapsed := time.Since(started); elapsed > 100*time.Millisecond {
+			t.Errorf("attempt %d: fail() blocked for %v", test.attempt, elapsed)
+		}
+		if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+			t.Fatalf("attempt %d: fail() = %v, want %v", test.attempt, err, test.wantErr)
+		}
+		var delayErr *LoginDelayError
+		if errors.As(err, &delayErr) != (test.wantRetryAfter > 0) {
+			t.Fatalf("attempt %d: fail() = %v, want a delay of %v", test.attempt, err, test.wantRetryAfter)
+		}
+		if delayErr != nil && delayErr.RetryAfter != test.wantRetryAfter {
+			t.Errorf("attempt %d: RetryAfter = %v, want %v", test.attempt, delayErr.RetryAfter, test.wantRetryAfter)
+		}
+	}
+}
+
+func TestLoginThrottleCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		wait     time.Duration
+		wantErr  error
+	}{
+		{name: "free attempts", failures: 1},
+		{name: "during the delay", failures: 2, wantErr: ErrTooManyAttempts},
+		{name: "after the delay", failures: 2, wait: 80 * time.Millisecond},
+		{name: "banned", failures: 3, wantErr: ErrLoginThrottled},
+		{name: "other address", wantErr: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := NewMemorySessionStore(time.Hour)
+			defer store.Close()
+			throttle := newLoginThrottle(LoginThrottleOptions{
+				FreeAttempts: 1,
+				BaseDelay:    50 * time.Millisecond,
+				BanAfter:     3,
+				Window:       time.Minute,
+			}, store, func(key string) string { return key })
+
+			for i := 0; i < test.failures; i++ {
+				throttle.fail("10.0.0.1")
+			}
+			time.Sleep(test.wait)
+			err := throttle.check("10.0.0.1")
+			if !errors.Is(err, test.wantErr) || (err == nil) != (test.wantErr == nil) {
+				t.Fatalf("check() = %v, want %v", err, test.wantErr)
+			}
+			var delayErr *LoginDelayError
+			if errors.As(err, &delayErr) && (delayErr.RetryAfter <= 0 || delayErr.RetryAfter > 50*time.Millisecond) {
+				t.Errorf("RetryAfter = %v, want the remaining delay", delayErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestSetRetryAfter(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "whole seconds", err: &LoginDelayError{RetryAfter: 2 * time.Second}, want: "2"},
+		{name: "rounded up", err: &LoginDelayError{RetryAfter: 1500 * time.Millisecond}, want: "2"},
+		{name: "ban", err: ErrLoginThrottled},
+		{name: "nil", err: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			setRetryAfter(w, test.err)
+			if got := w.Header().Get("Retry-After"); got != test.want {
+				t.Errorf("Retry-After = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package pager
+
+import "context"
+
+// withQueryTimeout bounds ctx with Options.QueryTimeout, unless the caller already set a deadline
+func withQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	queryTimeout := loadSettings().queryTimeout
+	if queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, queryTimeout)
+}
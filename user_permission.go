@@ -0,0 +1,266 @@
+package pager
+
+import (
+	"context"
+	"database/sql"
+)
+
+// grantedPermissionCondition matches the permission p granted to a user through a non-expired role,
+// a role of its groups or directly, binding grantedPermissionArgs
+const grantedPermissionCondition = `EXISTS (
+		SELECT 1 FROM rbac_user_role ur
+		JOIN rbac_role_permission rp ON ur.role_id = rp.role_id
+		WHERE rp.permission_id = p.id AND ur.user_id = ?
+		AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+	) OR EXISTS (
+		SELECT 1 FROM rbac_user_group ug
+		JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+		JOIN rbac_role_permission rp ON gr.role_id = rp.role_id
+		WHERE rp.permission_id = p.id AND ug.user_id = ?
+	) OR EXISTS (
+		SELECT 1 FROM rbac_user_permission up
+		WHERE up.permission_id = p.id AND up.user_id = ?
+	)`
+
+// grantedPermissionIDs lists the ids of the permissions granted to a user, walking user → roles, user → groups → roles
+// and the direct grants from the user side, so the lookups start from the few grants of the user instead of every permission.
+// It takes the arguments of grantedPermissionCondition
+const grantedPermissionIDs = `(
+		SELECT rp.permission_id FROM rbac_user_role ur
+		JOIN rbac_role_permission rp ON rp.role_id = ur.role_id
+		WHERE ur.user_id = ? AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+		UNION
+		SELECT rp.permission_id FROM rbac_user_group ug
+		JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+		JOIN rbac_role_permission rp ON rp.role_id = gr.role_id
+		WHERE ug.user_id = ?
+		UNION
+		SELECT up.permission_id FROM rbac_user_permission up
+		WHERE up.user_id = ?
+	) granted`
+
+// grantedPermissionArgs returns the arguments of grantedPermissionCondition, appended to args
+func grantedPermissionArgs(userID string, args ...interface{}) []interface{} {
+	return append(args, userID, clock.Now(), userID, userID)
+}
+
+func (u *User) GrantPermission(p *Permission, reason string) error {
+	return u.GrantPermissionWithContext(context.Background(), p, reason)
+}
+
+// GrantPermissionWithContext grants p to the user directly, bypassing the roles, for one-off exceptions
+func (u *User) GrantPermissionWithContext(ctx context.Context, p *Permission, reason string) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+
+	now := clock.Now()
+	insertQuery := `INSERT INTO rbac_user_permission (
+		user_id,
+		permission_id,
+		reason,
+		created_at,
+		updated_at
+	) VALUES (?,?,?,?,?) ON DUPLICATE KEY UPDATE reason = ?, updated_at = ?`
+	_, err := u.db.ExecContext(
+		ctx,
+		insertQuery,
+		u.ID,
+		p.ID,
+		reason,
+		now,
+		now,
+		reason,
+		now,
+	)
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(u.ID)
+	return nil
+}
+
+func (u *User) RevokePermission(p *Permission) error {
+	return u.RevokePermissionWithContext(context.Background(), p)
+}
+
+// RevokePermissionWithContext removes the direct grant of p, the grants through roles are kept
+func (u *User) RevokePermissionWithContext(ctx context.Context, p *Permission) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+	if p.ID == "" {
+		return ErrInvalidPermissionID
+	}
+
+	deleteQuery := `DELETE FROM rbac_user_permission WHERE user_id = ? AND permission_id = ?`
+	_, err := u.db.ExecContext(ctx, deleteQuery, u.ID, p.ID)
+	if err != nil {
+		return err
+	}
+	invalidateUserPermissions(u.ID)
+	return nil
+}
+
+func (u *User) GetDirectPermissions() ([]Permission, error) {
+	return u.GetDirectPermissionsWithContext(context.Background())
+}
+
+// GetDirectPermissionsWithContext returns the permissions granted to the user outside of the roles
+func (u *User) GetDirectPermissionsWithContext(ctx context.Context) ([]Permission, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	getQuery := `SELECT ` + permissionColumns("p") + `
+	FROM rbac_user_permission up
+	JOIN rbac_permission p ON p.id = up.permission_id
+	WHERE up.user_id = ?
+	ORDER BY p.name, p.id`
+	result, err := u.db.QueryContext(ctx, getQuery, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	permissions := make([]Permission, 0)
+	for result.Next() {
+		var permission Permission
+		err = result.Scan(permission.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, result.Err()
+}
+
+func (u *User) GetEffectivePermissions() ([]Permission, error) {
+	return u.GetEffectivePermissionsWithContext(context.Background())
+}
+
+// GetEffectivePermissionsWithContext returns the permissions granted to the user through the roles, the groups or directly,
+// each permission once and ordered by name
+func (u *User) GetEffectivePermissionsWithContext(ctx context.Context) ([]Permission, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	getQuery := `SELECT ` + permissionColumns("p") + `
+	FROM rbac_permission p
+	WHERE ` + grantedPermissionCondition + `
+	ORDER BY p.name, p.id`
+	result, err := u.db.QueryContext(ctx, getQuery, grantedPermissionArgs(u.ID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	permissions := make([]Permission, 0)
+	for result.Next() {
+		var permission Permission
+		err = result.Scan(permission.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, result.Err()
+}
+
+// PermissionGrant is an effective permission of a user with what grants it
+type PermissionGrant struct {
+	Permission
+	// Roles are the names of the roles granting the permission, held directly or through a group
+	Roles []string `json:"roles,omitempty"`
+	// Direct is set when the permission is also granted to the user directly
+	Direct bool `json:"direct"`
+}
+
+func (u *User) GetPermissionGrants() ([]PermissionGrant, error) {
+	return u.GetPermissionGrantsWithContext(context.Background())
+}
+
+// GetPermissionGrantsWithContext is GetEffectivePermissionsWithContext with the roles granting each permission,
+// the roles are ordered by name
+func (u *User) GetPermissionGrantsWithContext(ctx context.Context) ([]PermissionGrant, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return nil, ErrInvalidUserID
+	}
+
+	getQuery := `SELECT ` + permissionColumns("p") + `, granted.role_name
+	FROM (
+		SELECT rp.permission_id, r.name AS role_name FROM rbac_user_role ur
+		JOIN rbac_role r ON r.id = ur.role_id
+		JOIN rbac_role_permission rp ON rp.role_id = ur.role_id
+		WHERE ur.user_id = ? AND (ur.expires_at IS NULL OR ur.expires_at > ?)
+		UNION
+		SELECT rp.permission_id, r.name FROM rbac_user_group ug
+		JOIN rbac_group_role gr ON gr.group_id = ug.group_id
+		JOIN rbac_role r ON r.id = gr.role_id
+		JOIN rbac_role_permission rp ON rp.role_id = gr.role_id
+		WHERE ug.user_id = ?
+		UNION
+		SELECT up.permission_id, NULL FROM rbac_user_permission up
+		WHERE up.user_id = ?
+	) granted
+	JOIN rbac_permission p ON p.id = granted.permission_id
+	ORDER BY p.name, p.id, granted.role_name`
+	result, err := u.db.QueryContext(ctx, getQuery, grantedPermissionArgs(u.ID)...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	grants := make([]PermissionGrant, 0)
+	for result.Next() {
+		var permission Permission
+		var roleName sql.NullString
+		if err = result.Scan(append(permission.scanFields(), &roleName)...); err != nil {
+			return nil, err
+		}
+		if len(grants) == 0 || grants[len(grants)-1].ID != permission.ID {
+			grants = append(grants, PermissionGrant{Permission: permission})
+		}
+		grant := &grants[len(grants)-1]
+		if roleName.Valid {
+			grant.Roles = append(grant.Roles, roleName.String)
+		} else {
+			grant.Direct = true
+		}
+	}
+	return grants, result.Err()
+}
@@ -0,0 +1,86 @@
+package pager
+
+import (
+	"context"
+	"strings"
+)
+
+// ProfileChanges lists the fields to update, a nil field is kept
+type ProfileChanges struct {
+	Email    *string
+	Username *string
+	// Reverify deactivates the user when the email changes, until Auth.ConfirmEmail consumes
+	// the token sent by Auth.SendVerification
+	Reverify bool
+}
+
+func (u *User) UpdateProfile(changes ProfileChanges) error {
+	return u.UpdateProfileWithContext(context.Background(), changes)
+}
+
+// UpdateProfileWithContext changes the email and/or username, rejecting the ones taken by another user with ErrUserExists
+func (u *User) UpdateProfileWithContext(ctx context.Context, changes ProfileChanges) error {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if u.db == nil {
+		u.db = dbConnection
+	}
+	if u.ID == "" {
+		return ErrInvalidUserID
+	}
+
+	updated := *u
+	if changes.Email != nil {
+		updated.Email = strings.TrimSpace(*changes.Email)
+	}
+	if changes.Username != nil {
+		username, err := NormalizeUsername(*changes.Username)
+		// keep the usernames created before the policy when they are left unchanged
+		if err != nil && strings.TrimSpace(*changes.Username) != u.Username {
+			return err
+		}
+		if err == nil {
+			updated.Username = username
+		}
+	}
+	emailChanged := !strings.EqualFold(updated.Email, u.Email)
+	if changes.Reverify && emailChanged {
+		updated.Active = false
+	}
+	if err := runUserHooks(ctx, BeforeSave, &updated); err != nil {
+		return err
+	}
+	if err := updated.Validate(); err != nil {
+		return err
+	}
+
+	var count int64
+	countQuery := `SELECT COUNT(1) FROM rbac_user WHERE (email = ? OR username = ?) AND id <> ?`
+	err := queryRow(ctx, u.db, countQuery, updated.Email, updated.Username, u.ID).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUserExists
+	}
+
+	updated.UpdatedAt = clock.Now()
+	updateQuery := `UPDATE rbac_user SET email = ?, username = ?, active = IF(merged_into IS NULL, ?, 0), updated_at = ? WHERE id = ?`
+	_, err = u.db.ExecContext(
+		ctx,
+		updateQuery,
+		updated.Email,
+		updated.Username,
+		updated.Active,
+		updated.UpdatedAt,
+		u.ID,
+	)
+	if err != nil {
+		return err
+	}
+
+	*u = updated
+	invalidateUserPermissions(u.ID)
+	return runUserHooks(ctx, AfterSave, u)
+}
@@ -0,0 +1,148 @@
+package pager
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrNotServiceAccount = errors.New("tokens can only be issued to service accounts")
+
+type UserType string
+
+// Constants for user types
+const (
+	UserTypeHuman UserType = "human"
+	// UserTypeService accounts can't sign in with a password, they use tokens issued by Auth.IssueServiceToken.
+	// Policies meant for people, such as password expiry or MFA, should skip them
+	UserTypeService UserType = "service"
+)
+
+// IsService reports whether u is a service account
+func (u *User) IsService() bool {
+	return u.Type == UserTypeService
+}
+
+// IsMerged reports whether u was folded into another account by Pager.MergeUsers
+func (u *User) IsMerged() bool {
+	return u.MergedInto != ""
+}
+
+// IssueServiceToken creates a session token for the service account valid for ttl,
+// scopes are stored in the "scopes" claim separated by spaces
+func (a *Auth) IssueServiceToken(ctx context.Context, user *User, scopes []string, ttl time.Duration) (string, error) {
+	if user == nil || user.ID == "" {
+		return "", ErrInvalidUserID
+	}
+	if !user.IsService() {
+		return "", ErrNotServiceAccount
+	}
+	if !user.Active {
+		return "", ErrUserNotActive
+	}
+	if ttl <= 0 {
+		ttl = time.Duration(a.expiredInSeconds) * time.Second
+	}
+
+	var claims map[string]string
+	if len(scopes) > 0 {
+		claims = map[string]string{"scopes": strings.Join(scopes, " ")}
+	}
+	token := a.tokenStrategy.GenerateToken()
+	if err := a.storeSessionFor(token, user, claims, ttl); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// UserFilter narrows the user listings, the zero value matches every user
+type UserFilter struct {
+	Type   UserType
+	Active *bool
+}
+
+func (f UserFilter) where(alias string) (string, []interface{}) {
+	var conditions []string
+	var values []interface{}
+	if f.Type != "" {
+		conditions = append(conditions, alias+".type = ?")
+		values = append(values, f.Type)
+	}
+	if f.Active != nil {
+		conditions = append(conditions, alias+".active = ?")
+		values = append(values, *f.Active)
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conditions, " AND "), values
+}
+
+func pageOffset(page, size int64) int64 {
+	if page <= 1 {
+		return 0
+	}
+	return (page - 1) * size
+}
+
+func FindUsers(ctx context.Context, filter UserFilter, page, size int64, ptx *PagerTx) ([]User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	var db DbContract
+	if ptx == nil {
+		db = dbConnection
+	} else {
+		if ptx.dbTx == nil {
+			return nil, ErrTxWithNoBegin
+		}
+		db = ptx.db
+	}
+
+	condition, values := filter.where("u")
+	getQuery := `SELECT ` + userColumns("u") + `
+	FROM rbac_user u
+	WHERE ` + condition + `
+	ORDER BY u.username
+	LIMIT ? OFFSET ?`
+	return queryUsers(ctx, db, getQuery, append(values, size, pageOffset(page, size))...)
+}
+
+// GetUsersByFilter is GetUsers narrowed by filter
+func (g *Group) GetUsersByFilter(ctx context.Context, filter UserFilter, page, size int64) ([]User, error) {
+	ctx, cancel := withQueryTimeout(ctx)
+	defer cancel()
+
+	if g.db == nil {
+		g.db = dbConnection
+	}
+	condition, values := filter.where("u")
+	getQuery := `SELECT ` + userColumns("u") + `
+	FROM rbac_user_group g
+	JOIN rbac_user u ON g.user_id = u.id
+	WHERE g.group_id = ? AND ` + condition + `
+	ORDER BY u.username
+	LIMIT ? OFFSET ?`
+	args := append([]interface{}{g.ID}, values...)
+	return queryUsers(ctx, g.db, getQuery, append(args, size, pageOffset(page, size))...)
+}
+
+func queryUsers(ctx context.Context, db DbContract, query string, args ...interface{}) ([]User, error) {
+	result, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer result.Close()
+
+	users := make([]User, 0)
+	for result.Next() {
+		var user User
+		err = result.Scan(user.scanFields()...)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, result.Err()
+}
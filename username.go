@@ -0,0 +1,159 @@
+package pager
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	ErrReservedUsername   = errors.New("username is reserved")
+	ErrConfusableUsername = errors.New("username mixes look-alike characters of different scripts")
+)
+
+// DefaultReservedUsernames is used by UsernamePolicy when Reserved is nil
+var DefaultReservedUsernames = []string{
+	"admin", "administrator", "root", "system", "support", "security",
+	"postmaster", "webmaster", "hostmaster", "abuse", "noreply",
+}
+
+var unicodeUsernamePattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N}._-]{3,100}$`)
+
+// UsernamePolicy normalizes the usernames on registration and profile update
+type UsernamePolicy struct {
+	// Unicode accepts the letters and digits of every script, only ASCII ones otherwise
+	Unicode bool
+	// CaseFold stores the usernames case-folded, so "Alice" and "alice" collide
+	CaseFold bool
+	// RejectConfusables rejects the usernames mixing scripts, e.g. a Cyrillic "а" inside a Latin name
+	RejectConfusables bool
+	// Reserved names are matched ignoring case, '.', '_', '-' and look-alike characters ("r00t", "аdmin")
+	Reserved []string
+}
+
+// newUsernamePolicy copies policy with the skeletons of its reserved names
+func newUsernamePolicy(policy *UsernamePolicy) *UsernamePolicy {
+	if policy == nil {
+		return nil
+	}
+	copied := *policy
+	if copied.Reserved == nil {
+		copied.Reserved = DefaultReservedUsernames
+	}
+	reserved := make([]string, 0, len(copied.Reserved))
+	for _, name := range copied.Reserved {
+		reserved = append(reserved, usernameSkeleton(name))
+	}
+	copied.Reserved = reserved
+	return &copied
+}
+
+func currentUsernamePolicy() *UsernamePolicy {
+	return loadSettings().usernamePolicy
+}
+
+func validUsername(username string) bool {
+	if policy := currentUsernamePolicy(); policy != nil && policy.Unicode {
+		return unicodeUsernamePattern.MatchString(username)
+	}
+	return usernamePattern.MatchString(username)
+}
+
+// NormalizeUsername applies Options.UsernamePolicy, returning ErrReservedUsername or ErrConfusableUsername
+// for the rejected names. The username is converted to NFKC first, so the compatibility characters ("ａｄｍｉｎ",
+// "ﬁ") can't get around the checks. Without a policy the username is only trimmed
+func NormalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	policy := currentUsernamePolicy()
+	if policy == nil {
+		return username, nil
+	}
+	username = norm.NFKC.String(username)
+	if policy.CaseFold {
+		username = foldCase(username)
+	}
+	if policy.RejectConfusables && mixedScripts(username) {
+		return "", ErrConfusableUsername
+	}
+	skeleton := usernameSkeleton(username)
+	for _, reserved := range policy.Reserved {
+		if skeleton == reserved {
+			return "", ErrReservedUsername
+		}
+	}
+	return username, nil
+}
+
+// mixedScripts reports whether the letters of s belong to more than one script,
+// Han, Hiragana, Katakana and Hangul count as one since they are legitimately mixed
+func mixedScripts(s string) bool {
+	seen := ""
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		script := scriptOf(r)
+		if script == "" {
+			continue
+		}
+		if seen == "" {
+			seen = script
+		} else if seen != script {
+			return true
+		}
+	}
+	return false
+}
+
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if name == "Common" || name == "Inherited" || !unicode.Is(table, r) {
+			continue
+		}
+		switch name {
+		case "Han", "Hiragana", "Katakana", "Hangul", "Bopomofo":
+			return "CJK"
+		}
+		return name
+	}
+	return ""
+}
+
+// confusables maps the common look-alikes of the Latin letters, a subset of the Unicode TR39 data
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'l', 'ј': 'j', 'к': 'k', 'м': 'm', 'н': 'h',
+	'о': 'o', 'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'l', 'κ': 'k', 'ν': 'v', 'ο': 'o', 'ρ': 'p', 'τ': 't',
+	'υ': 'u', 'χ': 'x',
+	// Latin and digits
+	'0': 'o', '1': 'l', 'i': 'l', 'ı': 'l', '5': 's', 'ſ': 'f',
+}
+
+// foldCase applies the Unicode case folding, "Straße" and "STRASSE" fold to the same string
+func foldCase(s string) string {
+	// a Caser keeps state, it isn't shared between goroutines
+	return cases.Fold().String(s)
+}
+
+// usernameSkeleton converts to NFKC, folds the case, drops the separators and maps the look-alike characters,
+// two usernames with the same skeleton are visually confusable
+func usernameSkeleton(username string) string {
+	var b strings.Builder
+	for _, r := range foldCase(norm.NFKC.String(username)) {
+		switch r {
+		case '.', '_', '-':
+			continue
+		}
+		if latin, ok := confusables[r]; ok {
+			r = latin
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,40 @@
+package pager
+
+import "testing"
+
+func TestNormalizeUsername(t *testing.T) {
+	previous := loadSettings()
+	defer installSettings(previous)
+	updateSettings(func(s *settings) {
+		s.usernamePolicy = newUsernamePolicy(&UsernamePolicy{Unicode: true, CaseFold: true, RejectConfusables: true})
+	})
+
+	tests := []struct {
+		name     string
+		username string
+		want     string
+		wantErr  error
+	}{
+		{name: "lower-cased", username: "  Alice ", want: "alice"},
+		{name: "case folding", username: "STRAẞE", want: "strasse"},
+		{name: "fullwidth letters", username: "Ｊｏｈｎ", want: "john"},
+		{name: "ligature", username: "ﬁona", want: "fiona"},
+		{name: "reserved", username: "Admin", wantErr: ErrReservedUsername},
+		{name: "reserved look-alike", username: "r00t", wantErr: ErrReservedUsername},
+		{name: "reserved fullwidth", username: "ａｄｍｉｎ", wantErr: ErrReservedUsername},
+		{name: "reserved with separators", username: "web.master", wantErr: ErrReservedUsername},
+		{name: "mixed scripts", username: "pаypal", wantErr: ErrConfusableUsername},
+		{name: "single script", username: "Дмитрий", want: "дмитрий"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NormalizeUsername(test.username)
+			if err != test.wantErr {
+				t.Fatalf("NormalizeUsername(%q) = %v, want %v", test.username, err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("NormalizeUsername(%q) = %q, want %q", test.username, got, test.want)
+			}
+		})
+	}
+}
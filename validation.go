@@ -0,0 +1,101 @@
+package pager
+
+import (
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+
+	"github.com/dhanarJkusuma/pager/v2/matcher"
+)
+
+var (
+	usernamePattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{3,100}$`)
+	namePattern     = regexp.MustCompile(`^[a-zA-Z0-9._:-]{1,40}$`)
+	routePattern    = regexp.MustCompile(`^/[^\s]{0,99}$`)
+)
+
+var validMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+type ValidationError struct {
+	Field   string `json:"field" xml:"field,attr"`
+	Message string `json:"message" xml:",chardata"`
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// ValidationErrors collects every invalid field of an entity, so callers can report them all at once
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Error())
+	}
+	return "validation failed: " + strings.Join(messages, ", ")
+}
+
+func (e ValidationErrors) add(field, message string) ValidationErrors {
+	return append(e, &ValidationError{Field: field, Message: message})
+}
+
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (u *User) Validate() error {
+	var errs ValidationErrors
+	address, err := mail.ParseAddress(u.Email)
+	if err != nil || address.Address != u.Email || len(u.Email) > 100 {
+		errs = errs.add("email", "must be a valid email address")
+	}
+	if !validUsername(u.Username) {
+		errs = errs.add("username", "must be 3-100 characters of letters, digits, '.', '_' or '-'")
+	}
+	if u.Password == "" && !u.IsService() {
+		errs = errs.add("password", "must not be empty")
+	}
+	if u.Type != "" && u.Type != UserTypeHuman && u.Type != UserTypeService {
+		errs = errs.add("type", "must be human or service")
+	}
+	return errs.err()
+}
+
+func (r *Role) Validate() error {
+	var errs ValidationErrors
+	if !namePattern.MatchString(r.Name) {
+		errs = errs.add("name", "must be 1-40 characters of letters, digits, '.', '_', ':' or '-'")
+	}
+	return errs.err()
+}
+
+func (p *Permission) Validate() error {
+	var errs ValidationErrors
+	if !namePattern.MatchString(p.Name) {
+		errs = errs.add("name", "must be 1-40 characters of letters, digits, '.', '_', ':' or '-'")
+	}
+	if !validMethods[p.Method] {
+		errs = errs.add("method", "must be an uppercase HTTP method")
+	}
+	if !routePattern.MatchString(p.Route) {
+		errs = errs.add("route", "must start with '/', contain no whitespace and be at most 100 characters")
+	} else if _, err := matcher.Compile(p.Route); err != nil {
+		errs = errs.add("route", "has an invalid parameter constraint")
+	}
+	return errs.err()
+}
@@ -0,0 +1,99 @@
+package pager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// Constants for reporting view names
+const (
+	UserEffectivePermissionView = "v_rbac_user_effective_permission"
+	UserActiveRoleView          = "v_rbac_user_active_role"
+)
+
+// views are read-only projections of the effective access, meant for reporting databases and BI tools.
+// Expired role grants are filtered out at query time, grant_source tells the role grants from the direct ones
+var views = map[string]string{
+	UserEffectivePermissionView: `SELECT DISTINCT
+		u.id AS user_id,
+		u.username AS username,
+		u.email AS email,
+		'role' AS grant_source,
+		r.id AS role_id,
+		r.name AS role_name,
+		p.id AS permission_id,
+		p.name AS permission_name,
+		p.method AS method,
+		p.route AS route,
+		ur.expires_at AS expires_at
+	FROM rbac_user u
+	JOIN rbac_user_role ur ON ur.user_id = u.id
+	JOIN rbac_role r ON r.id = ur.role_id
+	JOIN rbac_role_permission rp ON rp.role_id = r.id
+	JOIN rbac_permission p ON p.id = rp.permission_id
+	WHERE u.active = 1
+	AND (ur.expires_at IS NULL OR ur.expires_at > CURRENT_TIMESTAMP)
+	UNION ALL
+	SELECT
+		u.id,
+		u.username,
+		u.email,
+		'direct',
+		NULL,
+		NULL,
+		p.id,
+		p.name,
+		p.method,
+		p.route,
+		NULL
+	FROM rbac_user u
+	JOIN rbac_user_permission up ON up.user_id = u.id
+	JOIN rbac_permission p ON p.id = up.permission_id
+	WHERE u.active = 1`,
+	UserActiveRoleView: `SELECT
+		u.id AS user_id,
+		u.username AS username,
+		r.id AS role_id,
+		r.name AS role_name,
+		ur.expires_at AS expires_at
+	FROM rbac_user u
+	JOIN rbac_user_role ur ON ur.user_id = u.id
+	JOIN rbac_role r ON r.id = ur.role_id
+	WHERE u.active = 1
+	AND (ur.expires_at IS NULL OR ur.expires_at > CURRENT_TIMESTAMP)`,
+}
+
+// RefreshViews (re)creates the reporting views, it's safe to call after every deploy
+func (m *Migration) RefreshViews() error {
+	for name, definition := range views {
+		_, err := dbConnection.Exec(fmt.Sprintf("CREATE OR REPLACE VIEW `%s` AS %s", name, definition))
+		if err != nil {
+			log.Println(err)
+			return errors.New(fmt.Sprintf(ErrMigration, "failed to create view "+name))
+		}
+	}
+	return nil
+}
+
+// ValidateViews checks that every reporting view exists and still compiles against the current tables
+func (m *Migration) ValidateViews() error {
+	for name := range views {
+		rows, err := dbConnection.Query(fmt.Sprintf("SELECT * FROM `%s` LIMIT 0", name))
+		if err != nil {
+			log.Println(err)
+			return errors.New(fmt.Sprintf(ErrMigration, "invalid view "+name))
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+func (m *Migration) dropViews() {
+	for name := range views {
+		_, err := dbConnection.Exec(fmt.Sprintf("DROP VIEW IF EXISTS `%s`", name))
+		if err != nil {
+			log.Println(err)
+		}
+	}
+}